@@ -13,6 +13,7 @@ package tar
 import (
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path"
@@ -165,6 +166,23 @@ type Header struct {
 	Devmajor int64 // Major device number (valid for TypeChar or TypeBlock)
 	Devminor int64 // Minor device number (valid for TypeChar or TypeBlock)
 
+	// SparseHoles represents a sequence of holes in a sparse file.
+	//
+	// A file is sparse if len(SparseHoles) > 0.
+	// If a file is sparse, Size is its logical size and the holes read
+	// back as NUL bytes, but are not stored in the archive.
+	// The holes must be sorted in ascending order, must not overlap,
+	// and must lie within Size. Writer.WriteHeader encodes sparse files
+	// in the GNU PAX 1.0 sparse format and so requires Format to be
+	// unspecified or FormatPAX.
+	//
+	// Writer.WriteHeader may round the holes inward to block boundaries;
+	// the bytes that are thereby stored must still be written as NULs.
+	// SparseHoles can be populated from an *os.File with DetectSparseHoles.
+	// Reader.Next populates it for sparse files in the archive; the last
+	// hole it reports always ends at Size and may be empty.
+	SparseHoles []SparseEntry
+
 	// Xattrs stores extended attributes as PAX records under the
 	// "SCHILY.xattr." namespace.
 	//
@@ -202,10 +220,10 @@ type Header struct {
 	Format Format
 }
 
-// sparseEntry represents a Length-sized fragment at Offset in the file.
-type sparseEntry struct{ Offset, Length int64 }
+// SparseEntry represents a Length-sized fragment at Offset in the file.
+type SparseEntry struct{ Offset, Length int64 }
 
-func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
+func (s SparseEntry) endOffset() int64 { return s.Offset + s.Length }
 
 // A sparse file can be represented as either a sparseDatas or a sparseHoles.
 // As long as the total size is known, they are equivalent and one can be
@@ -224,11 +242,11 @@ func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
 //	var compactFile = "abcdefgh"
 //
 // And the sparse map has the following entries:
-//	var spd sparseDatas = []sparseEntry{
+//	var spd sparseDatas = []SparseEntry{
 //		{Offset: 2,  Length: 5},  // Data fragment for 2..6
 //		{Offset: 18, Length: 3},  // Data fragment for 18..20
 //	}
-//	var sph sparseHoles = []sparseEntry{
+//	var sph sparseHoles = []SparseEntry{
 //		{Offset: 0,  Length: 2},  // Hole fragment for 0..1
 //		{Offset: 7,  Length: 11}, // Hole fragment for 7..17
 //		{Offset: 21, Length: 4},  // Hole fragment for 21..24
@@ -237,19 +255,19 @@ func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
 // Then the content of the resulting sparse file with a Header.Size of 25 is:
 //	var sparseFile = "\x00"*2 + "abcde" + "\x00"*11 + "fgh" + "\x00"*4
 type (
-	sparseDatas []sparseEntry
-	sparseHoles []sparseEntry
+	sparseDatas []SparseEntry
+	sparseHoles []SparseEntry
 )
 
 // validateSparseEntries reports whether sp is a valid sparse map.
 // It does not matter whether sp represents data fragments or hole fragments.
-func validateSparseEntries(sp []sparseEntry, size int64) bool {
+func validateSparseEntries(sp []SparseEntry, size int64) bool {
 	// Validate all sparse entries. These are the same checks as performed by
 	// the BSD tar utility.
 	if size < 0 {
 		return false
 	}
-	var pre sparseEntry
+	var pre SparseEntry
 	for _, cur := range sp {
 		switch {
 		case cur.Offset < 0 || cur.Length < 0:
@@ -273,7 +291,7 @@ func validateSparseEntries(sp []sparseEntry, size int64) bool {
 // Even though the Go tar Reader and the BSD tar utility can handle entries
 // with arbitrary offsets and lengths, the GNU tar utility can only handle
 // offsets and lengths that are multiples of blockSize.
-func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
+func alignSparseEntries(src []SparseEntry, size int64) []SparseEntry {
 	dst := src[:0]
 	for _, s := range src {
 		pos, end := s.Offset, s.endOffset()
@@ -282,7 +300,7 @@ func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
 			end -= blockPadding(-end) // Round-down to nearest blockSize
 		}
 		if pos < end {
-			dst = append(dst, sparseEntry{Offset: pos, Length: end - pos})
+			dst = append(dst, SparseEntry{Offset: pos, Length: end - pos})
 		}
 	}
 	return dst
@@ -296,9 +314,9 @@ func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
 //	* adjacent fragments are coalesced together
 //	* only the last fragment may be empty
 //	* the endOffset of the last fragment is the total size
-func invertSparseEntries(src []sparseEntry, size int64) []sparseEntry {
+func invertSparseEntries(src []SparseEntry, size int64) []SparseEntry {
 	dst := src[:0]
-	var pre sparseEntry
+	var pre SparseEntry
 	for _, cur := range src {
 		if cur.Length == 0 {
 			continue // Skip empty fragments
@@ -480,28 +498,23 @@ func (h Header) allowedFormats() (format Format, paxHdrs map[string]string, err
 		}
 	}
 
-	// TODO(dsnet): Re-enable this when adding sparse support.
-	// See https://golang.org/issue/22735
-	/*
-		// Check sparse files.
-		if len(h.SparseHoles) > 0 || h.Typeflag == TypeGNUSparse {
-			if isHeaderOnlyType(h.Typeflag) {
-				return FormatUnknown, nil, headerError{"header-only type cannot be sparse"}
-			}
-			if !validateSparseEntries(h.SparseHoles, h.Size) {
-				return FormatUnknown, nil, headerError{"invalid sparse holes"}
-			}
-			if h.Typeflag == TypeGNUSparse {
-				whyOnlyGNU = "only GNU supports TypeGNUSparse"
-				format.mayOnlyBe(FormatGNU)
-			} else {
-				whyNoGNU = "GNU supports sparse files only with TypeGNUSparse"
-				format.mustNotBe(FormatGNU)
-			}
-			whyNoUSTAR = "USTAR does not support sparse files"
-			format.mustNotBe(FormatUSTAR)
+	// Check sparse files.
+	if len(h.SparseHoles) > 0 {
+		if isHeaderOnlyType(h.Typeflag) {
+			return FormatUnknown, nil, headerError{"header-only type cannot be sparse"}
+		}
+		if !validateSparseEntries(h.SparseHoles, h.Size) {
+			return FormatUnknown, nil, headerError{"invalid sparse holes"}
 		}
-	*/
+		if h.Typeflag == TypeGNUSparse {
+			whyNoPAX = "PAX encodes sparse files as TypeReg, not TypeGNUSparse"
+			format.mustNotBe(FormatPAX)
+		}
+		whyNoGNU = "GNU does not support writing sparse files"
+		format.mustNotBe(FormatGNU)
+		whyNoUSTAR = "USTAR does not support sparse files"
+		format.mustNotBe(FormatUSTAR)
+	}
 
 	// Check desired format.
 	if wantFormat := h.Format; wantFormat != FormatUnknown {
@@ -601,6 +614,48 @@ func (fi headerFileInfo) Mode() (mode os.FileMode) {
 // sysStat, if non-nil, populates h from system-dependent fields of fi.
 var sysStat func(fi os.FileInfo, h *Header) error
 
+// sysSparseDetect, if non-nil, reports the holes in f.
+var sysSparseDetect func(f *os.File) (sparseHoles, error)
+
+// DetectSparseHoles searches for holes within f to populate SparseHoles
+// on supported operating systems and file systems.
+// On other systems, or if f is not a regular file, SparseHoles is set to nil.
+// If f is a regular file, Size is set to its size; otherwise Size is unchanged.
+// The file offset of f is reset to zero.
+//
+// When packing a sparse file, DetectSparseHoles should be called prior to
+// serializing the header to the archive with Writer.WriteHeader,
+// and the content should then be written with Writer.CopySparse,
+// which skips over the holes rather than reading them.
+func (h *Header) DetectSparseHoles(f *os.File) (err error) {
+	defer func() {
+		if _, serr := f.Seek(0, io.SeekStart); err == nil {
+			err = serr
+		}
+	}()
+
+	h.SparseHoles = nil
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	h.Size = fi.Size()
+	if sysSparseDetect == nil {
+		return nil
+	}
+	sph, err := sysSparseDetect(f)
+	if err != nil {
+		return err
+	}
+	if len(sph) > 0 && validateSparseEntries(sph, h.Size) {
+		h.SparseHoles = sph
+	}
+	return nil
+}
+
 const (
 	// Mode constants from the USTAR spec:
 	// See http://pubs.opengroup.org/onlinepubs/9699919799/utilities/pax.html#tag_20_92_13_06
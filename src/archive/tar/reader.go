@@ -195,6 +195,7 @@ func (tr *Reader) handleSparseFile(hdr *Header, rawHdr *block) error {
 			return ErrHeader
 		}
 		sph := invertSparseEntries(spd, hdr.Size)
+		hdr.SparseHoles = append([]SparseEntry{}, sph...) // Copy sparse map
 		tr.curr = &sparseFileReader{tr.curr, sph, 0}
 	}
 	return err
@@ -489,7 +490,7 @@ func (tr *Reader) readOldGNUSparseMap(hdr *Header, blk *block) (sparseDatas, err
 			if p.err != nil {
 				return nil, p.err
 			}
-			spd = append(spd, sparseEntry{Offset: offset, Length: length})
+			spd = append(spd, SparseEntry{Offset: offset, Length: length})
 		}
 
 		if s.IsExtended()[0] > 0 {
@@ -569,7 +570,7 @@ func readGNUSparseMap1x0(r io.Reader) (sparseDatas, error) {
 		if err1 != nil || err2 != nil {
 			return nil, ErrHeader
 		}
-		spd = append(spd, sparseEntry{Offset: offset, Length: length})
+		spd = append(spd, SparseEntry{Offset: offset, Length: length})
 	}
 	return spd, nil
 }
@@ -603,7 +604,7 @@ func readGNUSparseMap0x1(paxHdrs map[string]string) (sparseDatas, error) {
 		if err1 != nil || err2 != nil {
 			return nil, ErrHeader
 		}
-		spd = append(spd, sparseEntry{Offset: offset, Length: length})
+		spd = append(spd, SparseEntry{Offset: offset, Length: length})
 		sparseMap = sparseMap[2:]
 	}
 	return spd, nil
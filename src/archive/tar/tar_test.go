@@ -98,94 +98,94 @@ func (f *testFile) Seek(pos int64, whence int) (int64, error) {
 	return f.pos, nil
 }
 
-func equalSparseEntries(x, y []sparseEntry) bool {
+func equalSparseEntries(x, y []SparseEntry) bool {
 	return (len(x) == 0 && len(y) == 0) || reflect.DeepEqual(x, y)
 }
 
 func TestSparseEntries(t *testing.T) {
 	vectors := []struct {
-		in   []sparseEntry
+		in   []SparseEntry
 		size int64
 
 		wantValid    bool          // Result of validateSparseEntries
-		wantAligned  []sparseEntry // Result of alignSparseEntries
-		wantInverted []sparseEntry // Result of invertSparseEntries
+		wantAligned  []SparseEntry // Result of alignSparseEntries
+		wantInverted []SparseEntry // Result of invertSparseEntries
 	}{{
-		in: []sparseEntry{}, size: 0,
+		in: []SparseEntry{}, size: 0,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 0}},
+		wantInverted: []SparseEntry{{0, 0}},
 	}, {
-		in: []sparseEntry{}, size: 5000,
+		in: []SparseEntry{}, size: 5000,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{0, 5000}},
 	}, {
-		in: []sparseEntry{{0, 5000}}, size: 5000,
+		in: []SparseEntry{{0, 5000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 5000}},
-		wantInverted: []sparseEntry{{5000, 0}},
+		wantAligned:  []SparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{5000, 0}},
 	}, {
-		in: []sparseEntry{{1000, 4000}}, size: 5000,
+		in: []SparseEntry{{1000, 4000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{1024, 3976}},
-		wantInverted: []sparseEntry{{0, 1000}, {5000, 0}},
+		wantAligned:  []SparseEntry{{1024, 3976}},
+		wantInverted: []SparseEntry{{0, 1000}, {5000, 0}},
 	}, {
-		in: []sparseEntry{{0, 3000}}, size: 5000,
+		in: []SparseEntry{{0, 3000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 2560}},
-		wantInverted: []sparseEntry{{3000, 2000}},
+		wantAligned:  []SparseEntry{{0, 2560}},
+		wantInverted: []SparseEntry{{3000, 2000}},
 	}, {
-		in: []sparseEntry{{3000, 2000}}, size: 5000,
+		in: []SparseEntry{{3000, 2000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{3072, 1928}},
-		wantInverted: []sparseEntry{{0, 3000}, {5000, 0}},
+		wantAligned:  []SparseEntry{{3072, 1928}},
+		wantInverted: []SparseEntry{{0, 3000}, {5000, 0}},
 	}, {
-		in: []sparseEntry{{2000, 2000}}, size: 5000,
+		in: []SparseEntry{{2000, 2000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{2048, 1536}},
-		wantInverted: []sparseEntry{{0, 2000}, {4000, 1000}},
+		wantAligned:  []SparseEntry{{2048, 1536}},
+		wantInverted: []SparseEntry{{0, 2000}, {4000, 1000}},
 	}, {
-		in: []sparseEntry{{0, 2000}, {8000, 2000}}, size: 10000,
+		in: []SparseEntry{{0, 2000}, {8000, 2000}}, size: 10000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 1536}, {8192, 1808}},
-		wantInverted: []sparseEntry{{2000, 6000}, {10000, 0}},
+		wantAligned:  []SparseEntry{{0, 1536}, {8192, 1808}},
+		wantInverted: []SparseEntry{{2000, 6000}, {10000, 0}},
 	}, {
-		in: []sparseEntry{{0, 2000}, {2000, 2000}, {4000, 0}, {4000, 3000}, {7000, 1000}, {8000, 0}, {8000, 2000}}, size: 10000,
+		in: []SparseEntry{{0, 2000}, {2000, 2000}, {4000, 0}, {4000, 3000}, {7000, 1000}, {8000, 0}, {8000, 2000}}, size: 10000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 1536}, {2048, 1536}, {4096, 2560}, {7168, 512}, {8192, 1808}},
-		wantInverted: []sparseEntry{{10000, 0}},
+		wantAligned:  []SparseEntry{{0, 1536}, {2048, 1536}, {4096, 2560}, {7168, 512}, {8192, 1808}},
+		wantInverted: []SparseEntry{{10000, 0}},
 	}, {
-		in: []sparseEntry{{0, 0}, {1000, 0}, {2000, 0}, {3000, 0}, {4000, 0}, {5000, 0}}, size: 5000,
+		in: []SparseEntry{{0, 0}, {1000, 0}, {2000, 0}, {3000, 0}, {4000, 0}, {5000, 0}}, size: 5000,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{0, 5000}},
 	}, {
-		in: []sparseEntry{{1, 0}}, size: 0,
+		in: []SparseEntry{{1, 0}}, size: 0,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{-1, 0}}, size: 100,
+		in: []SparseEntry{{-1, 0}}, size: 100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{0, -1}}, size: 100,
+		in: []SparseEntry{{0, -1}}, size: 100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{0, 0}}, size: -100,
+		in: []SparseEntry{{0, 0}}, size: -100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{math.MaxInt64, 3}, {6, -5}}, size: 35,
+		in: []SparseEntry{{math.MaxInt64, 3}, {6, -5}}, size: 35,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{1, 3}, {6, -5}}, size: 35,
+		in: []SparseEntry{{1, 3}, {6, -5}}, size: 35,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{math.MaxInt64, math.MaxInt64}}, size: math.MaxInt64,
+		in: []SparseEntry{{math.MaxInt64, math.MaxInt64}}, size: math.MaxInt64,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{3, 3}}, size: 5,
+		in: []SparseEntry{{3, 3}}, size: 5,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{2, 0}, {1, 0}, {0, 0}}, size: 3,
+		in: []SparseEntry{{2, 0}, {1, 0}, {0, 0}}, size: 3,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{1, 3}, {2, 2}}, size: 10,
+		in: []SparseEntry{{1, 3}, {2, 2}}, size: 10,
 		wantValid: false,
 	}}
 
@@ -197,11 +197,11 @@ func TestSparseEntries(t *testing.T) {
 		if !v.wantValid {
 			continue
 		}
-		gotAligned := alignSparseEntries(append([]sparseEntry{}, v.in...), v.size)
+		gotAligned := alignSparseEntries(append([]SparseEntry{}, v.in...), v.size)
 		if !equalSparseEntries(gotAligned, v.wantAligned) {
 			t.Errorf("test %d, alignSparseEntries():\ngot  %v\nwant %v", i, gotAligned, v.wantAligned)
 		}
-		gotInverted := invertSparseEntries(append([]sparseEntry{}, v.in...), v.size)
+		gotInverted := invertSparseEntries(append([]SparseEntry{}, v.in...), v.size)
 		if !equalSparseEntries(gotInverted, v.wantInverted) {
 			t.Errorf("test %d, inverseSparseEntries():\ngot  %v\nwant %v", i, gotInverted, v.wantInverted)
 		}
@@ -336,6 +336,167 @@ func TestRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSparseRoundTrip(t *testing.T) {
+	data := make([]byte, 20*blockSize+10)
+	copy(data[8*blockSize:], "data in the middle")
+	copy(data[len(data)-4:], "tail")
+	holes := []SparseEntry{{0, 8 * blockSize}, {12 * blockSize, 8 * blockSize}}
+
+	write := func(t *testing.T, hdr *Header, useCopySparse bool) []byte {
+		var b bytes.Buffer
+		tw := NewWriter(&b)
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tw.WriteHeader: %v", err)
+		}
+		var n int64
+		var err error
+		if useCopySparse {
+			n, err = tw.CopySparse(bytes.NewReader(data))
+		} else {
+			var nn int
+			nn, err = tw.Write(data)
+			n = int64(nn)
+		}
+		if err != nil || n != int64(len(data)) {
+			t.Fatalf("write data = (%d, %v), want (%d, nil)", n, err, len(data))
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatalf("tw.Close: %v", err)
+		}
+		return b.Bytes()
+	}
+
+	read := func(t *testing.T, b []byte) *Header {
+		tr := NewReader(bytes.NewReader(b))
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("tr.Next: %v", err)
+		}
+		got, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("data mismatch after round trip")
+		}
+		if _, err := tr.Next(); err != io.EOF {
+			t.Errorf("tr.Next() = %v, want io.EOF", err)
+		}
+		return hdr
+	}
+
+	for _, useCopySparse := range []bool{false, true} {
+		t.Run(fmt.Sprintf("CopySparse=%v", useCopySparse), func(t *testing.T) {
+			b := write(t, &Header{
+				Name:        "sparse.db",
+				Size:        int64(len(data)),
+				ModTime:     time.Unix(1500000000, 0),
+				Typeflag:    TypeReg,
+				SparseHoles: holes,
+			}, useCopySparse)
+
+			// Only the data fragments are stored in the archive.
+			if len(b) >= len(data) {
+				t.Errorf("archive size = %d, expected holes to be elided", len(b))
+			}
+
+			hdr := read(t, b)
+			if hdr.Name != "sparse.db" || hdr.Size != int64(len(data)) {
+				t.Errorf("got (Name: %q, Size: %d), want (Name: %q, Size: %d)", hdr.Name, hdr.Size, "sparse.db", len(data))
+			}
+			if hdr.Typeflag != TypeReg {
+				t.Errorf("Typeflag = %v, want %v", hdr.Typeflag, TypeReg)
+			}
+			// The Reader reports a trailing empty hole at the end of the file.
+			wantHoles := append(holes, SparseEntry{int64(len(data)), 0})
+			if !reflect.DeepEqual(hdr.SparseHoles, wantHoles) {
+				t.Errorf("SparseHoles = %v, want %v", hdr.SparseHoles, wantHoles)
+			}
+
+			// A header read back from the archive must write out
+			// as the same sparse file.
+			b2 := write(t, hdr, useCopySparse)
+			if !bytes.Equal(b2, b) {
+				t.Errorf("rewritten archive differs from the original")
+			}
+			if hdr2 := read(t, b2); !reflect.DeepEqual(hdr2, hdr) {
+				t.Errorf("rewritten header mismatch:\ngot  %+v\nwant %+v", hdr2, hdr)
+			}
+		})
+	}
+}
+
+func TestDetectSparseHoles(t *testing.T) {
+	f, err := ioutil.TempFile("", "tar-sparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	const size = 1 << 20
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("hello"), size/2); err != nil {
+		t.Fatal(err)
+	}
+
+	var hdr Header
+	if err := hdr.DetectSparseHoles(f); err != nil {
+		t.Fatalf("DetectSparseHoles: %v", err)
+	}
+	if hdr.Size != size {
+		t.Errorf("Size = %d, want %d", hdr.Size, size)
+	}
+	if !validateSparseEntries(hdr.SparseHoles, hdr.Size) {
+		t.Errorf("invalid sparse holes: %v", hdr.SparseHoles)
+	}
+	if pos, err := f.Seek(0, io.SeekCurrent); err != nil || pos != 0 {
+		t.Errorf("file offset = (%d, %v), want (0, nil)", pos, err)
+	}
+	if len(hdr.SparseHoles) == 0 {
+		// Holes are only reported on systems and file systems that
+		// support them; the remainder of the test requires them.
+		t.Skip("file system did not report any sparse holes")
+	}
+
+	// The detected holes must not overlap the written data.
+	for _, h := range hdr.SparseHoles {
+		if h.Offset <= size/2 && size/2 < h.endOffset() {
+			t.Errorf("hole %v covers written data at offset %d", h, size/2)
+		}
+	}
+
+	// Archive the file and verify the contents survive.
+	var b bytes.Buffer
+	tw := NewWriter(&b)
+	hdr.Name = "sparse"
+	hdr.Typeflag = TypeReg
+	if err := tw.WriteHeader(&hdr); err != nil {
+		t.Fatalf("tw.WriteHeader: %v", err)
+	}
+	if _, err := tw.CopySparse(f); err != nil {
+		t.Fatalf("tw.CopySparse: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tw.Close: %v", err)
+	}
+	tr := NewReader(&b)
+	if _, err := tr.Next(); err != nil {
+		t.Fatalf("tr.Next: %v", err)
+	}
+	got, err := ioutil.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	want := make([]byte, size)
+	copy(want[size/2:], "hello")
+	if !bytes.Equal(got, want) {
+		t.Errorf("data mismatch after round trip")
+	}
+}
+
 type headerRoundTripTest struct {
 	h  *Header
 	fm os.FileMode
@@ -742,6 +903,21 @@ func TestHeaderAllowedFormats(t *testing.T) {
 	}, {
 		header:  &Header{Name: "foo/", Typeflag: TypeSymlink},
 		formats: FormatUSTAR | FormatPAX | FormatGNU,
+	}, {
+		header:  &Header{Size: 1000, SparseHoles: []SparseEntry{{0, 500}}},
+		formats: FormatPAX,
+	}, {
+		header:  &Header{Size: 1000, SparseHoles: []SparseEntry{{0, 500}}, Format: FormatGNU},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Size: 1000, SparseHoles: []SparseEntry{{0, 500}}, Typeflag: TypeGNUSparse},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Size: 1000, SparseHoles: []SparseEntry{{0, 5000}}},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Typeflag: TypeDir, SparseHoles: []SparseEntry{{0, 0}}},
+		formats: FormatUnknown,
 	}}
 
 	for i, v := range vectors {
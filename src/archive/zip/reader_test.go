@@ -8,14 +8,18 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"internal/obscuretestdata"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -1070,3 +1074,100 @@ func TestIssue12449(t *testing.T) {
 		t.Errorf("Error reading the archive: %v", err)
 	}
 }
+
+func TestFS(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		want []string
+	}{
+		{
+			"testdata/unix.zip",
+			[]string{"hello", "dir/bar", "dir/empty", "readonly"},
+		},
+		{
+			"testdata/dd.zip",
+			[]string{"filename"},
+		},
+	} {
+		t.Run(test.file, func(t *testing.T) {
+			z, err := OpenReader(test.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer z.Close()
+			if err := fstest.TestFS(z, test.want...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// zipFromNames returns an in-memory archive holding an entry for
+// each name; names ending in a slash are stored as directories.
+func zipFromNames(t *testing.T, names ...string) *Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(name, "/") {
+			io.WriteString(f, "contents of "+name)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z
+}
+
+func TestFSImpliedDirectories(t *testing.T) {
+	z := zipFromNames(t, "a/b/c", "a/d", "e/", "f")
+	if err := fstest.TestFS(z, "a", "a/b", "a/b/c", "a/d", "e", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	err := fs.WalkDir(z, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		names = append(names, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "a", "a/b", "a/b/c", "a/d", "e", "f"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("WalkDir = %q, want %q", names, want)
+	}
+
+	data, err := fs.ReadFile(z, "a/b/c")
+	if err != nil || string(data) != "contents of a/b/c" {
+		t.Errorf("ReadFile(a/b/c) = %q, %v", data, err)
+	}
+
+	for _, name := range []string{"/a", "a/", "a/../f", "missing"} {
+		_, err := z.Open(name)
+		var pe *fs.PathError
+		if !errors.As(err, &pe) {
+			t.Errorf("Open(%q) = %v, want *fs.PathError", name, err)
+		}
+	}
+}
+
+func TestFSDuplicateEntries(t *testing.T) {
+	// "a" is both a file and, implicitly, the parent directory of "a/b".
+	z := zipFromNames(t, "a", "a/b")
+	dirs, err := fs.ReadDir(z, ".")
+	if err == nil {
+		t.Errorf("ReadDir = %d entries, nil; want duplicate entry error", len(dirs))
+	}
+}
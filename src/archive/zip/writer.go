@@ -29,6 +29,10 @@ type Writer struct {
 	compressors map[uint16]Compressor
 	comment     string
 
+	// truncate, if non-nil, is called with the final size of the
+	// archive at Close. It is set by NewAppendWriter.
+	truncate func(size int64) error
+
 	// testHookCloseSizeOffset if non-nil is called with the size
 	// of offset of the central directory at Close.
 	testHookCloseSizeOffset func(size, offset uint64)
@@ -37,6 +41,7 @@ type Writer struct {
 type header struct {
 	*FileHeader
 	offset uint64
+	raw    bool
 }
 
 // NewWriter returns a new Writer writing a zip file to w.
@@ -44,6 +49,38 @@ func NewWriter(w io.Writer) *Writer {
 	return &Writer{cw: &countWriter{w: bufio.NewWriter(w)}}
 }
 
+// NewAppendWriter returns a Writer that adds files to the existing zip
+// archive r, which must have been read from the same file that w writes to.
+// The new files are written over r's central directory, and Close writes
+// a central directory that lists both the files already in r and the
+// new ones. The data of the existing files is neither read nor rewritten.
+// The archive comment of r is retained.
+//
+// NewAppendWriter seeks w to the start of r's central directory.
+// If w also has a Truncate(size int64) error method, as *os.File does,
+// Close truncates it to the end of the new archive, so that nothing
+// of the old central directory is left behind.
+func NewAppendWriter(w io.WriteSeeker, r *Reader) (*Writer, error) {
+	if _, err := w.Seek(r.dirOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	zw := NewWriter(w)
+	zw.SetOffset(r.dirOffset)
+	zw.comment = r.Comment
+	for _, f := range r.File {
+		fh := f.FileHeader
+		fh.Extra = stripZip64Extra(fh.Extra)
+		zw.dir = append(zw.dir, &header{
+			FileHeader: &fh,
+			offset:     uint64(f.headerOffset),
+		})
+	}
+	if t, ok := w.(interface{ Truncate(int64) error }); ok {
+		zw.truncate = t.Truncate
+	}
+	return zw, nil
+}
+
 // SetOffset sets the offset of the beginning of the zip data within the
 // underlying writer. It should be used when the zip data is appended to an
 // existing file, such as a binary executable.
@@ -202,7 +239,13 @@ func (w *Writer) Close() error {
 		return err
 	}
 
-	return w.cw.w.(*bufio.Writer).Flush()
+	if err := w.cw.w.(*bufio.Writer).Flush(); err != nil {
+		return err
+	}
+	if w.truncate != nil {
+		return w.truncate(w.cw.count)
+	}
+	return nil
 }
 
 // Create adds a file to the zip file using the provided name.
@@ -213,7 +256,7 @@ func (w *Writer) Close() error {
 // allowed. To create a directory instead of a file, add a trailing
 // slash to the name.
 // The file's contents must be written to the io.Writer before the next
-// call to Create, CreateHeader, or Close.
+// call to Create, CreateHeader, CreateRaw, Copy, or Close.
 func (w *Writer) Create(name string) (io.Writer, error) {
 	header := &FileHeader{
 		Name:   name,
@@ -251,16 +294,10 @@ func detectUTF8(s string) (valid, require bool) {
 //
 // This returns a Writer to which the file contents should be written.
 // The file's contents must be written to the io.Writer before the next
-// call to Create, CreateHeader, or Close.
+// call to Create, CreateHeader, CreateRaw, Copy, or Close.
 func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
-	if w.last != nil && !w.last.closed {
-		if err := w.last.close(); err != nil {
-			return nil, err
-		}
-	}
-	if len(w.dir) > 0 && w.dir[len(w.dir)-1].FileHeader == fh {
-		// See https://golang.org/issue/11144 confusion.
-		return nil, errors.New("archive/zip: invalid duplicate FileHeader")
+	if err := w.prepare(fh); err != nil {
+		return nil, err
 	}
 
 	// The ZIP format has a sad state of affairs regarding character encoding.
@@ -290,6 +327,9 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 
 	fh.CreatorVersion = fh.CreatorVersion&0xff00 | zipVersion20 // preserve compatibility byte
 	fh.ReaderVersion = zipVersion20
+	if fh.Method == Zstd {
+		fh.ReaderVersion = zipVersion63
+	}
 
 	// If Modified is set, this takes precedence over MS-DOS timestamp fields.
 	if !fh.Modified.IsZero() {
@@ -365,7 +405,7 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 		ow = fw
 	}
 	w.dir = append(w.dir, h)
-	if err := writeHeader(w.cw, fh); err != nil {
+	if err := writeHeader(w.cw, h); err != nil {
 		return nil, err
 	}
 	// If we're creating a directory, fw is nil.
@@ -373,7 +413,112 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 	return ow, nil
 }
 
-func writeHeader(w io.Writer, h *FileHeader) error {
+// prepare performs the bookkeeping operations required at the start of
+// CreateHeader and CreateRaw.
+func (w *Writer) prepare(fh *FileHeader) error {
+	if w.last != nil && !w.last.closed {
+		if err := w.last.close(); err != nil {
+			return err
+		}
+	}
+	if len(w.dir) > 0 && w.dir[len(w.dir)-1].FileHeader == fh {
+		// See https://golang.org/issue/11144 confusion.
+		return errors.New("archive/zip: invalid duplicate FileHeader")
+	}
+	return nil
+}
+
+// CreateRaw adds a file to the zip archive using the provided FileHeader and
+// returns a Writer to which the file contents should be written. The file's
+// contents must be written to the io.Writer before the next call to Create,
+// CreateHeader, CreateRaw, Copy, or Close.
+//
+// In contrast to CreateHeader, the bytes passed to Writer are not compressed:
+// they must already be compressed with fh.Method. The caller must set
+// fh.CRC32, fh.CompressedSize64 and fh.UncompressedSize64 to match the data.
+// If fh.Flags has the data descriptor bit (0x8) set, these values are
+// written in a data descriptor after the file data; otherwise they are
+// written in the local file header.
+func (w *Writer) CreateRaw(fh *FileHeader) (io.Writer, error) {
+	if err := w.prepare(fh); err != nil {
+		return nil, err
+	}
+
+	fh.Extra = stripZip64Extra(fh.Extra)
+	if fh.isZip64() {
+		fh.CompressedSize = uint32max
+		fh.UncompressedSize = uint32max
+		if fh.ReaderVersion < zipVersion45 {
+			fh.ReaderVersion = zipVersion45
+		}
+	} else {
+		fh.CompressedSize = uint32(fh.CompressedSize64)
+		fh.UncompressedSize = uint32(fh.UncompressedSize64)
+	}
+
+	h := &header{
+		FileHeader: fh,
+		offset:     uint64(w.cw.count),
+		raw:        true,
+	}
+	w.dir = append(w.dir, h)
+	if err := writeHeader(w.cw, h); err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(fh.Name, "/") {
+		w.last = nil
+		return dirWriter{}, nil
+	}
+
+	fw := &fileWriter{
+		header: h,
+		zipw:   w.cw,
+	}
+	w.last = fw
+	return fw, nil
+}
+
+// Copy copies the file f (obtained from a Reader) into w. It copies the raw
+// form directly bypassing decompression, compression, and validation.
+func (w *Writer) Copy(f *File) error {
+	r, err := f.OpenRaw()
+	if err != nil {
+		return err
+	}
+	fh := f.FileHeader
+	fw, err := w.CreateRaw(&fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+// stripZip64Extra returns extra without any zip64 extended information
+// fields. Writer adds its own zip64 fields where they are needed, so
+// keeping those copied from another archive would duplicate them.
+// The returned slice never shares memory with extra.
+func stripZip64Extra(extra []byte) []byte {
+	var out []byte
+	for b := readBuf(extra); len(b) >= 4; {
+		field := b
+		tag := b.uint16()
+		size := int(b.uint16())
+		if len(b) < size {
+			// Malformed trailing data; keep it as is.
+			out = append(out, field...)
+			break
+		}
+		b = b[size:]
+		if tag != zip64ExtraID {
+			out = append(out, field[:4+size]...)
+		}
+	}
+	return out
+}
+
+func writeHeader(w io.Writer, h *header) error {
 	const maxUint16 = 1<<16 - 1
 	if len(h.Name) > maxUint16 {
 		return errLongName
@@ -390,18 +535,39 @@ func writeHeader(w io.Writer, h *FileHeader) error {
 	b.uint16(h.Method)
 	b.uint16(h.ModifiedTime)
 	b.uint16(h.ModifiedDate)
-	b.uint32(0) // since we are writing a data descriptor crc32,
-	b.uint32(0) // compressed size,
-	b.uint32(0) // and uncompressed size should be zero
+	extra := h.Extra
+	if h.raw && h.Flags&0x8 == 0 {
+		// In raw mode the caller has supplied the checksum and sizes,
+		// and without a data descriptor they belong here.
+		b.uint32(h.CRC32)
+		b.uint32(h.CompressedSize)
+		b.uint32(h.UncompressedSize)
+		if h.isZip64() {
+			var zbuf [20]byte // 2x uint16 + 2x uint64
+			eb := writeBuf(zbuf[:])
+			eb.uint16(zip64ExtraID)
+			eb.uint16(16) // size = 2x uint64
+			eb.uint64(h.UncompressedSize64)
+			eb.uint64(h.CompressedSize64)
+			extra = append(zbuf[:], extra...)
+			if len(extra) > maxUint16 {
+				return errLongExtra
+			}
+		}
+	} else {
+		b.uint32(0) // since we are writing a data descriptor crc32,
+		b.uint32(0) // compressed size,
+		b.uint32(0) // and uncompressed size should be zero
+	}
 	b.uint16(uint16(len(h.Name)))
-	b.uint16(uint16(len(h.Extra)))
+	b.uint16(uint16(len(extra)))
 	if _, err := w.Write(buf[:]); err != nil {
 		return err
 	}
 	if _, err := io.WriteString(w, h.Name); err != nil {
 		return err
 	}
-	_, err := w.Write(h.Extra)
+	_, err := w.Write(extra)
 	return err
 }
 
@@ -446,6 +612,9 @@ func (w *fileWriter) Write(p []byte) (int, error) {
 	if w.closed {
 		return 0, errors.New("zip: write to closed file")
 	}
+	if w.raw {
+		return w.zipw.Write(p)
+	}
 	w.crc32.Write(p)
 	return w.rawCount.Write(p)
 }
@@ -455,6 +624,9 @@ func (w *fileWriter) close() error {
 		return errors.New("zip: file closed twice")
 	}
 	w.closed = true
+	if w.raw {
+		return w.writeDataDescriptor()
+	}
 	if err := w.comp.Close(); err != nil {
 		return err
 	}
@@ -474,6 +646,15 @@ func (w *fileWriter) close() error {
 		fh.UncompressedSize = uint32(fh.UncompressedSize64)
 	}
 
+	return w.writeDataDescriptor()
+}
+
+func (w *fileWriter) writeDataDescriptor() error {
+	fh := w.header.FileHeader
+	if fh.Flags&0x8 == 0 {
+		return nil
+	}
+
 	// Write data descriptor. This is more complicated than one would
 	// think, see e.g. comments in zipfile.c:putextended() and
 	// http://bugs.sun.com/bugdatabase/view_bug.do?bug_id=7073588.
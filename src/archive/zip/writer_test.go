@@ -6,8 +6,10 @@ package zip
 
 import (
 	"bytes"
+	"compress/flate"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -57,6 +59,12 @@ var writeTests = []WriteTest{
 		Method: Deflate,
 		Mode:   0755 | os.ModeSymlink,
 	},
+	{
+		Name:   "zstd",
+		Data:   []byte("Rabbits, guinea pigs, gophers, marsupial rats, and quolls, compressed."),
+		Method: Zstd,
+		Mode:   0644,
+	},
 }
 
 func TestWriter(t *testing.T) {
@@ -353,6 +361,193 @@ func TestWriterDirAttributes(t *testing.T) {
 	}
 }
 
+func TestWriterCopy(t *testing.T) {
+	largeData := make([]byte, 1<<17)
+	if _, err := rand.Read(largeData); err != nil {
+		t.Fatal("rand.Read failed:", err)
+	}
+	writeTests[1].Data = largeData
+	defer func() {
+		writeTests[1].Data = nil
+	}()
+
+	// write a source zip file
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	for _, wt := range writeTests {
+		testCreate(t, w, &wt)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read it back and copy every entry to a new zip file
+	src, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf2 := new(bytes.Buffer)
+	w2 := NewWriter(buf2)
+	for _, f := range src.File {
+		if err := w2.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read the copy back
+	dst, err := NewReader(bytes.NewReader(buf2.Bytes()), int64(buf2.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, wt := range writeTests {
+		testReadFile(t, dst.File[i], &wt)
+		if got, want := dst.File[i].CompressedSize64, src.File[i].CompressedSize64; got != want {
+			t.Errorf("%s: CompressedSize64 = %d, want %d", wt.Name, got, want)
+		}
+	}
+}
+
+func TestWriterCreateRaw(t *testing.T) {
+	data := []byte(strings.Repeat("Rabbits, guinea pigs, gophers, marsupial rats, and quolls. ", 100))
+	var comp bytes.Buffer
+	fw, err := flate.NewWriter(&comp, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, flags := range []uint16{0, 0x8} {
+		buf := new(bytes.Buffer)
+		w := NewWriter(buf)
+		fh := &FileHeader{
+			Name:               "raw.txt",
+			Method:             Deflate,
+			Flags:              flags,
+			CRC32:              crc32.ChecksumIEEE(data),
+			CompressedSize64:   uint64(comp.Len()),
+			UncompressedSize64: uint64(len(data)),
+		}
+		rw, err := w.CreateRaw(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rw.Write(comp.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testReadFile(t, r.File[0], &WriteTest{Name: "raw.txt", Data: data, Mode: 0666})
+
+		raw, err := r.File[0].OpenRaw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, comp.Bytes()) {
+			t.Errorf("flags %#x: OpenRaw returned different bytes than written", flags)
+		}
+
+		var sig [4]byte
+		binary.LittleEndian.PutUint32(sig[:], uint32(dataDescriptorSignature))
+		if has := bytes.Contains(buf.Bytes(), sig[:]); has != (flags&0x8 != 0) {
+			t.Errorf("flags %#x: data descriptor present = %v", flags, has)
+		}
+	}
+}
+
+func TestNewAppendWriter(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "TestNewAppendWriter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := NewWriter(tmp)
+	testCreate(t, w, &writeTests[0])
+	if err := w.SetComment("appendable"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := tmp.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(tmp, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err = NewAppendWriter(tmp, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wt := range writeTests[2:] {
+		testCreate(t, w, &wt)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err = tmp.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewReader(tmp, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Comment != "appendable" {
+		t.Errorf("Comment = %q, want %q", r.Comment, "appendable")
+	}
+	want := append([]WriteTest{writeTests[0]}, writeTests[2:]...)
+	if len(r.File) != len(want) {
+		t.Fatalf("got %d files, want %d", len(r.File), len(want))
+	}
+	for i, wt := range want {
+		testReadFile(t, r.File[i], &wt)
+	}
+}
+
+func TestStripZip64Extra(t *testing.T) {
+	var buf [28 + 9]byte
+	b := writeBuf(buf[:])
+	b.uint16(zip64ExtraID)
+	b.uint16(24)
+	b.uint64(1)
+	b.uint64(2)
+	b.uint64(3)
+	b.uint16(extTimeExtraID)
+	b.uint16(5)
+	b.uint8(1)
+	b.uint32(0x12345678)
+
+	got := stripZip64Extra(buf[:])
+	if want := buf[28:]; !bytes.Equal(got, want) {
+		t.Errorf("stripZip64Extra = %x, want %x", got, want)
+	}
+	if got := stripZip64Extra(nil); len(got) != 0 {
+		t.Errorf("stripZip64Extra(nil) = %x, want empty", got)
+	}
+}
+
 func testCreate(t *testing.T, w *Writer, wt *WriteTest) {
 	header := &FileHeader{
 		Name:   wt.Name,
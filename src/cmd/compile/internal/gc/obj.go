@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"sort"
 	"strconv"
 )
@@ -115,6 +116,7 @@ func dumpdata() {
 	externs := len(externdcl)
 
 	dumpglobls()
+	dumpembeds()
 	addptabs()
 	addsignats(externdcl)
 	dumpsignats()
@@ -363,23 +365,51 @@ func stringsym(pos src.XPos, s string) (data *obj.LSym) {
 	return symdata
 }
 
+// fileStringSym returns a symbol for the contents and the size of file.
+// If readonly is true, the symbol shares storage with any literal string
+// or other file with the same content and is placed in a read-only section.
+// If readonly is false, the symbol is a read-write copy separate from any other,
+// for use as the backing store of a []byte.
+// The content hash of file is copied into hash. (If hash is nil, nothing is copied.)
+func fileStringSym(pos src.XPos, file string, readonly bool, hash []byte) (*obj.LSym, int64, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	if hash != nil {
+		sum := sha256.Sum256(data)
+		copy(hash, sum[:])
+	}
+	if readonly {
+		return stringsym(pos, string(data)), int64(len(data)), nil
+	}
+	return slicedata(pos, string(data)), int64(len(data)), nil
+}
+
 var slicebytes_gen int
 
-func slicebytes(nam *Node, s string, len int) {
+// slicedata returns a new writable symbol holding s,
+// for use as the backing array of a []byte.
+func slicedata(pos src.XPos, s string) *obj.LSym {
 	slicebytes_gen++
 	symname := fmt.Sprintf(".gobytes.%d", slicebytes_gen)
 	sym := localpkg.Lookup(symname)
 	sym.Def = asTypesNode(newname(sym))
 
 	lsym := sym.Linksym()
-	off := dsname(lsym, 0, s, nam.Pos, "slice")
+	off := dsname(lsym, 0, s, pos, "slice")
 	ggloblsym(lsym, int32(off), obj.NOPTR|obj.LOCAL)
+	return lsym
+}
+
+func slicebytes(nam *Node, s string, len int) {
+	lsym := slicedata(nam.Pos, s)
 
 	if nam.Op != ONAME {
 		Fatalf("slicebytes %v", nam)
 	}
 	nsym := nam.Sym.Linksym()
-	off = int(nam.Xoffset)
+	off := int(nam.Xoffset)
 	off = dsymptr(nsym, off, lsym, 0)
 	off = duintptr(nsym, off, uint64(len))
 	duintptr(nsym, off, uint64(len))
@@ -87,6 +87,7 @@ type (
 		Type     Expr   // nil means no type
 		Values   Expr   // nil means no values
 		Group    *Group // nil means not part of a group
+		Pragma   Pragma
 		decl
 	}
 
@@ -117,6 +117,14 @@ func TestPackagesAndErrors(p *Package, cover *TestCover) (pmain, ptest, pxtest *
 		p.TestImports[i] = p1.ImportPath
 		imports = append(imports, p1)
 	}
+	var testEmbed, xtestEmbed map[string][]string
+	if len(p.TestEmbedPatterns) > 0 {
+		var err error
+		_, testEmbed, err = resolveEmbed(p.Dir, p.TestEmbedPatterns)
+		if err != nil && ptestErr == nil {
+			ptestErr = embedPackageError(&stk, err, p.Internal.Build.TestEmbedPatternPos)
+		}
+	}
 	stk.Pop()
 	stk.Push(p.ImportPath + "_test")
 	pxtestNeedsPtest := false
@@ -130,6 +138,13 @@ func TestPackagesAndErrors(p *Package, cover *TestCover) (pmain, ptest, pxtest *
 		}
 		p.XTestImports[i] = p1.ImportPath
 	}
+	if len(p.XTestEmbedPatterns) > 0 {
+		var err error
+		_, xtestEmbed, err = resolveEmbed(p.Dir, p.XTestEmbedPatterns)
+		if err != nil && pxtestErr == nil {
+			pxtestErr = embedPackageError(&stk, err, p.Internal.Build.XTestEmbedPatternPos)
+		}
+	}
 	stk.Pop()
 
 	// Test package.
@@ -169,6 +184,17 @@ func TestPackagesAndErrors(p *Package, cover *TestCover) (pmain, ptest, pxtest *
 			m[k] = append(m[k], v...)
 		}
 		ptest.Internal.Build.ImportPos = m
+		if testEmbed != nil {
+			ptest.EmbedPatterns = str.StringList(p.EmbedPatterns, p.TestEmbedPatterns)
+			ptest.EmbedFiles = str.StringList(p.EmbedFiles, p.TestEmbedFiles)
+			ptest.Internal.Embed = map[string][]string{}
+			for k, v := range p.Internal.Embed {
+				ptest.Internal.Embed[k] = v
+			}
+			for k, v := range testEmbed {
+				ptest.Internal.Embed[k] = v
+			}
+		}
 		ptest.collectDeps()
 	} else {
 		ptest = p
@@ -187,6 +213,9 @@ func TestPackagesAndErrors(p *Package, cover *TestCover) (pmain, ptest, pxtest *
 				Imports:    p.XTestImports,
 				ForTest:    p.ImportPath,
 				Error:      pxtestErr,
+
+				EmbedPatterns: p.XTestEmbedPatterns,
+				EmbedFiles:    p.XTestEmbedFiles,
 			},
 			Internal: PackageInternal{
 				LocalPrefix: p.Internal.LocalPrefix,
@@ -195,6 +224,7 @@ func TestPackagesAndErrors(p *Package, cover *TestCover) (pmain, ptest, pxtest *
 				},
 				Imports:    ximports,
 				RawImports: rawXTestImports,
+				Embed:      xtestEmbed,
 
 				Asmflags:   p.Internal.Asmflags,
 				Gcflags:    p.Internal.Gcflags,
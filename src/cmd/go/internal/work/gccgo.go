@@ -60,7 +60,7 @@ func checkGccgoBin() {
 	base.Exit()
 }
 
-func (tools gccgoToolchain) gc(b *Builder, a *Action, archive string, importcfg []byte, symabis string, asmhdr bool, gofiles []string) (ofile string, output []byte, err error) {
+func (tools gccgoToolchain) gc(b *Builder, a *Action, archive string, importcfg, embedcfg []byte, symabis string, asmhdr bool, gofiles []string) (ofile string, output []byte, err error) {
 	p := a.Package
 	objdir := a.Objdir
 	out := "_go_.o"
@@ -91,6 +91,15 @@ func (tools gccgoToolchain) gc(b *Builder, a *Action, archive string, importcfg
 			args = append(args, "-I", root)
 		}
 	}
+	if embedcfg != nil {
+		if !b.gccSupportsFlag(args[:1], "-fgo-embedcfg=/dev/null") {
+			return "", nil, fmt.Errorf("%s: gccgo does not support //go:embed", p.ImportPath)
+		}
+		if err := b.writeFile(objdir+"embedcfg", embedcfg); err != nil {
+			return "", nil, err
+		}
+		args = append(args, "-fgo-embedcfg="+objdir+"embedcfg")
+	}
 	if cfg.BuildTrimpath && b.gccSupportsFlag(args[:1], "-ffile-prefix-map=a=b") {
 		args = append(args, "-ffile-prefix-map="+base.Cwd+"=.")
 		args = append(args, "-ffile-prefix-map="+b.WorkDir+"=/tmp/go-build")
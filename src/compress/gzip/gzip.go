@@ -5,6 +5,7 @@
 package gzip
 
 import (
+	"bytes"
 	"compress/flate"
 	"errors"
 	"fmt"
@@ -36,8 +37,29 @@ type Writer struct {
 	closed      bool
 	buf         [10]byte
 	err         error
+
+	// Concurrent compression state; see SetConcurrency.
+	blockSize int
+	blocks    int
+	blk       []byte       // input not yet handed to a block
+	hist      []byte       // last window of input handed to blocks
+	pending   []*gzipBlock // blocks being compressed, in output order
+}
+
+// A gzipBlock is a portion of the input compressed on its own goroutine.
+type gzipBlock struct {
+	in   []byte
+	dict []byte
+	last bool
+	out  bytes.Buffer
+	err  error
+	done chan struct{}
 }
 
+// windowSize is the size of the DEFLATE sliding window, which bounds
+// how much preceding input is useful as a block's dictionary.
+const windowSize = 1 << 15
+
 // NewWriter returns a new Writer.
 // Writes to the returned writer are compressed and written to w.
 //
@@ -78,17 +100,45 @@ func (z *Writer) init(w io.Writer, level int) {
 		w:          w,
 		level:      level,
 		compressor: compressor,
+		blockSize:  z.blockSize,
+		blocks:     z.blocks,
 	}
 }
 
 // Reset discards the Writer z's state and makes it equivalent to the
 // result of its original state from NewWriter or NewWriterLevel, but
 // writing to w instead. This permits reusing a Writer rather than
-// allocating a new one.
+// allocating a new one. A concurrency setting made with SetConcurrency
+// is retained.
 func (z *Writer) Reset(w io.Writer) {
 	z.init(w, z.level)
 }
 
+// SetConcurrency makes z compress its input concurrently. The input is
+// split into blocks of blockSize bytes, and up to blocks of them are
+// compressed at the same time on separate goroutines. Each block uses
+// the end of the preceding input as a preset dictionary, so the output
+// is a single ordinary GZIP member, although it is usually slightly
+// larger than the output of sequential compression. Memory use grows
+// with blockSize times blocks.
+//
+// A blockSize of about 1 MB and blocks equal to runtime.GOMAXPROCS(0)
+// work well for large inputs.
+//
+// SetConcurrency must be called before the first call to Write, Flush,
+// or Close.
+func (z *Writer) SetConcurrency(blockSize, blocks int) error {
+	if blockSize <= 0 || blocks <= 0 {
+		return errors.New("gzip: invalid concurrency setting")
+	}
+	if z.wroteHeader {
+		return errors.New("gzip: SetConcurrency called after Write")
+	}
+	z.blockSize = blockSize
+	z.blocks = blocks
+	return nil
+}
+
 // writeBytes writes a length-prefixed byte slice to z.w.
 func (z *Writer) writeBytes(b []byte) error {
 	if len(b) > 0xffff {
@@ -187,16 +237,123 @@ func (z *Writer) Write(p []byte) (int, error) {
 				return 0, z.err
 			}
 		}
-		if z.compressor == nil {
+		if z.compressor == nil && z.blockSize == 0 {
 			z.compressor, _ = flate.NewWriter(z.w, z.level)
 		}
 	}
 	z.size += uint32(len(p))
 	z.digest = crc32.Update(z.digest, crc32.IEEETable, p)
+	if z.blockSize > 0 {
+		n, z.err = z.writeBlocks(p)
+		return n, z.err
+	}
 	n, z.err = z.compressor.Write(p)
 	return n, z.err
 }
 
+// writeBlocks buffers p into blocks and starts compressing
+// each block as soon as it is full.
+func (z *Writer) writeBlocks(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if z.blk == nil {
+			z.blk = make([]byte, 0, z.blockSize)
+		}
+		m := z.blockSize - len(z.blk)
+		if m > len(p) {
+			m = len(p)
+		}
+		z.blk = append(z.blk, p[:m]...)
+		p = p[m:]
+		if len(z.blk) == z.blockSize {
+			if err := z.startBlock(false); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// startBlock starts compressing the buffered input as a new block,
+// first writing out finished blocks if too many are in flight.
+func (z *Writer) startBlock(last bool) error {
+	for len(z.pending) >= z.blocks {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	b := &gzipBlock{in: z.blk, dict: z.hist, last: last, done: make(chan struct{})}
+	go b.compress(z.level)
+	z.pending = append(z.pending, b)
+
+	// The block keeps its input and dictionary, so build the
+	// next dictionary in a new slice.
+	hist := make([]byte, 0, windowSize)
+	if len(z.blk) < windowSize {
+		keep := windowSize - len(z.blk)
+		if keep > len(z.hist) {
+			keep = len(z.hist)
+		}
+		hist = append(hist, z.hist[len(z.hist)-keep:]...)
+		hist = append(hist, z.blk...)
+	} else {
+		hist = append(hist, z.blk[len(z.blk)-windowSize:]...)
+	}
+	z.hist = hist
+	z.blk = nil
+	return nil
+}
+
+// writeBlock waits for the oldest block in flight
+// and writes its compressed form to z.w.
+func (z *Writer) writeBlock() error {
+	b := z.pending[0]
+	<-b.done
+	z.pending[0] = nil
+	z.pending = z.pending[1:]
+	if b.err != nil {
+		return b.err
+	}
+	_, err := z.w.Write(b.out.Bytes())
+	return err
+}
+
+// flushBlocks compresses any buffered input and writes out all
+// blocks. If last is set, the final block ends the DEFLATE stream.
+func (z *Writer) flushBlocks(last bool) error {
+	if len(z.blk) > 0 || last {
+		if err := z.startBlock(last); err != nil {
+			return err
+		}
+	}
+	for len(z.pending) > 0 {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compress compresses the block. Blocks other than the last end with
+// a sync flush so that their output ends on a byte boundary and can
+// be concatenated with the output of the following block.
+func (b *gzipBlock) compress(level int) {
+	defer close(b.done)
+	fw, err := flate.NewWriterDict(&b.out, level, b.dict)
+	if err != nil {
+		b.err = err
+		return
+	}
+	if _, b.err = fw.Write(b.in); b.err != nil {
+		return
+	}
+	if b.last {
+		b.err = fw.Close()
+	} else {
+		b.err = fw.Flush()
+	}
+}
+
 // Flush flushes any pending compressed data to the underlying writer.
 //
 // It is useful mainly in compressed network protocols, to ensure that
@@ -218,6 +375,10 @@ func (z *Writer) Flush() error {
 			return z.err
 		}
 	}
+	if z.blockSize > 0 {
+		z.err = z.flushBlocks(false)
+		return z.err
+	}
 	z.err = z.compressor.Flush()
 	return z.err
 }
@@ -239,7 +400,11 @@ func (z *Writer) Close() error {
 			return z.err
 		}
 	}
-	z.err = z.compressor.Close()
+	if z.blockSize > 0 {
+		z.err = z.flushBlocks(true)
+	} else {
+		z.err = z.compressor.Close()
+	}
 	if z.err != nil {
 		return z.err
 	}
@@ -274,3 +274,106 @@ func TestLimitedWrite(t *testing.T) {
 		}
 	}
 }
+
+func TestWriterConcurrency(t *testing.T) {
+	data, err := ioutil.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, bytes.Repeat([]byte("Hello, Gophers! "), 10000)...)
+	for _, level := range []int{HuffmanOnly, NoCompression, BestSpeed, DefaultCompression, BestCompression} {
+		for _, blockSize := range []int{1000, 64 << 10, 1 << 20} {
+			var seq, buf bytes.Buffer
+			w, _ := NewWriterLevel(&seq, level)
+			w.Write(data)
+			w.Close()
+
+			w, _ = NewWriterLevel(&buf, level)
+			w.Name = "concurrent"
+			if err := w.SetConcurrency(blockSize, 4); err != nil {
+				t.Fatal(err)
+			}
+			// Write in odd sizes and flush halfway.
+			for i := 0; i < len(data); i += 7777 {
+				end := i + 7777
+				if end > len(data) {
+					end = len(data)
+				}
+				if _, err := w.Write(data[i:end]); err != nil {
+					t.Fatal(err)
+				}
+				if i < len(data)/2 && end >= len(data)/2 {
+					if err := w.Flush(); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if blockSize >= 64<<10 && level != NoCompression && buf.Len() > seq.Len()*11/10 {
+				t.Errorf("level %d, block size %d: compressed to %d bytes, want close to sequential %d bytes",
+					level, blockSize, buf.Len(), seq.Len())
+			}
+
+			r, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Multistream(false)
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("level %d, block size %d: %v", level, blockSize, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("level %d, block size %d: round trip mismatch", level, blockSize)
+			}
+			if r.Name != "concurrent" {
+				t.Errorf("Name = %q; want %q", r.Name, "concurrent")
+			}
+			if buf.Len() != 0 {
+				t.Errorf("%d bytes after the gzip member; want a single member", buf.Len())
+			}
+		}
+	}
+}
+
+func TestWriterConcurrencyEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetConcurrency(1<<20, 2)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(r); len(b) != 0 || err != nil {
+		t.Fatalf("ReadAll = %q, %v; want empty, nil", b, err)
+	}
+}
+
+func TestWriterConcurrencyReset(t *testing.T) {
+	msg := bytes.Repeat([]byte("hello world "), 1000)
+	var buf, buf2 bytes.Buffer
+	z := NewWriter(&buf)
+	z.SetConcurrency(1000, 2)
+	z.Write(msg)
+	z.Close()
+	z.Reset(&buf2)
+	if err := z.SetConcurrency(1000, 2); err != nil {
+		t.Fatalf("SetConcurrency after Reset: %v", err)
+	}
+	z.Write(msg)
+	z.Close()
+	if buf.String() != buf2.String() {
+		t.Error("output after Reset differs")
+	}
+	if err := z.SetConcurrency(1000, 2); err == nil {
+		t.Error("SetConcurrency after Write succeeded")
+	}
+	if err := NewWriter(ioutil.Discard).SetConcurrency(0, 1); err == nil {
+		t.Error("SetConcurrency with zero block size succeeded")
+	}
+}
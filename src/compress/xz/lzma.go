@@ -0,0 +1,513 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xz
+
+// A dict is the LZMA dictionary: a ring buffer holding the most recent
+// output, which also holds decoded data until it is read. The buffer
+// grows as needed up to the dictionary size, so that small inputs
+// do not pay for large dictionaries.
+type dict struct {
+	buf    []byte
+	size   int // dictionary size; buf wraps once it reaches this size
+	pos    int // write position in buf
+	total  int // bytes written since the last reset, for position states
+	full   int // bytes of history available, at most size
+	unread int // bytes written but not yet read
+}
+
+// reset prepares d for a block with the given dictionary size.
+func (d *dict) reset(size int) {
+	if len(d.buf) > size {
+		d.buf = d.buf[:size]
+	}
+	d.size = size
+	d.clear()
+}
+
+// clear discards the history. There must be no unread data.
+func (d *dict) clear() {
+	d.pos = 0
+	d.total = 0
+	d.full = 0
+	d.unread = 0
+}
+
+// wrap makes room for writing at d.pos, which is at the end of d.buf.
+func (d *dict) wrap() {
+	if len(d.buf) == d.size {
+		d.pos = 0
+		return
+	}
+	n := 2 * len(d.buf)
+	if n < 1<<16 {
+		n = 1 << 16
+	}
+	if n > d.size {
+		n = d.size
+	}
+	if n <= cap(d.buf) {
+		d.buf = d.buf[:n]
+		return
+	}
+	buf := make([]byte, n)
+	copy(buf, d.buf)
+	d.buf = buf
+}
+
+func (d *dict) advance(n int) {
+	d.pos += n
+	d.total += n
+	d.unread += n
+	d.full += n
+	if d.full > d.size {
+		d.full = d.size
+	}
+}
+
+func (d *dict) put(b byte) {
+	if d.pos == len(d.buf) {
+		d.wrap()
+	}
+	d.buf[d.pos] = b
+	d.advance(1)
+}
+
+// get returns the byte dist+1 bytes back. dist must be less than d.full.
+func (d *dict) get(dist int) byte {
+	i := d.pos - dist - 1
+	if i < 0 {
+		i += len(d.buf)
+	}
+	return d.buf[i]
+}
+
+// repeat copies n bytes starting dist+1 bytes back.
+// dist must be less than d.full.
+func (d *dict) repeat(dist, n int) {
+	for n > 0 {
+		if d.pos == len(d.buf) {
+			d.wrap()
+		}
+		src := d.pos - dist - 1
+		if src < 0 {
+			src += len(d.buf)
+		}
+		k := n
+		if m := len(d.buf) - d.pos; k > m {
+			k = m
+		}
+		if m := len(d.buf) - src; k > m {
+			k = m
+		}
+		if k > dist+1 {
+			k = dist + 1 // copy must not read what it writes
+		}
+		copy(d.buf[d.pos:d.pos+k], d.buf[src:src+k])
+		d.advance(k)
+		n -= k
+	}
+}
+
+// write appends uncompressed data.
+func (d *dict) write(p []byte) {
+	for len(p) > 0 {
+		if d.pos == len(d.buf) {
+			d.wrap()
+		}
+		k := copy(d.buf[d.pos:], p)
+		d.advance(k)
+		p = p[k:]
+	}
+}
+
+// read copies unread data into p.
+func (d *dict) read(p []byte) int {
+	n := 0
+	for d.unread > 0 && len(p) > 0 {
+		start := d.pos - d.unread
+		if start < 0 {
+			start += len(d.buf)
+		}
+		end := start + d.unread
+		if end > len(d.buf) {
+			end = len(d.buf)
+		}
+		k := copy(p, d.buf[start:end])
+		d.unread -= k
+		n += k
+		p = p[k:]
+	}
+	return n
+}
+
+// A rangeDecoder decodes bits from the range coded data of one LZMA2 chunk.
+// Reading past the end of the data yields zero bytes; the caller checks
+// that all of the data, and no more, was used.
+type rangeDecoder struct {
+	rng  uint32
+	code uint32
+	in   []byte
+	pos  int
+}
+
+func (rc *rangeDecoder) init(in []byte) bool {
+	if len(in) < 5 || in[0] != 0 {
+		return false
+	}
+	rc.rng = 0xffffffff
+	rc.code = uint32(in[1])<<24 | uint32(in[2])<<16 | uint32(in[3])<<8 | uint32(in[4])
+	rc.in = in
+	rc.pos = 5
+	return true
+}
+
+// finished reports whether the data was used up exactly,
+// leaving the range coder in its final state.
+func (rc *rangeDecoder) finished() bool {
+	return rc.pos == len(rc.in) && rc.code == 0
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng < 1<<24 {
+		rc.rng <<= 8
+		rc.code <<= 8
+		if rc.pos < len(rc.in) {
+			rc.code |= uint32(rc.in[rc.pos])
+		}
+		rc.pos++
+	}
+}
+
+const (
+	probBits  = 11
+	probInit  = 1 << (probBits - 1)
+	moveBits  = 5
+	alignBits = 4
+)
+
+type prob uint16
+
+// bit decodes a bit with probability p and updates p.
+func (rc *rangeDecoder) bit(p *prob) uint32 {
+	bound := (rc.rng >> probBits) * uint32(*p)
+	var b uint32
+	if rc.code < bound {
+		rc.rng = bound
+		*p += (1<<probBits - *p) >> moveBits
+	} else {
+		rc.rng -= bound
+		rc.code -= bound
+		*p -= *p >> moveBits
+		b = 1
+	}
+	rc.normalize()
+	return b
+}
+
+// bitTree decodes an n-bit value most significant bit first.
+// probs must have 1<<n entries.
+func (rc *rangeDecoder) bitTree(probs []prob, n uint) uint32 {
+	sym := uint32(1)
+	for i := uint(0); i < n; i++ {
+		sym = sym<<1 | rc.bit(&probs[sym])
+	}
+	return sym - 1<<n
+}
+
+// reverseBitTree decodes an n-bit value least significant bit first.
+// probs must have 1<<n - 1 entries.
+func (rc *rangeDecoder) reverseBitTree(probs []prob, n uint) uint32 {
+	sym := uint32(1)
+	var v uint32
+	for i := uint(0); i < n; i++ {
+		b := rc.bit(&probs[sym-1])
+		sym = sym<<1 | b
+		v |= b << i
+	}
+	return v
+}
+
+// direct decodes n bits with fixed probability one half.
+func (rc *rangeDecoder) direct(n uint) uint32 {
+	var v uint32
+	for ; n > 0; n-- {
+		rc.rng >>= 1
+		rc.code -= rc.rng
+		mask := 0 - rc.code>>31
+		rc.code += rc.rng & mask
+		v = v<<1 + mask + 1
+		rc.normalize()
+	}
+	return v
+}
+
+const (
+	numStates      = 12
+	numLitStates   = 7
+	posStatesMax   = 1 << 4
+	distStates     = 4
+	distSlotBits   = 6
+	distModelStart = 4
+	distModelEnd   = 14
+	fullDistances  = 1 << (distModelEnd / 2)
+	matchLenMin    = 2
+)
+
+type lenDecoder struct {
+	choice  prob
+	choice2 prob
+	low     [posStatesMax][1 << 3]prob
+	mid     [posStatesMax][1 << 3]prob
+	high    [1 << 8]prob
+}
+
+func (l *lenDecoder) reset() {
+	l.choice = probInit
+	l.choice2 = probInit
+	resetProbs(l.low[:])
+	resetProbs(l.mid[:])
+	for i := range l.high {
+		l.high[i] = probInit
+	}
+}
+
+func resetProbs(p [][1 << 3]prob) {
+	for i := range p {
+		for j := range p[i] {
+			p[i][j] = probInit
+		}
+	}
+}
+
+// decode returns the match length minus matchLenMin.
+func (l *lenDecoder) decode(rc *rangeDecoder, posState int) int {
+	if rc.bit(&l.choice) == 0 {
+		return int(rc.bitTree(l.low[posState][:], 3))
+	}
+	if rc.bit(&l.choice2) == 0 {
+		return 1<<3 + int(rc.bitTree(l.mid[posState][:], 3))
+	}
+	return 1<<4 + int(rc.bitTree(l.high[:], 8))
+}
+
+// An lzmaDecoder holds the state of the LZMA decoder, which persists
+// across LZMA2 chunks unless a chunk resets it.
+type lzmaDecoder struct {
+	rc rangeDecoder
+
+	lc, lp, pb uint
+	state      int
+	rep        [4]uint32 // most recent distances, minus one
+	pending    int       // bytes of the last match not yet copied
+
+	isMatch     [numStates][posStatesMax]prob
+	isRep       [numStates]prob
+	isRepG0     [numStates]prob
+	isRepG1     [numStates]prob
+	isRepG2     [numStates]prob
+	isRep0Long  [numStates][posStatesMax]prob
+	distSlot    [distStates][1 << distSlotBits]prob
+	distSpecial [fullDistances - distModelEnd]prob
+	distAlign   [1<<alignBits - 1]prob
+	matchLen    lenDecoder
+	repLen      lenDecoder
+	literal     []prob
+}
+
+// setProps sets the literal context, literal position and
+// position bits from an LZMA2 properties byte.
+func (d *lzmaDecoder) setProps(b byte) error {
+	if b >= 9*5*5 {
+		return StructuralError("invalid LZMA properties")
+	}
+	d.lc = uint(b % 9)
+	b /= 9
+	d.lp = uint(b % 5)
+	d.pb = uint(b / 5)
+	if d.lc+d.lp > 4 || d.pb > 4 {
+		return StructuralError("invalid LZMA properties")
+	}
+	return nil
+}
+
+// reset resets the state and probabilities.
+func (d *lzmaDecoder) reset() {
+	d.state = 0
+	d.rep = [4]uint32{}
+	d.pending = 0
+	for i := range d.isMatch {
+		for j := range d.isMatch[i] {
+			d.isMatch[i][j] = probInit
+			d.isRep0Long[i][j] = probInit
+		}
+		d.isRep[i] = probInit
+		d.isRepG0[i] = probInit
+		d.isRepG1[i] = probInit
+		d.isRepG2[i] = probInit
+	}
+	for i := range d.distSlot {
+		for j := range d.distSlot[i] {
+			d.distSlot[i][j] = probInit
+		}
+	}
+	for i := range d.distSpecial {
+		d.distSpecial[i] = probInit
+	}
+	for i := range d.distAlign {
+		d.distAlign[i] = probInit
+	}
+	d.matchLen.reset()
+	d.repLen.reset()
+	n := 0x300 << (d.lc + d.lp)
+	if cap(d.literal) < n {
+		d.literal = make([]prob, n)
+	}
+	d.literal = d.literal[:n]
+	for i := range d.literal {
+		d.literal[i] = probInit
+	}
+}
+
+// finished reports whether the current chunk ended cleanly.
+func (d *lzmaDecoder) finished() bool {
+	return d.pending == 0 && d.rc.finished()
+}
+
+// decode decodes n bytes of the current chunk into dict.
+func (d *lzmaDecoder) decode(dict *dict, n int) error {
+	rc := &d.rc
+	if d.pending > 0 {
+		k := d.pending
+		if k > n {
+			k = n
+		}
+		dict.repeat(int(d.rep[0]), k)
+		d.pending -= k
+		n -= k
+	}
+	pbMask := 1<<d.pb - 1
+	lpMask := 1<<d.lp - 1
+	for n > 0 {
+		posState := dict.total & pbMask
+		if rc.bit(&d.isMatch[d.state][posState]) == 0 {
+			var prev byte
+			if dict.full > 0 {
+				prev = dict.get(0)
+			}
+			litState := (dict.total&lpMask)<<d.lc + int(prev)>>(8-d.lc)
+			probs := d.literal[0x300*litState : 0x300*litState+0x300]
+			sym := uint32(1)
+			if d.state < numLitStates {
+				for sym < 0x100 {
+					sym = sym<<1 | rc.bit(&probs[sym])
+				}
+			} else {
+				match := uint32(dict.get(int(d.rep[0]))) << 1
+				offset := uint32(0x100)
+				for sym < 0x100 {
+					matchBit := match & offset
+					match <<= 1
+					b := rc.bit(&probs[offset+matchBit+sym])
+					sym = sym<<1 | b
+					if b != 0 {
+						offset = matchBit
+					} else {
+						offset &^= matchBit
+					}
+				}
+			}
+			dict.put(byte(sym))
+			n--
+			switch {
+			case d.state < 4:
+				d.state = 0
+			case d.state < 10:
+				d.state -= 3
+			default:
+				d.state -= 6
+			}
+			continue
+		}
+
+		var length int
+		if rc.bit(&d.isRep[d.state]) == 0 {
+			if d.state < numLitStates {
+				d.state = 7
+			} else {
+				d.state = 10
+			}
+			l := d.matchLen.decode(rc, posState)
+			d.rep[3], d.rep[2], d.rep[1] = d.rep[2], d.rep[1], d.rep[0]
+			distState := l
+			if distState > distStates-1 {
+				distState = distStates - 1
+			}
+			slot := rc.bitTree(d.distSlot[distState][:], distSlotBits)
+			if slot < distModelStart {
+				d.rep[0] = slot
+			} else {
+				limit := uint(slot>>1 - 1)
+				dist := 2 | slot&1
+				if slot < distModelEnd {
+					dist <<= limit
+					dist += rc.reverseBitTree(d.distSpecial[dist-slot:], limit)
+				} else {
+					dist = dist<<(limit-alignBits) | rc.direct(limit-alignBits)
+					dist = dist<<alignBits | rc.reverseBitTree(d.distAlign[:], alignBits)
+				}
+				d.rep[0] = dist
+			}
+			length = l + matchLenMin
+		} else {
+			if rc.bit(&d.isRepG0[d.state]) == 0 {
+				if rc.bit(&d.isRep0Long[d.state][posState]) == 0 {
+					// A single byte at the last distance.
+					if d.state < numLitStates {
+						d.state = 9
+					} else {
+						d.state = 11
+					}
+					length = 1
+				}
+			} else {
+				var dist uint32
+				if rc.bit(&d.isRepG1[d.state]) == 0 {
+					dist = d.rep[1]
+				} else {
+					if rc.bit(&d.isRepG2[d.state]) == 0 {
+						dist = d.rep[2]
+					} else {
+						dist = d.rep[3]
+						d.rep[3] = d.rep[2]
+					}
+					d.rep[2] = d.rep[1]
+				}
+				d.rep[1] = d.rep[0]
+				d.rep[0] = dist
+			}
+			if length == 0 {
+				if d.state < numLitStates {
+					d.state = 8
+				} else {
+					d.state = 11
+				}
+				length = d.repLen.decode(rc, posState) + matchLenMin
+			}
+		}
+		// This also rejects the end of payload marker,
+		// which LZMA2 does not use.
+		if uint64(d.rep[0]) >= uint64(dict.full) {
+			return StructuralError("invalid LZMA match distance")
+		}
+		k := length
+		if k > n {
+			k = n
+		}
+		dict.repeat(int(d.rep[0]), k)
+		d.pending = length - k
+		n -= k
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xz
+
+const lzma2FilterID = 0x21
+
+// lzma2DictSize returns the dictionary size encoded
+// in the LZMA2 filter properties byte p.
+func lzma2DictSize(p byte) (int64, error) {
+	if p > 40 {
+		return 0, StructuralError("invalid LZMA2 dictionary size")
+	}
+	if p == 40 {
+		return 0xffffffff, nil
+	}
+	return int64(2|p&1) << (p/2 + 11), nil
+}
+
+// An lzma2Reader decodes the LZMA2 data of a block. LZMA2 data is a
+// sequence of chunks, each holding up to 2 MB of uncompressed data,
+// which is either stored or LZMA compressed.
+type lzma2Reader struct {
+	dict dict
+	lzma lzmaDecoder
+
+	in     []byte // the current chunk
+	inPos  int    // read position in in, for stored chunks
+	remain int    // uncompressed bytes left in the current chunk
+	stored bool
+
+	needDictReset bool
+	needProps     bool
+	eos           bool
+}
+
+// reset prepares r for a new block.
+func (r *lzma2Reader) reset(dictSize int) {
+	r.dict.reset(dictSize)
+	r.remain = 0
+	r.needDictReset = true
+	r.needProps = true
+	r.eos = false
+}
+
+// done reports whether the end of the LZMA2 data was reached.
+func (r *lzma2Reader) done() bool {
+	return r.eos
+}
+
+// decode decodes as much of the current chunk as the dictionary
+// can hold, or reads the header of the next chunk. There must be
+// no unread data in the dictionary.
+func (r *lzma2Reader) decode(z *Reader) error {
+	if r.remain == 0 {
+		return r.readChunk(z)
+	}
+	n := r.dict.size - r.dict.unread
+	if n > r.remain {
+		n = r.remain
+	}
+	if r.stored {
+		r.dict.write(r.in[r.inPos : r.inPos+n])
+		r.inPos += n
+	} else if err := r.lzma.decode(&r.dict, n); err != nil {
+		return err
+	}
+	r.remain -= n
+	if r.remain == 0 && !r.stored && !r.lzma.finished() {
+		return StructuralError("LZMA2 chunk size mismatch")
+	}
+	return nil
+}
+
+// readChunk reads the header and data of the next chunk.
+func (r *lzma2Reader) readChunk(z *Reader) error {
+	c, err := z.readByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	if c == 0 {
+		r.eos = true
+		return nil
+	}
+	if c >= 0xe0 || c == 1 {
+		r.needProps = true
+		r.needDictReset = false
+		r.dict.clear()
+	} else if r.needDictReset {
+		return StructuralError("missing LZMA2 dictionary reset")
+	}
+
+	var size int
+	if c >= 0x80 {
+		// LZMA chunk. Bits 5-6 say what to reset: nothing, the
+		// state, the state and properties, or everything.
+		hdr := z.buf[:4]
+		if c >= 0xc0 {
+			hdr = z.buf[:5]
+		}
+		if err := z.readFull(hdr); err != nil {
+			return noEOF(err)
+		}
+		r.remain = (int(c&0x1f)<<16 | int(hdr[0])<<8 | int(hdr[1])) + 1
+		size = (int(hdr[2])<<8 | int(hdr[3])) + 1
+		if c >= 0xc0 {
+			if err := r.lzma.setProps(hdr[4]); err != nil {
+				return err
+			}
+			r.needProps = false
+		} else if r.needProps {
+			return StructuralError("missing LZMA2 properties")
+		}
+		if c >= 0xa0 {
+			r.lzma.reset()
+		}
+		r.stored = false
+	} else {
+		// Stored chunk, with (1) or without (2) a dictionary reset.
+		if c > 2 {
+			return StructuralError("invalid LZMA2 control byte")
+		}
+		hdr := z.buf[:2]
+		if err := z.readFull(hdr); err != nil {
+			return noEOF(err)
+		}
+		size = (int(hdr[0])<<8 | int(hdr[1])) + 1
+		r.remain = size
+		r.stored = true
+		r.inPos = 0
+	}
+
+	if cap(r.in) < size {
+		r.in = make([]byte, size, 1<<16)
+	}
+	r.in = r.in[:size]
+	if err := z.readFull(r.in); err != nil {
+		return noEOF(err)
+	}
+	if !r.stored && !r.lzma.rc.init(r.in) {
+		return StructuralError("invalid LZMA2 chunk")
+	}
+	return nil
+}
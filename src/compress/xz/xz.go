@@ -0,0 +1,567 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package xz implements reading of xz format compressed files,
+// as specified in The .xz File Format, version 1.0.4.
+//
+// Streams may be concatenated and may contain any number of blocks.
+// Blocks must use the LZMA2 filter on its own, which is what xz and
+// most other tools produce by default. The integrity checks CRC32,
+// CRC64 and SHA-256 are verified.
+package xz
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+const (
+	headerSize = 12
+	footerSize = 12
+)
+
+var (
+	headerMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	footerMagic = []byte{'Y', 'Z'}
+)
+
+// Check types.
+const (
+	checkNone   = 0x00
+	checkCRC32  = 0x01
+	checkCRC64  = 0x04
+	checkSHA256 = 0x0a
+)
+
+// DefaultMemoryLimit is the default limit on the memory a Reader
+// may use for the dictionary of a block. It is sufficient for files
+// produced by xz at any of its preset levels.
+const DefaultMemoryLimit = 128 << 20
+
+var (
+	// ErrChecksum is returned when reading xz data that has an invalid checksum.
+	ErrChecksum = errors.New("xz: invalid checksum")
+	// ErrHeader is returned when reading xz data that has an invalid stream header.
+	ErrHeader = errors.New("xz: invalid header")
+	// ErrMemoryLimit is returned when decoding a block would need
+	// more memory than the limit set with SetMemoryLimit.
+	ErrMemoryLimit = errors.New("xz: memory limit exceeded")
+)
+
+// A StructuralError is returned when the xz data is found to be
+// syntactically invalid.
+type StructuralError string
+
+func (s StructuralError) Error() string {
+	return "xz data invalid: " + string(s)
+}
+
+var le = binary.LittleEndian
+
+const maxInt = int(^uint(0) >> 1)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// byteReader is the interface the Reader needs from its source,
+// so that single bytes can be read efficiently.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// A Reader is an io.Reader that can be read to retrieve
+// uncompressed data from an xz format compressed file.
+//
+// An xz file may be a concatenation of streams, each holding any
+// number of blocks. By default, Reads from the Reader return the
+// concatenation of the uncompressed data of all streams, skipping
+// the padding between them.
+//
+// Blocks and streams store checks of their contents. The Reader
+// returns ErrChecksum when Read reaches the end of a block whose
+// data does not match its check, or of a stream whose index does
+// not match its blocks. Clients should treat data returned by Read
+// as tentative until they receive the io.EOF marking the end of the
+// data.
+type Reader struct {
+	r           byteReader
+	n           int64 // bytes read from r
+	memLimit    int64
+	multistream bool
+	err         error
+	buf         [1024]byte // block headers are at most 1024 bytes
+
+	flags   [2]byte   // stream flags
+	check   hash.Hash // check of the current block, if any
+	records int64     // blocks in the current stream
+	recHash hash.Hash32
+
+	// Current block.
+	inBlock     bool
+	headerSize  int64
+	compStart   int64 // value of n at the start of the compressed data
+	compSize    int64 // -1 if not declared
+	uncompSize  int64 // -1 if not declared
+	uncompCount int64
+
+	lz lzma2Reader
+}
+
+// NewReader creates a new Reader reading the given reader.
+// If r does not also implement io.ByteReader,
+// the decompressor may read more data than necessary from r.
+//
+// The stream header is read immediately; it is an error if it is
+// invalid. If r is empty, NewReader returns io.EOF.
+func NewReader(r io.Reader) (*Reader, error) {
+	z := new(Reader)
+	if err := z.Reset(r); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// Reset discards the Reader z's state and makes it equivalent to the
+// result of its original state from NewReader, but reading from r
+// instead. This permits reusing a Reader rather than allocating a new
+// one. The memory limit is retained.
+func (z *Reader) Reset(r io.Reader) error {
+	memLimit := z.memLimit
+	if memLimit == 0 {
+		memLimit = DefaultMemoryLimit
+	}
+	*z = Reader{
+		memLimit:    memLimit,
+		multistream: true,
+		recHash:     z.recHash,
+		lz:          z.lz,
+	}
+	if rr, ok := r.(byteReader); ok {
+		z.r = rr
+	} else {
+		z.r = bufio.NewReader(r)
+	}
+	if z.recHash == nil {
+		z.recHash = crc32.NewIEEE()
+	}
+	// Keep the buffers of the LZMA2 decoder, but drop any
+	// output that was not read.
+	z.lz.dict.unread = 0
+	z.err = z.readStreamHeader(z.buf[:headerSize], true)
+	return z.err
+}
+
+// SetMemoryLimit sets the largest amount of memory, in bytes, that z
+// may use for the dictionary of a block. Blocks that need more fail
+// with ErrMemoryLimit.
+func (z *Reader) SetMemoryLimit(n int64) {
+	z.memLimit = n
+}
+
+// Multistream controls whether the reader supports multistream files.
+//
+// If enabled (the default), the Reader expects the input to be a
+// sequence of individually compressed streams, optionally separated
+// by stream padding, each with its own header and footer. Reading
+// returns the concatenation of the uncompressed data of all streams.
+//
+// Calling Multistream(false) disables this behavior; the Reader then
+// returns io.EOF after the footer of the first stream, without reading
+// any padding that follows it. This can be useful when reading file
+// formats that distinguish individual xz streams or mix xz streams
+// with other data streams.
+func (z *Reader) Multistream(ok bool) {
+	z.multistream = ok
+}
+
+func (z *Reader) readFull(b []byte) error {
+	n, err := io.ReadFull(z.r, b)
+	z.n += int64(n)
+	return err
+}
+
+func (z *Reader) readByte() (byte, error) {
+	b, err := z.r.ReadByte()
+	if err == nil {
+		z.n++
+	}
+	return b, err
+}
+
+// readStreamHeader reads the stream header. If first is set, the
+// header is at the start of the input, and reaching EOF before it
+// is reported as io.EOF. Otherwise the first four bytes of the header
+// have already been read into buf.
+func (z *Reader) readStreamHeader(buf []byte, first bool) error {
+	if first {
+		if err := z.readFull(buf); err != nil {
+			return err
+		}
+	} else if err := z.readFull(buf[4:]); err != nil {
+		return noEOF(err)
+	}
+	if string(buf[:6]) != string(headerMagic) {
+		return ErrHeader
+	}
+	if crc32.ChecksumIEEE(buf[6:8]) != le.Uint32(buf[8:12]) {
+		return ErrHeader
+	}
+	if buf[6] != 0 || buf[7]&0xf0 != 0 {
+		return ErrHeader
+	}
+	switch buf[7] {
+	case checkNone, checkCRC32, checkCRC64, checkSHA256:
+	default:
+		return fmt.Errorf("xz: unsupported check type %#x", buf[7])
+	}
+	copy(z.flags[:], buf[6:8])
+	z.records = 0
+	z.recHash.Reset()
+	return nil
+}
+
+// checkSize returns the size of the check stored after each block.
+func (z *Reader) checkSize() int {
+	switch z.flags[1] {
+	case checkCRC32:
+		return 4
+	case checkCRC64:
+		return 8
+	case checkSHA256:
+		return 32
+	}
+	return 0
+}
+
+// Read implements io.Reader, reading uncompressed bytes from its underlying Reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if n := z.lz.dict.read(p); n > 0 {
+			z.uncompCount += int64(n)
+			if z.check != nil {
+				z.check.Write(p[:n])
+			}
+			return n, nil
+		}
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.step()
+	}
+}
+
+// step decodes more data, or moves on to the next block or stream.
+func (z *Reader) step() error {
+	if z.inBlock {
+		if !z.lz.done() {
+			err := z.lz.decode(z)
+			if z.uncompSize >= 0 && z.uncompCount+int64(z.lz.dict.unread) > z.uncompSize {
+				return StructuralError("block size mismatch")
+			}
+			return err
+		}
+		return z.readBlockTrailer()
+	}
+	b, err := z.readByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	if b != 0 {
+		return z.readBlockHeader(b)
+	}
+	if err := z.readIndex(); err != nil {
+		return err
+	}
+	if !z.multistream {
+		return io.EOF
+	}
+	return z.readStreamPadding()
+}
+
+// readBlockHeader reads the header of a block whose first byte is b.
+func (z *Reader) readBlockHeader(b byte) error {
+	size := (int(b) + 1) * 4
+	buf := z.buf[:size]
+	buf[0] = b
+	if err := z.readFull(buf[1:]); err != nil {
+		return noEOF(err)
+	}
+	if crc32.ChecksumIEEE(buf[:size-4]) != le.Uint32(buf[size-4:]) {
+		return ErrChecksum
+	}
+	flags := buf[1]
+	if flags&0x3c != 0 {
+		return StructuralError("reserved block flags set")
+	}
+	rest := buf[2 : size-4]
+	z.compSize, z.uncompSize = -1, -1
+	var err error
+	if flags&0x40 != 0 {
+		var v uint64
+		if v, rest, err = parseVLI(rest); err != nil {
+			return err
+		}
+		if v == 0 {
+			return StructuralError("zero compressed size")
+		}
+		z.compSize = int64(v)
+	}
+	if flags&0x80 != 0 {
+		var v uint64
+		if v, rest, err = parseVLI(rest); err != nil {
+			return err
+		}
+		z.uncompSize = int64(v)
+	}
+	if flags&3 != 0 {
+		// Only LZMA2 on its own is supported; other filters
+		// precede it in the chain.
+		id, _, err := parseVLI(rest)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("xz: unsupported filter %#x", id)
+	}
+	id, rest, err := parseVLI(rest)
+	if err != nil {
+		return err
+	}
+	if id != lzma2FilterID {
+		return fmt.Errorf("xz: unsupported filter %#x", id)
+	}
+	propSize, rest, err := parseVLI(rest)
+	if err != nil {
+		return err
+	}
+	if propSize != 1 || len(rest) < 1 {
+		return StructuralError("invalid LZMA2 properties")
+	}
+	dictSize, err := lzma2DictSize(rest[0])
+	if err != nil {
+		return err
+	}
+	for _, c := range rest[1:] {
+		if c != 0 {
+			return StructuralError("nonzero block header padding")
+		}
+	}
+	if dictSize > z.memLimit || dictSize > int64(maxInt) {
+		return ErrMemoryLimit
+	}
+
+	z.inBlock = true
+	z.headerSize = int64(size)
+	z.compStart = z.n
+	z.uncompCount = 0
+	switch z.flags[1] {
+	case checkCRC32:
+		z.check = crc32.NewIEEE()
+	case checkCRC64:
+		z.check = crc64.New(crc64Table)
+	case checkSHA256:
+		z.check = sha256.New()
+	default:
+		z.check = nil
+	}
+	z.lz.reset(int(dictSize))
+	return nil
+}
+
+// readBlockTrailer verifies the sizes of the block just decoded
+// and reads its padding and check.
+func (z *Reader) readBlockTrailer() error {
+	compSize := z.n - z.compStart
+	if z.compSize >= 0 && compSize != z.compSize ||
+		z.uncompSize >= 0 && z.uncompCount != z.uncompSize {
+		return StructuralError("block size mismatch")
+	}
+	pad := int(-compSize & 3)
+	buf := z.buf[:pad+z.checkSize()]
+	if err := z.readFull(buf); err != nil {
+		return noEOF(err)
+	}
+	for _, c := range buf[:pad] {
+		if c != 0 {
+			return StructuralError("nonzero block padding")
+		}
+	}
+	if z.check != nil {
+		// CRC32 and CRC64 are stored little-endian.
+		want := buf[pad:]
+		sum := z.buf[len(buf) : len(buf)+len(want)]
+		switch h := z.check.(type) {
+		case hash.Hash32:
+			le.PutUint32(sum, h.Sum32())
+		case hash.Hash64:
+			le.PutUint64(sum, h.Sum64())
+		default:
+			sum = h.Sum(sum[:0])
+		}
+		if string(sum) != string(want) {
+			return ErrChecksum
+		}
+	}
+	z.addRecord(uint64(z.headerSize+compSize)+uint64(z.checkSize()), uint64(z.uncompCount))
+	z.inBlock = false
+	return nil
+}
+
+// addRecord adds a block to the running hash that is compared
+// against the index at the end of the stream.
+func (z *Reader) addRecord(unpadded, uncompressed uint64) {
+	var b [16]byte
+	le.PutUint64(b[:8], unpadded)
+	le.PutUint64(b[8:], uncompressed)
+	z.recHash.Write(b[:])
+	z.records++
+}
+
+// readIndex reads the index and the stream footer. The index
+// indicator byte has already been read.
+func (z *Reader) readIndex() error {
+	ir := indexReader{z: z, crc: crc32.Update(0, crc32.IEEETable, []byte{0}), n: 1}
+	count, err := ir.readVLI()
+	if err != nil {
+		return err
+	}
+	if int64(count) != z.records {
+		return StructuralError("index does not match blocks")
+	}
+	want := z.recHash.Sum32()
+	z.recHash.Reset()
+	for i := uint64(0); i < count; i++ {
+		unpadded, err := ir.readVLI()
+		if err != nil {
+			return err
+		}
+		uncompressed, err := ir.readVLI()
+		if err != nil {
+			return err
+		}
+		z.addRecord(unpadded, uncompressed)
+	}
+	if z.recHash.Sum32() != want {
+		return StructuralError("index does not match blocks")
+	}
+	for ir.n%4 != 0 {
+		b, err := ir.readByte()
+		if err != nil {
+			return err
+		}
+		if b != 0 {
+			return StructuralError("nonzero index padding")
+		}
+	}
+	buf := z.buf[:4+footerSize]
+	if err := z.readFull(buf); err != nil {
+		return noEOF(err)
+	}
+	if le.Uint32(buf[:4]) != ir.crc {
+		return ErrChecksum
+	}
+	indexSize := ir.n + 4
+
+	footer := buf[4:]
+	if crc32.ChecksumIEEE(footer[4:10]) != le.Uint32(footer[:4]) {
+		return ErrChecksum
+	}
+	if string(footer[10:]) != string(footerMagic) {
+		return StructuralError("bad stream footer magic")
+	}
+	if (int64(le.Uint32(footer[4:8]))+1)*4 != indexSize {
+		return StructuralError("index size mismatch")
+	}
+	if footer[8] != z.flags[0] || footer[9] != z.flags[1] {
+		return StructuralError("stream header and footer flags differ")
+	}
+	return nil
+}
+
+// readStreamPadding skips the padding after a stream and reads the
+// header of the next stream. It returns io.EOF at the end of the input.
+func (z *Reader) readStreamPadding() error {
+	buf := z.buf[:headerSize]
+	for {
+		err := z.readFull(buf[:4])
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return StructuralError("stream padding not a multiple of four bytes")
+		}
+		if le.Uint32(buf) != 0 {
+			return z.readStreamHeader(buf, false)
+		}
+	}
+}
+
+// An indexReader reads from the index, keeping track
+// of its size and CRC32.
+type indexReader struct {
+	z   *Reader
+	crc uint32
+	n   int64
+}
+
+func (ir *indexReader) readByte() (byte, error) {
+	b, err := ir.z.readByte()
+	if err != nil {
+		return 0, noEOF(err)
+	}
+	ir.crc = crc32.Update(ir.crc, crc32.IEEETable, []byte{b})
+	ir.n++
+	return b, nil
+}
+
+func (ir *indexReader) readVLI() (uint64, error) {
+	var v uint64
+	for i := uint(0); i < 9; i++ {
+		b, err := ir.readByte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			if b == 0 && i > 0 {
+				return 0, StructuralError("non-minimal integer encoding")
+			}
+			return v, nil
+		}
+	}
+	return 0, StructuralError("integer too large")
+}
+
+// parseVLI parses a variable-length integer from the start of b.
+func parseVLI(b []byte) (uint64, []byte, error) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		c := b[i]
+		v |= uint64(c&0x7f) << (7 * uint(i))
+		if c&0x80 == 0 {
+			if c == 0 && i > 0 {
+				return 0, nil, StructuralError("non-minimal integer encoding")
+			}
+			return v, b[i+1:], nil
+		}
+	}
+	return 0, nil, StructuralError("invalid integer in block header")
+}
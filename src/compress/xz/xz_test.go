@@ -0,0 +1,199 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xz
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// The files in testdata were produced by xz 5.2:
+//
+//	xz ../testdata/gettysburg.txt
+//	xz -0 -C crc32 ../testdata/gettysburg.txt (as gettysburg-crc32.txt.xz)
+//	xz -6 -C sha256 --block-size=16384 --lzma2=dict=4KiB ../testdata/e.txt
+//	xz -C none gettysburg.txt.xz
+//	xz archive.tar
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+var readerTests = []struct {
+	name string
+	raw  string
+}{
+	// Default settings: one block with a CRC64 check.
+	{"gettysburg.txt.xz", "../testdata/gettysburg.txt"},
+	{"gettysburg-crc32.txt.xz", "../testdata/gettysburg.txt"},
+	// Several blocks with SHA-256 checks and a dictionary much
+	// smaller than the data.
+	{"e.txt.xz", "../testdata/e.txt"},
+	// Incompressible data, held in stored chunks, without checks.
+	{"gettysburg.txt.xz.xz", "testdata/gettysburg.txt.xz"},
+}
+
+func TestReader(t *testing.T) {
+	for _, tt := range readerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			z, err := NewReader(bytes.NewReader(readFile(t, "testdata/"+tt.name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadAll(z)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := readFile(t, tt.raw); !bytes.Equal(got, want) {
+				t.Fatalf("got %d bytes, want %d bytes matching %s", len(got), len(want), tt.raw)
+			}
+		})
+	}
+}
+
+func TestReaderSmallReads(t *testing.T) {
+	z, err := NewReader(bytes.NewReader(readFile(t, "testdata/e.txt.xz")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	buf := make([]byte, 7)
+	for {
+		n, err := z.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := readFile(t, "../testdata/e.txt"); !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestMultistream(t *testing.T) {
+	g := readFile(t, "testdata/gettysburg.txt.xz")
+	var in bytes.Buffer
+	in.Write(g)
+	in.Write(make([]byte, 8)) // stream padding
+	in.Write(readFile(t, "testdata/gettysburg-crc32.txt.xz"))
+	want := readFile(t, "../testdata/gettysburg.txt")
+
+	z, err := NewReader(bytes.NewReader(in.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, append(want, want...)) {
+		t.Fatalf("got %d bytes, want %d", len(got), 2*len(want))
+	}
+
+	r := bytes.NewReader(in.Bytes())
+	z, err = NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.Multistream(false)
+	got, err = ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+	if n := r.Len(); n != in.Len()-len(g) {
+		t.Errorf("%d bytes left after first stream, want %d", n, in.Len()-len(g))
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	g := readFile(t, "testdata/gettysburg.txt.xz")
+	corrupt := func(off int) []byte {
+		b := append([]byte(nil), g...)
+		b[off] ^= 0x40
+		return b
+	}
+	tests := []struct {
+		desc string
+		in   []byte
+		err  error // nil means any error
+	}{
+		{"bad magic", corrupt(1), ErrHeader},
+		{"bad stream flags", corrupt(7), ErrHeader},
+		{"bad block header", corrupt(13), ErrChecksum},
+		// The CRC64 of the block precedes the 12-byte
+		// index and the 12-byte footer.
+		{"bad check", corrupt(len(g) - 25), ErrChecksum},
+		{"bad compressed data", corrupt(len(g) / 2), nil},
+		{"bad footer", corrupt(len(g) - 3), nil},
+		{"bad padding", append(append([]byte(nil), g...), 0, 0, 0), nil},
+		{"truncated", g[:len(g)-1], io.ErrUnexpectedEOF},
+		{"truncated block", g[:len(g)/2], io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		z, err := NewReader(bytes.NewReader(tt.in))
+		if err == nil {
+			_, err = ioutil.ReadAll(z)
+		}
+		if err == nil || tt.err != nil && err != tt.err {
+			t.Errorf("%s: got error %v, want %v", tt.desc, err, tt.err)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader(nil)); err != io.EOF {
+		t.Fatalf("NewReader of empty input: got error %v, want %v", err, io.EOF)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	// gettysburg.txt.xz uses an 8 MiB dictionary.
+	z, err := NewReader(bytes.NewReader(readFile(t, "testdata/gettysburg.txt.xz")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.SetMemoryLimit(1 << 20)
+	if _, err := ioutil.ReadAll(z); err != ErrMemoryLimit {
+		t.Fatalf("got error %v, want %v", err, ErrMemoryLimit)
+	}
+
+	// The limit is retained across Reset. e.txt.xz uses a 4 KiB dictionary.
+	if err := z.Reset(bytes.NewReader(readFile(t, "testdata/e.txt.xz"))); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := readFile(t, "../testdata/e.txt"); !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	in, err := ioutil.ReadFile("testdata/e.txt.xz")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(100003)
+	z := new(Reader)
+	for i := 0; i < b.N; i++ {
+		z.Reset(bytes.NewReader(in))
+		io.Copy(ioutil.Discard, z)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// A forwardBitReader reads little-endian bit fields from the start of
+// a byte slice. It is used for FSE table descriptions.
+type forwardBitReader struct {
+	data []byte
+	off  uint // offset in bits
+}
+
+// peek returns the next n bits, n <= 24, without consuming them.
+// Bits past the end of the data read as zero.
+func (br *forwardBitReader) peek(n uint) uint32 {
+	i := br.off >> 3
+	var v uint32
+	for j := uint(0); j < 4 && int(i+j) < len(br.data); j++ {
+		v |= uint32(br.data[i+j]) << (8 * j)
+	}
+	return (v >> (br.off & 7)) & (1<<n - 1)
+}
+
+func (br *forwardBitReader) skip(n uint) { br.off += n }
+
+func (br *forwardBitReader) val(n uint) uint32 {
+	v := br.peek(n)
+	br.skip(n)
+	return v
+}
+
+// bytesRead reports the number of whole bytes consumed so far,
+// or -1 if more bits were consumed than available.
+func (br *forwardBitReader) bytesRead() int {
+	n := int((br.off + 7) >> 3)
+	if n > len(br.data) {
+		return -1
+	}
+	return n
+}
+
+// A reverseBitReader reads a bitstream that was written forwards by a
+// bitWriter, starting from its end. The last byte of the stream holds
+// a marker bit that indicates where the stream begins.
+type reverseBitReader struct {
+	data []byte
+	off  int    // data[:off] has not been loaded yet
+	bits uint64 // the low cnt bits are valid
+	cnt  uint
+}
+
+func newReverseBitReader(data []byte) (reverseBitReader, error) {
+	if len(data) == 0 {
+		return reverseBitReader{}, StructuralError("empty bitstream")
+	}
+	last := data[len(data)-1]
+	if last == 0 {
+		return reverseBitReader{}, StructuralError("missing bitstream end marker")
+	}
+	return reverseBitReader{
+		data: data,
+		off:  len(data) - 1,
+		bits: uint64(last),
+		cnt:  uint(bits.Len8(last)) - 1,
+	}, nil
+}
+
+func (br *reverseBitReader) fill() {
+	for br.cnt <= 56 && br.off > 0 {
+		br.off--
+		br.bits = br.bits<<8 | uint64(br.data[br.off])
+		br.cnt += 8
+	}
+}
+
+// val consumes and returns the next n bits, n <= 32.
+// It reports false if fewer than n bits remain.
+func (br *reverseBitReader) val(n uint) (uint32, bool) {
+	if n == 0 {
+		return 0, true
+	}
+	if br.cnt < n {
+		br.fill()
+		if br.cnt < n {
+			return 0, false
+		}
+	}
+	br.cnt -= n
+	return uint32(br.bits>>br.cnt) & (1<<n - 1), true
+}
+
+// peek returns the next n bits without consuming them, padding with
+// zero bits if fewer than n remain.
+func (br *reverseBitReader) peek(n uint) uint32 {
+	if br.cnt < n {
+		br.fill()
+		if br.cnt < n {
+			return uint32(br.bits<<(n-br.cnt)) & (1<<n - 1)
+		}
+	}
+	return uint32(br.bits>>(br.cnt-n)) & (1<<n - 1)
+}
+
+// skip consumes n bits that were previously peeked.
+func (br *reverseBitReader) skip(n uint) bool {
+	if br.cnt < n {
+		return false
+	}
+	br.cnt -= n
+	return true
+}
+
+// remaining reports the number of unread bits.
+func (br *reverseBitReader) remaining() int {
+	return br.off*8 + int(br.cnt)
+}
+
+// A bitWriter writes little-endian bit fields. The resulting stream is
+// meant to be read backwards by a reverseBitReader.
+type bitWriter struct {
+	out   []byte
+	bits  uint64
+	nbits uint
+}
+
+// add appends the low n bits of v, n <= 32.
+func (bw *bitWriter) add(v uint32, n uint) {
+	bw.bits |= uint64(v&(1<<n-1)) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 32 {
+		bw.out = append(bw.out, byte(bw.bits), byte(bw.bits>>8), byte(bw.bits>>16), byte(bw.bits>>24))
+		bw.bits >>= 32
+		bw.nbits -= 32
+	}
+}
+
+// close writes the end marker and flushes the remaining bits.
+func (bw *bitWriter) close() []byte {
+	bw.add(1, 1)
+	for bw.nbits > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits >>= 8
+		if bw.nbits < 8 {
+			bw.nbits = 0
+		} else {
+			bw.nbits -= 8
+		}
+	}
+	return bw.out
+}
@@ -0,0 +1,338 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// decompressBlock decodes a Compressed_Block (RFC 8878, 3.1.1.3),
+// appending the output to z.hist.
+func (z *Reader) decompressBlock(data []byte) error {
+	lits, n, err := z.readLiterals(data)
+	if err != nil {
+		return err
+	}
+	return z.execSequences(data[n:], lits)
+}
+
+// readLiterals decodes the literals section at the start of data and
+// returns the literals and the size of the section.
+func (z *Reader) readLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, StructuralError("missing literals section")
+	}
+	typ := data[0] & 3
+	sizeFormat := (data[0] >> 2) & 3
+
+	if typ < 2 {
+		// Raw_Literals_Block or RLE_Literals_Block.
+		var regen, hdr int
+		switch sizeFormat {
+		case 0, 2:
+			regen, hdr = int(data[0]>>3), 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, StructuralError("truncated literals header")
+			}
+			regen, hdr = int(data[0]>>4)|int(data[1])<<4, 2
+		case 3:
+			if len(data) < 3 {
+				return nil, 0, StructuralError("truncated literals header")
+			}
+			regen, hdr = int(data[0]>>4)|int(data[1])<<4|int(data[2])<<12, 3
+		}
+		if regen > z.blockMax {
+			return nil, 0, StructuralError("literals too large")
+		}
+		if typ == 0 {
+			if hdr+regen > len(data) {
+				return nil, 0, StructuralError("truncated literals")
+			}
+			return data[hdr : hdr+regen], hdr + regen, nil
+		}
+		if hdr >= len(data) {
+			return nil, 0, StructuralError("truncated literals")
+		}
+		lits := z.literals[:0]
+		for i := 0; i < regen; i++ {
+			lits = append(lits, data[hdr])
+		}
+		z.literals = lits
+		return lits, hdr + 1, nil
+	}
+
+	// Compressed_Literals_Block or Treeless_Literals_Block.
+	var regen, comp, hdr int
+	streams := 4
+	switch sizeFormat {
+	case 0, 1:
+		if len(data) < 3 {
+			return nil, 0, StructuralError("truncated literals header")
+		}
+		v := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		regen, comp, hdr = int(v>>4&0x3ff), int(v>>14&0x3ff), 3
+		if sizeFormat == 0 {
+			streams = 1
+		}
+	case 2:
+		if len(data) < 4 {
+			return nil, 0, StructuralError("truncated literals header")
+		}
+		v := le.Uint32(data)
+		regen, comp, hdr = int(v>>4&0x3fff), int(v>>18), 4
+	case 3:
+		if len(data) < 5 {
+			return nil, 0, StructuralError("truncated literals header")
+		}
+		v := uint64(le.Uint32(data)) | uint64(data[4])<<32
+		regen, comp, hdr = int(v>>4&0x3ffff), int(v>>22&0x3ffff), 5
+	}
+	if regen > z.blockMax {
+		return nil, 0, StructuralError("literals too large")
+	}
+	if hdr+comp > len(data) {
+		return nil, 0, StructuralError("truncated literals")
+	}
+	src := data[hdr : hdr+comp]
+
+	if typ == 2 {
+		tableBits, n, err := readHuffman(src, z.huffBuf[:])
+		if err != nil {
+			return nil, 0, err
+		}
+		z.huff, z.huffBits = z.huffBuf[:], tableBits
+		src = src[n:]
+	} else if z.huff == nil {
+		return nil, 0, StructuralError("treeless literals without a previous Huffman table")
+	}
+
+	if cap(z.literals) < regen {
+		z.literals = make([]byte, regen, maxBlockSize)
+	}
+	lits := z.literals[:regen]
+	if streams == 1 {
+		if err := decodeHuffman(lits, src, z.huff, z.huffBits); err != nil {
+			return nil, 0, err
+		}
+		return lits, hdr + comp, nil
+	}
+
+	if len(src) < 6 {
+		return nil, 0, StructuralError("truncated literals jump table")
+	}
+	s1, s2, s3 := int(le.Uint16(src)), int(le.Uint16(src[2:])), int(le.Uint16(src[4:]))
+	src = src[6:]
+	if s1+s2+s3 > len(src) {
+		return nil, 0, StructuralError("invalid literals jump table")
+	}
+	seg := (regen + 3) / 4
+	if 3*seg > regen {
+		return nil, 0, StructuralError("too few literals for four streams")
+	}
+	ends := [4]int{s1, s1 + s2, s1 + s2 + s3, len(src)}
+	prev := 0
+	for i, end := range ends {
+		dst := lits[i*seg:]
+		if i < 3 {
+			dst = dst[:seg]
+		}
+		if err := decodeHuffman(dst, src[prev:end], z.huff, z.huffBits); err != nil {
+			return nil, 0, err
+		}
+		prev = end
+	}
+	return lits, hdr + comp, nil
+}
+
+// execSequences decodes the sequences section in data and executes the
+// sequences, appending the output to z.hist.
+func (z *Reader) execSequences(data []byte, lits []byte) error {
+	if len(data) == 0 {
+		return StructuralError("missing sequences section")
+	}
+	start := len(z.hist)
+	nseq := int(data[0])
+	n := 1
+	if nseq == 0 {
+		if len(data) != 1 {
+			return StructuralError("extra data after sequences section")
+		}
+		if len(lits) > z.blockMax {
+			return StructuralError("block too large")
+		}
+		z.hist = append(z.hist, lits...)
+		return nil
+	}
+	if nseq >= 128 {
+		if nseq < 255 {
+			if len(data) < 2 {
+				return StructuralError("truncated sequences header")
+			}
+			nseq = (nseq-128)<<8 | int(data[1])
+			n = 2
+		} else {
+			if len(data) < 3 {
+				return StructuralError("truncated sequences header")
+			}
+			nseq = int(data[1]) | int(data[2])<<8 + 0x7f00
+			n = 3
+		}
+	}
+	if n >= len(data) {
+		return StructuralError("truncated sequences header")
+	}
+	modes := data[n]
+	n++
+	if modes&3 != 0 {
+		return StructuralError("reserved bits set in sequences header")
+	}
+
+	var err error
+	var m int
+	if z.llTable, m, err = readSeqTable(data[n:], modes>>6, z.llTable, z.llBuf[:], predefLLTable, maxLLSymbol, maxLLLog); err != nil {
+		return err
+	}
+	n += m
+	if z.ofTable, m, err = readSeqTable(data[n:], modes>>4&3, z.ofTable, z.ofBuf[:], predefOFTable, maxOFSymbol, maxOFLog); err != nil {
+		return err
+	}
+	n += m
+	if z.mlTable, m, err = readSeqTable(data[n:], modes>>2&3, z.mlTable, z.mlBuf[:], predefMLTable, maxMLSymbol, maxMLLog); err != nil {
+		return err
+	}
+	n += m
+
+	br, err := newReverseBitReader(data[n:])
+	if err != nil {
+		return err
+	}
+	llT, ofT, mlT := z.llTable, z.ofTable, z.mlTable
+	llLog := uint(bits.Len(uint(len(llT))) - 1)
+	ofLog := uint(bits.Len(uint(len(ofT))) - 1)
+	mlLog := uint(bits.Len(uint(len(mlT))) - 1)
+	llState, ok1 := br.val(llLog)
+	ofState, ok2 := br.val(ofLog)
+	mlState, ok3 := br.val(mlLog)
+	if !ok1 || !ok2 || !ok3 {
+		return StructuralError("truncated sequences bitstream")
+	}
+
+	rep := z.repeats
+	for i := 0; i < nseq; i++ {
+		lle, ofe, mle := llT[llState], ofT[ofState], mlT[mlState]
+
+		ofExtra, ok1 := br.val(uint(ofe.sym))
+		mlExtra, ok2 := br.val(uint(mlBits[mle.sym]))
+		llExtra, ok3 := br.val(uint(llBits[lle.sym]))
+		if !ok1 || !ok2 || !ok3 {
+			return StructuralError("truncated sequences bitstream")
+		}
+		offset := uint32(1)<<ofe.sym + ofExtra
+		ml := int(mlBase[mle.sym] + mlExtra)
+		ll := int(llBase[lle.sym] + llExtra)
+
+		if offset > 3 {
+			offset -= 3
+			rep[0], rep[1], rep[2] = offset, rep[0], rep[1]
+		} else {
+			if ll == 0 {
+				offset++
+			}
+			switch offset {
+			case 1:
+				offset = rep[0]
+			case 2:
+				offset = rep[1]
+				rep[0], rep[1] = offset, rep[0]
+			case 3:
+				offset = rep[2]
+				rep[0], rep[1], rep[2] = offset, rep[0], rep[1]
+			case 4:
+				offset = rep[0] - 1
+				rep[0], rep[1], rep[2] = offset, rep[0], rep[1]
+			}
+		}
+
+		if i < nseq-1 {
+			v1, ok1 := br.val(uint(lle.bits))
+			v2, ok2 := br.val(uint(mle.bits))
+			v3, ok3 := br.val(uint(ofe.bits))
+			if !ok1 || !ok2 || !ok3 {
+				return StructuralError("truncated sequences bitstream")
+			}
+			llState = uint32(lle.base) + v1
+			mlState = uint32(mle.base) + v2
+			ofState = uint32(ofe.base) + v3
+		}
+
+		if ll > len(lits) {
+			return StructuralError("literal length exceeds literals")
+		}
+		if len(z.hist)-start+ll+ml > z.blockMax {
+			return StructuralError("block too large")
+		}
+		z.hist = append(z.hist, lits[:ll]...)
+		lits = lits[ll:]
+		if offset == 0 || int(offset) > len(z.hist) {
+			return StructuralError("match offset out of range")
+		}
+		z.copyMatch(int(offset), ml)
+	}
+	if br.remaining() != 0 {
+		return StructuralError("trailing bits in sequences bitstream")
+	}
+	if len(z.hist)-start+len(lits) > z.blockMax {
+		return StructuralError("block too large")
+	}
+	z.hist = append(z.hist, lits...)
+	z.repeats = rep
+	return nil
+}
+
+// copyMatch appends length bytes starting offset bytes back in z.hist.
+func (z *Reader) copyMatch(offset, length int) {
+	src := len(z.hist) - offset
+	for length > 0 {
+		n := length
+		if n > offset {
+			n = offset
+		}
+		z.hist = append(z.hist, z.hist[src:src+n]...)
+		src += n
+		length -= n
+	}
+}
+
+// readSeqTable returns the decoding table for one kind of sequence
+// symbol, as selected by mode, and the number of bytes of data consumed.
+func readSeqTable(data []byte, mode byte, prev, buf, predef []fseEntry, maxSym int, maxLog uint8) ([]fseEntry, int, error) {
+	switch mode {
+	case 0: // Predefined_Mode
+		return predef, 0, nil
+	case 1: // RLE_Mode
+		if len(data) == 0 {
+			return nil, 0, StructuralError("truncated sequences header")
+		}
+		if int(data[0]) > maxSym {
+			return nil, 0, StructuralError("invalid RLE sequence symbol")
+		}
+		return rleFSE(buf, data[0]), 1, nil
+	case 2: // FSE_Compressed_Mode
+		var norm [maxMLSymbol + 1]int16
+		tableLog, n, err := readNCount(data, norm[:maxSym+1], maxSym, maxLog)
+		if err != nil {
+			return nil, 0, err
+		}
+		t := buf[:1<<tableLog]
+		if err := buildFSE(t, norm[:maxSym+1], tableLog); err != nil {
+			return nil, 0, err
+		}
+		return t, n, nil
+	default: // Repeat_Mode
+		if prev == nil {
+			return nil, 0, StructuralError("repeated sequence table without a previous table")
+		}
+		return prev, 0, nil
+	}
+}
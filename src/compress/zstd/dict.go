@@ -0,0 +1,77 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+// A dictionary primes the decoder and encoder with history and, for
+// dictionaries in the Zstandard dictionary format (RFC 8878, section 5),
+// with entropy tables and repeat offsets. Any other byte slice is used
+// as a raw content dictionary, which has an ID of 0.
+type dictionary struct {
+	id      uint32
+	content []byte
+
+	// Only set for formatted dictionaries.
+	hasTables bool
+	huff      []huffEntry
+	huffBits  uint8
+	llTable   []fseEntry
+	mlTable   []fseEntry
+	ofTable   []fseEntry
+	repeats   [3]uint32
+}
+
+func parseDictionary(b []byte) (*dictionary, error) {
+	if len(b) < 8 || le.Uint32(b) != dictionaryMagic {
+		return &dictionary{content: b}, nil
+	}
+	d := &dictionary{id: le.Uint32(b[4:]), hasTables: true}
+	data := b[8:]
+
+	d.huff = make([]huffEntry, 1<<huffmanMaxBits)
+	huffBits, n, err := readHuffman(data, d.huff)
+	if err != nil {
+		return nil, err
+	}
+	d.huffBits = huffBits
+	data = data[n:]
+
+	// The FSE tables are stored in the order offsets,
+	// match lengths, literal lengths.
+	var norm [maxMLSymbol + 1]int16
+	readTable := func(maxSym int, maxLog uint8) ([]fseEntry, error) {
+		tableLog, n, err := readNCount(data, norm[:maxSym+1], maxSym, maxLog)
+		if err != nil {
+			return nil, err
+		}
+		t := make([]fseEntry, 1<<tableLog)
+		if err := buildFSE(t, norm[:maxSym+1], tableLog); err != nil {
+			return nil, err
+		}
+		data = data[n:]
+		return t, nil
+	}
+	if d.ofTable, err = readTable(maxOFSymbol, maxOFLog); err != nil {
+		return nil, err
+	}
+	if d.mlTable, err = readTable(maxMLSymbol, maxMLLog); err != nil {
+		return nil, err
+	}
+	if d.llTable, err = readTable(maxLLSymbol, maxLLLog); err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 {
+		return nil, StructuralError("truncated dictionary")
+	}
+	d.content = data[12:]
+	for i := range d.repeats {
+		r := le.Uint32(data[4*i:])
+		if r == 0 || r > uint32(len(d.content)) {
+			return nil, StructuralError("invalid dictionary repeat offset")
+		}
+		d.repeats[i] = r
+	}
+	return d, nil
+}
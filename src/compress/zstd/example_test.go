@@ -0,0 +1,60 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd_test
+
+import (
+	"bytes"
+	"compress/zstd"
+	"io"
+	"log"
+	"os"
+)
+
+func Example_writerReader() {
+	var buf bytes.Buffer
+	zw := zstd.NewWriter(&buf)
+	if _, err := zw.Write([]byte("A long time ago in a galaxy far, far away...")); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	zr := zstd.NewReader(&buf)
+	if _, err := io.Copy(os.Stdout, zr); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// A long time ago in a galaxy far, far away...
+}
+
+func ExampleNewWriterLevelDict() {
+	// Messages that share content with the dictionary compress well,
+	// even when they are short.
+	dict := []byte(`{"jsonrpc": "2.0", "method": "subtract", "params": [], "id": 1}`)
+	msg := []byte(`{"jsonrpc": "2.0", "method": "subtract", "params": [42, 23], "id": 1}`)
+
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriterLevelDict(&buf, zstd.BestCompression, dict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zw.Write(msg)
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	zr, err := zstd.NewReaderDict(&buf, dict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := io.Copy(os.Stdout, zr); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// {"jsonrpc": "2.0", "method": "subtract", "params": [42, 23], "id": 1}
+}
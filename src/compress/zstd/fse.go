@@ -0,0 +1,246 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// Finite State Entropy coding, as described in RFC 8878, section 4.1.
+
+// An fseEntry is one state of an FSE decoding table.
+type fseEntry struct {
+	sym  uint8  // symbol decoded in this state
+	bits uint8  // number of bits to read for the next state
+	base uint16 // added to those bits to form the next state
+}
+
+// readNCount reads an FSE table description from data. It stores the
+// normalized counts of symbols 0 through maxSym in norm and returns the
+// accuracy log and the number of bytes consumed.
+func readNCount(data []byte, norm []int16, maxSym int, maxLog uint8) (tableLog uint8, n int, err error) {
+	br := forwardBitReader{data: data}
+	accuracyLog := uint(br.val(4)) + 5
+	if accuracyLog > uint(maxLog) {
+		return 0, 0, StructuralError("FSE accuracy log too large")
+	}
+
+	remaining := (1 << accuracyLog) + 1
+	threshold := 1 << accuracyLog
+	nbits := accuracyLog + 1
+	sym := 0
+	for remaining > 1 && sym <= maxSym {
+		max := (2*threshold - 1) - remaining
+		var count int
+		if v := int(br.peek(nbits - 1)); v < max {
+			count = v
+			br.skip(nbits - 1)
+		} else {
+			count = int(br.peek(nbits))
+			if count >= threshold {
+				count -= max
+			}
+			br.skip(nbits)
+		}
+		count-- // a count of -1 means "less than 1"
+		if count >= 0 {
+			remaining -= count
+		} else {
+			remaining--
+		}
+		if remaining < 1 {
+			return 0, 0, StructuralError("invalid FSE distribution")
+		}
+		norm[sym] = int16(count)
+		sym++
+
+		if count == 0 {
+			// A zero count is followed by 2-bit repeat flags giving
+			// the number of additional zero counts.
+			for {
+				r := int(br.val(2))
+				for i := 0; i < r; i++ {
+					if sym > maxSym {
+						return 0, 0, StructuralError("FSE symbol overflow")
+					}
+					norm[sym] = 0
+					sym++
+				}
+				if r != 3 {
+					break
+				}
+			}
+		}
+
+		for remaining < threshold {
+			nbits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 {
+		return 0, 0, StructuralError("invalid FSE distribution")
+	}
+	for ; sym <= maxSym; sym++ {
+		norm[sym] = 0
+	}
+	if n = br.bytesRead(); n < 0 {
+		return 0, 0, StructuralError("truncated FSE table description")
+	}
+	return uint8(accuracyLog), n, nil
+}
+
+// spreadFSE assigns symbols to the states of a table of size 1<<tableLog.
+// Symbols with a count of -1 are placed in the highest states.
+func spreadFSE(symbols []uint8, norm []int16, tableLog uint8) error {
+	size := 1 << tableLog
+	mask := size - 1
+	high := size - 1
+	for s, c := range norm {
+		if c == -1 {
+			if high < 0 {
+				return StructuralError("invalid FSE distribution")
+			}
+			symbols[high] = uint8(s)
+			high--
+		}
+	}
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for s, c := range norm {
+		for i := 0; i < int(c); i++ {
+			symbols[pos] = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return StructuralError("invalid FSE distribution")
+	}
+	return nil
+}
+
+// buildFSE fills t, which must have 1<<tableLog entries, with the
+// decoding table for the normalized counts in norm.
+func buildFSE(t []fseEntry, norm []int16, tableLog uint8) error {
+	size := 1 << tableLog
+	var symbols [1 << maxMLLog]uint8
+	if err := spreadFSE(symbols[:size], norm, tableLog); err != nil {
+		return err
+	}
+	var next [256]uint16
+	for s, c := range norm {
+		if c == -1 {
+			next[s] = 1
+		} else {
+			next[s] = uint16(c)
+		}
+	}
+	for i := 0; i < size; i++ {
+		s := symbols[i]
+		state := next[s]
+		next[s]++
+		nb := tableLog - uint8(bits.Len16(state)-1)
+		t[i] = fseEntry{
+			sym:  s,
+			bits: nb,
+			base: uint16(int(state)<<nb - size),
+		}
+	}
+	return nil
+}
+
+// rleFSE returns a single-state table that always decodes sym.
+func rleFSE(t []fseEntry, sym uint8) []fseEntry {
+	t[0] = fseEntry{sym: sym}
+	return t[:1]
+}
+
+// An fseEncoder holds the encoding table for an FSE distribution.
+type fseEncoder struct {
+	tableLog uint8
+	states   []uint16         // next states, indexed by symbol-dependent offset
+	symTT    []fseSymbolTrans // per-symbol transformation
+}
+
+type fseSymbolTrans struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+// newFSEEncoder builds the encoding table for norm. The layout mirrors
+// the reference implementation so that it pairs with buildFSE.
+func newFSEEncoder(norm []int16, tableLog uint8) *fseEncoder {
+	size := 1 << tableLog
+	symbols := make([]uint8, size)
+	if err := spreadFSE(symbols, norm, tableLog); err != nil {
+		panic(err)
+	}
+	cumul := make([]int, len(norm)+1)
+	for s, c := range norm {
+		if c == -1 {
+			cumul[s+1] = cumul[s] + 1
+		} else {
+			cumul[s+1] = cumul[s] + int(c)
+		}
+	}
+	e := &fseEncoder{
+		tableLog: tableLog,
+		states:   make([]uint16, size),
+		symTT:    make([]fseSymbolTrans, len(norm)),
+	}
+	for u := 0; u < size; u++ {
+		s := symbols[u]
+		e.states[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+	total := 0
+	for s, c := range norm {
+		switch c {
+		case 0:
+			e.symTT[s].deltaNbBits = uint32(tableLog+1)<<16 - uint32(size)
+		case -1, 1:
+			e.symTT[s].deltaNbBits = uint32(tableLog)<<16 - uint32(size)
+			e.symTT[s].deltaFindState = int32(total - 1)
+			total++
+		default:
+			maxBitsOut := uint32(tableLog) - uint32(bits.Len16(uint16(c-1))-1)
+			minStatePlus := uint32(c) << maxBitsOut
+			e.symTT[s].deltaNbBits = maxBitsOut<<16 - minStatePlus
+			e.symTT[s].deltaFindState = int32(total - int(c))
+			total += int(c)
+		}
+	}
+	return e
+}
+
+// An fseState is the state of an FSE encoder.
+type fseState struct {
+	value uint32
+	enc   *fseEncoder
+}
+
+// init sets the state so that decoding it produces sym,
+// without emitting any bits.
+func (st *fseState) init(enc *fseEncoder, sym uint8) {
+	st.enc = enc
+	tt := enc.symTT[sym]
+	nbBitsOut := (tt.deltaNbBits + 1<<15) >> 16
+	v := nbBitsOut<<16 - tt.deltaNbBits
+	st.value = uint32(enc.states[int32(v>>nbBitsOut)+tt.deltaFindState])
+}
+
+// encode emits the bits that lead the decoder from the next state
+// to the current one and moves to a state that decodes sym.
+func (st *fseState) encode(bw *bitWriter, sym uint8) {
+	tt := st.enc.symTT[sym]
+	nbBitsOut := (st.value + tt.deltaNbBits) >> 16
+	bw.add(st.value, uint(nbBitsOut))
+	st.value = uint32(st.enc.states[int32(st.value>>nbBitsOut)+tt.deltaFindState])
+}
+
+// flush emits the final state, which the decoder reads first.
+func (st *fseState) flush(bw *bitWriter) {
+	bw.add(st.value, uint(st.enc.tableLog))
+}
@@ -0,0 +1,460 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// Huffman coding of literals, as described in RFC 8878, section 4.2.
+
+// A huffEntry is one entry of a Huffman decoding table. The table is
+// indexed by the next tableBits bits of the stream.
+type huffEntry struct {
+	sym  uint8
+	bits uint8 // length of the code for sym
+}
+
+// readHuffman reads a Huffman tree description from data, stores the
+// decoding table in t, which must have 1<<huffmanMaxBits entries, and
+// returns the table log and the number of bytes consumed.
+func readHuffman(data []byte, t []huffEntry) (tableBits uint8, n int, err error) {
+	if len(data) == 0 {
+		return 0, 0, StructuralError("missing Huffman tree description")
+	}
+	hdr := int(data[0])
+	var weights [huffmanMaxSymbols]uint8
+	count := 0
+	if hdr < 128 {
+		// The weights are compressed with FSE.
+		n = 1 + hdr
+		if n > len(data) {
+			return 0, 0, StructuralError("truncated Huffman weights")
+		}
+		if count, err = readHuffmanWeights(data[1:n], weights[:]); err != nil {
+			return 0, 0, err
+		}
+	} else {
+		// The weights are stored directly, 4 bits each.
+		count = hdr - 127
+		n = 1 + (count+1)/2
+		if n > len(data) {
+			return 0, 0, StructuralError("truncated Huffman weights")
+		}
+		for i := 0; i < count; i += 2 {
+			b := data[1+i/2]
+			weights[i] = b >> 4
+			weights[i+1] = b & 0xf
+		}
+	}
+
+	// The weight of the last symbol is implied by the others,
+	// as the weights must describe a complete prefix code.
+	var total uint32
+	for _, w := range weights[:count] {
+		if w > huffmanMaxBits {
+			return 0, 0, StructuralError("invalid Huffman weight")
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return 0, 0, StructuralError("invalid Huffman weights")
+	}
+	maxBits := uint8(bits.Len32(total))
+	if maxBits > huffmanMaxBits {
+		return 0, 0, StructuralError("Huffman table too large")
+	}
+	left := uint32(1)<<maxBits - total
+	if left&(left-1) != 0 {
+		return 0, 0, StructuralError("incomplete Huffman code")
+	}
+	weights[count] = uint8(bits.Len32(left))
+	count++
+
+	// Codes are assigned in order of increasing weight and,
+	// within a weight, increasing symbol value.
+	var start [huffmanMaxBits + 2]uint32
+	for _, w := range weights[:count] {
+		if w > 0 {
+			start[w] += 1 << (w - 1)
+		}
+	}
+	pos := uint32(0)
+	for w := uint8(1); w <= maxBits; w++ {
+		pos, start[w] = pos+start[w], pos
+	}
+	for s, w := range weights[:count] {
+		if w == 0 {
+			continue
+		}
+		e := huffEntry{sym: uint8(s), bits: maxBits + 1 - w}
+		end := start[w] + 1<<(w-1)
+		for i := start[w]; i < end; i++ {
+			t[i] = e
+		}
+		start[w] = end
+	}
+	return maxBits, n, nil
+}
+
+// readHuffmanWeights decodes FSE-compressed Huffman weights, which use
+// two interleaved states sharing one bitstream.
+func readHuffmanWeights(data []byte, weights []uint8) (int, error) {
+	var norm [huffmanMaxSymbols]int16
+	tableLog, n, err := readNCount(data, norm[:], huffmanMaxSymbols-1, 6)
+	if err != nil {
+		return 0, err
+	}
+	var t [1 << 6]fseEntry
+	if err := buildFSE(t[:1<<tableLog], norm[:], tableLog); err != nil {
+		return 0, err
+	}
+	br, err := newReverseBitReader(data[n:])
+	if err != nil {
+		return 0, err
+	}
+	s1, ok1 := br.val(uint(tableLog))
+	s2, ok2 := br.val(uint(tableLog))
+	if !ok1 || !ok2 {
+		return 0, StructuralError("truncated Huffman weights")
+	}
+	states := [2]uint32{s1, s2}
+	count := 0
+	for i := 0; ; i ^= 1 {
+		if count >= huffmanMaxSymbols-2 {
+			return 0, StructuralError("too many Huffman weights")
+		}
+		e := t[states[i]]
+		weights[count] = e.sym
+		count++
+		v, ok := br.val(uint(e.bits))
+		if !ok {
+			// The stream is exhausted: the other state
+			// holds the final weight.
+			weights[count] = t[states[i^1]].sym
+			count++
+			break
+		}
+		states[i] = uint32(e.base) + v
+	}
+	return count, nil
+}
+
+// decodeHuffman decodes len(dst) symbols from the bitstream src.
+func decodeHuffman(dst, src []byte, t []huffEntry, tableBits uint8) error {
+	br, err := newReverseBitReader(src)
+	if err != nil {
+		return err
+	}
+	for i := range dst {
+		e := t[br.peek(uint(tableBits))]
+		if !br.skip(uint(e.bits)) {
+			return StructuralError("truncated Huffman stream")
+		}
+		dst[i] = e.sym
+	}
+	if br.remaining() != 0 {
+		return StructuralError("trailing bits in Huffman stream")
+	}
+	return nil
+}
+
+// A huffEncoder holds a Huffman code for literals.
+type huffEncoder struct {
+	maxBits uint8
+	numSyms int // the highest coded symbol plus one
+	codes   [huffmanMaxSymbols]uint16
+	lens    [huffmanMaxSymbols]uint8
+}
+
+// init builds a length-limited Huffman code for the histogram hist.
+// It reports false if there are fewer than two distinct symbols.
+func (e *huffEncoder) init(hist *[huffmanMaxSymbols]int) bool {
+	type leaf struct {
+		freq int
+		sym  int
+	}
+	var leaves []leaf
+	for s, f := range hist {
+		if f > 0 {
+			leaves = append(leaves, leaf{f, s})
+			e.numSyms = s + 1
+		}
+	}
+	if len(leaves) < 2 {
+		return false
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].freq != leaves[j].freq {
+			return leaves[i].freq < leaves[j].freq
+		}
+		return leaves[i].sym < leaves[j].sym
+	})
+
+	// Build the tree with the two-queue method: leaves are consumed
+	// in order of frequency and internal nodes are created in order
+	// of frequency, so the smallest node is always at a queue head.
+	n := len(leaves)
+	freq := make([]int, 2*n-1)
+	parent := make([]int, 2*n-1)
+	for i, l := range leaves {
+		freq[i] = l.freq
+	}
+	li, ni := 0, n
+	pick := func(next int) int {
+		if li < n && (ni >= next || freq[li] <= freq[ni]) {
+			li++
+			return li - 1
+		}
+		ni++
+		return ni - 1
+	}
+	for next := n; next < 2*n-1; next++ {
+		a := pick(next)
+		b := pick(next)
+		freq[next] = freq[a] + freq[b]
+		parent[a], parent[b] = next, next
+	}
+	depth := make([]int, 2*n-1)
+	for i := 2*n - 3; i >= 0; i-- {
+		depth[i] = depth[parent[i]] + 1
+	}
+
+	// Limit the code lengths to huffmanMaxBits, then adjust them so
+	// that they describe a complete prefix code again.
+	const limit = huffmanMaxBits
+	lens := make([]int, n)
+	kraft := 0
+	for i := range leaves {
+		l := depth[i]
+		if l > limit {
+			l = limit
+		}
+		lens[i] = l
+		kraft += 1 << (limit - l)
+	}
+	// Leaves are in increasing order of frequency, so the first
+	// candidate found is the cheapest one to lengthen or shorten.
+	for kraft > 1<<limit {
+		best := -1
+		for i, l := range lens {
+			if l < limit && (best < 0 || l > lens[best]) {
+				best = i
+			}
+		}
+		kraft -= 1 << (limit - lens[best] - 1)
+		lens[best]++
+	}
+	for kraft < 1<<limit {
+		best := -1
+		for i := n - 1; i >= 0; i-- {
+			if best < 0 || lens[i] > lens[best] {
+				best = i
+			}
+		}
+		lens[best]--
+		kraft += 1 << (limit - lens[best] - 1)
+	}
+
+	e.maxBits = 0
+	for i := range e.lens {
+		e.lens[i] = 0
+	}
+	for i, l := range leaves {
+		e.lens[l.sym] = uint8(lens[i])
+		if uint8(lens[i]) > e.maxBits {
+			e.maxBits = uint8(lens[i])
+		}
+	}
+
+	// Assign codes in the order used by readHuffman.
+	pos := uint32(0)
+	for w := uint8(1); w <= e.maxBits; w++ {
+		for s := 0; s < e.numSyms; s++ {
+			if e.lens[s] == 0 || e.maxBits+1-e.lens[s] != w {
+				continue
+			}
+			e.codes[s] = uint16(pos >> (w - 1))
+			pos += 1 << (w - 1)
+		}
+	}
+	return true
+}
+
+// appendTree appends the tree description. It uses FSE-compressed
+// weights when possible and falls back to directly stored weights.
+// It reports false if neither representation applies.
+func (e *huffEncoder) appendTree(dst []byte) ([]byte, bool) {
+	numWeights := e.numSyms - 1 // the last weight is implied
+	var weights [huffmanMaxSymbols]uint8
+	for s := 0; s < numWeights; s++ {
+		if e.lens[s] != 0 {
+			weights[s] = e.maxBits + 1 - e.lens[s]
+		}
+	}
+	start := len(dst)
+	dst = append(dst, 0)
+	if b, ok := appendFSEWeights(dst, weights[:numWeights]); ok && len(b)-start-1 < 128 &&
+		(numWeights > 128 || len(b)-start-1 < (numWeights+1)/2) {
+		b[start] = byte(len(b) - start - 1)
+		return b, true
+	}
+	if numWeights > 128 {
+		return dst[:start], false
+	}
+	dst[start] = byte(127 + numWeights)
+	for s := 0; s < numWeights; s += 2 {
+		dst = append(dst, weights[s]<<4|weights[s+1])
+	}
+	return dst, true
+}
+
+// appendFSEWeights appends the Huffman weights compressed with FSE,
+// using two interleaved states as expected by readHuffmanWeights.
+func appendFSEWeights(dst []byte, weights []uint8) ([]byte, bool) {
+	if len(weights) < 2 {
+		return dst, false
+	}
+	const tableLog = 6
+	var counts [huffmanMaxBits + 1]int
+	for _, w := range weights {
+		counts[w]++
+	}
+	maxSym := 0
+	var norm [huffmanMaxBits + 1]int16
+	sum, largest := 0, 0
+	for w, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if c == len(weights) {
+			return dst, false // a single weight value cannot be FSE coded
+		}
+		n := c << tableLog / len(weights)
+		if n == 0 {
+			n = 1
+		}
+		norm[w] = int16(n)
+		sum += n
+		maxSym = w
+		if n > int(norm[largest]) {
+			largest = w
+		}
+	}
+	norm[largest] += int16(1<<tableLog - sum)
+	if norm[largest] < 1 {
+		return dst, false
+	}
+	start := len(dst)
+	dst = appendNCount(dst, norm[:maxSym+1], tableLog)
+
+	enc := newFSEEncoder(norm[:maxSym+1], tableLog)
+	bw := bitWriter{out: dst}
+	var s1, s2 fseState
+	i := len(weights)
+	if i&1 != 0 {
+		s1.init(enc, weights[i-1])
+		s2.init(enc, weights[i-2])
+		s1.encode(&bw, weights[i-3])
+		i -= 3
+	} else {
+		s2.init(enc, weights[i-1])
+		s1.init(enc, weights[i-2])
+		i -= 2
+	}
+	for i > 0 {
+		s2.encode(&bw, weights[i-1])
+		s1.encode(&bw, weights[i-2])
+		i -= 2
+	}
+	s2.flush(&bw)
+	s1.flush(&bw)
+	dst = bw.close()
+
+	// The decoder stops when the bitstream is exhausted, which is
+	// ambiguous for some final states. Make sure the weights
+	// decode as intended.
+	var check [huffmanMaxSymbols]uint8
+	n, err := readHuffmanWeights(dst[start:], check[:])
+	if err != nil || n != len(weights) || string(check[:n]) != string(weights) {
+		return dst[:start], false
+	}
+	return dst, true
+}
+
+// appendNCount appends the description of the FSE distribution norm,
+// the inverse of readNCount.
+func appendNCount(dst []byte, norm []int16, tableLog uint8) []byte {
+	var bits uint64
+	var nbits uint
+	put := func(v uint64, n uint) {
+		bits |= v << nbits
+		nbits += n
+		for nbits >= 8 {
+			dst = append(dst, byte(bits))
+			bits >>= 8
+			nbits -= 8
+		}
+	}
+	put(uint64(tableLog-5), 4)
+	remaining := 1<<tableLog + 1
+	threshold := 1 << tableLog
+	nb := uint(tableLog) + 1
+	prev0 := false
+	for sym := 0; sym < len(norm) && remaining > 1; {
+		if prev0 {
+			start := sym
+			for sym < len(norm) && norm[sym] == 0 {
+				sym++
+			}
+			for sym >= start+3 {
+				put(3, 2)
+				start += 3
+			}
+			put(uint64(sym-start), 2)
+		}
+		count := int(norm[sym])
+		sym++
+		max := (2*threshold - 1) - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		count++
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			put(uint64(count), nb-1)
+		} else {
+			put(uint64(count), nb)
+		}
+		prev0 = count == 1
+		for remaining < threshold {
+			nb--
+			threshold >>= 1
+		}
+	}
+	if nbits > 0 {
+		dst = append(dst, byte(bits))
+	}
+	return dst
+}
+
+// appendStream appends the Huffman coded bitstream for src.
+// The symbols are written in reverse so that the decoder,
+// which reads the stream backwards, produces them in order.
+func (e *huffEncoder) appendStream(dst, src []byte) []byte {
+	bw := bitWriter{out: dst}
+	for i := len(src) - 1; i >= 0; i-- {
+		s := src[i]
+		bw.add(uint32(e.codes[s]), uint(e.lens[s]))
+	}
+	return bw.close()
+}
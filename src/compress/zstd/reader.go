@@ -0,0 +1,337 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "io"
+
+// A Reader is an io.Reader that can be read to retrieve
+// uncompressed data from Zstandard compressed data.
+//
+// The compressed data may be a concatenation of frames. Reads from the
+// Reader return the concatenation of their uncompressed data, and
+// skippable frames are ignored.
+//
+// Frames may store a checksum of the uncompressed data. The Reader will
+// return an ErrChecksum when Read reaches the end of such a frame if the
+// data does not match. Clients should treat data returned by Read as
+// tentative until they receive the io.EOF marking the end of the data.
+type Reader struct {
+	r         io.Reader
+	dict      *dictionary
+	maxWindow int
+	err       error
+
+	// Current frame.
+	inFrame     bool
+	lastBlock   bool
+	hasChecksum bool
+	windowSize  int
+	blockMax    int
+	contentSize int64 // -1 if unknown
+	produced    int64
+	digest      xxhash64
+
+	// hist holds the most recent window of decompressed data.
+	// Data in hist[out:] has not yet been returned by Read.
+	hist []byte
+	out  int
+
+	// Entropy tables and repeat offsets carried from block to block.
+	huff     []huffEntry
+	huffBits uint8
+	llTable  []fseEntry
+	mlTable  []fseEntry
+	ofTable  []fseEntry
+	repeats  [3]uint32
+
+	huffBuf [1 << huffmanMaxBits]huffEntry
+	llBuf   [1 << maxLLLog]fseEntry
+	mlBuf   [1 << maxMLLog]fseEntry
+	ofBuf   [1 << maxOFLog]fseEntry
+
+	block    []byte
+	literals []byte
+	buf      [14]byte // large enough for any frame header
+}
+
+// NewReader creates a new Reader reading the given reader.
+// The Reader never reads past the end of the last frame it decodes.
+func NewReader(r io.Reader) *Reader {
+	z := &Reader{maxWindow: DefaultMaxWindowSize}
+	z.Reset(r)
+	return z
+}
+
+// NewReaderDict is like NewReader but uses a dictionary. The dictionary
+// may be in the Zstandard dictionary format, in which case frames that
+// name a different dictionary ID are rejected with ErrDictionary, or it
+// may be arbitrary data that was used as a raw content dictionary by the
+// compressor.
+func NewReaderDict(r io.Reader, dict []byte) (*Reader, error) {
+	d, err := parseDictionary(dict)
+	if err != nil {
+		return nil, err
+	}
+	z := &Reader{dict: d, maxWindow: DefaultMaxWindowSize}
+	z.Reset(r)
+	return z, nil
+}
+
+// Reset discards the Reader z's state and makes it equivalent to the
+// result of its original state from NewReader or NewReaderDict, but
+// reading from r instead. The dictionary and the maximum window size
+// are retained. This permits reusing a Reader rather than allocating
+// a new one.
+func (z *Reader) Reset(r io.Reader) {
+	z.r = r
+	z.err = nil
+	z.inFrame = false
+	z.hist = z.hist[:0]
+	z.out = 0
+}
+
+// SetMaxWindowSize sets the largest window size, in bytes, that z
+// accepts. Frames that need a larger window fail with ErrWindowSize.
+// The memory used by z is proportional to the window size of the
+// frame being decompressed.
+func (z *Reader) SetMaxWindowSize(size int) {
+	z.maxWindow = size
+}
+
+// Read implements io.Reader, reading uncompressed bytes from its underlying Reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if z.out < len(z.hist) {
+			n := copy(p, z.hist[z.out:])
+			z.out += n
+			return n, nil
+		}
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.step()
+	}
+}
+
+// step decodes the next frame header, block or frame trailer.
+func (z *Reader) step() error {
+	switch {
+	case !z.inFrame:
+		return z.readFrameHeader()
+	case z.lastBlock:
+		return z.readFrameTrailer()
+	default:
+		return z.readBlock()
+	}
+}
+
+func (z *Reader) readFrameHeader() error {
+	for {
+		if _, err := io.ReadFull(z.r, z.buf[:4]); err != nil {
+			return err // io.EOF at a frame boundary ends the stream
+		}
+		magic := le.Uint32(z.buf[:4])
+		if magic == frameMagic {
+			break
+		}
+		if magic&skippableMask != skippableMagic {
+			return ErrHeader
+		}
+		if _, err := io.ReadFull(z.r, z.buf[:4]); err != nil {
+			return noEOF(err)
+		}
+		if err := z.skip(int64(le.Uint32(z.buf[:4]))); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.ReadFull(z.r, z.buf[:1]); err != nil {
+		return noEOF(err)
+	}
+	desc := z.buf[0]
+	if desc&0x08 != 0 {
+		return ErrHeader // reserved bit
+	}
+	single := desc&0x20 != 0
+	fcsSize := [4]int{0, 2, 4, 8}[desc>>6]
+	if fcsSize == 0 && single {
+		fcsSize = 1
+	}
+	dictSize := [4]int{0, 1, 2, 4}[desc&3]
+	n := fcsSize + dictSize
+	if !single {
+		n++
+	}
+	hdr := z.buf[:n]
+	if _, err := io.ReadFull(z.r, hdr); err != nil {
+		return noEOF(err)
+	}
+
+	var windowSize uint64
+	if !single {
+		exp, mant := hdr[0]>>3, hdr[0]&7
+		windowLog := minWindowLog + uint(exp)
+		if windowLog > maxWindowLog {
+			return ErrWindowSize
+		}
+		base := uint64(1) << windowLog
+		windowSize = base + base/8*uint64(mant)
+		hdr = hdr[1:]
+	}
+	var dictID uint32
+	switch dictSize {
+	case 1:
+		dictID = uint32(hdr[0])
+	case 2:
+		dictID = uint32(le.Uint16(hdr))
+	case 4:
+		dictID = le.Uint32(hdr)
+	}
+	hdr = hdr[dictSize:]
+	z.contentSize = -1
+	switch fcsSize {
+	case 1:
+		z.contentSize = int64(hdr[0])
+	case 2:
+		z.contentSize = int64(le.Uint16(hdr)) + 256
+	case 4:
+		z.contentSize = int64(le.Uint32(hdr))
+	case 8:
+		z.contentSize = int64(le.Uint64(hdr))
+		if z.contentSize < 0 {
+			return ErrWindowSize
+		}
+	}
+	if single {
+		windowSize = uint64(z.contentSize)
+	}
+	if windowSize > uint64(z.maxWindow) {
+		return ErrWindowSize
+	}
+	if dictID != 0 && (z.dict == nil || z.dict.id != dictID) {
+		return ErrDictionary
+	}
+
+	z.inFrame = true
+	z.lastBlock = false
+	z.hasChecksum = desc&0x04 != 0
+	z.windowSize = int(windowSize)
+	z.blockMax = z.windowSize
+	if z.blockMax > maxBlockSize {
+		z.blockMax = maxBlockSize
+	}
+	z.produced = 0
+	z.digest.reset()
+
+	z.hist = z.hist[:0]
+	z.huff = nil
+	z.llTable, z.mlTable, z.ofTable = nil, nil, nil
+	z.repeats = [3]uint32{1, 4, 8}
+	if d := z.dict; d != nil {
+		z.hist = append(z.hist, d.content...)
+		if d.hasTables {
+			z.huff, z.huffBits = d.huff, d.huffBits
+			z.llTable, z.mlTable, z.ofTable = d.llTable, d.mlTable, d.ofTable
+			z.repeats = d.repeats
+		}
+	}
+	z.out = len(z.hist)
+	return nil
+}
+
+func (z *Reader) readFrameTrailer() error {
+	if z.hasChecksum {
+		if _, err := io.ReadFull(z.r, z.buf[:4]); err != nil {
+			return noEOF(err)
+		}
+		if le.Uint32(z.buf[:4]) != uint32(z.digest.sum64()) {
+			return ErrChecksum
+		}
+	}
+	if z.contentSize >= 0 && z.produced != z.contentSize {
+		return StructuralError("frame content size mismatch")
+	}
+	z.inFrame = false
+	return nil
+}
+
+// skip discards n bytes of input.
+func (z *Reader) skip(n int64) error {
+	if len(z.block) < 4096 {
+		z.block = make([]byte, 4096)
+	}
+	for n > 0 {
+		b := z.block
+		if int64(len(b)) > n {
+			b = b[:n]
+		}
+		m, err := io.ReadFull(z.r, b)
+		n -= int64(m)
+		if err != nil {
+			return noEOF(err)
+		}
+	}
+	return nil
+}
+
+func (z *Reader) readBlock() error {
+	if _, err := io.ReadFull(z.r, z.buf[:3]); err != nil {
+		return noEOF(err)
+	}
+	h := uint32(z.buf[0]) | uint32(z.buf[1])<<8 | uint32(z.buf[2])<<16
+	last := h&1 != 0
+	size := int(h >> 3)
+	if size > z.blockMax {
+		return StructuralError("block too large")
+	}
+
+	// All previous output has been returned by Read, so the
+	// history can be trimmed to the window.
+	if n := len(z.hist) - z.windowSize; n > 0 && n >= z.windowSize {
+		z.hist = z.hist[:copy(z.hist, z.hist[n:])]
+		z.out = len(z.hist)
+	}
+	start := len(z.hist)
+
+	switch (h >> 1) & 3 {
+	case 0: // Raw_Block
+		z.hist = append(z.hist, make([]byte, size)...)
+		if _, err := io.ReadFull(z.r, z.hist[start:]); err != nil {
+			return noEOF(err)
+		}
+	case 1: // RLE_Block
+		if _, err := io.ReadFull(z.r, z.buf[:1]); err != nil {
+			return noEOF(err)
+		}
+		for i := 0; i < size; i++ {
+			z.hist = append(z.hist, z.buf[0])
+		}
+	case 2: // Compressed_Block
+		if cap(z.block) < size {
+			z.block = make([]byte, size)
+		}
+		b := z.block[:size]
+		if _, err := io.ReadFull(z.r, b); err != nil {
+			return noEOF(err)
+		}
+		if err := z.decompressBlock(b); err != nil {
+			return err
+		}
+	default:
+		return StructuralError("reserved block type")
+	}
+
+	out := z.hist[start:]
+	z.digest.write(out)
+	z.produced += int64(len(out))
+	if z.contentSize >= 0 && z.produced > z.contentSize {
+		return StructuralError("frame content size mismatch")
+	}
+	z.lastBlock = last
+	return nil
+}
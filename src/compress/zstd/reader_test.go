@@ -0,0 +1,172 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// The files in testdata were produced by the reference implementation:
+//
+//	zstd -19 --check ../testdata/gettysburg.txt -o gettysburg.txt.zst
+//	zstd -3 --no-check ../testdata/e.txt -o e.txt.zst
+//	zstd -19 --check -D gettysburg.dict ../testdata/gettysburg.txt -o gettysburg.dict.zst
+//
+// gettysburg.dict was made with zstd --train on excerpts of gettysburg.txt.
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+var readerTests = []struct {
+	name string
+	raw  string
+	dict string
+}{
+	{"gettysburg.txt.zst", "../testdata/gettysburg.txt", ""},
+	{"e.txt.zst", "../testdata/e.txt", ""},
+	{"gettysburg.dict.zst", "../testdata/gettysburg.txt", "gettysburg.dict"},
+}
+
+func TestReader(t *testing.T) {
+	for _, tt := range readerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := readFile(t, "testdata/"+tt.name)
+			want := readFile(t, tt.raw)
+			var z *Reader
+			if tt.dict == "" {
+				z = NewReader(bytes.NewReader(compressed))
+			} else {
+				var err error
+				z, err = NewReaderDict(bytes.NewReader(compressed), readFile(t, "testdata/"+tt.dict))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := ioutil.ReadAll(z)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("got %d bytes, want %d bytes matching %s", len(got), len(want), tt.raw)
+			}
+		})
+	}
+}
+
+func TestReaderMissingDictionary(t *testing.T) {
+	z := NewReader(bytes.NewReader(readFile(t, "testdata/gettysburg.dict.zst")))
+	if _, err := ioutil.ReadAll(z); err != ErrDictionary {
+		t.Fatalf("got error %v, want %v", err, ErrDictionary)
+	}
+}
+
+func TestReaderConcatenated(t *testing.T) {
+	g := readFile(t, "testdata/gettysburg.txt.zst")
+	var in bytes.Buffer
+	// A skippable frame carrying user data, followed by two frames.
+	var hdr [8]byte
+	binary.LittleEndian.PutUint32(hdr[:4], skippableMagic|7)
+	binary.LittleEndian.PutUint32(hdr[4:], 5)
+	in.Write(hdr[:])
+	in.WriteString("hello")
+	in.Write(g)
+	in.Write(g)
+
+	got, err := ioutil.ReadAll(NewReader(&in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := readFile(t, "../testdata/gettysburg.txt")
+	want = append(want, want...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestReaderChecksum(t *testing.T) {
+	b := readFile(t, "testdata/gettysburg.txt.zst")
+	b[len(b)-1] ^= 1
+	if _, err := ioutil.ReadAll(NewReader(bytes.NewReader(b))); err != ErrChecksum {
+		t.Fatalf("got error %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	b := readFile(t, "testdata/gettysburg.txt.zst")
+	for _, n := range []int{0, 1, 4, 6, 20, len(b) / 2, len(b) - 1} {
+		_, err := ioutil.ReadAll(NewReader(bytes.NewReader(b[:n])))
+		if n == 0 {
+			if err != nil {
+				t.Errorf("empty input: got error %v, want nil", err)
+			}
+			continue
+		}
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("truncated to %d bytes: got error %v, want %v", n, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestReaderHeader(t *testing.T) {
+	_, err := ioutil.ReadAll(NewReader(bytes.NewReader([]byte("not zstd data"))))
+	if err != ErrHeader {
+		t.Fatalf("got error %v, want %v", err, ErrHeader)
+	}
+}
+
+func TestReaderMaxWindowSize(t *testing.T) {
+	b := readFile(t, "testdata/e.txt.zst")
+	z := NewReader(bytes.NewReader(b))
+	z.SetMaxWindowSize(1 << 10)
+	if _, err := ioutil.ReadAll(z); err != ErrWindowSize {
+		t.Fatalf("got error %v, want %v", err, ErrWindowSize)
+	}
+
+	// The limit is retained across Reset.
+	z.Reset(bytes.NewReader(b))
+	if _, err := ioutil.ReadAll(z); err != ErrWindowSize {
+		t.Fatalf("after Reset: got error %v, want %v", err, ErrWindowSize)
+	}
+
+	z.SetMaxWindowSize(DefaultMaxWindowSize)
+	z.Reset(bytes.NewReader(b))
+	got, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := readFile(t, "../testdata/e.txt"); !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestXXHash(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	}
+	for _, tt := range tests {
+		var d xxhash64
+		d.reset()
+		d.write([]byte(tt.in))
+		if got := d.sum64(); got != tt.want {
+			t.Errorf("xxhash64(%q) = %#x, want %#x", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,733 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// Compression levels. Levels between BestSpeed and BestCompression
+// search increasingly hard for matches; they do not correspond to the
+// numbered levels of the reference implementation.
+const (
+	NoCompression      = 0
+	BestSpeed          = 1
+	BestCompression    = 9
+	DefaultCompression = -1
+)
+
+const defaultLevel = 3
+
+// levels holds the match finder parameters for each compression level.
+var levels = [BestCompression + 1]struct {
+	depth int  // maximum number of candidates examined per position
+	lazy  bool // whether to look for a longer match at the next position
+}{
+	1: {1, false},
+	2: {2, false},
+	3: {4, false},
+	4: {8, true},
+	5: {16, true},
+	6: {32, true},
+	7: {64, true},
+	8: {128, true},
+	9: {256, true},
+}
+
+const (
+	writerWindowLog = 20
+	hashLog         = 17
+	minMatch        = 4
+)
+
+// A Writer takes data written to it and writes the compressed
+// form of that data to an underlying writer (see NewWriter).
+//
+// The Writer produces a single frame, which carries a checksum of the
+// uncompressed data.
+type Writer struct {
+	w           io.Writer
+	level       int
+	dict        *dictionary
+	err         error
+	wroteHeader bool
+	closed      bool
+	digest      xxhash64
+	windowLog   uint
+
+	// hist holds the window of previously compressed data,
+	// followed by the data of the block being collected,
+	// which starts at hist[start].
+	hist  []byte
+	start int
+
+	// Match finder state. Positions are indexes into hist.
+	table []int32 // most recent position for each hash
+	chain []int32 // previous position with the same hash, indexed by position modulo the window size
+	ins   int     // next position to insert into table
+
+	seqs    []sequence
+	lits    []byte
+	reps    [3]uint32 // repeat offsets as seen by the decoder
+	litCost [256]int  // estimated cost of each literal, in 1/16 bits
+	out     []byte
+	scratch []byte
+	huff    huffEncoder
+}
+
+// A sequence copies litLen literals followed by a match of matchLen
+// bytes. An offVal of 1 to 3 selects a repeat offset; larger values
+// are the distance of the match plus 3.
+type sequence struct {
+	litLen   uint32
+	matchLen uint32
+	offVal   uint32
+}
+
+// NewWriter creates a new Writer.
+// Writes to the returned Writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the Writer when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevelDict(w, DefaultCompression, nil)
+	return z
+}
+
+// NewWriterLevel is like NewWriter but specifies the compression level instead
+// of assuming DefaultCompression.
+//
+// The compression level can be DefaultCompression, NoCompression,
+// or any integer value between BestSpeed and BestCompression inclusive.
+// The error returned will be nil if the level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	return NewWriterLevelDict(w, level, nil)
+}
+
+// NewWriterLevelDict is like NewWriterLevel but specifies a dictionary to
+// compress with. The dictionary may be in the Zstandard dictionary format,
+// in which case its ID is recorded in the frame header, or it may be
+// arbitrary data to be used as a raw content dictionary. Only the content
+// of the dictionary is used for compression.
+//
+// The same dictionary must be passed to NewReaderDict to decompress the data.
+func NewWriterLevelDict(w io.Writer, level int, dict []byte) (*Writer, error) {
+	if level == DefaultCompression {
+		level = defaultLevel
+	}
+	if level < NoCompression || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	var d *dictionary
+	if dict != nil {
+		var err error
+		if d, err = parseDictionary(dict); err != nil {
+			return nil, err
+		}
+	}
+	z := &Writer{level: level, dict: d, windowLog: writerWindowLog}
+	if level == NoCompression {
+		z.windowLog = 17
+	} else {
+		z.table = make([]int32, 1<<hashLog)
+		if levels[level].depth > 1 {
+			z.chain = make([]int32, 1<<z.windowLog)
+		}
+	}
+	z.Reset(w)
+	return z, nil
+}
+
+// Reset discards the Writer z's state and makes it equivalent to the
+// result of its original state from NewWriter or NewWriterLevelDict, but
+// writing to w instead. This permits reusing a Writer rather than
+// allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.w = w
+	z.err = nil
+	z.wroteHeader = false
+	z.closed = false
+	z.digest.reset()
+	z.hist = z.hist[:0]
+	if z.dict != nil && z.level != NoCompression {
+		content := z.dict.content
+		if max := 1 << z.windowLog; len(content) > max {
+			content = content[len(content)-max:]
+		}
+		z.hist = append(z.hist, content...)
+	}
+	z.start = len(z.hist)
+	z.ins = 0
+	z.reps = [3]uint32{1, 4, 8}
+	if z.dict != nil && z.dict.hasTables {
+		z.reps = z.dict.repeats
+	}
+	for i := range z.table {
+		z.table[i] = -1
+	}
+}
+
+// Write writes a compressed form of p to the underlying io.Writer. The
+// compressed bytes are not necessarily flushed until the Writer is closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errClosed
+	}
+	n := 0
+	for len(p) > 0 {
+		// Compress a full block only once more data arrives,
+		// so that Close can mark the final block as the last one.
+		if len(z.hist)-z.start == maxBlockSize {
+			if z.err = z.writeBlock(false); z.err != nil {
+				return n, z.err
+			}
+		}
+		m := maxBlockSize - (len(z.hist) - z.start)
+		if m > len(p) {
+			m = len(p)
+		}
+		z.hist = append(z.hist, p[:m]...)
+		z.digest.write(p[:m])
+		n += m
+		p = p[m:]
+	}
+	return n, nil
+}
+
+var errClosed = fmt.Errorf("zstd: write to closed Writer")
+
+// Flush compresses any pending data and writes it to the underlying writer.
+// It is useful mainly in compressed network protocols, to ensure that
+// a remote reader has enough data to reconstruct a packet.
+// Flush does not return until the data has been written.
+// If the underlying writer returns an error, Flush returns that error.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	if !z.wroteHeader {
+		z.err = z.writeFrameHeader(false)
+	}
+	if z.err == nil && len(z.hist) > z.start {
+		z.err = z.writeBlock(false)
+	}
+	return z.err
+}
+
+// Close closes the Writer by flushing any unwritten data to the underlying
+// io.Writer and writing the frame trailer.
+// It does not close the underlying io.Writer.
+func (z *Writer) Close() error {
+	if z.err != nil || z.closed {
+		return z.err
+	}
+	z.closed = true
+	if z.err = z.writeBlock(true); z.err != nil {
+		return z.err
+	}
+	var b [4]byte
+	le.PutUint32(b[:], uint32(z.digest.sum64()))
+	_, z.err = z.w.Write(b[:])
+	return z.err
+}
+
+// writeFrameHeader writes the frame header. If only is set, the pending
+// block is the entire content of the frame, so its size is recorded and
+// the frame is marked as a single segment that needs no separate window.
+func (z *Writer) writeFrameHeader(only bool) error {
+	z.wroteHeader = true
+	b := append(z.out[:0], 0, 0, 0, 0, 0)
+	le.PutUint32(b, frameMagic)
+	desc := byte(0x04) // content checksum
+	if only {
+		desc |= 0x20
+	} else {
+		b = append(b, byte(z.windowLog-minWindowLog)<<3)
+	}
+	if z.dict != nil && z.dict.id != 0 {
+		id := z.dict.id
+		switch {
+		case id < 1<<8:
+			desc |= 1
+			b = append(b, byte(id))
+		case id < 1<<16:
+			desc |= 2
+			b = append(b, byte(id), byte(id>>8))
+		default:
+			desc |= 3
+			b = append(b, byte(id), byte(id>>8), byte(id>>16), byte(id>>24))
+		}
+	}
+	if only {
+		size := len(z.hist) - z.start
+		switch {
+		case size < 256:
+			b = append(b, byte(size))
+		case size < 256+1<<16:
+			desc |= 1 << 6
+			b = append(b, byte(size-256), byte((size-256)>>8))
+		default:
+			desc |= 2 << 6
+			b = append(b, byte(size), byte(size>>8), byte(size>>16), byte(size>>24))
+		}
+	}
+	b[4] = desc
+	z.out = b
+	_, err := z.w.Write(b)
+	return err
+}
+
+// writeBlock compresses the pending data as one block and writes it.
+func (z *Writer) writeBlock(last bool) error {
+	if !z.wroteHeader {
+		if err := z.writeFrameHeader(last); err != nil {
+			return err
+		}
+	}
+	z.out = z.appendBlock(z.out[:0], z.hist[z.start:], last)
+	if _, err := z.w.Write(z.out); err != nil {
+		return err
+	}
+	z.start = len(z.hist)
+	z.slide()
+	return nil
+}
+
+// slide discards history that has fallen out of the window. It moves the
+// data by a multiple of the window size, so that positions keep their
+// slot in the chain.
+func (z *Writer) slide() {
+	window := 1 << z.windowLog
+	if z.start < 2*window {
+		return
+	}
+	delta := (z.start - window) &^ (window - 1)
+	z.hist = z.hist[:copy(z.hist, z.hist[delta:])]
+	z.start -= delta
+	z.ins -= delta
+	rebase := func(t []int32) {
+		for i, v := range t {
+			if v -= int32(delta); v < 0 {
+				v = -1
+			}
+			t[i] = v
+		}
+	}
+	rebase(z.table)
+	rebase(z.chain)
+}
+
+func blockHeader(dst []byte, typ byte, size int, last bool) []byte {
+	h := uint32(size)<<3 | uint32(typ)<<1
+	if last {
+		h |= 1
+	}
+	return append(dst, byte(h), byte(h>>8), byte(h>>16))
+}
+
+// appendBlock appends block, including its header, to dst. It falls back
+// to a raw or RLE block when compression does not help.
+func (z *Writer) appendBlock(dst, block []byte, last bool) []byte {
+	if z.level != NoCompression && len(block) > 0 {
+		rle := true
+		for _, c := range block[1:] {
+			if c != block[0] {
+				rle = false
+				break
+			}
+		}
+		if rle && len(block) > 1 {
+			dst = blockHeader(dst, 1, len(block), last)
+			return append(dst, block[0])
+		}
+
+		reps := z.parse()
+		n := len(dst)
+		dst = append(dst, 0, 0, 0)
+		dst = z.appendLiterals(dst, z.lits)
+		dst = z.appendSequences(dst, z.seqs)
+		if size := len(dst) - n - 3; size < len(block) {
+			blockHeader(dst[:n], 2, size, last) // fill in the placeholder
+			z.reps = reps
+			return dst
+		}
+		dst = dst[:n]
+	}
+	dst = blockHeader(dst, 0, len(block), last)
+	return append(dst, block...)
+}
+
+func hash4(b []byte) uint32 {
+	return (le.Uint32(b) * 2654435761) >> (32 - hashLog)
+}
+
+// insertTo adds the positions up to and including p to the match finder.
+func (z *Writer) insertTo(p int) {
+	mask := 1<<z.windowLog - 1
+	for ; z.ins <= p && z.ins+minMatch <= len(z.hist); z.ins++ {
+		h := hash4(z.hist[z.ins:])
+		if z.chain != nil {
+			z.chain[z.ins&mask] = z.table[h]
+		}
+		z.table[h] = int32(z.ins)
+	}
+}
+
+// findMatch returns the longest match for the data at position i,
+// which must not have been inserted yet. It also tries the most
+// recent offset, rep, which is the cheapest one to encode.
+func (z *Writer) findMatch(i int, rep uint32) (offset, length int) {
+	window := 1 << z.windowLog
+	mask := window - 1
+	cur := z.hist[i:]
+	if r := int(rep); r <= i {
+		if l := matchLen(z.hist[i-r:], cur); l >= minMatch {
+			offset, length = r, l
+		}
+	}
+	cand := int(z.table[hash4(cur)])
+	for d := levels[z.level].depth; d > 0 && cand >= 0 && i-cand < window; d-- {
+		if length < len(cur) && z.hist[cand+length] == cur[length] {
+			if l := matchLen(z.hist[cand:], cur); l > length {
+				offset, length = i-cand, l
+				if l == len(cur) {
+					break
+				}
+			}
+		}
+		if z.chain == nil {
+			break
+		}
+		next := int(z.chain[cand&mask])
+		if next >= cand {
+			break
+		}
+		cand = next
+	}
+	return offset, length
+}
+
+// matchLen returns the length of the common prefix of a and b,
+// where len(a) >= len(b).
+func matchLen(a, b []byte) int {
+	n := 0
+	for len(b)-n >= 8 {
+		if x := le.Uint64(a[n:]) ^ le.Uint64(b[n:]); x != 0 {
+			return n + bits.TrailingZeros64(x)>>3
+		}
+		n += 8
+	}
+	for n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// parse splits the pending block into sequences and literals.
+// It returns the repeat offsets the decoder will have after the block.
+func (z *Writer) parse() [3]uint32 {
+	z.seqs = z.seqs[:0]
+	z.lits = z.lits[:0]
+	hist := z.hist
+	z.estimateLiteralCost(hist[z.start:])
+	reps := z.reps
+	lazy := levels[z.level].lazy
+	lit := z.start
+	for i := z.start; i+minMatch <= len(hist); {
+		offset, length := z.findMatch(i, reps[0])
+		z.insertTo(i)
+		if length >= minMatch && lazy && i+1+minMatch <= len(hist) {
+			if o, l := z.findMatch(i+1, reps[0]); l > length {
+				z.insertTo(i + 1)
+				i++
+				offset, length = o, l
+			}
+		}
+		if length < minMatch || !z.worthMatch(hist[i:i+length], uint32(offset), &reps) {
+			i++
+			continue
+		}
+		z.seqs = append(z.seqs, sequence{
+			litLen:   uint32(i - lit),
+			matchLen: uint32(length),
+			offVal:   encodeOffset(&reps, uint32(offset), i-lit),
+		})
+		z.lits = append(z.lits, hist[lit:i]...)
+		i += length
+		lit = i
+		if levels[z.level].depth > 1 {
+			z.insertTo(i - 1)
+		} else if z.ins < i {
+			z.ins = i
+		}
+	}
+	z.lits = append(z.lits, hist[lit:]...)
+	return reps
+}
+
+// estimateLiteralCost sets z.litCost from the byte frequencies in block.
+func (z *Writer) estimateLiteralCost(block []byte) {
+	var hist [256]int
+	for _, c := range block {
+		hist[c]++
+	}
+	for c, n := range hist {
+		cost := 16 * 8
+		if n > 0 {
+			cost = int(16 * math.Log2(float64(len(block))/float64(n)))
+			if cost < 16 {
+				cost = 16
+			}
+		}
+		z.litCost[c] = cost
+	}
+}
+
+// worthMatch reports whether encoding match as a match is likely to be
+// cheaper than encoding its bytes as literals.
+func (z *Writer) worthMatch(match []byte, offset uint32, reps *[3]uint32) bool {
+	if len(match) >= 32 {
+		return true
+	}
+	cost := 16 * 6
+	if offset != reps[0] && offset != reps[1] && offset != reps[2] {
+		cost = 16 * (bits.Len32(offset+3) + 8)
+	}
+	lits := 0
+	for _, c := range match {
+		lits += z.litCost[c]
+	}
+	return lits > cost
+}
+
+// encodeOffset returns the offset value for a match at offset following
+// litLen literals and updates the repeat offsets accordingly.
+func encodeOffset(reps *[3]uint32, offset uint32, litLen int) uint32 {
+	if litLen > 0 {
+		switch offset {
+		case reps[0]:
+			return 1
+		case reps[1]:
+			reps[0], reps[1] = offset, reps[0]
+			return 2
+		case reps[2]:
+			reps[0], reps[1], reps[2] = offset, reps[0], reps[1]
+			return 3
+		}
+	} else {
+		// Without literals, the repeat codes are shifted by one.
+		switch offset {
+		case reps[1]:
+			reps[0], reps[1] = offset, reps[0]
+			return 1
+		case reps[2]:
+			reps[0], reps[1], reps[2] = offset, reps[0], reps[1]
+			return 2
+		case reps[0] - 1:
+			reps[0], reps[1], reps[2] = offset, reps[0], reps[1]
+			return 3
+		}
+	}
+	reps[0], reps[1], reps[2] = offset, reps[0], reps[1]
+	return offset + 3
+}
+
+func rawLiteralsHeader(dst []byte, typ byte, n int) []byte {
+	switch {
+	case n < 1<<5:
+		return append(dst, typ|byte(n)<<3)
+	case n < 1<<12:
+		return append(dst, typ|1<<2|byte(n)<<4, byte(n>>4))
+	default:
+		return append(dst, typ|3<<2|byte(n)<<4, byte(n>>4), byte(n>>12))
+	}
+}
+
+// appendLiterals appends the literals section for lits.
+func (z *Writer) appendLiterals(dst, lits []byte) []byte {
+	n := len(lits)
+	if n > 1 {
+		rle := true
+		for _, c := range lits[1:] {
+			if c != lits[0] {
+				rle = false
+				break
+			}
+		}
+		if rle {
+			return append(rawLiteralsHeader(dst, 1, n), lits[0])
+		}
+	}
+	if n >= 64 {
+		if b, ok := z.huffLiterals(dst, lits); ok {
+			return b
+		}
+	}
+	return append(rawLiteralsHeader(dst, 0, n), lits...)
+}
+
+// huffLiterals appends lits as a Huffman compressed literals section.
+// It reports false if that would not be smaller than raw literals.
+func (z *Writer) huffLiterals(dst, lits []byte) ([]byte, bool) {
+	var hist [huffmanMaxSymbols]int
+	for _, c := range lits {
+		hist[c]++
+	}
+	if !z.huff.init(&hist) {
+		return dst, false
+	}
+	n := len(lits)
+	body, ok := z.huff.appendTree(z.scratch[:0])
+	if !ok {
+		return dst, false
+	}
+	single := n < 1<<10
+	if single {
+		body = z.huff.appendStream(body, lits)
+	} else {
+		jump := len(body)
+		body = append(body, 0, 0, 0, 0, 0, 0)
+		seg := (n + 3) / 4
+		for i := 0; i < 4; i++ {
+			end := (i + 1) * seg
+			if end > n {
+				end = n
+			}
+			before := len(body)
+			body = z.huff.appendStream(body, lits[i*seg:end])
+			if i < 3 {
+				size := len(body) - before
+				if size > 0xffff {
+					z.scratch = body
+					return dst, false
+				}
+				le.PutUint16(body[jump+2*i:], uint16(size))
+			}
+		}
+	}
+	z.scratch = body
+	comp := len(body)
+
+	max := n
+	if comp > max {
+		max = comp
+	}
+	var sizeFormat, shift, hdr uint
+	switch {
+	case single:
+		if comp >= 1<<10 {
+			return dst, false
+		}
+		sizeFormat, shift, hdr = 0, 14, 3
+	case max < 1<<10:
+		sizeFormat, shift, hdr = 1, 14, 3
+	case max < 1<<14:
+		sizeFormat, shift, hdr = 2, 18, 4
+	default:
+		sizeFormat, shift, hdr = 3, 22, 5
+	}
+	if int(hdr)+comp >= len(rawLiteralsHeader(nil, 0, n))+n {
+		return dst, false
+	}
+	v := 2 | uint64(sizeFormat)<<2 | uint64(n)<<4 | uint64(comp)<<shift
+	for i := uint(0); i < hdr; i++ {
+		dst = append(dst, byte(v>>(8*i)))
+	}
+	return append(dst, body...), true
+}
+
+var (
+	llEncoder = newFSEEncoder(predefLLNorm, predefLLLog)
+	mlEncoder = newFSEEncoder(predefMLNorm, predefMLLog)
+	ofEncoder = newFSEEncoder(predefOFNorm, predefOFLog)
+)
+
+// Codes for small literal and match lengths, which do not follow
+// the logarithmic pattern of larger ones.
+var llCodes, mlCodes = lengthCodes()
+
+func lengthCodes() (ll [64]uint8, ml [128]uint8) {
+	for v := range ll {
+		for ll[v] < maxLLSymbol && llBase[ll[v]+1] <= uint32(v) {
+			ll[v]++
+		}
+	}
+	for v := range ml {
+		for ml[v] < maxMLSymbol && mlBase[ml[v]+1]-3 <= uint32(v) {
+			ml[v]++
+		}
+	}
+	return ll, ml
+}
+
+func llCode(ll uint32) uint8 {
+	if ll < 64 {
+		return llCodes[ll]
+	}
+	return uint8(bits.Len32(ll)) - 1 + 19
+}
+
+func mlCode(ml uint32) uint8 {
+	v := ml - 3
+	if v < 128 {
+		return mlCodes[v]
+	}
+	return uint8(bits.Len32(v)) - 1 + 36
+}
+
+// appendSequences appends the sequences section for seqs.
+// It always uses the predefined distributions.
+func (z *Writer) appendSequences(dst []byte, seqs []sequence) []byte {
+	n := len(seqs)
+	switch {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7f00:
+		dst = append(dst, byte(n>>8)+128, byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+	dst = append(dst, 0) // Predefined_Mode for all tables
+
+	// The decoder reads the bitstream backwards, so the sequences
+	// are encoded starting with the last one.
+	bw := bitWriter{out: dst}
+	var llState, mlState, ofState fseState
+	for i := n - 1; i >= 0; i-- {
+		s := seqs[i]
+		llc := llCode(s.litLen)
+		mlc := mlCode(s.matchLen)
+		offVal := s.offVal
+		ofc := uint8(bits.Len32(offVal)) - 1
+		if i == n-1 {
+			mlState.init(mlEncoder, mlc)
+			ofState.init(ofEncoder, ofc)
+			llState.init(llEncoder, llc)
+		} else {
+			ofState.encode(&bw, ofc)
+			mlState.encode(&bw, mlc)
+			llState.encode(&bw, llc)
+		}
+		bw.add(s.litLen-llBase[llc], uint(llBits[llc]))
+		bw.add(s.matchLen-mlBase[mlc], uint(mlBits[mlc]))
+		bw.add(offVal-1<<ofc, uint(ofc))
+	}
+	mlState.flush(&bw)
+	ofState.flush(&bw)
+	llState.flush(&bw)
+	return bw.close()
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func roundTripInputs(t *testing.T) map[string][]byte {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 300<<10)
+	r.Read(random)
+	// Text with long repeats that span blocks and a skewed alphabet
+	// that includes bytes above 0x80.
+	var mixed bytes.Buffer
+	e := readFile(t, "../testdata/e.txt")
+	for i := 0; i < 3; i++ {
+		mixed.Write(e[i*1000:])
+		for j := 0; j < 5000; j++ {
+			mixed.WriteByte(byte(0x80 + r.Intn(4)*r.Intn(30)))
+		}
+	}
+	return map[string][]byte{
+		"empty":      nil,
+		"byte":       {'x'},
+		"short":      []byte("hello, hello, hello, world"),
+		"zeros":      make([]byte, 200<<10),
+		"random":     random,
+		"gettysburg": readFile(t, "../testdata/gettysburg.txt"),
+		"pi":         readFile(t, "../testdata/pi.txt"),
+		"mixed":      mixed.Bytes(),
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	inputs := roundTripInputs(t)
+	for level := DefaultCompression; level <= BestCompression; level++ {
+		for name, in := range inputs {
+			t.Run(fmt.Sprintf("%d/%s", level, name), func(t *testing.T) {
+				var buf bytes.Buffer
+				w, err := NewWriterLevel(&buf, level)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Write(in); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if name == "random" && buf.Len() > len(in)+len(in)/1000+64 {
+					t.Errorf("compressed random data to %d bytes, larger than %d input bytes", buf.Len(), len(in))
+				}
+				got, err := ioutil.ReadAll(NewReader(&buf))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, in) {
+					t.Fatalf("round trip mismatch: got %d bytes, want %d", len(got), len(in))
+				}
+			})
+		}
+	}
+}
+
+func TestWriterSmallWrites(t *testing.T) {
+	in := readFile(t, "../testdata/e.txt")
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < len(in); i += 777 {
+		end := i + 777
+		if end > len(in) {
+			end = len(in)
+		}
+		if _, err := w.Write(in[i:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, in) {
+		t.Fatalf("round trip mismatch: got %d bytes, want %d", len(got), len(in))
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	w := NewWriter(pw)
+	r := NewReader(pr)
+	msgs := []string{"hello", "zstd", "world"}
+	go func() {
+		for _, m := range msgs {
+			w.Write([]byte(m))
+			w.Flush()
+		}
+	}()
+	for _, m := range msgs {
+		buf := make([]byte, len(m))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != m {
+			t.Fatalf("got %q, want %q", buf, m)
+		}
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	in := readFile(t, "../testdata/gettysburg.txt")
+	var buf1, buf2 bytes.Buffer
+	w := NewWriter(&buf1)
+	w.Write(in)
+	w.Close()
+	w.Reset(&buf2)
+	w.Write(in)
+	w.Close()
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Fatal("output after Reset differs")
+	}
+	if _, err := w.Write(in); err == nil {
+		t.Fatal("Write after Close succeeded")
+	}
+}
+
+func TestWriterDict(t *testing.T) {
+	in := readFile(t, "../testdata/gettysburg.txt")
+	for _, dict := range [][]byte{
+		readFile(t, "testdata/gettysburg.dict"),
+		in[len(in)/2:], // raw content dictionary
+	} {
+		var plain, buf bytes.Buffer
+		w := NewWriter(&plain)
+		w.Write(in)
+		w.Close()
+
+		w, err := NewWriterLevelDict(&buf, DefaultCompression, dict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(in)
+		w.Close()
+		if buf.Len() >= plain.Len() {
+			t.Errorf("compressed with dictionary to %d bytes, want fewer than %d", buf.Len(), plain.Len())
+		}
+
+		r, err := NewReaderDict(&buf, dict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, in) {
+			t.Fatalf("round trip mismatch: got %d bytes, want %d", len(got), len(in))
+		}
+	}
+}
+
+func TestWriterLevel(t *testing.T) {
+	for _, level := range []int{-2, BestCompression + 1} {
+		if _, err := NewWriterLevel(ioutil.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel(%d) succeeded", level)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	in, err := ioutil.ReadFile("../testdata/e.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(in)))
+	w := NewWriter(ioutil.Discard)
+	for i := 0; i < b.N; i++ {
+		w.Reset(ioutil.Discard)
+		w.Write(in)
+		w.Close()
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	in, err := ioutil.ReadFile("testdata/e.txt.zst")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(100003)
+	r := NewReader(nil)
+	for i := 0; i < b.N; i++ {
+		r.Reset(bytes.NewReader(in))
+		io.Copy(ioutil.Discard, r)
+	}
+}
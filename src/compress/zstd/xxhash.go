@@ -0,0 +1,117 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// XXH64 is used for the optional content checksum of a frame.
+// The algorithm is described at
+// https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md.
+
+const (
+	xxhPrime1 = 11400714785074694791
+	xxhPrime2 = 14029467366897019727
+	xxhPrime3 = 1609587929392839161
+	xxhPrime4 = 9650029242287828579
+	xxhPrime5 = 2870177450012600261
+)
+
+// xxhash64 computes XXH64 with a seed of zero.
+type xxhash64 struct {
+	v   [4]uint64
+	len uint64
+	buf [32]byte
+	n   int // bytes in buf
+}
+
+func (h *xxhash64) reset() {
+	p1, p2 := uint64(xxhPrime1), uint64(xxhPrime2)
+	h.v[0] = p1 + p2
+	h.v[1] = p2
+	h.v[2] = 0
+	h.v[3] = -p1
+	h.len = 0
+	h.n = 0
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime1
+}
+
+func xxhMergeRound(acc, val uint64) uint64 {
+	acc ^= xxhRound(0, val)
+	return acc*xxhPrime1 + xxhPrime4
+}
+
+func (h *xxhash64) write(p []byte) {
+	h.len += uint64(len(p))
+	if h.n > 0 {
+		c := copy(h.buf[h.n:], p)
+		h.n += c
+		p = p[c:]
+		if h.n < len(h.buf) {
+			return
+		}
+		h.stripes(h.buf[:])
+		h.n = 0
+	}
+	if len(p) >= 32 {
+		m := len(p) &^ 31
+		h.stripes(p[:m])
+		p = p[m:]
+	}
+	h.n = copy(h.buf[:], p)
+}
+
+func (h *xxhash64) stripes(p []byte) {
+	v0, v1, v2, v3 := h.v[0], h.v[1], h.v[2], h.v[3]
+	for ; len(p) >= 32; p = p[32:] {
+		v0 = xxhRound(v0, le.Uint64(p[0:]))
+		v1 = xxhRound(v1, le.Uint64(p[8:]))
+		v2 = xxhRound(v2, le.Uint64(p[16:]))
+		v3 = xxhRound(v3, le.Uint64(p[24:]))
+	}
+	h.v[0], h.v[1], h.v[2], h.v[3] = v0, v1, v2, v3
+}
+
+func (h *xxhash64) sum64() uint64 {
+	var acc uint64
+	if h.len >= 32 {
+		v0, v1, v2, v3 := h.v[0], h.v[1], h.v[2], h.v[3]
+		acc = bits.RotateLeft64(v0, 1) + bits.RotateLeft64(v1, 7) +
+			bits.RotateLeft64(v2, 12) + bits.RotateLeft64(v3, 18)
+		acc = xxhMergeRound(acc, v0)
+		acc = xxhMergeRound(acc, v1)
+		acc = xxhMergeRound(acc, v2)
+		acc = xxhMergeRound(acc, v3)
+	} else {
+		acc = xxhPrime5
+	}
+	acc += h.len
+
+	p := h.buf[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		acc ^= xxhRound(0, le.Uint64(p))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(p) >= 4 {
+		acc ^= uint64(le.Uint32(p)) * xxhPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime2 + xxhPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		acc ^= uint64(b) * xxhPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxhPrime2
+	acc ^= acc >> 29
+	acc *= xxhPrime3
+	acc ^= acc >> 32
+	return acc
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zstd implements reading and writing of Zstandard compressed data,
+// as specified in RFC 8878.
+//
+// The Reader supports the complete format, including skippable frames,
+// concatenated frames and dictionaries. The Writer produces standard frames
+// that any conforming decoder can read; it trades some compression ratio
+// for simplicity and speed compared to the reference implementation.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	frameMagic        = 0xfd2fb528
+	skippableMagic    = 0x184d2a50 // low 4 bits are user-defined
+	skippableMask     = 0xfffffff0
+	dictionaryMagic   = 0xec30a437
+	maxBlockSize      = 128 << 10
+	minWindowLog      = 10
+	maxWindowLog      = 31
+	huffmanMaxBits    = 11
+	huffmanMaxSymbols = 256
+)
+
+// DefaultMaxWindowSize is the largest window size that a Reader accepts
+// unless SetMaxWindowSize is called. It matches the limit that RFC 8878
+// places on the "zstd" HTTP content coding, and bounds the memory a
+// Reader needs to hold its history to roughly twice this size.
+const DefaultMaxWindowSize = 8 << 20
+
+var (
+	// ErrChecksum is returned when reading Zstandard data that has an invalid checksum.
+	ErrChecksum = errors.New("zstd: invalid checksum")
+	// ErrHeader is returned when reading Zstandard data that has an invalid frame header.
+	ErrHeader = errors.New("zstd: invalid header")
+	// ErrWindowSize is returned when a frame requires a larger window
+	// than the Reader is permitted to allocate.
+	ErrWindowSize = errors.New("zstd: window size exceeds limit")
+	// ErrDictionary is returned when a frame requires a dictionary
+	// that was not supplied to the Reader.
+	ErrDictionary = errors.New("zstd: missing or mismatched dictionary")
+)
+
+// A StructuralError is returned when the Zstandard data is found to be
+// syntactically invalid.
+type StructuralError string
+
+func (s StructuralError) Error() string {
+	return "zstd data invalid: " + string(s)
+}
+
+var le = binary.LittleEndian
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Baselines and extra bit counts for literal length codes (RFC 8878, 3.1.1.3.2.1.1).
+var llBase = [36]uint32{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+	8192, 16384, 32768, 65536,
+}
+
+var llBits = [36]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+	13, 14, 15, 16,
+}
+
+// Baselines and extra bit counts for match length codes.
+var mlBase = [53]uint32{
+	3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+	19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+	35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+	4099, 8195, 16387, 32771, 65539,
+}
+
+var mlBits = [53]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+	12, 13, 14, 15, 16,
+}
+
+const (
+	maxLLSymbol = 35
+	maxMLSymbol = 52
+	maxOFSymbol = 31
+
+	maxLLLog = 9
+	maxMLLog = 9
+	maxOFLog = 8
+)
+
+// Predefined distributions used by sequences in Predefined_Mode
+// (RFC 8878, 3.1.1.3.2.2).
+var (
+	predefLLNorm = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	predefMLNorm = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	predefOFNorm = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+const (
+	predefLLLog = 6
+	predefMLLog = 6
+	predefOFLog = 5
+)
+
+var (
+	predefLLTable = mustBuildFSE(predefLLNorm, predefLLLog)
+	predefMLTable = mustBuildFSE(predefMLNorm, predefMLLog)
+	predefOFTable = mustBuildFSE(predefOFNorm, predefOFLog)
+)
+
+func mustBuildFSE(norm []int16, tableLog uint8) []fseEntry {
+	t := make([]fseEntry, 1<<tableLog)
+	if err := buildFSE(t, norm, tableLog); err != nil {
+		panic(err)
+	}
+	return t
+}
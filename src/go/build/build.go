@@ -436,13 +436,25 @@ type Package struct {
 	Imports   []string                    // import paths from GoFiles, CgoFiles
 	ImportPos map[string][]token.Position // line information for Imports
 
+	// //go:embed patterns found in Go source files
+	// For example, if a source file says
+	//	//go:embed a* b.c
+	// then the list will contain those two strings as separate entries.
+	// (See package embed for more details about //go:embed.)
+	EmbedPatterns   []string                    // patterns from GoFiles, CgoFiles
+	EmbedPatternPos map[string][]token.Position // line information for EmbedPatterns
+
 	// Test information
-	TestGoFiles    []string                    // _test.go files in package
-	TestImports    []string                    // import paths from TestGoFiles
-	TestImportPos  map[string][]token.Position // line information for TestImports
-	XTestGoFiles   []string                    // _test.go files outside package
-	XTestImports   []string                    // import paths from XTestGoFiles
-	XTestImportPos map[string][]token.Position // line information for XTestImports
+	TestGoFiles          []string                    // _test.go files in package
+	TestImports          []string                    // import paths from TestGoFiles
+	TestImportPos        map[string][]token.Position // line information for TestImports
+	TestEmbedPatterns    []string                    // patterns from TestGoFiles
+	TestEmbedPatternPos  map[string][]token.Position // line information for TestEmbedPatterns
+	XTestGoFiles         []string                    // _test.go files outside package
+	XTestImports         []string                    // import paths from XTestGoFiles
+	XTestImportPos       map[string][]token.Position // line information for XTestImports
+	XTestEmbedPatterns   []string                    // patterns from XTestGoFiles
+	XTestEmbedPatternPos map[string][]token.Position // line information for XTestEmbedPatterns
 }
 
 // IsCommand reports whether the package is considered a
@@ -788,6 +800,9 @@ Found:
 	imported := make(map[string][]token.Position)
 	testImported := make(map[string][]token.Position)
 	xTestImported := make(map[string][]token.Position)
+	embedPos := make(map[string][]token.Position)
+	testEmbedPos := make(map[string][]token.Position)
+	xTestEmbedPos := make(map[string][]token.Position)
 	allTags := make(map[string]bool)
 	fset := token.NewFileSet()
 	for _, d := range dirs {
@@ -805,7 +820,8 @@ Found:
 			p.InvalidGoFiles = append(p.InvalidGoFiles, name)
 		}
 
-		match, data, filename, err := ctxt.matchFile(p.Dir, name, allTags, &p.BinaryOnly)
+		var embeds []fileEmbed
+		match, data, filename, err := ctxt.matchFile(p.Dir, name, allTags, &p.BinaryOnly, &embeds)
 		if err != nil {
 			badFile(err)
 			continue
@@ -947,26 +963,30 @@ Found:
 		}
 
 		var fileList *[]string
-		var importMap map[string][]token.Position
+		var importMap, embedMap map[string][]token.Position
 		switch {
 		case isCgo:
 			allTags["cgo"] = true
 			if ctxt.CgoEnabled {
 				fileList = &p.CgoFiles
 				importMap = imported
+				embedMap = embedPos
 			} else {
-				// Ignore imports from cgo files if cgo is disabled.
+				// Ignore imports and embeds from cgo files if cgo is disabled.
 				fileList = &p.IgnoredGoFiles
 			}
 		case isXTest:
 			fileList = &p.XTestGoFiles
 			importMap = xTestImported
+			embedMap = xTestEmbedPos
 		case isTest:
 			fileList = &p.TestGoFiles
 			importMap = testImported
+			embedMap = testEmbedPos
 		default:
 			fileList = &p.GoFiles
 			importMap = imported
+			embedMap = embedPos
 		}
 		*fileList = append(*fileList, name)
 		if importMap != nil {
@@ -974,6 +994,11 @@ Found:
 				importMap[imp.path] = append(importMap[imp.path], fset.Position(imp.pos))
 			}
 		}
+		if embedMap != nil {
+			for _, emb := range embeds {
+				embedMap[emb.pattern] = append(embedMap[emb.pattern], emb.pos)
+			}
+		}
 	}
 
 	for tag := range allTags {
@@ -981,9 +1006,12 @@ Found:
 	}
 	sort.Strings(p.AllTags)
 
-	p.Imports, p.ImportPos = cleanImports(imported)
-	p.TestImports, p.TestImportPos = cleanImports(testImported)
-	p.XTestImports, p.XTestImportPos = cleanImports(xTestImported)
+	p.Imports, p.ImportPos = cleanDecls(imported)
+	p.TestImports, p.TestImportPos = cleanDecls(testImported)
+	p.XTestImports, p.XTestImportPos = cleanDecls(xTestImported)
+	p.EmbedPatterns, p.EmbedPatternPos = cleanDecls(embedPos)
+	p.TestEmbedPatterns, p.TestEmbedPatternPos = cleanDecls(testEmbedPos)
+	p.XTestEmbedPatterns, p.XTestEmbedPatternPos = cleanDecls(xTestEmbedPos)
 
 	// add the .S/.sx files only if we are using cgo
 	// (which means gcc will compile them).
@@ -1293,7 +1321,7 @@ func parseWord(data []byte) (word, rest []byte) {
 // MatchFile considers the name of the file and may use ctxt.OpenFile to
 // read some or all of the file's content.
 func (ctxt *Context) MatchFile(dir, name string) (match bool, err error) {
-	match, _, _, err = ctxt.matchFile(dir, name, nil, nil)
+	match, _, _, err = ctxt.matchFile(dir, name, nil, nil, nil)
 	return
 }
 
@@ -1305,7 +1333,10 @@ func (ctxt *Context) MatchFile(dir, name string) (match bool, err error) {
 // until the first non-comment.
 // If allTags is non-nil, matchFile records any encountered build tag
 // by setting allTags[tag] = true.
-func (ctxt *Context) matchFile(dir, name string, allTags map[string]bool, binaryOnly *bool) (match bool, data []byte, filename string, err error) {
+// If embeds is non-nil and the file is a Go program importing "embed",
+// matchFile reads the whole file and sets *embeds to the patterns
+// of its //go:embed directives.
+func (ctxt *Context) matchFile(dir, name string, allTags map[string]bool, binaryOnly *bool, embeds *[]fileEmbed) (match bool, data []byte, filename string, err error) {
 	if strings.HasPrefix(name, "_") ||
 		strings.HasPrefix(name, ".") {
 		return
@@ -1340,7 +1371,11 @@ func (ctxt *Context) matchFile(dir, name string, allTags map[string]bool, binary
 	}
 
 	if strings.HasSuffix(filename, ".go") {
-		data, err = readImports(f, false, nil)
+		if embeds != nil {
+			data, *embeds, err = readGoFile(f, filename)
+		} else {
+			data, err = readImports(f, false, nil)
+		}
 		if strings.HasSuffix(filename, "_test.go") {
 			binaryOnly = nil // ignore //go:binary-only-package comments in _test.go files
 		}
@@ -1367,7 +1402,7 @@ func (ctxt *Context) matchFile(dir, name string, allTags map[string]bool, binary
 	return
 }
 
-func cleanImports(m map[string][]token.Position) ([]string, map[string][]token.Position) {
+func cleanDecls(m map[string][]token.Position) ([]string, map[string][]token.Position) {
 	all := make([]string, 0, len(m))
 	for path := range m {
 		all = append(all, path)
@@ -47,6 +47,7 @@ var pkgDeps = map[string][]string{
 	"internal/cpu":            {},
 	"internal/bytealg":        {"unsafe", "internal/cpu"},
 	"internal/reflectlite":    {"runtime", "unsafe"},
+	"internal/intern":         {"runtime", "sync", "unsafe"},
 
 	"L0": {
 		"errors",
@@ -86,7 +87,7 @@ var pkgDeps = map[string][]string{
 	// L2 adds Unicode and strings processing.
 	"bufio":   {"L0", "unicode/utf8", "bytes"},
 	"bytes":   {"L0", "unicode", "unicode/utf8"},
-	"path":    {"L0", "unicode/utf8", "strings"},
+	"path":    {"L0", "unicode/utf8"},
 	"strings": {"L0", "unicode", "unicode/utf8"},
 	"unicode": {},
 
@@ -171,8 +172,10 @@ var pkgDeps = map[string][]string{
 	"internal/cfg":     {"L0"},
 	"internal/poll":    {"L0", "internal/oserror", "internal/race", "syscall", "time", "unicode/utf16", "unicode/utf8", "internal/syscall/windows", "internal/syscall/unix"},
 	"internal/testlog": {"L0"},
-	"os":               {"L1", "os", "syscall", "time", "internal/oserror", "internal/poll", "internal/syscall/windows", "internal/syscall/unix", "internal/syscall/execenv", "internal/testlog"},
-	"path/filepath":    {"L2", "os", "syscall", "internal/syscall/windows"},
+	"io/fs":            {"L0", "internal/oserror", "path", "sort", "time", "unicode/utf8"},
+	"embed":            {"L0", "io/fs", "time"},
+	"os":               {"L1", "os", "syscall", "time", "internal/oserror", "internal/poll", "internal/syscall/windows", "internal/syscall/unix", "internal/syscall/execenv", "internal/testlog", "io/fs"},
+	"path/filepath":    {"L2", "io/fs", "os", "syscall", "internal/syscall/windows"},
 	"io/ioutil":        {"L2", "os", "path/filepath", "time"},
 	"os/exec":          {"L2", "os", "context", "path/filepath", "syscall", "internal/syscall/execenv"},
 	"os/signal":        {"L2", "os", "syscall"},
@@ -180,6 +183,7 @@ var pkgDeps = map[string][]string{
 	// OS enables basic operating system functionality,
 	// but not direct use of package syscall, nor os/signal.
 	"OS": {
+		"io/fs",
 		"io/ioutil",
 		"os",
 		"os/exec",
@@ -200,6 +204,7 @@ var pkgDeps = map[string][]string{
 	"text/tabwriter": {"L2"},
 
 	"testing":                  {"L2", "flag", "fmt", "internal/race", "os", "runtime/debug", "runtime/pprof", "runtime/trace", "time"},
+	"testing/fstest":           {"L2", "fmt", "io/fs", "reflect", "time"},
 	"testing/iotest":           {"L2", "log"},
 	"testing/quick":            {"L2", "flag", "fmt", "reflect", "time"},
 	"internal/obscuretestdata": {"L2", "OS", "encoding/base64"},
@@ -246,13 +251,15 @@ var pkgDeps = map[string][]string{
 
 	// One of a kind.
 	"archive/tar":                    {"L4", "OS", "syscall", "os/user"},
-	"archive/zip":                    {"L4", "OS", "compress/flate"},
+	"archive/zip":                    {"L4", "OS", "compress/flate", "compress/zstd"},
 	"container/heap":                 {"sort"},
 	"compress/bzip2":                 {"L4"},
 	"compress/flate":                 {"L4"},
 	"compress/gzip":                  {"L4", "compress/flate"},
 	"compress/lzw":                   {"L4"},
+	"compress/xz":                    {"L4", "crypto/sha256"},
 	"compress/zlib":                  {"L4", "compress/flate"},
+	"compress/zstd":                  {"L4"},
 	"context":                        {"errors", "internal/reflectlite", "sync", "sync/atomic", "time"},
 	"database/sql":                   {"L4", "container/list", "context", "database/sql/driver", "database/sql/internal"},
 	"database/sql/driver":            {"L4", "context", "time", "database/sql/internal"},
@@ -323,12 +330,15 @@ var pkgDeps = map[string][]string{
 	// Internal package used only for testing.
 	"os/signal/internal/pty": {"CGO", "fmt", "os", "syscall"},
 
+	// Comparable IP address types with no dependencies on the network stack.
+	"net/netip": {"L0", "math", "math/bits", "strconv", "internal/intern"},
+
 	// Basic networking.
 	// Because net must be used by any package that wants to
 	// do networking portably, it must have a small dependency set: just L0+basic os.
 	"net": {
 		"L0", "CGO",
-		"context", "math/rand", "os", "sort", "syscall", "time",
+		"context", "math/rand", "net/netip", "os", "sort", "syscall", "time",
 		"internal/nettrace", "internal/poll", "internal/syscall/unix",
 		"internal/syscall/windows", "internal/singleflight", "internal/race",
 		"golang.org/x/net/dns/dnsmessage", "golang.org/x/net/lif", "golang.org/x/net/route",
@@ -337,6 +347,7 @@ var pkgDeps = map[string][]string{
 	// NET enables use of basic network-related packages.
 	"NET": {
 		"net",
+		"net/netip",
 		"mime",
 		"net/textproto",
 		"net/url",
@@ -420,6 +431,8 @@ var pkgDeps = map[string][]string{
 	"net/http": {
 		"L4", "NET", "OS",
 		"compress/gzip",
+		"compress/zlib",
+		"compress/zstd",
 		"container/list",
 		"context",
 		"crypto/rand",
@@ -444,15 +457,18 @@ var pkgDeps = map[string][]string{
 	"expvar":             {"L4", "OS", "encoding/json", "net/http"},
 	"net/http/cgi":       {"L4", "NET", "OS", "crypto/tls", "net/http", "regexp"},
 	"net/http/cookiejar": {"L4", "NET", "net/http"},
+	"net/http/httpcache": {"L4", "NET", "OS", "container/list", "net/http"},
 	"net/http/fcgi":      {"L4", "NET", "OS", "context", "net/http", "net/http/cgi"},
 	"net/http/httptest": {
-		"L4", "NET", "OS", "crypto/tls", "flag", "net/http", "net/http/internal", "crypto/x509",
+		"L4", "NET", "OS", "context", "crypto/tls", "flag", "net/http", "net/http/internal", "crypto/x509",
 		"golang.org/x/net/http/httpguts",
 	},
-	"net/http/httputil": {"L4", "NET", "OS", "context", "net/http", "net/http/internal", "golang.org/x/net/http/httpguts"},
-	"net/http/pprof":    {"L4", "OS", "html/template", "net/http", "runtime/pprof", "runtime/trace"},
-	"net/rpc":           {"L4", "NET", "encoding/gob", "html/template", "net/http", "go/token"},
-	"net/rpc/jsonrpc":   {"L4", "NET", "encoding/json", "net/rpc"},
+	"net/http/httputil":  {"L4", "NET", "OS", "context", "net/http", "net/http/internal", "golang.org/x/net/http/httpguts"},
+	"net/http/pprof":     {"L4", "OS", "html/template", "net/http", "runtime/pprof", "runtime/trace"},
+	"net/http/websocket": {"L4", "NET", "OS", "CRYPTO", "compress/flate", "context", "crypto/rand", "crypto/tls", "net/http", "golang.org/x/net/http/httpguts"},
+	"net/rpc":            {"L4", "NET", "encoding/gob", "html/template", "net/http", "go/token"},
+	"net/securedns":      {"L4", "NET", "context", "crypto/tls", "encoding/base64", "io/ioutil", "net/http"},
+	"net/rpc/jsonrpc":    {"L4", "NET", "encoding/json", "net/rpc"},
 }
 
 // isMacro reports whether p is a package dependency macro
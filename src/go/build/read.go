@@ -6,8 +6,15 @@ package build
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
+	"fmt"
+	"go/token"
 	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -212,7 +219,11 @@ func readComments(f io.Reader) ([]byte, error) {
 // and stops reading the input once the imports have completed.
 func readImports(f io.Reader, reportSyntaxError bool, imports *[]string) ([]byte, error) {
 	r := &importReader{b: bufio.NewReader(f)}
+	return r.readImports(reportSyntaxError, imports)
+}
 
+// readImports implements the package-level readImports function for r.
+func (r *importReader) readImports(reportSyntaxError bool, imports *[]string) ([]byte, error) {
 	r.readKeyword("package")
 	r.readIdent()
 	for r.peekByte(true) == 'i' {
@@ -245,3 +256,202 @@ func readImports(f io.Reader, reportSyntaxError bool, imports *[]string) ([]byte
 
 	return r.buf, r.err
 }
+
+// A fileEmbed is a single //go:embed pattern and its position.
+type fileEmbed struct {
+	pattern string
+	pos     token.Position
+}
+
+// readGoFile is like readImports, but if the file imports "embed",
+// it also reads the rest of the file and returns the patterns of
+// the //go:embed directives it contains. The returned data still
+// stops at the end of the imports, as with readImports.
+func readGoFile(f io.Reader, filename string) (data []byte, embeds []fileEmbed, err error) {
+	r := &importReader{b: bufio.NewReader(f)}
+	var imports []string
+	data, err = r.readImports(false, &imports)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hasEmbed := false
+	for _, imp := range imports {
+		if path, err := strconv.Unquote(imp); err == nil && path == "embed" {
+			hasEmbed = true
+			break
+		}
+	}
+	if !hasEmbed {
+		return data, nil, nil
+	}
+
+	// r.buf holds everything read so far, which may include
+	// one byte past the imports; the rest is still unread.
+	rest, err := ioutil.ReadAll(r.b)
+	if err != nil {
+		return nil, nil, err
+	}
+	src := append(r.buf[:len(r.buf):len(r.buf)], rest...)
+	return data, findEmbeds(src, filename), nil
+}
+
+var goEmbed = []byte("//go:embed")
+
+// findEmbeds returns the patterns of the //go:embed directives in src.
+// Like the compiler, it only recognizes directives at the start of a line,
+// and it skips string, rune, and comment text so that text that merely
+// looks like a directive is not reported.
+// Badly formed directives are ignored: the compiler reports them when it
+// finds them, and go list can still succeed with what is known.
+func findEmbeds(src []byte, filename string) []fileEmbed {
+	var embeds []fileEmbed
+	line, lineStart := 1, 0
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '\n':
+			line++
+			lineStart = i + 1
+
+		case '"', '\'':
+			// Interpreted strings and runes end at the closing quote
+			// or, if malformed, at the end of the line.
+			for i++; i < len(src) && src[i] != c && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			if i < len(src) && src[i] == '\n' {
+				i-- // let the loop see the newline
+			}
+
+		case '`':
+			for i++; i < len(src) && src[i] != '`'; i++ {
+				if src[i] == '\n' {
+					line++
+					lineStart = i + 1
+				}
+			}
+
+		case '/':
+			if i+1 >= len(src) {
+				break
+			}
+			switch src[i+1] {
+			case '*':
+				end := bytes.Index(src[i+2:], []byte("*/"))
+				if end < 0 {
+					end = len(src)
+				} else {
+					end += i + 2 + len("*/")
+				}
+				for j := i; j < end; j++ {
+					if src[j] == '\n' {
+						line++
+						lineStart = j + 1
+					}
+				}
+				i = end - 1
+
+			case '/':
+				end := bytes.IndexByte(src[i:], '\n')
+				if end < 0 {
+					end = len(src)
+				} else {
+					end += i
+				}
+				text := src[i:end]
+				if i == lineStart && bytes.HasPrefix(text, goEmbed) {
+					args := string(text[len(goEmbed):])
+					if args != "" && (args[0] == ' ' || args[0] == '\t') {
+						off := i + len(goEmbed)
+						pos := token.Position{
+							Filename: filename,
+							Offset:   off,
+							Line:     line,
+							Column:   utf8.RuneCount(src[lineStart:off]) + 1,
+						}
+						if list, err := parseGoEmbed(args, pos); err == nil {
+							embeds = append(embeds, list...)
+						}
+					}
+				}
+				i = end - 1
+			}
+		}
+	}
+	return embeds
+}
+
+// parseGoEmbed parses the text following "//go:embed" to extract the glob patterns.
+// It accepts unquoted space-separated patterns as well as double-quoted and back-quoted Go strings.
+// This is based on a similar function in cmd/compile/internal/gc/noder.go;
+// this version calculates position information as well.
+func parseGoEmbed(args string, pos token.Position) ([]fileEmbed, error) {
+	trimBytes := func(n int) {
+		pos.Offset += n
+		pos.Column += utf8.RuneCountInString(args[:n])
+		args = args[n:]
+	}
+	trimSpace := func() {
+		trim := strings.TrimLeftFunc(args, unicode.IsSpace)
+		trimBytes(len(args) - len(trim))
+	}
+
+	var list []fileEmbed
+	for trimSpace(); args != ""; trimSpace() {
+		var path string
+		pathPos := pos
+	Switch:
+		switch args[0] {
+		default:
+			i := len(args)
+			for j, c := range args {
+				if unicode.IsSpace(c) {
+					i = j
+					break
+				}
+			}
+			path = args[:i]
+			trimBytes(i)
+
+		case '`':
+			i := strings.Index(args[1:], "`")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+			}
+			path = args[1 : 1+i]
+			trimBytes(1 + i + 1)
+
+		case '"':
+			i := 1
+			for ; i < len(args); i++ {
+				if args[i] == '\\' {
+					i++
+					continue
+				}
+				if args[i] == '"' {
+					q, err := strconv.Unquote(args[:i+1])
+					if err != nil {
+						return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args[:i+1])
+					}
+					path = q
+					trimBytes(i + 1)
+					break Switch
+				}
+			}
+			if i >= len(args) {
+				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+			}
+		}
+
+		if args != "" {
+			r, _ := utf8.DecodeRuneInString(args)
+			if !unicode.IsSpace(r) {
+				return nil, fmt.Errorf("invalid quoted string in //go:embed: %s", args)
+			}
+		}
+		list = append(list, fileEmbed{path, pathPos})
+	}
+	return list, nil
+}
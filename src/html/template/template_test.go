@@ -9,6 +9,7 @@ import (
 	. "html/template"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestTemplateClone(t *testing.T) {
@@ -110,11 +111,39 @@ func TestRedefineOtherParsers(t *testing.T) {
 	if _, err := c.root.ParseGlob("*.no.template"); err == nil || !strings.Contains(err.Error(), "Execute") {
 		t.Errorf("ParseGlob: %v\nwanted error about already having Executed", err)
 	}
+	fsys := fstest.MapFS{"a.tmpl": {Data: []byte(`a`)}}
+	if _, err := c.root.ParseFS(fsys, "*.tmpl"); err == nil || !strings.Contains(err.Error(), "Execute") {
+		t.Errorf("ParseFS: %v\nwanted error about already having Executed", err)
+	}
 	if _, err := c.root.AddParseTree("t1", c.root.Tree); err == nil || !strings.Contains(err.Error(), "Execute") {
 		t.Errorf("AddParseTree: %v\nwanted error about already having Executed", err)
 	}
 }
 
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout/base.tmpl": {Data: []byte(`<p>{{template "body" .}}</p>`)},
+		"pages/body.tmpl":  {Data: []byte(`{{define "body"}}{{.}}{{end}}`)},
+	}
+	tmpl, err := ParseFS(fsys, "layout/*.tmpl", "pages/*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tmpl.Name(), "base.tmpl"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, "<script>"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "<p>&lt;script&gt;</p>"; got != want {
+		t.Errorf("Execute = %q, want %q", got, want)
+	}
+	if _, err := ParseFS(fsys, "missing/*.tmpl"); err == nil || !strings.Contains(err.Error(), "matches no files") {
+		t.Errorf("ParseFS with unmatched pattern = %v, want error about no files", err)
+	}
+}
+
 func TestNumbers(t *testing.T) {
 	c := newTestCase(t)
 	c.mustParse(c.root, `{{print 1_2.3_4}} {{print 0x0_1.e_0p+02}}`)
@@ -6,6 +6,7 @@
 
 package unix
 
-// Linux getrandom system call number.
-// See GetRandom in getrandom_linux.go.
-const randomTrap uintptr = 4353
+const (
+	getrandomTrap     uintptr = 4353
+	copyFileRangeTrap uintptr = 4360
+)
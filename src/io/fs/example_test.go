@@ -0,0 +1,40 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs_test
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"testing/fstest"
+)
+
+func ExampleWalkDir() {
+	fsys := fstest.MapFS{
+		"index.html":      {Data: []byte("<html></html>")},
+		"static/app.js":   {Data: []byte("main()")},
+		"static/app.css":  {Data: []byte("body {}")},
+		"templates/a.tpl": {Data: []byte("{{.}}")},
+	}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == "templates" {
+			return fs.SkipDir
+		}
+		fmt.Println(path, d.IsDir())
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// . true
+	// index.html false
+	// static true
+	// static/app.css false
+	// static/app.js false
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs_test
+
+import (
+	"errors"
+	. "io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+var walkTree = fstest.MapFS{
+	"a/x":         {},
+	"a/y":         {},
+	"a/b/x":       {},
+	"a/b/z":       {},
+	"a/c/x":       {},
+	"a/d/x":       {},
+	"a/d/y/x":     {},
+	"a/d/y/z/x":   {},
+	"empty":       {Mode: ModeDir},
+	"top.txt":     {Data: []byte("top")},
+	"zz/deep/a/b": {},
+}
+
+func TestWalkDir(t *testing.T) {
+	var visited []string
+	err := WalkDir(walkTree, ".", func(path string, d DirEntry, err error) error {
+		if err != nil {
+			t.Errorf("walk %s: %v", path, err)
+			return err
+		}
+		if d.IsDir() != (walkTree[path] == nil || walkTree[path].Mode.IsDir()) {
+			t.Errorf("walk %s: IsDir = %v, want %v", path, d.IsDir(), !d.IsDir())
+		}
+		visited = append(visited, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		".", "a", "a/b", "a/b/x", "a/b/z", "a/c", "a/c/x", "a/d", "a/d/x",
+		"a/d/y", "a/d/y/x", "a/d/y/z", "a/d/y/z/x", "a/x", "a/y",
+		"empty", "top.txt", "zz", "zz/deep", "zz/deep/a", "zz/deep/a/b",
+	}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("WalkDir visited\n\t%q\nwant\n\t%q", visited, want)
+	}
+}
+
+func TestWalkDirSkipDir(t *testing.T) {
+	var visited []string
+	err := WalkDir(walkTree, "a", func(path string, d DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, path)
+		switch path {
+		case "a/b":
+			return SkipDir // skip the directory
+		case "a/d/x":
+			return SkipDir // skip the rest of a/d
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "a/b", "a/c", "a/c/x", "a/d", "a/d/x", "a/x", "a/y"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("WalkDir visited\n\t%q\nwant\n\t%q", visited, want)
+	}
+}
+
+func TestWalkDirErrors(t *testing.T) {
+	// A missing root is reported to fn with a nil DirEntry.
+	var called bool
+	err := WalkDir(walkTree, "missing", func(path string, d DirEntry, err error) error {
+		called = true
+		if path != "missing" || d != nil || !errors.Is(err, ErrNotExist) {
+			t.Errorf("fn(%q, %v, %v), want fn(%q, nil, ErrNotExist)", path, d, err, "missing")
+		}
+		return err
+	})
+	if !called || !errors.Is(err, ErrNotExist) {
+		t.Errorf("WalkDir(missing) = %v (called=%v), want ErrNotExist", err, called)
+	}
+
+	// An error returned by fn stops the walk.
+	stop := errors.New("stop")
+	var visited []string
+	err = WalkDir(walkTree, ".", func(path string, d DirEntry, err error) error {
+		visited = append(visited, path)
+		if path == "a/c" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("WalkDir = %v, want %v", err, stop)
+	}
+	if last := visited[len(visited)-1]; last != "a/c" {
+		t.Errorf("WalkDir continued after error; last visited %q", last)
+	}
+}
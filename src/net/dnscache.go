@@ -0,0 +1,241 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// defaultDNSCacheEntries is the size of a DNSCache with MaxEntries 0.
+const defaultDNSCacheEntries = 1000
+
+// A DNSCache is an in-memory cache of DNS responses for Go's built-in
+// DNS resolver. See Resolver.Cache.
+//
+// Responses are cached by name and query type for the lowest TTL of
+// their answer records. Negative responses, for names that do not
+// exist or have no records of the requested type, are cached as
+// described in RFC 2308: for the TTL of the SOA record in the
+// authority section or its MINIMUM field, whichever is lower. Negative
+// responses without an SOA record, and failures such as timeouts and
+// server errors, are not cached.
+//
+// The zero value is an empty cache ready to use. A DNSCache is safe
+// for concurrent use by multiple goroutines and may be shared by
+// several Resolvers. It must not be copied after first use.
+type DNSCache struct {
+	// MaxEntries is the maximum number of responses kept in the
+	// cache. When the cache is full, the least recently used
+	// response is evicted. If zero, 1000 responses are kept.
+	MaxEntries int
+
+	// MaxTTL optionally limits how long a response is cached,
+	// regardless of the TTLs of its records.
+	MaxTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[dnsCacheKey]*dnsCacheEntry
+	lru     dnsCacheEntry // list sentinel; lru.next is the most recently used
+	stats   DNSCacheStats
+}
+
+// DNSCacheStats holds statistics about the use of a DNSCache.
+type DNSCacheStats struct {
+	Entries   int    // number of responses currently cached
+	Hits      uint64 // queries answered from the cache
+	Misses    uint64 // queries sent to a server
+	Evictions uint64 // unexpired responses evicted to make room
+}
+
+type dnsCacheKey struct {
+	name  string // rooted and lower case
+	qtype dnsmessage.Type
+}
+
+// A dnsCacheValue holds the results of tryOneName.
+type dnsCacheValue struct {
+	p        dnsmessage.Parser // positioned at the first answer of the query type
+	server   string
+	notFound *DNSError // non-nil for negative responses
+}
+
+type dnsCacheEntry struct {
+	key        dnsCacheKey
+	val        dnsCacheValue
+	expires    time.Time
+	prev, next *dnsCacheEntry
+}
+
+// Stats returns statistics about the use of c.
+func (c *DNSCache) Stats() DNSCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// Flush removes all responses from c. It does not reset the
+// statistics returned by Stats.
+func (c *DNSCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.lru.prev, c.lru.next = nil, nil
+}
+
+func (c *DNSCache) init() {
+	if c.entries == nil {
+		c.entries = make(map[dnsCacheKey]*dnsCacheEntry)
+		c.lru.prev, c.lru.next = &c.lru, &c.lru
+	}
+}
+
+// get returns the cached results of querying name for qtype, if any.
+func (c *DNSCache) get(name string, qtype dnsmessage.Type) (dnsCacheValue, bool) {
+	key := dnsCacheKey{lowerASCIIString(name), qtype}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	e := c.entries[key]
+	if e != nil && !testHookDNSCacheNow().Before(e.expires) {
+		c.remove(e)
+		e = nil
+	}
+	if e == nil {
+		c.stats.Misses++
+		return dnsCacheValue{}, false
+	}
+	c.stats.Hits++
+	c.unlink(e)
+	c.pushFront(e)
+	return e.val, true
+}
+
+// put adds the results of querying name for qtype to c. The parser
+// resp holds the full response, positioned at the start of the
+// answer section, and is used to determine how long to keep v.
+func (c *DNSCache) put(name string, qtype dnsmessage.Type, v dnsCacheValue, resp dnsmessage.Parser) {
+	var ttl uint32
+	var ok bool
+	if v.notFound != nil {
+		ttl, ok = negativeTTL(resp)
+	} else {
+		ttl, ok = answerTTL(resp)
+	}
+	if !ok || ttl == 0 {
+		return
+	}
+	d := time.Duration(ttl) * time.Second
+	if c.MaxTTL > 0 && d > c.MaxTTL {
+		d = c.MaxTTL
+	}
+	now := testHookDNSCacheNow()
+
+	key := dnsCacheKey{lowerASCIIString(name), qtype}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	if e := c.entries[key]; e != nil {
+		c.remove(e)
+	}
+	max := c.MaxEntries
+	if max <= 0 {
+		max = defaultDNSCacheEntries
+	}
+	for len(c.entries) >= max {
+		e := c.lru.prev
+		if now.Before(e.expires) {
+			c.stats.Evictions++
+		}
+		c.remove(e)
+	}
+	e := &dnsCacheEntry{key: key, val: v, expires: now.Add(d)}
+	c.entries[key] = e
+	c.pushFront(e)
+}
+
+func (c *DNSCache) remove(e *dnsCacheEntry) {
+	c.unlink(e)
+	delete(c.entries, e.key)
+}
+
+func (c *DNSCache) unlink(e *dnsCacheEntry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+}
+
+func (c *DNSCache) pushFront(e *dnsCacheEntry) {
+	e.prev = &c.lru
+	e.next = c.lru.next
+	e.prev.next = e
+	e.next.prev = e
+}
+
+// answerTTL returns the lowest TTL of the answer records in p, which
+// must be positioned at the start of the answer section.
+func answerTTL(p dnsmessage.Parser) (ttl uint32, ok bool) {
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return ttl, ok
+		}
+		if err != nil {
+			return 0, false
+		}
+		if !ok || h.TTL < ttl {
+			ttl, ok = h.TTL, true
+		}
+		if err := p.SkipAnswer(); err != nil {
+			return 0, false
+		}
+	}
+}
+
+// negativeTTL returns how long the negative response in p may be
+// cached, as described in RFC 2308 section 5. The parser p must be
+// positioned at the start of the answer section.
+func negativeTTL(p dnsmessage.Parser) (ttl uint32, ok bool) {
+	if err := p.SkipAllAnswers(); err != nil {
+		return 0, false
+	}
+	for {
+		h, err := p.AuthorityHeader()
+		if err != nil {
+			return 0, false
+		}
+		if h.Type != dnsmessage.TypeSOA {
+			if err := p.SkipAuthority(); err != nil {
+				return 0, false
+			}
+			continue
+		}
+		soa, err := p.SOAResource()
+		if err != nil {
+			return 0, false
+		}
+		ttl = h.TTL
+		if soa.MinTTL < ttl {
+			ttl = soa.MinTTL
+		}
+		return ttl, true
+	}
+}
+
+// lowerASCIIString returns s with ASCII letters mapped to lower case.
+func lowerASCIIString(s string) string {
+	for i := 0; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			b := []byte(s)
+			lowerASCIIBytes(b[i:])
+			return string(b)
+		}
+	}
+	return s
+}
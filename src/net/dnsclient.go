@@ -5,12 +5,28 @@
 package net
 
 import (
+	"context"
 	"math/rand"
 	"sort"
 
 	"golang.org/x/net/dns/dnsmessage"
 )
 
+// A DNSUpstream is a DNS server used by Go's built-in DNS resolver in
+// place of the name servers configured in /etc/resolv.conf.
+// See Resolver.Upstreams.
+type DNSUpstream interface {
+	// Exchange sends the DNS query message req to the server and
+	// returns its response. Both messages are in the wire format of
+	// RFC 1035 section 4, without a length prefix. Exchange must not
+	// modify or retain req, and must return once ctx is done.
+	Exchange(ctx context.Context, req []byte) (resp []byte, err error)
+
+	// String returns a description of the server, such as its
+	// address, for use in DNSError.Server.
+	String() string
+}
+
 // reverseaddr returns the in-addr.arpa. or ip6.arpa. hostname of the IP
 // address addr suitable for rDNS (PTR) record lookup or an error if it fails
 // to parse the IP address.
@@ -182,6 +182,58 @@ func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Que
 	return dnsmessage.Parser{}, dnsmessage.Header{}, errNoAnswerFromDNSServer
 }
 
+// exchangeUpstream sends a query to an upstream and checks its
+// response the way exchange does.
+func (r *Resolver) exchangeUpstream(ctx context.Context, u DNSUpstream, q dnsmessage.Question, timeout time.Duration) (dnsmessage.Parser, dnsmessage.Header, error) {
+	q.Class = dnsmessage.ClassINET
+	id, req, _, err := newRequest(q)
+	if err != nil {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotMarshalDNSMessage
+	}
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(timeout))
+	defer cancel()
+
+	resp, err := u.Exchange(ctx, req)
+	if err != nil {
+		// Upstreams may wrap context errors in their own;
+		// report them the same way as for other servers.
+		if ctx.Err() != nil {
+			err = mapErr(ctx.Err())
+		}
+		return dnsmessage.Parser{}, dnsmessage.Header{}, upstreamError{err}
+	}
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotUnmarshalDNSMessage
+	}
+	rq, err := p.Question()
+	if err != nil {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotUnmarshalDNSMessage
+	}
+	if !checkResponse(id, q, h, rq) {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+	}
+	if err := p.SkipQuestion(); err != dnsmessage.ErrSectionDone {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+	}
+	return p, h, nil
+}
+
+// An upstreamError is an error returned by DNSUpstream.Exchange.
+// Like socket-level errors talking to other servers, it is temporary.
+type upstreamError struct {
+	err error
+}
+
+func (e upstreamError) Error() string   { return e.err.Error() }
+func (e upstreamError) Temporary() bool { return true }
+
+func (e upstreamError) Timeout() bool {
+	t, ok := e.err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
+
 // checkHeader performs basic sanity checks on the header.
 func checkHeader(p *dnsmessage.Parser, h dnsmessage.Header) error {
 	if h.RCode == dnsmessage.RCodeNameError {
@@ -235,13 +287,50 @@ func skipToAnswer(p *dnsmessage.Parser, qtype dnsmessage.Type) error {
 // Do a lookup for a single name, which must be rooted
 // (otherwise answer will not find the answers).
 func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string, qtype dnsmessage.Type) (dnsmessage.Parser, string, error) {
+	cache := r.cache()
+	if cache == nil {
+		p, _, server, err := r.queryOneName(ctx, cfg, name, qtype)
+		return p, server, err
+	}
+	if v, ok := cache.get(name, qtype); ok {
+		if v.notFound != nil {
+			dnsErr := *v.notFound
+			return v.p, v.server, &dnsErr
+		}
+		return v.p, v.server, nil
+	}
+	p, resp, server, err := r.queryOneName(ctx, cfg, name, qtype)
+	v := dnsCacheValue{p: p, server: server}
+	if err != nil {
+		dnsErr, ok := err.(*DNSError)
+		if !ok || !dnsErr.IsNotFound {
+			return p, server, err
+		}
+		// Keep a copy, as callers may modify the error.
+		notFound := *dnsErr
+		v.notFound = &notFound
+	}
+	cache.put(name, qtype, v, resp)
+	return p, server, err
+}
+
+// queryOneName is tryOneName without the cache. Besides the results
+// of tryOneName, it returns the response positioned at the start of
+// its answer section.
+func (r *Resolver) queryOneName(ctx context.Context, cfg *dnsConfig, name string, qtype dnsmessage.Type) (p, resp dnsmessage.Parser, server string, err error) {
 	var lastErr error
 	serverOffset := cfg.serverOffset()
 	sLen := uint32(len(cfg.servers))
+	upstreams := r != nil && len(r.Upstreams) > 0
+	if upstreams {
+		// Upstreams are always tried in the configured order.
+		serverOffset = 0
+		sLen = uint32(len(r.Upstreams))
+	}
 
 	n, err := dnsmessage.NewName(name)
 	if err != nil {
-		return dnsmessage.Parser{}, "", errCannotMarshalDNSMessage
+		return dnsmessage.Parser{}, dnsmessage.Parser{}, "", errCannotMarshalDNSMessage
 	}
 	q := dnsmessage.Question{
 		Name:  n,
@@ -251,9 +340,15 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 
 	for i := 0; i < cfg.attempts; i++ {
 		for j := uint32(0); j < sLen; j++ {
-			server := cfg.servers[(serverOffset+j)%sLen]
-
-			p, h, err := r.exchange(ctx, server, q, cfg.timeout, cfg.useTCP)
+			var h dnsmessage.Header
+			if upstreams {
+				u := r.Upstreams[j]
+				server = u.String()
+				p, h, err = r.exchangeUpstream(ctx, u, q, cfg.timeout)
+			} else {
+				server = cfg.servers[(serverOffset+j)%sLen]
+				p, h, err = r.exchange(ctx, server, q, cfg.timeout, cfg.useTCP)
+			}
 			if err != nil {
 				dnsErr := &DNSError{
 					Err:    err.Error(),
@@ -263,14 +358,17 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 				if nerr, ok := err.(Error); ok && nerr.Timeout() {
 					dnsErr.IsTimeout = true
 				}
-				// Set IsTemporary for socket-level errors. Note that this flag
-				// may also be used to indicate a SERVFAIL response.
-				if _, ok := err.(*OpError); ok {
+				// Set IsTemporary for socket-level and upstream transport
+				// errors. Note that this flag may also be used to indicate
+				// a SERVFAIL response.
+				switch err.(type) {
+				case *OpError, upstreamError:
 					dnsErr.IsTemporary = true
 				}
 				lastErr = dnsErr
 				continue
 			}
+			resp = p
 
 			if err := checkHeader(&p, h); err != nil {
 				dnsErr := &DNSError{
@@ -286,7 +384,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 					// another server won't help.
 
 					dnsErr.IsNotFound = true
-					return p, server, dnsErr
+					return p, resp, server, dnsErr
 				}
 				lastErr = dnsErr
 				continue
@@ -294,7 +392,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 
 			err = skipToAnswer(&p, qtype)
 			if err == nil {
-				return p, server, nil
+				return p, resp, server, nil
 			}
 			lastErr = &DNSError{
 				Err:    err.Error(),
@@ -306,11 +404,11 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 				// server won't help.
 
 				lastErr.(*DNSError).IsNotFound = true
-				return p, server, lastErr
+				return p, resp, server, lastErr
 			}
 		}
 	}
-	return dnsmessage.Parser{}, "", lastErr
+	return dnsmessage.Parser{}, dnsmessage.Parser{}, "", lastErr
 }
 
 // A resolverConfig represents a DNS stub resolver configuration.
@@ -1800,3 +1800,289 @@ func TestPTRandNonPTR(t *testing.T) {
 		t.Errorf("names = %q; want %q", names, want)
 	}
 }
+
+type fakeUpstream struct {
+	name string
+	rh   func(q dnsmessage.Message) (dnsmessage.Message, error)
+	used *[]string
+}
+
+func (u fakeUpstream) Exchange(ctx context.Context, req []byte) ([]byte, error) {
+	var q dnsmessage.Message
+	if err := q.Unpack(req); err != nil {
+		return nil, err
+	}
+	*u.used = append(*u.used, u.name)
+	r, err := u.rh(q)
+	if err != nil {
+		return nil, err
+	}
+	return r.Pack()
+}
+
+func (u fakeUpstream) String() string { return u.name }
+
+func TestResolverUpstreams(t *testing.T) {
+	defer dnsWaitGroup.Wait()
+
+	conf, err := newResolvConfTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conf.teardown()
+
+	// Upstreams replace the name servers and ignore rotation.
+	if err := conf.writeAndUpdate([]string{"nameserver 192.0.2.1", "options rotate attempts:1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	refused := func(q dnsmessage.Message) (dnsmessage.Message, error) {
+		return dnsmessage.Message{}, errors.New("connection refused")
+	}
+	servfail := func(q dnsmessage.Message) (dnsmessage.Message, error) {
+		r := mockTXTResponse(q)
+		r.RCode = dnsmessage.RCodeServerFailure
+		r.Answers = nil
+		return r, nil
+	}
+	nxdomain := func(q dnsmessage.Message) (dnsmessage.Message, error) {
+		r := mockTXTResponse(q)
+		r.RCode = dnsmessage.RCodeNameError
+		r.Answers = nil
+		return r, nil
+	}
+	wrongID := func(q dnsmessage.Message) (dnsmessage.Message, error) {
+		r := mockTXTResponse(q)
+		r.ID++
+		return r, nil
+	}
+	ok := func(q dnsmessage.Message) (dnsmessage.Message, error) {
+		return mockTXTResponse(q), nil
+	}
+
+	tests := []struct {
+		name     string
+		rhs      []func(dnsmessage.Message) (dnsmessage.Message, error)
+		wantUsed []string
+		wantErr  *DNSError // nil for success
+	}{
+		{
+			name:     "first answers",
+			rhs:      []func(dnsmessage.Message) (dnsmessage.Message, error){ok, refused},
+			wantUsed: []string{"u0"},
+		},
+		{
+			name:     "fallback",
+			rhs:      []func(dnsmessage.Message) (dnsmessage.Message, error){refused, servfail, wrongID, ok},
+			wantUsed: []string{"u0", "u1", "u2", "u3"},
+		},
+		{
+			name:     "no such host stops",
+			rhs:      []func(dnsmessage.Message) (dnsmessage.Message, error){nxdomain, ok},
+			wantUsed: []string{"u0"},
+			wantErr:  &DNSError{Server: "u0", IsNotFound: true},
+		},
+		{
+			name:     "all fail",
+			rhs:      []func(dnsmessage.Message) (dnsmessage.Message, error){servfail, refused},
+			wantUsed: []string{"u0", "u1"},
+			wantErr:  &DNSError{Err: "connection refused", Server: "u1", IsTemporary: true},
+		},
+	}
+	for _, tt := range tests {
+		var used []string
+		r := Resolver{}
+		for i, rh := range tt.rhs {
+			r.Upstreams = append(r.Upstreams, fakeUpstream{name: "u" + itoa(i), rh: rh, used: &used})
+		}
+		txt, err := r.LookupTXT(context.Background(), "www.golang.org.")
+		if !reflect.DeepEqual(used, tt.wantUsed) {
+			t.Errorf("%s: used upstreams %v; want %v", tt.name, used, tt.wantUsed)
+		}
+		if tt.wantErr == nil {
+			if err != nil || len(txt) != 1 || txt[0] != "ok" {
+				t.Errorf("%s: LookupTXT = %q, %v; want [ok]", tt.name, txt, err)
+			}
+			continue
+		}
+		de, isDNSErr := err.(*DNSError)
+		if !isDNSErr {
+			t.Errorf("%s: LookupTXT error = %v; want a DNSError", tt.name, err)
+			continue
+		}
+		if de.Server != tt.wantErr.Server || de.IsNotFound != tt.wantErr.IsNotFound || de.IsTemporary != tt.wantErr.IsTemporary ||
+			tt.wantErr.Err != "" && de.Err != tt.wantErr.Err {
+			t.Errorf("%s: LookupTXT error = %#v; want %#v", tt.name, de, tt.wantErr)
+		}
+	}
+}
+
+func TestResolverUpstreamTimeout(t *testing.T) {
+	var used []string
+	r := Resolver{Upstreams: []DNSUpstream{fakeUpstream{name: "slow", used: &used, rh: func(q dnsmessage.Message) (dnsmessage.Message, error) {
+		time.Sleep(100 * time.Millisecond)
+		return dnsmessage.Message{}, errors.New("Post https://slow/dns-query: context deadline exceeded")
+	}}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := r.LookupTXT(ctx, "www.golang.org.")
+	if de, ok := err.(*DNSError); !ok || !de.IsTimeout || de.Server != "slow" {
+		t.Errorf("LookupTXT error = %#v; want timeout from slow", err)
+	}
+}
+
+// newCacheTestResolver returns a Resolver with the cache c whose upstream
+// answers TXT queries and counts them in queries. It answers
+// www.golang.org. with a TTL of 60 seconds, missing.golang.org. with
+// an NXDOMAIN response whose SOA record allows 30 seconds of negative
+// caching, nosoa.golang.org. with an NXDOMAIN response without SOA,
+// fail.golang.org. with SERVFAIL, and other names with a TTL of 60.
+func newCacheTestResolver(c *DNSCache, queries map[string]int) *Resolver {
+	var used []string
+	rh := func(q dnsmessage.Message) (dnsmessage.Message, error) {
+		name := q.Questions[0].Name.String()
+		queries[name]++
+		r := mockTXTResponse(q)
+		switch name {
+		case "missing.golang.org.", "nosoa.golang.org.":
+			r.RCode = dnsmessage.RCodeNameError
+			r.Answers = nil
+			if name == "missing.golang.org." {
+				r.Authorities = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{
+						Name:  dnsmessage.MustNewName("golang.org."),
+						Type:  dnsmessage.TypeSOA,
+						Class: dnsmessage.ClassINET,
+						TTL:   300,
+					},
+					Body: &dnsmessage.SOAResource{
+						NS:     dnsmessage.MustNewName("ns1.golang.org."),
+						MBox:   dnsmessage.MustNewName("hostmaster.golang.org."),
+						MinTTL: 30,
+					},
+				}}
+			}
+		case "fail.golang.org.":
+			r.RCode = dnsmessage.RCodeServerFailure
+			r.Answers = nil
+		default:
+			r.Answers[0].Header.TTL = 60
+		}
+		return r, nil
+	}
+	return &Resolver{
+		Upstreams: []DNSUpstream{fakeUpstream{name: "u", rh: rh, used: &used}},
+		Cache:     c,
+	}
+}
+
+func TestDNSCache(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func() { testHookDNSCacheNow = time.Now }()
+	testHookDNSCacheNow = func() time.Time { return now }
+
+	var cache DNSCache
+	queries := make(map[string]int)
+	r := newCacheTestResolver(&cache, queries)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		advance     time.Duration // before the lookup
+		wantQueries int           // total for name after the lookup
+		wantErr     bool
+	}{
+		{"www.golang.org.", 0, 1, false},
+		{"www.golang.org.", 59 * time.Second, 1, false},
+		{"WWW.Golang.ORG.", 0, 1, false},
+		{"www.golang.org.", time.Second, 2, false},
+		{"missing.golang.org.", 0, 1, true},
+		{"missing.golang.org.", 29 * time.Second, 1, true},
+		{"missing.golang.org.", time.Second, 2, true},
+		{"nosoa.golang.org.", 0, 1, true},
+		{"nosoa.golang.org.", 0, 2, true},
+	}
+	for i, tt := range tests {
+		now = now.Add(tt.advance)
+		txt, err := r.LookupTXT(ctx, tt.name)
+		if tt.wantErr {
+			de, ok := err.(*DNSError)
+			if !ok {
+				t.Fatalf("#%d: LookupTXT(%s) error = %v; want a DNSError", i, tt.name, err)
+			}
+			if !de.IsNotFound || de.Name != tt.name {
+				t.Errorf("#%d: LookupTXT(%s) error = %#v; want not found", i, tt.name, de)
+			}
+		} else if err != nil || len(txt) != 1 || txt[0] != "ok" {
+			t.Errorf("#%d: LookupTXT(%s) = %q, %v; want [ok]", i, tt.name, txt, err)
+		}
+		if got := queries[lowerASCIIString(tt.name)]; got != tt.wantQueries {
+			t.Errorf("#%d: %s queried %d times; want %d", i, tt.name, got, tt.wantQueries)
+		}
+	}
+
+	// Failures are never cached.
+	var perLookup int
+	for i := 1; i <= 2; i++ {
+		_, err := r.LookupTXT(ctx, "fail.golang.org.")
+		if de, ok := err.(*DNSError); !ok || !de.IsTemporary {
+			t.Errorf("LookupTXT(fail.golang.org.) error = %#v; want temporary error", err)
+		}
+		if i == 1 {
+			perLookup = queries["fail.golang.org."]
+		}
+	}
+	if n := queries["fail.golang.org."]; n != 2*perLookup {
+		t.Errorf("fail.golang.org. queried %d times by 2 lookups; want %d", n, 2*perLookup)
+	}
+
+	want := DNSCacheStats{Entries: 2, Hits: 3, Misses: 8}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v; want %+v", got, want)
+	}
+	cache.Flush()
+	if n := cache.Stats().Entries; n != 0 {
+		t.Errorf("after Flush, Stats().Entries = %d; want 0", n)
+	}
+	if _, err := r.LookupTXT(ctx, "www.golang.org."); err != nil {
+		t.Fatal(err)
+	}
+	if n := queries["www.golang.org."]; n != 3 {
+		t.Errorf("after Flush, www.golang.org. queried %d times; want 3", n)
+	}
+}
+
+func TestDNSCacheLimits(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	defer func() { testHookDNSCacheNow = time.Now }()
+	testHookDNSCacheNow = func() time.Time { return now }
+
+	cache := DNSCache{MaxEntries: 2, MaxTTL: 10 * time.Second}
+	queries := make(map[string]int)
+	r := newCacheTestResolver(&cache, queries)
+	ctx := context.Background()
+
+	for _, name := range []string{"a.golang.org.", "b.golang.org.", "a.golang.org.", "c.golang.org.", "a.golang.org.", "b.golang.org."} {
+		if _, err := r.LookupTXT(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// b is the least recently used entry when c is added.
+	wantQueries := map[string]int{"a.golang.org.": 1, "b.golang.org.": 2, "c.golang.org.": 1}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Errorf("queries = %v; want %v", queries, wantQueries)
+	}
+	want := DNSCacheStats{Entries: 2, Hits: 2, Misses: 4, Evictions: 2}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v; want %+v", got, want)
+	}
+
+	// MaxTTL shortens the TTL of 60 seconds.
+	now = now.Add(10 * time.Second)
+	if _, err := r.LookupTXT(ctx, "a.golang.org."); err != nil {
+		t.Fatal(err)
+	}
+	if n := queries["a.golang.org."]; n != 2 {
+		t.Errorf("a.golang.org. queried %d times; want 2", n)
+	}
+}
@@ -58,6 +58,10 @@ func (e *OpError) isValid() error {
 			if addr == "" {
 				return fmt.Errorf("OpError.Source or Addr is empty: %#v, %v", addr, e)
 			}
+		case addrPortUDPAddr:
+			if !addr.IsValid() {
+				return fmt.Errorf("OpError.Source or Addr is invalid: %#v, %v", addr, e)
+			}
 		default:
 			return fmt.Errorf("OpError.Source or Addr is unknown type: %T, %v", addr, e)
 		}
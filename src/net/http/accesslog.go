@@ -0,0 +1,163 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Server access logging.
+
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// An AccessRecord describes a request served by a Server and the
+// response to it. It's passed to the optional Server.AccessLog hook.
+type AccessRecord struct {
+	// Request is the request as seen by the handler. Its Body
+	// must not be used.
+	Request *Request
+
+	Method     string
+	URL        *url.URL
+	Proto      string // the request's protocol, such as "HTTP/1.1"
+	RemoteAddr string
+
+	// TLS is the TLS connection state, or nil for requests
+	// received without TLS.
+	TLS *tls.ConnectionState
+
+	// StatusCode is the response status code. If the handler
+	// returned without writing a response header, it's 200, which
+	// the server then sends. It's zero if no response was sent
+	// because the handler panicked or hijacked the connection
+	// before writing a response header.
+	StatusCode int
+
+	// BytesWritten is the number of response body bytes written
+	// by the handler. BytesRead is the number of request body
+	// bytes read by the handler.
+	BytesWritten int64
+	BytesRead    int64
+
+	// Start is when the server started serving the request,
+	// before it waited for a handler because of
+	// Server.MaxConcurrentHandlers.
+	Start time.Time
+
+	// FirstByte is the time from Start until the handler wrote
+	// the response header, explicitly or with its first Write.
+	// If the handler wrote no response header, it's the same as
+	// Duration.
+	FirstByte time.Duration
+
+	// Duration is the time from Start until the handler returned.
+	Duration time.Duration
+
+	// Err is non-nil if the handler panicked. If the panic value
+	// is an error, such as ErrAbortHandler, Err is that error.
+	Err error
+}
+
+// responseStatser is implemented by the ResponseWriters of the
+// HTTP/1 and HTTP/2 servers, so the access log hook needn't wrap
+// them and hide their optional interfaces from handlers.
+type responseStatser interface {
+	// timeResponseHeader asks the ResponseWriter to record when
+	// the final response header is written.
+	timeResponseHeader()
+
+	responseStats() (wroteHeader bool, status int, written int64, wroteHeaderAt time.Time)
+}
+
+func (w *response) timeResponseHeader() {
+	w.timeHeader = true
+}
+
+func (w *response) responseStats() (wroteHeader bool, status int, written int64, wroteHeaderAt time.Time) {
+	return w.wroteHeader, w.status, w.written, w.wroteHeaderAt
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64 // accessed atomically
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.n, int64(n))
+	return n, err
+}
+
+// logAccess prepares to record the serving of req with rw, and
+// returns a function that calls srv.AccessLog. The returned
+// function must be deferred, so it can observe handler panics.
+// It propagates them from within the deferred call, so the stack
+// trace logged by the server still shows where the handler panicked.
+func (srv *Server) logAccess(rw ResponseWriter, req *Request) func() {
+	start := time.Now()
+	rs, _ := rw.(responseStatser)
+	if rs != nil {
+		rs.timeResponseHeader()
+	}
+	var cb *countingBody
+	if req.Body != nil && req.Body != NoBody {
+		cb = &countingBody{ReadCloser: req.Body}
+		req.Body = cb
+	}
+	return func() {
+		end := time.Now()
+		rec := &AccessRecord{
+			Request:    req,
+			Method:     req.Method,
+			URL:        req.URL,
+			Proto:      req.Proto,
+			RemoteAddr: req.RemoteAddr,
+			TLS:        req.TLS,
+			Start:      start,
+			Duration:   end.Sub(start),
+			FirstByte:  end.Sub(start),
+		}
+		if cb != nil {
+			rec.BytesRead = atomic.LoadInt64(&cb.n)
+			// The HTTP/1 server inspects the body's concrete
+			// type once the handler returns.
+			if req.Body == cb {
+				req.Body = cb.ReadCloser
+			}
+		}
+		v := recover()
+		if v != nil {
+			if err, ok := v.(error); ok {
+				rec.Err = err
+			} else {
+				rec.Err = fmt.Errorf("panic: %v", v)
+			}
+		}
+		if rs != nil {
+			wroteHeader, status, written, wroteHeaderAt := rs.responseStats()
+			rec.BytesWritten = written
+			switch {
+			case wroteHeader:
+				rec.StatusCode = status
+				rec.FirstByte = wroteHeaderAt.Sub(start)
+			case v == nil && !isHijacked(rw):
+				rec.StatusCode = StatusOK
+			}
+		}
+		srv.AccessLog(rec)
+		if v != nil {
+			panic(v)
+		}
+	}
+}
+
+func isHijacked(rw ResponseWriter) bool {
+	w, ok := rw.(*response)
+	return ok && w.conn.hijacked()
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"io"
+	"io/ioutil"
+	. "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// accessLogTest starts a server with h and an AccessLog hook, and
+// returns the server and a channel of the records passed to the hook.
+func accessLogTest(t *testing.T, h2 bool, h HandlerFunc) (*clientServerTest, <-chan *AccessRecord) {
+	recs := make(chan *AccessRecord, 1)
+	cst := newClientServerTest(t, h2, h, func(ts *httptest.Server) {
+		ts.Config.ErrorLog = quietLog
+		ts.Config.AccessLog = func(rec *AccessRecord) {
+			recs <- rec
+		}
+	})
+	return cst, recs
+}
+
+func waitAccessRecord(t *testing.T, recs <-chan *AccessRecord) *AccessRecord {
+	t.Helper()
+	select {
+	case rec := <-recs:
+		return rec
+	case <-time.After(10 * time.Second):
+		t.Fatal("AccessLog not called")
+		return nil
+	}
+}
+
+func TestServerAccessLog_h1(t *testing.T) { testServerAccessLog(t, h1Mode) }
+func TestServerAccessLog_h2(t *testing.T) { testServerAccessLog(t, h2Mode) }
+
+func testServerAccessLog(t *testing.T, h2 bool) {
+	setParallel(t)
+	defer afterTest(t)
+	cst, recs := accessLogTest(t, h2, func(w ResponseWriter, r *Request) {
+		if _, ok := w.(Flusher); !ok {
+			t.Error("ResponseWriter is not a Flusher")
+		}
+		io.CopyN(ioutil.Discard, r.Body, 3)
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(StatusCreated)
+		io.WriteString(w, "hello, ")
+		w.(Flusher).Flush()
+		io.WriteString(w, "world")
+	})
+	defer cst.close()
+
+	res, err := cst.c.Post(cst.ts.URL+"/path?q=1", "text/plain", strings.NewReader("abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	rec := waitAccessRecord(t, recs)
+	wantProto := "HTTP/1.1"
+	if h2 {
+		wantProto = "HTTP/2.0"
+	}
+	if rec.Method != "POST" || rec.URL.String() != "/path?q=1" || rec.Proto != wantProto {
+		t.Errorf("request = %s %v %s; want POST /path?q=1 %s", rec.Method, rec.URL, rec.Proto, wantProto)
+	}
+	if rec.StatusCode != StatusCreated {
+		t.Errorf("StatusCode = %d; want %d", rec.StatusCode, StatusCreated)
+	}
+	if rec.BytesWritten != int64(len("hello, world")) {
+		t.Errorf("BytesWritten = %d; want %d", rec.BytesWritten, len("hello, world"))
+	}
+	if rec.BytesRead != 3 {
+		t.Errorf("BytesRead = %d; want 3", rec.BytesRead)
+	}
+	if rec.RemoteAddr == "" || rec.RemoteAddr != rec.Request.RemoteAddr {
+		t.Errorf("RemoteAddr = %q; want %q", rec.RemoteAddr, rec.Request.RemoteAddr)
+	}
+	if (rec.TLS != nil) != h2 {
+		t.Errorf("TLS = %v; want TLS only for HTTP/2", rec.TLS)
+	}
+	if rec.FirstByte < 10*time.Millisecond || rec.FirstByte > rec.Duration {
+		t.Errorf("FirstByte = %v, Duration = %v; want 10ms <= FirstByte <= Duration", rec.FirstByte, rec.Duration)
+	}
+	if rec.Start.IsZero() || rec.Err != nil {
+		t.Errorf("Start = %v, Err = %v; want non-zero Start and nil Err", rec.Start, rec.Err)
+	}
+}
+
+func TestServerAccessLogImplicitStatus_h1(t *testing.T) { testServerAccessLogImplicitStatus(t, h1Mode) }
+func TestServerAccessLogImplicitStatus_h2(t *testing.T) { testServerAccessLogImplicitStatus(t, h2Mode) }
+
+func testServerAccessLogImplicitStatus(t *testing.T, h2 bool) {
+	setParallel(t)
+	defer afterTest(t)
+	cst, recs := accessLogTest(t, h2, func(w ResponseWriter, r *Request) {})
+	defer cst.close()
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	rec := waitAccessRecord(t, recs)
+	if rec.StatusCode != StatusOK || rec.BytesWritten != 0 || rec.BytesRead != 0 {
+		t.Errorf("StatusCode, BytesWritten, BytesRead = %d, %d, %d; want 200, 0, 0", rec.StatusCode, rec.BytesWritten, rec.BytesRead)
+	}
+	if rec.FirstByte != rec.Duration {
+		t.Errorf("FirstByte = %v; want Duration, %v", rec.FirstByte, rec.Duration)
+	}
+}
+
+func TestServerAccessLogPanic_h1(t *testing.T) { testServerAccessLogPanic(t, h1Mode) }
+func TestServerAccessLogPanic_h2(t *testing.T) { testServerAccessLogPanic(t, h2Mode) }
+
+func testServerAccessLogPanic(t *testing.T, h2 bool) {
+	setParallel(t)
+	defer afterTest(t)
+	cst, recs := accessLogTest(t, h2, func(w ResponseWriter, r *Request) {
+		panic("boom")
+	})
+	defer cst.close()
+
+	if res, err := cst.c.Get(cst.ts.URL); err == nil {
+		res.Body.Close()
+		t.Fatal("request to panicking handler succeeded")
+	}
+	rec := waitAccessRecord(t, recs)
+	if rec.Err == nil || rec.Err.Error() != "panic: boom" {
+		t.Errorf("Err = %v; want panic: boom", rec.Err)
+	}
+	if rec.StatusCode != 0 {
+		t.Errorf("StatusCode = %d; want 0", rec.StatusCode)
+	}
+}
+
+func TestServerAccessLogHijack(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	cst, recs := accessLogTest(t, h1Mode, func(w ResponseWriter, r *Request) {
+		conn, bufrw, err := w.(Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		bufrw.WriteString("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n")
+		bufrw.Flush()
+	})
+	defer cst.close()
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if rec := waitAccessRecord(t, recs); rec.StatusCode != 0 {
+		t.Errorf("StatusCode = %d; want 0 for a hijacked connection", rec.StatusCode)
+	}
+}
@@ -9,6 +9,7 @@ package http_test
 import (
 	"bytes"
 	"compress/gzip"
+	"compress/zstd"
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/tls"
@@ -20,7 +21,9 @@ import (
 	"net"
 	. "net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/http/httputil"
+	"net/textproto"
 	"net/url"
 	"os"
 	"reflect"
@@ -440,6 +443,25 @@ func TestH12_AutoGzip(t *testing.T) {
 	}.run(t)
 }
 
+// Verify that with AcceptZstd, both HTTP/1 and HTTP/2 request and
+// auto-decompress zstd.
+func TestH12_AutoZstd(t *testing.T) {
+	h12Compare{
+		Opts: []interface{}{
+			func(tr *Transport) { tr.AcceptZstd = true },
+		},
+		Handler: func(w ResponseWriter, r *Request) {
+			if ae := r.Header.Get("Accept-Encoding"); ae != "gzip, zstd" {
+				t.Errorf("%s Accept-Encoding = %q; want gzip, zstd", r.Proto, ae)
+			}
+			w.Header().Set("Content-Encoding", "zstd")
+			zw := zstd.NewWriter(w)
+			io.WriteString(zw, "I am some zstd content. Go go go go go go go go go go go go should compress well.")
+			zw.Close()
+		},
+	}.run(t)
+}
+
 func TestH12_AutoGzip_Disabled(t *testing.T) {
 	h12Compare{
 		Opts: []interface{}{
@@ -486,6 +508,96 @@ func test304Responses(t *testing.T, h2 bool) {
 	}
 }
 
+func TestInformationalResponses_h1(t *testing.T) { testInformationalResponses(t, h1Mode) }
+func TestInformationalResponses_h2(t *testing.T) { testInformationalResponses(t, h2Mode) }
+
+func testInformationalResponses(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		h := w.Header()
+		h.Set("Content-Length", "5")
+		h.Add("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(StatusEarlyHints)
+		h.Add("Link", "</script.js>; rel=preload; as=script")
+		w.WriteHeader(StatusEarlyHints)
+		w.WriteHeader(StatusOK)
+		io.WriteString(w, "hello")
+	}))
+	defer cst.close()
+
+	var got []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if cl, ok := header["Content-Length"]; ok {
+				t.Errorf("%d response has Content-Length %q", code, cl)
+			}
+			got = append(got, fmt.Sprintf("%d %q", code, header["Link"]))
+			return nil
+		},
+	}
+	req, _ := NewRequest("GET", cst.ts.URL, nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	want := []string{
+		`103 ["</style.css>; rel=preload; as=style"]`,
+		`103 ["</style.css>; rel=preload; as=style" "</script.js>; rel=preload; as=script"]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("informational responses:\n got %q\nwant %q", got, want)
+	}
+	if res.StatusCode != StatusOK {
+		t.Errorf("status = %v; want 200", res.Status)
+	}
+	if n := len(res.Header["Link"]); n != 2 {
+		t.Errorf("final response has %d Link headers; want 2", n)
+	}
+	if body, err := ioutil.ReadAll(res.Body); err != nil || string(body) != "hello" {
+		t.Errorf("body = %q, %v; want hello", body, err)
+	}
+}
+
+// HTTP/2 forbids 101 Switching Protocols, so the server ignores it.
+func TestSwitchingProtocolsIgnored_h2(t *testing.T) {
+	defer afterTest(t)
+	var errorLog lockedBytesBuffer
+	cst := newClientServerTest(t, h2Mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(StatusSwitchingProtocols)
+		io.WriteString(w, "hello")
+	}), optWithServerLog(log.New(&errorLog, "", 0)))
+	defer cst.close()
+
+	var got []int
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			got = append(got, code)
+			return nil
+		},
+	}
+	req, _ := NewRequest("GET", cst.ts.URL, nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if len(got) > 0 {
+		t.Errorf("got informational responses %v; want none", got)
+	}
+	if res.StatusCode != StatusOK {
+		t.Errorf("status = %v; want 200", res.Status)
+	}
+	if body, err := ioutil.ReadAll(res.Body); err != nil || string(body) != "hello" {
+		t.Errorf("body = %q, %v; want hello", body, err)
+	}
+	if !strings.Contains(errorLog.String(), "ignoring WriteHeader(101)") {
+		t.Errorf("server log = %q; want a message about ignoring 101", errorLog.String())
+	}
+}
+
 func TestH12_ServerEmptyContentLength(t *testing.T) {
 	h12Compare{
 		Handler: func(w ResponseWriter, r *Request) {
@@ -1577,3 +1689,82 @@ func TestH12_WebSocketUpgrade(t *testing.T) {
 		},
 	}.run(t)
 }
+
+func TestUnencryptedHTTP2(t *testing.T) {
+	CondSkipHTTP2(t)
+	defer afterTest(t)
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	ts.Config.Protocols = new(Protocols)
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+
+	get := func(protos Protocols) (string, error) {
+		tr := &Transport{Protocols: &protos}
+		defer tr.CloseIdleConnections()
+		res, err := (&Client{Transport: tr}).Get(ts.URL)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		slurp, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return "", err
+		}
+		if res.Proto != string(slurp) {
+			t.Errorf("response proto = %q; handler saw %q", res.Proto, slurp)
+		}
+		return string(slurp), nil
+	}
+
+	var h2c Protocols
+	h2c.SetUnencryptedHTTP2(true)
+	if got, err := get(h2c); err != nil || got != "HTTP/2.0" {
+		t.Errorf("h2c client: got %q, %v; want HTTP/2.0", got, err)
+	}
+	var h1 Protocols
+	h1.SetHTTP1(true)
+	h1.SetUnencryptedHTTP2(true)
+	if got, err := get(h1); err != nil || got != "HTTP/1.1" {
+		t.Errorf("HTTP/1 client: got %q, %v; want HTTP/1.1", got, err)
+	}
+}
+
+func TestUnencryptedHTTP2Only(t *testing.T) {
+	CondSkipHTTP2(t)
+	defer afterTest(t)
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {}))
+	ts.Config.Protocols = new(Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Config.ErrorLog = quietLog
+	ts.Start()
+	defer ts.Close()
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	res, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err == nil {
+		res.Body.Close()
+		t.Fatalf("HTTP/1 request to h2c-only server succeeded with %v", res.Status)
+	}
+}
+
+func TestProtocolsString(t *testing.T) {
+	var p Protocols
+	if got, want := p.String(), "{}"; got != want {
+		t.Errorf("zero Protocols = %q; want %q", got, want)
+	}
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	if got, want := p.String(), "{HTTP1,UnencryptedHTTP2}"; got != want {
+		t.Errorf("Protocols = %q; want %q", got, want)
+	}
+	p.SetHTTP1(false)
+	p.SetHTTP2(true)
+	if got, want := p.String(), "{HTTP2,UnencryptedHTTP2}"; got != want {
+		t.Errorf("Protocols = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Server-side response compression.
+
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A ContentEncoder returns a new writer that encodes data written to
+// it with a content coding and writes the result to w. Closing the
+// returned writer must flush any buffered data and write the end of
+// the encoded stream, but must not close w.
+//
+// If the returned writer has a Flush method with the signature
+// Flush() error, it is called when the handler flushes the response.
+type ContentEncoder func(w io.Writer) (io.WriteCloser, error)
+
+var contentEncoders struct {
+	sync.RWMutex
+	names []string // in order of registration, which is the server's preference
+	m     map[string]ContentEncoder
+}
+
+func init() {
+	RegisterContentEncoder("gzip", func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	})
+	// The "deflate" content coding is the zlib format (RFC 7230, section 4.2.2).
+	RegisterContentEncoder("deflate", func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriter(w), nil
+	})
+}
+
+// RegisterContentEncoder registers a ContentEncoder for the named
+// content coding, such as "br", for use by CompressHandler.
+// Registering an encoder for a coding that is already registered,
+// such as the built-in "gzip" and "deflate", replaces it.
+//
+// When a client accepts several codings equally, CompressHandler
+// prefers the one registered first.
+func RegisterContentEncoder(coding string, enc ContentEncoder) {
+	coding = strings.ToLower(coding)
+	contentEncoders.Lock()
+	defer contentEncoders.Unlock()
+	if contentEncoders.m == nil {
+		contentEncoders.m = make(map[string]ContentEncoder)
+	}
+	if _, dup := contentEncoders.m[coding]; !dup {
+		contentEncoders.names = append(contentEncoders.names, coding)
+	}
+	contentEncoders.m[coding] = enc
+}
+
+// negotiateContentEncoding returns the registered content coding
+// that the Accept-Encoding header values accept with the highest
+// quality, or "" if none is acceptable.
+func negotiateContentEncoding(accept []string) (string, ContentEncoder) {
+	if len(accept) == 0 {
+		return "", nil
+	}
+	q := make(map[string]float64)
+	for _, v := range accept {
+		for _, s := range strings.Split(v, ",") {
+			name, params := s, ""
+			if i := strings.Index(s, ";"); i >= 0 {
+				name, params = s[:i], s[i+1:]
+			}
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			qv := 1.0
+			for _, p := range strings.Split(params, ";") {
+				p = strings.TrimSpace(p)
+				if len(p) > 2 && (p[0] == 'q' || p[0] == 'Q') && p[1] == '=' {
+					if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+						qv = f
+					}
+				}
+			}
+			q[name] = qv
+		}
+	}
+
+	contentEncoders.RLock()
+	defer contentEncoders.RUnlock()
+	var best string
+	var bestQ float64
+	for _, name := range contentEncoders.names {
+		qv, ok := q[name]
+		if !ok {
+			qv = q["*"]
+		}
+		if qv > bestQ {
+			best, bestQ = name, qv
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+	return best, contentEncoders.m[best]
+}
+
+// compressMinSize is the smallest response body CompressHandler
+// compresses, unless the handler flushes before writing that much.
+// It is also the amount of data buffered to sniff the content type.
+const compressMinSize = sniffLen
+
+// CompressHandler returns a handler that compresses the responses of h
+// using a content coding accepted by the client, as indicated by the
+// request's Accept-Encoding header. The gzip and deflate codings are
+// supported by default; more can be added with RegisterContentEncoder.
+//
+// A response is sent unmodified if it is the response to a HEAD
+// request, has a status code that does not permit a body or a
+// partial-content status, already has a Content-Encoding, has a
+// Cache-Control header with the no-transform directive, is shorter
+// than 512 bytes, or has a content type that is typically already
+// compressed, such as image/jpeg or application/zip. If the handler
+// does not set a Content-Type, it is detected from the data with
+// DetectContentType, as the Server would; if the handler flushes before
+// writing any data without setting a Content-Type, the response is sent
+// unmodified.
+//
+// When a response is compressed, its Content-Length and Accept-Ranges
+// headers are removed and a strong ETag is made weak, since it no
+// longer identifies the bytes sent. Every response gets a
+// "Vary: Accept-Encoding" header.
+//
+// Calls to Flush flush the compressed stream, so streaming responses
+// continue to work. The ResponseWriter passed to h also forwards
+// Hijacker and Pusher to the original ResponseWriter, and flush errors
+// are reported through ResponseController.
+func CompressHandler(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		coding, enc := negotiateContentEncoding(r.Header["Accept-Encoding"])
+		if enc == nil || r.Method == "HEAD" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{rw: w, coding: coding, enc: enc}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter is the ResponseWriter used by CompressHandler.
+type compressWriter struct {
+	rw     ResponseWriter
+	coding string
+	enc    ContentEncoder
+
+	code    int    // status passed to WriteHeader, or 0
+	buf     []byte // data written before the decision to compress
+	decided bool   // whether the header has been written to rw
+	flushed bool   // whether the handler called Flush
+	zw      io.WriteCloser
+}
+
+func (cw *compressWriter) Header() Header { return cw.rw.Header() }
+
+func (cw *compressWriter) Unwrap() ResponseWriter { return cw.rw }
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code <= 199 && code != StatusSwitchingProtocols {
+		cw.rw.WriteHeader(code)
+		return
+	}
+	if cw.decided || cw.code != 0 {
+		// Let the underlying ResponseWriter report the superfluous call.
+		cw.rw.WriteHeader(code)
+		return
+	}
+	checkWriteHeaderCode(code)
+	cw.code = code
+	if !bodyAllowedForStatus(code) || code == StatusSwitchingProtocols {
+		cw.decide()
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if cw.code == 0 {
+			cw.code = StatusOK
+		}
+		n := compressMinSize - len(cw.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		cw.buf = append(cw.buf, p[:n]...)
+		if len(cw.buf) < compressMinSize {
+			return n, nil
+		}
+		if err := cw.decide(); err != nil {
+			return n, err
+		}
+		if n == len(p) {
+			return n, nil
+		}
+		m, err := cw.Write(p[n:])
+		return n + m, err
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.rw.Write(p)
+}
+
+func (cw *compressWriter) Flush() { cw.FlushError() }
+
+// FlushError is like Flush but returns any error encountered writing
+// buffered data, flushing the encoder, or flushing the underlying
+// ResponseWriter. It lets a ResponseController report write failures.
+func (cw *compressWriter) FlushError() error {
+	cw.flushed = true
+	var err error
+	if !cw.decided {
+		if cw.code == 0 {
+			cw.code = StatusOK
+		}
+		err = cw.decide()
+	}
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		if ferr := f.Flush(); err == nil {
+			err = ferr
+		}
+	}
+	if ferr := NewResponseController(cw.rw).Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// Push forwards to the underlying ResponseWriter, so that HTTP/2
+// server push keeps working for wrapped handlers. The pushed
+// response is served, and compressed, on its own.
+func (cw *compressWriter) Push(target string, opts *PushOptions) error {
+	if p, ok := cw.rw.(Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return ErrNotSupported
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.rw.(Hijacker)
+	if !ok {
+		return nil, nil, ErrNotSupported
+	}
+	c, rw, err := hj.Hijack()
+	if err == nil {
+		// The handler owns the connection now; write nothing more.
+		cw.decided = true
+		cw.buf = nil
+	}
+	return c, rw, err
+}
+
+// decide writes the response header, choosing whether to compress
+// the body, and then writes any buffered data, returning any error
+// from that write.
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.rw.Header()
+	if _, haveType := h["Content-Type"]; !haveType && len(cw.buf) > 0 && h.Get("Transfer-Encoding") == "" {
+		h.Set("Content-Type", DetectContentType(cw.buf))
+	}
+	if cw.shouldCompress() {
+		zw, err := cw.enc(cw.rw)
+		if err == nil {
+			cw.zw = zw
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			h.Set("Content-Encoding", cw.coding)
+			if etag := h.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("Etag", "W/"+etag)
+			}
+		}
+	}
+	cw.rw.WriteHeader(cw.code)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) > 0 {
+		if _, err := cw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *compressWriter) shouldCompress() bool {
+	if !bodyAllowedForStatus(cw.code) || cw.code == StatusPartialContent || cw.code == StatusSwitchingProtocols {
+		return false
+	}
+	if !cw.flushed && len(cw.buf) < compressMinSize {
+		return false
+	}
+	h := cw.rw.Header()
+	if _, haveType := h["Content-Type"]; !haveType && len(cw.buf) == 0 {
+		// The handler flushed before writing anything, so there
+		// is no data to detect the content type from.
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n < compressMinSize {
+			return false
+		}
+	}
+	for _, v := range h["Cache-Control"] {
+		for _, d := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), "no-transform") {
+				return false
+			}
+		}
+	}
+	return compressibleContentType(h.Get("Content-Type"))
+}
+
+// close finishes the response after the handler returns.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.code == 0 {
+			cw.code = StatusOK
+		}
+		cw.decide()
+	}
+	if cw.zw != nil {
+		cw.zw.Close()
+	}
+}
+
+// compressibleContentType reports whether a body of the given
+// content type is likely to benefit from compression. It returns
+// false for the formats DetectContentType recognizes as already
+// compressed.
+func compressibleContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch mt {
+	case "image/svg+xml", "image/bmp", "image/x-icon", "audio/wave":
+		return true
+	case "application/x-gzip", "application/gzip", "application/zip",
+		"application/x-rar-compressed", "application/zstd",
+		"application/pdf", "font/woff", "font/woff2":
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mt, "image/"),
+		strings.HasPrefix(mt, "audio/"),
+		strings.HasPrefix(mt, "video/"):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,338 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	. "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var compressText = strings.Repeat("All work and no play makes Jack a dull boy.\n", 50)
+
+func TestCompressHandler(t *testing.T) {
+	pngHeader := "\x89PNG\x0D\x0A\x1A\x0A"
+	tests := []struct {
+		name     string
+		method   string
+		accept   string
+		header   map[string]string // set by the handler
+		code     int
+		body     string
+		wantCE   string // "" for uncompressed
+		wantETag string
+	}{
+		{name: "gzip", accept: "gzip, deflate", body: compressText, wantCE: "gzip"},
+		{name: "deflate by quality", accept: "gzip;q=0.5, deflate", body: compressText, wantCE: "deflate"},
+		{name: "wildcard", accept: "*", body: compressText, wantCE: "gzip"},
+		{name: "refused", accept: "gzip;q=0, identity", body: compressText},
+		{name: "no Accept-Encoding", body: compressText},
+		{name: "short", accept: "gzip", body: "hello"},
+		{name: "sniffed image", accept: "gzip", body: pngHeader + compressText},
+		{name: "declared image", accept: "gzip", header: map[string]string{"Content-Type": "image/jpeg"}, body: compressText},
+		{name: "svg", accept: "gzip", header: map[string]string{"Content-Type": "image/svg+xml"}, body: compressText, wantCE: "gzip"},
+		{name: "already encoded", accept: "gzip", header: map[string]string{"Content-Encoding": "br"}, body: compressText, wantCE: "br"},
+		{name: "no-transform", accept: "gzip", header: map[string]string{"Cache-Control": "public, no-transform"}, body: compressText},
+		{name: "HEAD", method: "HEAD", accept: "gzip", body: compressText},
+		{name: "no content", accept: "gzip", code: StatusNoContent},
+		{name: "partial content", accept: "gzip", header: map[string]string{"Content-Range": "bytes 0-10/100"}, code: StatusPartialContent, body: compressText},
+		{name: "strong ETag", accept: "gzip", header: map[string]string{"Etag": `"v1"`}, body: compressText, wantCE: "gzip", wantETag: `W/"v1"`},
+		{name: "weak ETag", accept: "gzip", header: map[string]string{"Etag": `W/"v1"`}, body: compressText, wantCE: "gzip", wantETag: `W/"v1"`},
+		{name: "uncompressed ETag", accept: "identity", header: map[string]string{"Etag": `"v1"`}, body: compressText, wantETag: `"v1"`},
+	}
+	for _, tt := range tests {
+		h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+			for k, v := range tt.header {
+				w.Header().Set(k, v)
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+			if tt.code != 0 {
+				w.WriteHeader(tt.code)
+			}
+			// Write in small pieces to exercise buffering.
+			for b := tt.body; len(b) > 0; {
+				n := 100
+				if n > len(b) {
+					n = len(b)
+				}
+				w.Write([]byte(b[:n]))
+				b = b[n:]
+			}
+		}))
+		method := tt.method
+		if method == "" {
+			method = "GET"
+		}
+		req := httptest.NewRequest(method, "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept-Encoding", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		res := rec.Result()
+
+		if got := res.Header.Get("Content-Encoding"); got != tt.wantCE {
+			t.Errorf("%s: Content-Encoding = %q; want %q", tt.name, got, tt.wantCE)
+			continue
+		}
+		if got := res.Header.Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %q; want Accept-Encoding", tt.name, got)
+		}
+		if got := res.Header.Get("Etag"); got != tt.wantETag {
+			t.Errorf("%s: ETag = %q; want %q", tt.name, got, tt.wantETag)
+		}
+		var body io.Reader = res.Body
+		switch tt.wantCE {
+		case "gzip":
+			if cl := res.Header.Get("Content-Length"); cl != "" {
+				t.Errorf("%s: compressed response has Content-Length %q", tt.name, cl)
+			}
+			zr, err := gzip.NewReader(body)
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			body = zr
+		case "deflate":
+			zr, err := zlib.NewReader(body)
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			body = zr
+		}
+		got, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Errorf("%s: reading body: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.body {
+			t.Errorf("%s: body = %q; want %q", tt.name, got, tt.body)
+		}
+	}
+}
+
+func TestCompressHandlerFlush_h1(t *testing.T) { testCompressHandlerFlush(t, h1Mode) }
+func TestCompressHandlerFlush_h2(t *testing.T) { testCompressHandlerFlush(t, h2Mode) }
+
+func testCompressHandlerFlush(t *testing.T, h2 bool) {
+	setParallel(t)
+	defer afterTest(t)
+	proceed := make(chan bool)
+	cst := newClientServerTest(t, h2, CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "first line\n")
+		w.(Flusher).Flush()
+		<-proceed
+		io.WriteString(w, "second line\n")
+	})))
+	defer cst.close()
+	defer close(proceed)
+
+	// The Transport requests gzip and decodes it transparently.
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if !res.Uncompressed {
+		t.Errorf("response was not compressed")
+	}
+	br := bufio.NewReader(res.Body)
+	line, err := br.ReadString('\n')
+	if err != nil || line != "first line\n" {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+	proceed <- true
+	rest, err := ioutil.ReadAll(br)
+	if err != nil || string(rest) != "second line\n" {
+		t.Errorf("rest = %q, %v", rest, err)
+	}
+}
+
+func TestCompressHandlerFlushFirst(t *testing.T) {
+	for _, contentType := range []string{"", "text/event-stream"} {
+		h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			w.(Flusher).Flush()
+			io.WriteString(w, compressText)
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		res := rec.Result()
+
+		// Without a Content-Type there is nothing to detect it from
+		// when the header is flushed, so the response is not compressed.
+		wantCE := ""
+		if contentType != "" {
+			wantCE = "gzip"
+		}
+		if got := res.Header.Get("Content-Encoding"); got != wantCE {
+			t.Errorf("Content-Type %q: Content-Encoding = %q; want %q", contentType, got, wantCE)
+			continue
+		}
+		var body io.Reader = res.Body
+		if wantCE == "gzip" {
+			zr, err := gzip.NewReader(body)
+			if err != nil {
+				t.Errorf("Content-Type %q: %v", contentType, err)
+				continue
+			}
+			body = zr
+		}
+		if got, err := ioutil.ReadAll(body); err != nil || string(got) != compressText {
+			t.Errorf("Content-Type %q: body = %q, %v; want %q", contentType, got, err, compressText)
+		}
+	}
+}
+
+func TestCompressHandlerLargeWrite(t *testing.T) {
+	text := strings.Repeat(compressText, 50)
+	h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if n, err := io.WriteString(w, text); n != len(text) || err != nil {
+			t.Errorf("Write = %d, %v; want %d, nil", n, err, len(text))
+		}
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	res := rec.Result()
+
+	if got := res.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q; want text/plain; charset=utf-8", got)
+	}
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadAll(zr); err != nil || string(got) != text {
+		t.Errorf("body = %d bytes, %v; want %d bytes", len(got), err, len(text))
+	}
+}
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (r *pushRecorder) Push(target string, opts *PushOptions) error {
+	r.pushed = append(r.pushed, target)
+	return nil
+}
+
+func TestCompressHandlerPush(t *testing.T) {
+	h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		p, ok := w.(Pusher)
+		if !ok {
+			t.Fatal("ResponseWriter does not implement Pusher")
+		}
+		if err := p.Push("/style.css", nil); err != nil {
+			t.Errorf("Push = %v; want nil", err)
+		}
+		io.WriteString(w, compressText)
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, req)
+	if len(rec.pushed) != 1 || rec.pushed[0] != "/style.css" {
+		t.Errorf("pushed = %q; want [/style.css]", rec.pushed)
+	}
+
+	// Without a Pusher underneath, Push reports ErrNotSupported.
+	h = CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if err := w.(Pusher).Push("/style.css", nil); err != ErrNotSupported {
+			t.Errorf("Push = %v; want ErrNotSupported", err)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+// failingResponseWriter is a ResponseWriter whose writes and
+// flushes fail with the given errors.
+type failingResponseWriter struct {
+	header   Header
+	writeErr error
+	flushErr error
+}
+
+func (w *failingResponseWriter) Header() Header {
+	if w.header == nil {
+		w.header = make(Header)
+	}
+	return w.header
+}
+
+func (w *failingResponseWriter) Write(p []byte) (int, error) {
+	if w.writeErr != nil {
+		return 0, w.writeErr
+	}
+	return len(p), nil
+}
+
+func (w *failingResponseWriter) WriteHeader(int)   {}
+func (w *failingResponseWriter) FlushError() error { return w.flushErr }
+
+func TestCompressHandlerFlushError(t *testing.T) {
+	errWrite := errors.New("write failed")
+	errFlush := errors.New("flush failed")
+	for _, tt := range []struct {
+		rw   *failingResponseWriter
+		want error
+	}{
+		{&failingResponseWriter{}, nil},
+		{&failingResponseWriter{writeErr: errWrite}, errWrite},
+		{&failingResponseWriter{flushErr: errFlush}, errFlush},
+	} {
+		h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, "hello")
+			if err := NewResponseController(w).Flush(); err != tt.want {
+				t.Errorf("writeErr %v, flushErr %v: Flush = %v; want %v", tt.rw.writeErr, tt.rw.flushErr, err, tt.want)
+			}
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(tt.rw, req)
+	}
+}
+
+type upperWriter struct{ w io.Writer }
+
+func (u upperWriter) Write(p []byte) (int, error) { return u.w.Write(bytes.ToUpper(p)) }
+func (u upperWriter) Close() error                { return nil }
+
+func TestRegisterContentEncoder(t *testing.T) {
+	RegisterContentEncoder("x-upper", func(w io.Writer) (io.WriteCloser, error) {
+		return upperWriter{w}, nil
+	})
+	h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, compressText)
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.8, X-Upper")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Encoding"); got != "x-upper" {
+		t.Fatalf("Content-Encoding = %q; want x-upper", got)
+	}
+	if got := rec.Body.String(); got != strings.ToUpper(compressText) {
+		t.Errorf("body = %q", got)
+	}
+}
@@ -284,6 +284,14 @@ func ExportSetH2GoawayTimeout(d time.Duration) (restore func()) {
 	return func() { http2goAwayTimeout = old }
 }
 
+// ExportSetServeMux114 sets whether ServeMux uses its Go 1.14
+// behavior, as with GODEBUG=httpmuxgo114=1.
+func ExportSetServeMux114(v bool) (restore func()) {
+	old := use114
+	use114 = v
+	return func() { use114 = old }
+}
+
 func (r *Request) ExportIsReplayable() bool { return r.isReplayable() }
 
 // ExportCloseTransportConnsAbruptly closes all idle connections from
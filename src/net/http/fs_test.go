@@ -26,6 +26,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -244,6 +245,64 @@ func TestFSRedirect(t *testing.T) {
 	}
 }
 
+func TestFileServerFS(t *testing.T) {
+	defer afterTest(t)
+	fsys := fstest.MapFS{
+		"hello.txt":      {Data: []byte("hello, world\n"), ModTime: time.Unix(1000000000, 0)},
+		"sub/index.html": {Data: []byte("<p>index</p>")},
+		"sub/b.txt":      {Data: []byte("b")},
+	}
+	ts := httptest.NewServer(FileServer(FS(fsys)))
+	defer ts.Close()
+
+	get := func(path string, hdr ...string) (*Response, string) {
+		t.Helper()
+		req, _ := NewRequest("GET", ts.URL+path, nil)
+		for i := 0; i+1 < len(hdr); i += 2 {
+			req.Header.Set(hdr[i], hdr[i+1])
+		}
+		res, err := DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(b)
+	}
+
+	res, body := get("/hello.txt")
+	if res.StatusCode != StatusOK || body != "hello, world\n" {
+		t.Errorf("GET /hello.txt = %d %q", res.StatusCode, body)
+	}
+	if got, want := res.Header.Get("Last-Modified"), time.Unix(1000000000, 0).UTC().Format(TimeFormat); got != want {
+		t.Errorf("Last-Modified = %q, want %q", got, want)
+	}
+
+	res, body = get("/hello.txt", "Range", "bytes=7-11")
+	if res.StatusCode != StatusPartialContent || body != "world" {
+		t.Errorf("ranged GET /hello.txt = %d %q", res.StatusCode, body)
+	}
+
+	res, body = get("/")
+	if res.StatusCode != StatusOK || !strings.Contains(body, `<a href="hello.txt">hello.txt</a>`) || !strings.Contains(body, `<a href="sub/">sub/</a>`) {
+		t.Errorf("GET / = %d %q", res.StatusCode, body)
+	}
+
+	res, body = get("/sub/")
+	if res.StatusCode != StatusOK || body != "<p>index</p>" {
+		t.Errorf("GET /sub/ = %d %q", res.StatusCode, body)
+	}
+
+	for _, path := range []string{"/missing", "/hello.txt/x"} {
+		if res, _ := get(path); res.StatusCode != StatusNotFound {
+			t.Errorf("GET %s = %d, want %d", path, res.StatusCode, StatusNotFound)
+		}
+	}
+}
+
 type testFileSystem struct {
 	open func(name string) (File, error)
 }
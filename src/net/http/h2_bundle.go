@@ -22,6 +22,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"compress/zstd"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
@@ -826,7 +827,7 @@ func (c *http2dialCall) dial(addr string) {
 // This code decides which ones live or die.
 // The return value used is whether c was used.
 // c is never closed.
-func (p *http2clientConnPool) addConnIfNeeded(key string, t *http2Transport, c *tls.Conn) (used bool, err error) {
+func (p *http2clientConnPool) addConnIfNeeded(key string, t *http2Transport, c net.Conn) (used bool, err error) {
 	p.mu.Lock()
 	for _, cc := range p.conns[key] {
 		if cc.CanTakeNewRequest() {
@@ -861,7 +862,7 @@ type http2addConnCall struct {
 	err  error
 }
 
-func (c *http2addConnCall) run(t *http2Transport, key string, tc *tls.Conn) {
+func (c *http2addConnCall) run(t *http2Transport, key string, tc net.Conn) {
 	cc, err := t.NewClientConn(tc)
 
 	p := c.p
@@ -3868,6 +3869,19 @@ func http2ConfigureServer(s *Server, conf *http2Server) error {
 		})
 	}
 	s.TLSNextProto[http2NextProtoTLS] = protoHandler
+	s.serveUnencryptedHTTP2 = func(ctx context.Context, c net.Conn, r io.Reader, upgrade *Request, settings []byte) {
+		if http2testHookOnConn != nil {
+			http2testHookOnConn()
+		}
+		conf.ServeConn(c, &http2ServeConnOpts{
+			Context:        ctx,
+			Handler:        serverHandler{s},
+			BaseConfig:     s,
+			UpgradeRequest: upgrade,
+			Settings:       settings,
+			reader:         r,
+		})
+	}
 	return nil
 }
 
@@ -3885,6 +3899,21 @@ type http2ServeConnOpts struct {
 	// requests. If nil, BaseConfig.Handler is used. If BaseConfig
 	// or BaseConfig.Handler is nil, http.DefaultServeMux is used.
 	Handler Handler
+
+	// UpgradeRequest is an initial request received on a connection
+	// undergoing an h2c upgrade. The request must not have a body,
+	// and the 101 Switching Protocols response must already have
+	// been written. It is served as stream 1.
+	UpgradeRequest *Request
+
+	// Settings is the decoded contents of the HTTP2-Settings header
+	// in an h2c upgrade request.
+	Settings []byte
+
+	// reader, if non-nil, is used instead of the connection for
+	// reading, so that data the net/http server has already
+	// buffered is not lost.
+	reader io.Reader
 }
 
 func (o *http2ServeConnOpts) context() context.Context {
@@ -3980,7 +4009,11 @@ func (s *http2Server) ServeConn(c net.Conn, opts *http2ServeConnOpts) {
 	sc.inflow.add(http2initialWindowSize)
 	sc.hpackEncoder = hpack.NewEncoder(&sc.headerWriteBuf)
 
-	fr := http2NewFramer(sc.bw, c)
+	sc.br = c
+	if opts != nil && opts.reader != nil {
+		sc.br = opts.reader
+	}
+	fr := http2NewFramer(sc.bw, sc.br)
 	fr.ReadMetaHeaders = hpack.NewDecoder(http2initialHeaderTableSize, nil)
 	fr.MaxHeaderListSize = sc.maxHeaderListSize()
 	fr.SetMaxReadFrameSize(s.maxReadFrameSize())
@@ -4032,6 +4065,18 @@ func (s *http2Server) ServeConn(c net.Conn, opts *http2ServeConnOpts) {
 		}
 	}
 
+	if opts != nil && opts.Settings != nil {
+		fr := &http2SettingsFrame{http2FrameHeader: http2FrameHeader{valid: true}, p: opts.Settings}
+		if len(opts.Settings)%6 != 0 || fr.ForeachSetting(sc.processSetting) != nil {
+			sc.rejectConn(http2ErrCodeProtocol, "invalid HTTP2-Settings header")
+			return
+		}
+	}
+
+	if opts != nil && opts.UpgradeRequest != nil {
+		sc.upgradeRequest(opts.UpgradeRequest)
+	}
+
 	if hook := http2testHookGetServerConn; hook != nil {
 		hook(sc)
 	}
@@ -4060,6 +4105,7 @@ type http2serverConn struct {
 	srv              *http2Server
 	hs               *Server
 	conn             net.Conn
+	br               io.Reader            // reading from conn; may hold data buffered by net/http
 	bw               *http2bufferedWriter // writing to conn
 	handler          Handler
 	baseCtx          context.Context
@@ -4158,6 +4204,7 @@ type http2stream struct {
 	resetQueued      bool        // RST_STREAM queued for write; set by sc.resetStream
 	gotTrailerHeader bool        // HEADER frame for trailers was seen
 	wroteHeaders     bool        // whether we wrote headers (not status 100)
+	readDeadline     *time.Timer // nil if unused
 	writeDeadline    *time.Timer // nil if unused
 
 	trailer    Header // accumulated trailers
@@ -4411,8 +4458,12 @@ func (sc *http2serverConn) serve() {
 	// "StateNew" state. We can't go directly to idle, though.
 	// Active means we read some data and anticipate a request. We'll
 	// do another Active when we get a HEADERS frame.
-	sc.setConnState(StateActive)
-	sc.setConnState(StateIdle)
+	if sc.maxClientStreamID == 0 {
+		sc.setConnState(StateActive)
+		sc.setConnState(StateIdle)
+	}
+	// Otherwise, the connection was upgraded from HTTP/1.1 and
+	// stream 1 has already marked it active.
 
 	if sc.srv.IdleTimeout != 0 {
 		sc.idleTimer = time.AfterFunc(sc.srv.IdleTimeout, sc.onIdleTimer)
@@ -4451,6 +4502,8 @@ func (sc *http2serverConn) serve() {
 			switch v := msg.(type) {
 			case func(int):
 				v(loopNum) // for testing
+			case func(*http2serverConn):
+				v(sc)
 			case *http2serverMessage:
 				switch v {
 				case http2settingsTimerMsg:
@@ -4535,7 +4588,7 @@ func (sc *http2serverConn) readPreface() error {
 	go func() {
 		// Read the client preface
 		buf := make([]byte, len(http2ClientPreface))
-		if _, err := io.ReadFull(sc.conn, buf); err != nil {
+		if _, err := io.ReadFull(sc.br, buf); err != nil {
 			errc <- err
 		} else if !bytes.Equal(buf, http2clientPreface) {
 			errc <- fmt.Errorf("bogus greeting %q", buf)
@@ -5082,6 +5135,9 @@ func (sc *http2serverConn) closeStream(st *http2stream, err error) {
 		panic(fmt.Sprintf("invariant; can't close stream in state %v", st.state))
 	}
 	st.state = http2stateClosed
+	if st.readDeadline != nil {
+		st.readDeadline.Stop()
+	}
 	if st.writeDeadline != nil {
 		st.writeDeadline.Stop()
 	}
@@ -5334,6 +5390,16 @@ func (st *http2stream) copyTrailersToHandlerRequest() {
 	}
 }
 
+var http2errReadDeadline error = &http2httpError{msg: "http2: request body read deadline exceeded", timeout: true}
+
+// onReadTimeout is run on its own goroutine (from time.AfterFunc)
+// when the stream's read deadline has fired.
+func (st *http2stream) onReadTimeout() {
+	if st.body != nil {
+		st.body.CloseWithError(http2errReadDeadline)
+	}
+}
+
 // onWriteTimeout is run on its own goroutine (from time.AfterFunc)
 // when the stream's WriteTimeout has fired.
 func (st *http2stream) onWriteTimeout() {
@@ -5681,6 +5747,11 @@ func (sc *http2serverConn) newWriterAndRequestNoBody(st *http2stream, rp http2re
 	}
 	req = req.WithContext(st.ctx)
 
+	rw := sc.newResponseWriter(st, req, body)
+	return rw, req, nil
+}
+
+func (sc *http2serverConn) newResponseWriter(st *http2stream, req *Request, body *http2requestBody) *http2responseWriter {
 	rws := http2responseWriterStatePool.Get().(*http2responseWriterState)
 	bwSave := rws.bw
 	*rws = http2responseWriterState{} // zero all the fields
@@ -5691,8 +5762,36 @@ func (sc *http2serverConn) newWriterAndRequestNoBody(st *http2stream, rp http2re
 	rws.req = req
 	rws.body = body
 
-	rw := &http2responseWriter{rws: rws}
-	return rw, req, nil
+	return &http2responseWriter{rws: rws}
+}
+
+// upgradeRequest starts serving the request that arrived on an
+// HTTP/1.1 connection with an "Upgrade: h2c" header. Per RFC 7540
+// section 3.2, it is assigned stream 1, which starts half-closed
+// (remote).
+func (sc *http2serverConn) upgradeRequest(req *Request) {
+	sc.serveG.check()
+	id := uint32(1)
+	sc.maxClientStreamID = id
+	st := sc.newStream(id, 0, http2stateHalfClosedRemote)
+
+	body := &http2requestBody{conn: sc, stream: st}
+	req = req.WithContext(st.ctx)
+	req.Proto = "HTTP/2.0"
+	req.ProtoMajor = 2
+	req.ProtoMinor = 0
+	req.Body = body
+	req.ContentLength = 0
+	req.Close = false
+	for _, k := range []string{"Connection", "Upgrade", "Http2-Settings", "Keep-Alive", "Proxy-Connection"} {
+		req.Header.Del(k)
+	}
+	st.reqTrailer = req.Trailer
+	if st.reqTrailer != nil {
+		st.trailer = make(Header)
+	}
+	rw := sc.newResponseWriter(st, req, body)
+	go sc.runHandler(rw, req, sc.handler.ServeHTTP)
 }
 
 // Run on its own goroutine.
@@ -5924,6 +6023,9 @@ type http2responseWriterState struct {
 	sentContentLen int64 // non-zero if handler set a Content-Length header
 	wroteBytes     int64
 
+	timeHeader    bool      // record wroteHeaderAt, for Server.AccessLog
+	wroteHeaderAt time.Time // when WriteHeader was (logically) called
+
 	closeNotifierMu sync.Mutex // guards closeNotifierCh
 	closeNotifierCh chan bool  // nil until first used
 }
@@ -6124,23 +6226,89 @@ func (rws *http2responseWriterState) promoteUndeclaredTrailers() {
 	}
 }
 
+func (w *http2responseWriter) SetReadDeadline(deadline time.Time) error {
+	st := w.rws.stream
+	if !deadline.IsZero() && deadline.Before(time.Now()) {
+		// If we're setting a deadline in the past, reset the stream immediately
+		// so reads after SetReadDeadline returns will fail.
+		st.onReadTimeout()
+		return nil
+	}
+	w.rws.conn.sendServeMsg(func(sc *http2serverConn) {
+		if st.state == http2stateClosed {
+			return
+		}
+		if st.readDeadline != nil {
+			if !st.readDeadline.Stop() {
+				// Deadline already exceeded.
+				return
+			}
+		}
+		if deadline.IsZero() {
+			st.readDeadline = nil
+		} else if st.readDeadline == nil {
+			st.readDeadline = time.AfterFunc(time.Until(deadline), st.onReadTimeout)
+		} else {
+			st.readDeadline.Reset(time.Until(deadline))
+		}
+	})
+	return nil
+}
+
+func (w *http2responseWriter) SetWriteDeadline(deadline time.Time) error {
+	st := w.rws.stream
+	if !deadline.IsZero() && deadline.Before(time.Now()) {
+		// If we're setting a deadline in the past, reset the stream immediately
+		// so writes after SetWriteDeadline returns will fail.
+		st.onWriteTimeout()
+		return nil
+	}
+	w.rws.conn.sendServeMsg(func(sc *http2serverConn) {
+		if st.state == http2stateClosed {
+			return
+		}
+		if st.writeDeadline != nil {
+			if !st.writeDeadline.Stop() {
+				// Deadline already exceeded.
+				return
+			}
+		}
+		if deadline.IsZero() {
+			st.writeDeadline = nil
+		} else if st.writeDeadline == nil {
+			st.writeDeadline = time.AfterFunc(time.Until(deadline), st.onWriteTimeout)
+		} else {
+			st.writeDeadline.Reset(time.Until(deadline))
+		}
+	})
+	return nil
+}
+
+func (w *http2responseWriter) EnableFullDuplex() error {
+	// We always support full duplex responses, so this is a no-op.
+	return nil
+}
+
 func (w *http2responseWriter) Flush() {
+	w.FlushError()
+}
+
+func (w *http2responseWriter) FlushError() error {
 	rws := w.rws
 	if rws == nil {
 		panic("Header called after Handler finished")
 	}
+	var err error
 	if rws.bw.Buffered() > 0 {
-		if err := rws.bw.Flush(); err != nil {
-			// Ignore the error. The frame writer already knows.
-			return
-		}
+		err = rws.bw.Flush()
 	} else {
 		// The bufio.Writer won't call chunkWriter.Write
 		// (writeChunk with zero bytes, so we have to do it
 		// ourselves to force the HTTP response header and/or
 		// final DATA frame (with END_STREAM) to be sent.
-		rws.writeChunk(nil)
+		_, err = rws.writeChunk(nil)
 	}
+	return err
 }
 
 func (w *http2responseWriter) CloseNotify() <-chan bool {
@@ -6203,8 +6371,39 @@ func (w *http2responseWriter) WriteHeader(code int) {
 func (rws *http2responseWriterState) writeHeader(code int) {
 	if !rws.wroteHeader {
 		http2checkWriteHeaderCode(code)
+
+		// Handle informational headers
+		if code >= 100 && code <= 199 {
+			if code == StatusSwitchingProtocols {
+				// HTTP/2 does not support 101 (RFC 7540, section 8.1.1).
+				rws.conn.logf("http2: ignoring WriteHeader(101); Switching Protocols is not supported by HTTP/2")
+				return
+			}
+			// Per RFC 8297 we must not clear the current header map
+			h := rws.handlerHeader
+			_, cl := h["Content-Length"]
+			_, te := h["Transfer-Encoding"]
+			if cl || te {
+				h = h.Clone()
+				h.Del("Content-Length")
+				h.Del("Transfer-Encoding")
+			}
+			err := rws.conn.writeHeaders(rws.stream, &http2writeResHeaders{
+				streamID:    rws.stream.id,
+				httpResCode: code,
+				h:           h,
+			})
+			if err != nil {
+				rws.dirty = true
+			}
+			return
+		}
+
 		rws.wroteHeader = true
 		rws.status = code
+		if rws.timeHeader {
+			rws.wroteHeaderAt = time.Now()
+		}
 		if len(rws.handlerHeader) > 0 {
 			rws.snapHeader = http2cloneHeader(rws.handlerHeader)
 		}
@@ -6262,6 +6461,17 @@ func (w *http2responseWriter) write(lenData int, dataB []byte, dataS string) (n
 	}
 }
 
+// timeResponseHeader and responseStats implement the unexported
+// interface net/http uses for Server.AccessLog.
+func (w *http2responseWriter) timeResponseHeader() {
+	w.rws.timeHeader = true
+}
+
+func (w *http2responseWriter) responseStats() (wroteHeader bool, status int, written int64, wroteHeaderAt time.Time) {
+	rws := w.rws
+	return rws.wroteHeader, rws.status, rws.wroteBytes, rws.wroteHeaderAt
+}
+
 func (w *http2responseWriter) handlerDone() {
 	rws := w.rws
 	dirty := rws.dirty
@@ -6607,6 +6817,19 @@ type http2Transport struct {
 	// waiting for their turn.
 	StrictMaxConcurrentStreams bool
 
+	// ReadIdleTimeout is the timeout after which a health check using ping
+	// frame will be carried out if no frame is received on the connection.
+	// Note that a ping response is considered a received frame, so if
+	// there is no other traffic on the connection, the health check will
+	// be performed every ReadIdleTimeout interval.
+	// If zero, no health check is performed.
+	ReadIdleTimeout time.Duration
+
+	// PingTimeout is the timeout after which the connection will be closed
+	// if a response to Ping is not received.
+	// Defaults to 15s.
+	PingTimeout time.Duration
+
 	// t1, if non-nil, is the standard library Transport using
 	// this transport. Its settings are used (but not its
 	// RoundTrip method, etc).
@@ -6626,6 +6849,17 @@ func (t *http2Transport) maxHeaderListSize() uint32 {
 	return t.MaxHeaderListSize
 }
 
+func (t *http2Transport) pingTimeout() time.Duration {
+	if t.PingTimeout == 0 {
+		return 15 * time.Second
+	}
+	return t.PingTimeout
+}
+
+func (t *http2Transport) acceptZstd() bool {
+	return t.t1 != nil && t.t1.AcceptZstd
+}
+
 func (t *http2Transport) disableCompression() bool {
 	return t.DisableCompression || (t.t1 != nil && t.t1.DisableCompression)
 }
@@ -6657,18 +6891,7 @@ func http2configureTransport(t1 *Transport) (*http2Transport, error) {
 		t1.TLSClientConfig.NextProtos = append(t1.TLSClientConfig.NextProtos, "http/1.1")
 	}
 	upgradeFn := func(authority string, c *tls.Conn) RoundTripper {
-		addr := http2authorityAddr("https", authority)
-		if used, err := connPool.addConnIfNeeded(addr, t2, c); err != nil {
-			go c.Close()
-			return http2erringRoundTripper{err}
-		} else if !used {
-			// Turns out we don't need this c.
-			// For example, two goroutines made requests to the same host
-			// at the same time, both kicking off TCP dials. (since protocol
-			// was unknown)
-			go c.Close()
-		}
-		return t2
+		return connPool.addConnOrClose(t2, "https", authority, c)
 	}
 	if m := t1.TLSNextProto; len(m) == 0 {
 		t1.TLSNextProto = map[string]func(string, *tls.Conn) RoundTripper{
@@ -6677,9 +6900,29 @@ func http2configureTransport(t1 *Transport) (*http2Transport, error) {
 	} else {
 		m["h2"] = upgradeFn
 	}
+	t1.h2cNext = func(authority string, c net.Conn) RoundTripper {
+		return connPool.addConnOrClose(t2, "http", authority, c)
+	}
 	return t2, nil
 }
 
+// addConnOrClose adds c, a new connection to authority that
+// speaks HTTP/2, to the pool, closing it if it is not needed.
+func (p *http2clientConnPool) addConnOrClose(t2 *http2Transport, scheme, authority string, c net.Conn) RoundTripper {
+	addr := http2authorityAddr(scheme, authority)
+	if used, err := p.addConnIfNeeded(addr, t2, c); err != nil {
+		go c.Close()
+		return http2erringRoundTripper{err}
+	} else if !used {
+		// Turns out we don't need this c.
+		// For example, two goroutines made requests to the same host
+		// at the same time, both kicking off TCP dials. (since protocol
+		// was unknown)
+		go c.Close()
+	}
+	return t2
+}
+
 func (t *http2Transport) connPool() http2ClientConnPool {
 	t.connPoolOnce.Do(t.initConnPool)
 	return t.connPoolOrDef
@@ -7350,10 +7593,21 @@ func (cc *http2ClientConn) sendGoAway() error {
 //
 // In-flight requests are interrupted. For a graceful shutdown, use Shutdown instead.
 func (cc *http2ClientConn) Close() error {
+	err := errors.New("http2: client connection force closed via ClientConn.Close")
+	return cc.closeForError(err)
+}
+
+// closeForLostPing closes the client connection immediately after
+// a health check ping went unanswered.
+func (cc *http2ClientConn) closeForLostPing() error {
+	err := errors.New("http2: client connection lost")
+	return cc.closeForError(err)
+}
+
+func (cc *http2ClientConn) closeForError(err error) error {
 	cc.mu.Lock()
 	defer cc.cond.Broadcast()
 	defer cc.mu.Unlock()
-	err := errors.New("http2: client connection force closed via ClientConn.Close")
 	for id, cs := range cc.streams {
 		select {
 		case cs.resc <- http2resAndError{err: err}:
@@ -8024,7 +8278,11 @@ func (cc *http2ClientConn) encodeHeaders(req *Request, addGzipHeader bool, trail
 			f("content-length", strconv.FormatInt(contentLength, 10))
 		}
 		if addGzipHeader {
-			f("accept-encoding", "gzip")
+			if cc.t.acceptZstd() {
+				f("accept-encoding", "gzip, zstd")
+			} else {
+				f("accept-encoding", "gzip")
+			}
 		}
 		if !didUA {
 			f("user-agent", http2defaultUserAgent)
@@ -8242,8 +8500,17 @@ func (rl *http2clientConnReadLoop) run() error {
 	rl.closeWhenIdle = cc.t.disableKeepAlives() || cc.singleUse
 	gotReply := false // ever saw a HEADERS reply
 	gotSettings := false
+	readIdleTimeout := cc.t.ReadIdleTimeout
+	var t *time.Timer
+	if readIdleTimeout != 0 {
+		t = time.AfterFunc(readIdleTimeout, cc.healthCheck)
+		defer t.Stop()
+	}
 	for {
 		f, err := cc.fr.ReadFrame()
+		if t != nil {
+			t.Reset(readIdleTimeout)
+		}
 		if err != nil {
 			cc.vlogf("http2: Transport readFrame error on conn %p: (%T) %v", cc, err, err)
 		}
@@ -8470,6 +8737,12 @@ func (rl *http2clientConnReadLoop) handleResponse(cs *http2clientStream, f *http
 		res.ContentLength = -1
 		res.Body = &http2gzipReader{body: res.Body}
 		res.Uncompressed = true
+	} else if cs.requestedGzip && cs.cc.t.acceptZstd() && res.Header.Get("Content-Encoding") == "zstd" {
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Body = &http2zstdReader{body: res.Body}
+		res.Uncompressed = true
 	}
 	return res, nil
 }
@@ -8845,6 +9118,21 @@ func (rl *http2clientConnReadLoop) processResetStream(f *http2RSTStreamFrame) er
 	return nil
 }
 
+// healthCheck pings the server and closes cc if no ack arrives in time.
+func (cc *http2ClientConn) healthCheck() {
+	pingTimeout := cc.t.pingTimeout()
+	// We don't need to periodically ping in the health check, because the readLoop of ClientConn will
+	// trigger the healthCheck again if there is no frame received.
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	err := cc.Ping(ctx)
+	if err != nil {
+		cc.closeForLostPing()
+		cc.t.connPool().MarkDead(cc)
+		return
+	}
+}
+
 // Ping sends a PING frame to the server and waits for the ack.
 func (cc *http2ClientConn) Ping(ctx context.Context) error {
 	c := make(chan struct{})
@@ -8992,6 +9280,24 @@ func (gz *http2gzipReader) Close() error {
 	return gz.body.Close()
 }
 
+// zstdReader wraps a response body so it can lazily
+// create a zstd.Reader on the first call to Read
+type http2zstdReader struct {
+	body io.ReadCloser // underlying Response.Body
+	zr   *zstd.Reader  // lazily-initialized zstd reader
+}
+
+func (zs *http2zstdReader) Read(p []byte) (n int, err error) {
+	if zs.zr == nil {
+		zs.zr = zstd.NewReader(zs.body)
+	}
+	return zs.zr.Read(p)
+}
+
+func (zs *http2zstdReader) Close() error {
+	return zs.body.Close()
+}
+
 type http2errorReader struct{ err error }
 
 func (r http2errorReader) Read(p []byte) (int, error) { return 0, r.err }
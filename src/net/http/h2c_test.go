@@ -0,0 +1,97 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !nethttpomithttp2
+
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestServerH2CUpgrade(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{
+		Protocols: new(Protocols),
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			fmt.Fprintf(w, "%s %s upgrade=%q", r.Proto, r.URL.Path, r.Header.Get("Upgrade"))
+		}),
+	}
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// SETTINGS_MAX_CONCURRENT_STREAMS = 100, base64url-encoded.
+	const req = "GET /foo HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Connection: Upgrade, HTTP2-Settings\r\n" +
+		"Upgrade: h2c\r\n" +
+		"HTTP2-Settings: AAMAAABk\r\n\r\n"
+	if _, err := c.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(c)
+	res, err := ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != StatusSwitchingProtocols || res.Header.Get("Upgrade") != "h2c" {
+		t.Fatalf("got %v, Upgrade %q; want 101 Switching Protocols to h2c", res.Status, res.Header.Get("Upgrade"))
+	}
+
+	if _, err := c.Write([]byte(http2ClientPreface)); err != nil {
+		t.Fatal(err)
+	}
+	fr := http2NewFramer(c, br)
+	fr.ReadMetaHeaders = hpack.NewDecoder(http2initialHeaderTableSize, nil)
+	if err := fr.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+	var status string
+	var body strings.Builder
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Header().StreamID != 1 {
+			continue
+		}
+		switch f := f.(type) {
+		case *http2MetaHeadersFrame:
+			status = f.PseudoValue("status")
+		case *http2DataFrame:
+			body.Write(f.Data())
+		default:
+			t.Fatalf("unexpected frame on stream 1: %v", f)
+		}
+		if f.Header().Flags.Has(http2FlagDataEndStream) {
+			break
+		}
+	}
+	if status != "200" {
+		t.Errorf("status = %q; want 200", status)
+	}
+	if got, want := body.String(), `HTTP/2.0 /foo upgrade=""`; got != want {
+		t.Errorf("body = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !nethttpomithttp2
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestTransportHTTP2PingTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// The server answers requests but never acknowledges PING frames.
+	accepted := make(chan bool, 10)
+	closed := make(chan bool, 10)
+	serve := func(c net.Conn) {
+		defer c.Close()
+		br := bufio.NewReader(c)
+		buf := make([]byte, len(http2ClientPreface))
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return
+		}
+		fr := http2NewFramer(c, br)
+		fr.WriteSettings()
+		for {
+			f, err := fr.ReadFrame()
+			if err != nil {
+				closed <- true
+				return
+			}
+			switch f := f.(type) {
+			case *http2SettingsFrame:
+				if !f.IsAck() {
+					fr.WriteSettingsAck()
+				}
+			case *http2HeadersFrame:
+				var hbuf bytes.Buffer
+				enc := hpack.NewEncoder(&hbuf)
+				enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+				fr.WriteHeaders(http2HeadersFrameParam{
+					StreamID:      f.StreamID,
+					BlockFragment: hbuf.Bytes(),
+					EndStream:     true,
+					EndHeaders:    true,
+				})
+			}
+		}
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- true
+			go serve(c)
+		}
+	}()
+
+	var protos Protocols
+	protos.SetUnencryptedHTTP2(true)
+	tr := &Transport{
+		Protocols: &protos,
+		HTTP2: &HTTP2Config{
+			SendPingTimeout: 50 * time.Millisecond,
+			PingTimeout:     50 * time.Millisecond,
+		},
+	}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	for i := 0; i < 2; i++ {
+		res, err := c.Get("http://" + ln.Addr().String())
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		res.Body.Close()
+		if res.ProtoMajor != 2 {
+			t.Fatalf("request %d: got %v, want HTTP/2", i, res.Proto)
+		}
+		select {
+		case <-closed:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("request %d: connection not closed after unanswered ping", i)
+		}
+	}
+	if n := len(accepted); n != 2 {
+		t.Errorf("server accepted %d connections; want 2", n)
+	}
+}
@@ -0,0 +1,577 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package httpcache implements a caching http.RoundTripper following
+// the rules of RFC 7234 for a private (single-user) cache.
+//
+// A Transport stores responses to GET requests and serves them
+// again while they are fresh, as determined by the Cache-Control,
+// Expires, Date, Age and Last-Modified headers. Stale responses
+// carrying an ETag or Last-Modified header are revalidated with a
+// conditional request. Responses are selected according to their
+// Vary header.
+//
+// Requests with an unsafe method, such as POST, invalidate any
+// response stored for the same URL.
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatusHeader is the response header set by Transport to report
+// how a response was produced. Its value is one of:
+//
+//	HIT          the response was served from the cache
+//	REVALIDATED  the server confirmed that the cached response is still valid
+//	MISS         the response came from the server
+const StatusHeader = "X-Cache"
+
+// Values of StatusHeader.
+const (
+	statusHit         = "HIT"
+	statusRevalidated = "REVALIDATED"
+	statusMiss        = "MISS"
+)
+
+// defaultCacheSize is the size of the MemoryCache used when
+// Transport.Cache is nil.
+const defaultCacheSize = 32 << 20
+
+// maxBodySize is the largest response body the Transport will store.
+const maxBodySize = 64 << 20
+
+// A Cache stores encoded responses for a Transport.
+//
+// Implementations must be safe for concurrent use by multiple
+// goroutines. The Transport does not modify values passed to Set
+// or returned by Get.
+type Cache interface {
+	// Get returns the value stored for key, if any.
+	Get(key string) (value []byte, ok bool)
+
+	// Set stores value for key, replacing any existing value.
+	Set(key string, value []byte)
+
+	// Delete removes the value stored for key, if any.
+	Delete(key string)
+}
+
+// Transport is an http.RoundTripper that answers requests from a
+// cache when possible, and otherwise uses an underlying RoundTripper.
+//
+// Every response returned by Transport has a StatusHeader header.
+//
+// Requests carrying their own conditional headers, such as
+// If-None-Match, or a Range header bypass the cache.
+//
+// Transport is safe for concurrent use by multiple goroutines.
+type Transport struct {
+	// Transport is used to make requests that cannot be answered
+	// from the cache. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Cache stores the responses. If nil, a MemoryCache holding
+	// up to 32 MB is used.
+	Cache Cache
+
+	cacheOnce    sync.Once
+	defaultCache Cache
+}
+
+// timeNow is time.Now, overridden by tests.
+var timeNow = time.Now
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) cache() Cache {
+	if t.Cache != nil {
+		return t.Cache
+	}
+	t.cacheOnce.Do(func() {
+		t.defaultCache = NewMemoryCache(defaultCacheSize)
+	})
+	return t.defaultCache
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+	if req.Method != "GET" {
+		resp, err := t.transport().RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		// RFC 7234, section 4.4.
+		if !isSafeMethod(req.Method) && resp.StatusCode < 400 {
+			t.cache().Delete(key)
+		}
+		resp.Header.Set(StatusHeader, statusMiss)
+		return resp, nil
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if reqCC.has("no-store") || bypassesCache(req) {
+		resp, err := t.transport().RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Header.Set(StatusHeader, statusMiss)
+		return resp, nil
+	}
+
+	e := t.load(key, req)
+	if e != nil && e.fresh(reqCC, timeNow()) {
+		return e.response(req, timeNow(), statusHit), nil
+	}
+	if reqCC.has("only-if-cached") {
+		// RFC 7234, section 5.2.1.7.
+		return &http.Response{
+			Status:     "504 Gateway Timeout",
+			StatusCode: http.StatusGatewayTimeout,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{StatusHeader: {statusMiss}},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	outreq := req
+	if e != nil {
+		etag := e.header.Get("Etag")
+		lastModified := e.header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outreq = req.Clone(req.Context())
+			if etag != "" {
+				outreq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outreq.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	reqTime := timeNow()
+	resp, err := t.transport().RoundTrip(outreq)
+	if err != nil {
+		return nil, err
+	}
+	respTime := timeNow()
+
+	if outreq != req && resp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		e.update(resp.Header, reqTime, respTime)
+		t.store(key, e)
+		return e.response(req, respTime, statusRevalidated), nil
+	}
+
+	if storable(reqCC, resp) {
+		ne := newEntry(req, resp, reqTime, respTime)
+		resp.Body = &cachingBody{
+			ReadCloser: resp.Body,
+			onEOF: func(body []byte) {
+				ne.body = body
+				t.store(key, ne)
+			},
+		}
+	} else if e != nil {
+		t.cache().Delete(key)
+	}
+	resp.Header.Set(StatusHeader, statusMiss)
+	return resp, nil
+}
+
+// load returns the entry stored for key if it matches req.
+func (t *Transport) load(key string, req *http.Request) *entry {
+	b, ok := t.cache().Get(key)
+	if !ok {
+		return nil
+	}
+	e, err := decodeEntry(b)
+	if err != nil {
+		t.cache().Delete(key)
+		return nil
+	}
+	if !e.matches(req) {
+		return nil
+	}
+	return e
+}
+
+func (t *Transport) store(key string, e *entry) {
+	var buf bytes.Buffer
+	if err := e.encode(&buf); err != nil {
+		return
+	}
+	t.cache().Set(key, buf.Bytes())
+}
+
+func cacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// bypassesCache reports whether req is a conditional or range
+// request, whose response must come from the server.
+func bypassesCache(req *http.Request) bool {
+	for _, k := range []string{"If-None-Match", "If-Modified-Since", "If-Range", "If-Match", "If-Unmodified-Since", "Range"} {
+		if _, ok := req.Header[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheableByDefault reports whether responses with the status code
+// may be stored without explicit freshness information
+// (RFC 7231, section 6.1).
+func cacheableByDefault(code int) bool {
+	switch code {
+	case 200, 203, 204, 300, 301, 404, 405, 410, 414, 501:
+		return true
+	}
+	return false
+}
+
+// storable reports whether resp may be stored (RFC 7234, section 3).
+func storable(reqCC cacheControl, resp *http.Response) bool {
+	if resp.StatusCode == http.StatusPartialContent {
+		return false
+	}
+	respCC := parseCacheControl(resp.Header)
+	if reqCC.has("no-store") || respCC.has("no-store") {
+		return false
+	}
+	if resp.Header.Get("Vary") == "*" {
+		return false
+	}
+	return cacheableByDefault(resp.StatusCode) ||
+		respCC.has("max-age") ||
+		resp.Header.Get("Expires") != ""
+}
+
+// cacheControl holds the directives of a Cache-Control header.
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range h["Cache-Control"] {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			name, val := d, ""
+			if i := strings.Index(d, "="); i >= 0 {
+				name, val = strings.TrimSpace(d[:i]), strings.TrimSpace(d[i+1:])
+				val = strings.Trim(val, `"`)
+			}
+			cc[strings.ToLower(name)] = val
+		}
+	}
+	if len(cc) == 0 && h.Get("Pragma") == "no-cache" {
+		// RFC 7234, section 5.4.
+		cc["no-cache"] = ""
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// duration returns the value of a delta-seconds directive.
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// An entry is a stored response.
+type entry struct {
+	reqTime  time.Time
+	respTime time.Time
+
+	// vary holds the request header values selected by the
+	// response's Vary header.
+	vary http.Header
+
+	status     string
+	statusCode int
+	protoMajor int
+	protoMinor int
+	header     http.Header
+	body       []byte
+}
+
+func newEntry(req *http.Request, resp *http.Response, reqTime, respTime time.Time) *entry {
+	e := &entry{
+		reqTime:    reqTime,
+		respTime:   respTime,
+		vary:       http.Header{},
+		status:     resp.Status,
+		statusCode: resp.StatusCode,
+		protoMajor: resp.ProtoMajor,
+		protoMinor: resp.ProtoMinor,
+		header:     resp.Header.Clone(),
+	}
+	e.header.Del(StatusHeader)
+	if e.header.Get("Date") == "" {
+		// RFC 7231, section 7.1.1.2.
+		e.header.Set("Date", respTime.UTC().Format(http.TimeFormat))
+	}
+	for _, f := range varyFields(resp.Header) {
+		e.vary.Set(f, strings.Join(req.Header[f], ","))
+	}
+	return e
+}
+
+func varyFields(h http.Header) []string {
+	var fields []string
+	for _, v := range h["Vary"] {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, textproto.CanonicalMIMEHeaderKey(f))
+			}
+		}
+	}
+	return fields
+}
+
+// matches reports whether e may be used for req, according to the
+// Vary header of the stored response (RFC 7234, section 4.1).
+func (e *entry) matches(req *http.Request) bool {
+	for _, f := range varyFields(e.header) {
+		if e.vary.Get(f) != strings.Join(req.Header[f], ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// date returns the value of the stored response's Date header.
+func (e *entry) date() time.Time {
+	if d, err := http.ParseTime(e.header.Get("Date")); err == nil {
+		return d
+	}
+	return e.respTime
+}
+
+// age returns the current age of the stored response
+// (RFC 7234, section 4.2.3).
+func (e *entry) age(now time.Time) time.Duration {
+	apparentAge := e.respTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(e.header.Get("Age"), 10, 64); err == nil && n > 0 {
+		ageValue = time.Duration(n) * time.Second
+	}
+	correctedAgeValue := ageValue + e.respTime.Sub(e.reqTime)
+	initialAge := apparentAge
+	if correctedAgeValue > initialAge {
+		initialAge = correctedAgeValue
+	}
+	return initialAge + now.Sub(e.respTime)
+}
+
+// lifetime returns the freshness lifetime of the stored response
+// (RFC 7234, section 4.2.1).
+func (e *entry) lifetime(respCC cacheControl) time.Duration {
+	if d, ok := respCC.duration("max-age"); ok {
+		return d
+	}
+	if v, ok := e.header["Expires"]; ok {
+		expires, err := http.ParseTime(strings.Join(v, ""))
+		if err != nil {
+			// Invalid dates, such as "0", mean already expired.
+			return 0
+		}
+		return expires.Sub(e.date())
+	}
+	// Heuristic freshness (RFC 7234, section 4.2.2).
+	if lm, err := http.ParseTime(e.header.Get("Last-Modified")); err == nil && cacheableByDefault(e.statusCode) {
+		d := e.date().Sub(lm) / 10
+		if d > 24*time.Hour {
+			d = 24 * time.Hour
+		}
+		return d
+	}
+	return 0
+}
+
+// fresh reports whether e may be served for req without
+// contacting the server.
+func (e *entry) fresh(reqCC cacheControl, now time.Time) bool {
+	respCC := parseCacheControl(e.header)
+	if reqCC.has("no-cache") || respCC.has("no-cache") {
+		return false
+	}
+	lifetime := e.lifetime(respCC)
+	age := e.age(now)
+	if maxAge, ok := reqCC.duration("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.duration("min-fresh"); ok {
+		age += minFresh
+	}
+	if reqCC.has("max-stale") && !respCC.has("must-revalidate") {
+		maxStale, ok := reqCC.duration("max-stale")
+		if !ok {
+			// No limit on staleness.
+			return true
+		}
+		lifetime += maxStale
+	}
+	return age < lifetime
+}
+
+// update refreshes e with the headers of a 304 Not Modified
+// response (RFC 7234, section 4.3.4).
+func (e *entry) update(h http.Header, reqTime, respTime time.Time) {
+	for k, v := range h {
+		switch k {
+		case "Content-Length", "Transfer-Encoding", StatusHeader:
+			continue
+		}
+		e.header[k] = v
+	}
+	e.reqTime = reqTime
+	e.respTime = respTime
+}
+
+// response returns a new response for req from e.
+func (e *entry) response(req *http.Request, now time.Time, status string) *http.Response {
+	h := e.header.Clone()
+	h.Set("Age", strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+	h.Set(StatusHeader, status)
+	return &http.Response{
+		Status:        e.status,
+		StatusCode:    e.statusCode,
+		Proto:         fmt.Sprintf("HTTP/%d.%d", e.protoMajor, e.protoMinor),
+		ProtoMajor:    e.protoMajor,
+		ProtoMinor:    e.protoMinor,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// encode writes e as a line holding the request and response
+// times, a MIME header block holding the Vary-selected request
+// headers, and the response itself in HTTP/1.1 wire format.
+func (e *entry) encode(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%d %d\r\n", e.reqTime.UnixNano(), e.respTime.UnixNano()); err != nil {
+		return err
+	}
+	if err := e.vary.Write(w); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\r\n"); err != nil {
+		return err
+	}
+	resp := &http.Response{
+		Status:        e.status,
+		StatusCode:    e.statusCode,
+		ProtoMajor:    e.protoMajor,
+		ProtoMinor:    e.protoMinor,
+		Header:        e.header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+	}
+	return resp.Write(w)
+}
+
+func decodeEntry(b []byte) (*entry, error) {
+	br := bufio.NewReader(bytes.NewReader(b))
+	tp := textproto.NewReader(br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	var reqTime, respTime int64
+	if _, err := fmt.Sscanf(line, "%d %d", &reqTime, &respTime); err != nil {
+		return nil, err
+	}
+	vary, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{
+		reqTime:    time.Unix(0, reqTime),
+		respTime:   time.Unix(0, respTime),
+		vary:       http.Header(vary),
+		status:     resp.Status,
+		statusCode: resp.StatusCode,
+		protoMajor: resp.ProtoMajor,
+		protoMinor: resp.ProtoMinor,
+		header:     resp.Header,
+		body:       body,
+	}, nil
+}
+
+// cachingBody is a response body that calls onEOF with the complete
+// body once it has been read to the end.
+type cachingBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	onEOF func(body []byte)
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.onEOF != nil {
+		b.buf.Write(p[:n])
+		if b.buf.Len() > maxBodySize {
+			b.onEOF = nil
+			b.buf = bytes.Buffer{}
+		} else if err == io.EOF {
+			b.onEOF(b.buf.Bytes())
+			b.onEOF = nil
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,374 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock replaces timeNow for the duration of a test.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(t *testing.T) *fakeClock {
+	c := &fakeClock{now: time.Now()}
+	old := timeNow
+	timeNow = c.Now
+	t.Cleanup(func() { timeNow = old })
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// cacheTest is a server whose handler sees the number of requests
+// made so far, and a Transport in front of it.
+type cacheTest struct {
+	t     *testing.T
+	ts    *httptest.Server
+	tr    *Transport
+	count int32
+}
+
+func newCacheTest(t *testing.T, h func(w http.ResponseWriter, r *http.Request, n int)) *cacheTest {
+	ct := &cacheTest{t: t}
+	ct.ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, int(atomic.AddInt32(&ct.count, 1)))
+	}))
+	t.Cleanup(ct.ts.Close)
+	ct.tr = &Transport{Transport: ct.ts.Client().Transport}
+	return ct
+}
+
+// do makes a request with the given method and header lines
+// ("Key: value"), and returns the cache status and body.
+func (ct *cacheTest) do(method string, header ...string) (status, body string) {
+	ct.t.Helper()
+	req, err := http.NewRequest(method, ct.ts.URL, nil)
+	if err != nil {
+		ct.t.Fatal(err)
+	}
+	for _, kv := range header {
+		i := strings.Index(kv, ":")
+		req.Header.Add(kv[:i], strings.TrimSpace(kv[i+1:]))
+	}
+	res, err := ct.tr.RoundTrip(req)
+	if err != nil {
+		ct.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		ct.t.Fatal(err)
+	}
+	return res.Header.Get(StatusHeader), string(b)
+}
+
+func (ct *cacheTest) get(header ...string) (status, body string) {
+	ct.t.Helper()
+	return ct.do("GET", header...)
+}
+
+type want struct{ status, body string }
+
+func (ct *cacheTest) check(step string, status, body string, w want) {
+	ct.t.Helper()
+	if status != w.status || body != w.body {
+		ct.t.Errorf("%s: got %s %q; want %s %q", step, status, body, w.status, w.body)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	clock := newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	s, b := ct.get()
+	ct.check("first", s, b, want{"MISS", "response 1"})
+	clock.Advance(30 * time.Second)
+	s, b = ct.get()
+	ct.check("fresh", s, b, want{"HIT", "response 1"})
+	s, b = ct.get("Cache-Control: max-age=10")
+	ct.check("request max-age", s, b, want{"MISS", "response 2"})
+	clock.Advance(61 * time.Second)
+	s, b = ct.get()
+	ct.check("stale", s, b, want{"MISS", "response 3"})
+	s, b = ct.get("Cache-Control: no-cache")
+	ct.check("request no-cache", s, b, want{"MISS", "response 4"})
+	s, b = ct.get("Pragma: no-cache")
+	ct.check("request Pragma: no-cache", s, b, want{"MISS", "response 5"})
+}
+
+func TestMaxStale(t *testing.T) {
+	clock := newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	clock.Advance(90 * time.Second)
+	s, b := ct.get("Cache-Control: max-stale=60")
+	ct.check("within max-stale", s, b, want{"HIT", "response 1"})
+	s, b = ct.get("Cache-Control: max-stale=10")
+	ct.check("beyond max-stale", s, b, want{"MISS", "response 2"})
+}
+
+func TestAgeHeader(t *testing.T) {
+	clock := newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Age", "50")
+	})
+	ct.get()
+	clock.Advance(5 * time.Second)
+	req, _ := http.NewRequest("GET", ct.ts.URL, nil)
+	res, err := ct.tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got := res.Header.Get("Age"); got != "55" {
+		t.Errorf("Age = %q; want 55", got)
+	}
+	clock.Advance(10 * time.Second)
+	if s, _ := ct.get(); s != "MISS" {
+		t.Errorf("after Age exceeds max-age: got %s; want MISS", s)
+	}
+}
+
+func TestExpires(t *testing.T) {
+	clock := newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		now := time.Now().UTC()
+		w.Header().Set("Date", now.Format(http.TimeFormat))
+		w.Header().Set("Expires", now.Add(time.Hour).Format(http.TimeFormat))
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	s, b := ct.get()
+	ct.check("before Expires", s, b, want{"HIT", "response 1"})
+	clock.Advance(2 * time.Hour)
+	s, b = ct.get()
+	ct.check("after Expires", s, b, want{"MISS", "response 2"})
+}
+
+func TestInvalidExpires(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Expires", "0")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	s, b := ct.get()
+	ct.check("second", s, b, want{"MISS", "response 2"})
+}
+
+func TestNoStore(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 1 {
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	s, b := ct.get("Cache-Control: no-store")
+	ct.check("request no-store", s, b, want{"MISS", "response 2"})
+	s, b = ct.get()
+	ct.check("after no-store", s, b, want{"MISS", "response 3"})
+	s, b = ct.get()
+	ct.check("stored", s, b, want{"HIT", "response 3"})
+}
+
+func TestRevalidateETag(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Etag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("X-Revalidated", fmt.Sprint(n))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	s, b := ct.get()
+	ct.check("revalidated", s, b, want{"REVALIDATED", "response 1"})
+	if n := atomic.LoadInt32(&ct.count); n != 2 {
+		t.Errorf("server saw %d requests; want 2", n)
+	}
+
+	// The stored headers are updated from the 304 response.
+	req, _ := http.NewRequest("GET", ct.ts.URL, nil)
+	res, err := ct.tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got := res.Header.Get("X-Revalidated"); got != "3" {
+		t.Errorf("X-Revalidated = %q; want 3", got)
+	}
+}
+
+func TestRevalidateLastModified(t *testing.T) {
+	clock := newFakeClock(t)
+	lastModified := time.Now().Add(-100 * time.Hour).UTC().Format(http.TimeFormat)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	// Heuristic freshness is a tenth of the time since
+	// Last-Modified, here 10 hours.
+	clock.Advance(9 * time.Hour)
+	s, b := ct.get()
+	ct.check("heuristically fresh", s, b, want{"HIT", "response 1"})
+	clock.Advance(2 * time.Hour)
+	s, b = ct.get()
+	ct.check("stale", s, b, want{"REVALIDATED", "response 1"})
+}
+
+func TestVary(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, "response %d %s", n, r.Header.Get("Accept-Language"))
+	})
+	ct.get("Accept-Language: en")
+	s, b := ct.get("Accept-Language: en")
+	ct.check("same", s, b, want{"HIT", "response 1 en"})
+	s, b = ct.get("Accept-Language: fr")
+	ct.check("different", s, b, want{"MISS", "response 2 fr"})
+	s, b = ct.get("Accept-Language: fr")
+	ct.check("replaced", s, b, want{"HIT", "response 2 fr"})
+	s, b = ct.get()
+	ct.check("missing", s, b, want{"MISS", "response 3 "})
+}
+
+func TestVaryStar(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "*")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	s, b := ct.get()
+	ct.check("second", s, b, want{"MISS", "response 2"})
+}
+
+func TestUnsafeMethodInvalidates(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	s, b := ct.do("HEAD")
+	ct.check("HEAD", s, b, want{"MISS", ""})
+	s, b = ct.get()
+	ct.check("after HEAD", s, b, want{"HIT", "response 1"})
+	ct.do("POST")
+	s, b = ct.get()
+	ct.check("after POST", s, b, want{"MISS", "response 4"})
+}
+
+func TestOnlyIfCached(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	req, _ := http.NewRequest("GET", ct.ts.URL, nil)
+	req.Header.Set("Cache-Control", "only-if-cached")
+	res, err := ct.tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("uncached: got %v; want 504", res.Status)
+	}
+	ct.get()
+	s, b := ct.get("Cache-Control: only-if-cached")
+	ct.check("cached", s, b, want{"HIT", "response 1"})
+}
+
+func TestConditionalRequestBypassesCache(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	ct.get()
+	s, b := ct.get(`If-None-Match: "x"`)
+	ct.check("conditional", s, b, want{"MISS", "response 2"})
+	s, b = ct.get("Range: bytes=0-3")
+	ct.check("range", s, b, want{"MISS", "response 3"})
+}
+
+func TestUnreadBodyNotStored(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	})
+	req, _ := http.NewRequest("GET", ct.ts.URL, nil)
+	res, err := ct.tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	s, b := ct.get()
+	ct.check("after unread body", s, b, want{"MISS", "response 2"})
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "X-N")
+		fmt.Fprintf(w, "response for %s", r.Header.Get("X-N"))
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			hdr := fmt.Sprintf("X-N: %d", i%3)
+			req, _ := http.NewRequest("GET", ct.ts.URL, nil)
+			req.Header.Set("X-N", fmt.Sprint(i%3))
+			res, err := ct.tr.RoundTrip(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if want := "response for " + fmt.Sprint(i%3); string(b) != want {
+				t.Errorf("%s: got %q; want %q", hdr, b, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
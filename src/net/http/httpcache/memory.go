@@ -0,0 +1,97 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryCache is a Cache that holds values in memory. When the total
+// size of its keys and values exceeds its limit, it evicts the least
+// recently used values.
+//
+// MemoryCache is safe for concurrent use by multiple goroutines.
+type MemoryCache struct {
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List // of *memoryItem, most recently used first
+	items map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+func (it *memoryItem) size() int64 {
+	return int64(len(it.key) + len(it.value))
+}
+
+// NewMemoryCache returns a MemoryCache holding up to maxBytes bytes
+// of keys and values.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored for key, if any, and marks it
+// as recently used.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*memoryItem).value, true
+}
+
+// Set stores value for key. Values larger than the cache's
+// limit are not stored.
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	it := &memoryItem{key: key, value: value}
+	if it.size() > c.maxBytes {
+		return
+	}
+	c.items[key] = c.lru.PushFront(it)
+	c.size += it.size()
+	for c.size > c.maxBytes {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// Delete removes the value stored for key, if any.
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Len returns the number of values in the cache.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *MemoryCache) removeElement(el *list.Element) {
+	it := c.lru.Remove(el).(*memoryItem)
+	delete(c.items, it.key)
+	c.size -= it.size()
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpcache
+
+import "testing"
+
+func TestMemoryCacheEviction(t *testing.T) {
+	c := NewMemoryCache(30)
+	c.Set("a", make([]byte, 9)) // 10 bytes
+	c.Set("b", make([]byte, 9))
+	c.Set("c", make([]byte, 9))
+	if c.Len() != 3 {
+		t.Fatalf("Len = %d; want 3", c.Len())
+	}
+	// Using "a" makes "b" the least recently used.
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal(`Get("a") failed`)
+	}
+	c.Set("d", make([]byte, 9))
+	if _, ok := c.Get("b"); ok {
+		t.Error(`"b" was not evicted`)
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%q was evicted", k)
+		}
+	}
+
+	// Replacing a value accounts for the old size.
+	c.Set("a", make([]byte, 4))
+	c.Set("e", make([]byte, 4))
+	if c.Len() != 4 {
+		t.Errorf("Len = %d; want 4", c.Len())
+	}
+
+	// Values larger than the cache are not stored.
+	c.Set("big", make([]byte, 100))
+	if _, ok := c.Get("big"); ok {
+		t.Error("oversized value was stored")
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error(`"a" was not deleted`)
+	}
+}
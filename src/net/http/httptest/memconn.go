@@ -0,0 +1,88 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// In-memory connections for Server.
+
+package httptest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+var errMemListenerClosed = errors.New("httptest: in-memory listener closed")
+
+// memPort numbers in-memory listeners, so that each has a distinct
+// address. The addresses are never bound.
+var memPort uint32
+
+// A memListener is a net.Listener whose connections are made by
+// calling its dial method, with no sockets involved. Each connection
+// is one end of a net.Pipe.
+type memListener struct {
+	addr     *net.TCPAddr
+	conns    chan net.Conn
+	done     chan struct{}
+	once     sync.Once
+	nextPort uint32 // accessed atomically; for client addresses
+}
+
+func newMemListener() *memListener {
+	port := atomic.AddUint32(&memPort, 1)
+	return &memListener{
+		addr:  &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(port)},
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (l *memListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, errMemListenerClosed
+	}
+}
+
+func (l *memListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *memListener) Addr() net.Addr { return l.addr }
+
+// dial returns a new connection to l. It is suitable for use as a
+// Transport's DialContext hook; the address is ignored.
+func (l *memListener) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	clientAddr := &net.TCPAddr{IP: l.addr.IP, Port: int(atomic.AddUint32(&l.nextPort, 1))}
+	c1, c2 := net.Pipe()
+	client := &memConn{Conn: c1, local: clientAddr, remote: l.addr}
+	server := &memConn{Conn: c2, local: l.addr, remote: clientAddr}
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		c1.Close()
+		c2.Close()
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: l.addr, Err: errMemListenerClosed}
+	case <-ctx.Done():
+		c1.Close()
+		c2.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// A memConn is a net.Pipe connection with TCP addresses, so that
+// handlers may parse Request.RemoteAddr as usual.
+type memConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c *memConn) LocalAddr() net.Addr  { return c.local }
+func (c *memConn) RemoteAddr() net.Addr { return c.remote }
@@ -0,0 +1,359 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Implementation of ReplayTransport
+
+package httptest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// A ReplayTransport is an http.RoundTripper for testing clients of
+// HTTP services without contacting them. In record mode, it sends
+// requests with an underlying transport and writes the exchanges to a
+// golden file. Otherwise, it replies to requests with the responses
+// recorded in the file.
+//
+// The file holds the requests and responses in HTTP/1.1 wire format,
+// as written by http.Request.WriteProxy and http.Response.Write, with
+// bodies of known length in place of chunked ones. It can be checked
+// into version control, edited and diffed by hand.
+//
+// A request is answered with the first recorded exchange whose
+// request matches it and that hasn't been replayed yet, so repeated
+// identical requests get their responses in the recorded order.
+type ReplayTransport struct {
+	// File is the name of the golden file.
+	File string
+
+	// Record selects record mode. In record mode, File is replaced
+	// with the exchanges made through the ReplayTransport, after each
+	// one. Tests often set it from a command-line flag.
+	Record bool
+
+	// Transport sends requests in record mode.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// MatchHeaders lists the request header fields whose values
+	// must be equal for a recorded request to match a request, in
+	// addition to its method, URL and body.
+	MatchHeaders []string
+
+	// IgnoreBody reports whether recorded requests match requests
+	// with different bodies.
+	IgnoreBody bool
+
+	// Match, if non-nil, reports whether the recorded request rec
+	// matches req, in place of the comparison of method, URL,
+	// MatchHeaders and body. The request bodies may be read.
+	Match func(req, rec *http.Request) bool
+
+	// RedactHeaders lists request and response header fields whose
+	// values are replaced by "REDACTED" in File. The Authorization
+	// and Proxy-Authorization fields are always redacted.
+	RedactHeaders []string
+
+	// Redact, if non-nil, modifies each exchange before it is
+	// written to File, to remove secrets. It may change the
+	// requests' and responses' URL, header fields and bodies. In
+	// replay mode, requests are redacted with a nil response before
+	// being matched against the recorded ones, so that requests
+	// with secrets still match.
+	Redact func(*http.Request, *http.Response)
+
+	mu        sync.Mutex
+	loaded    bool
+	loadErr   error
+	exchanges []*exchange
+}
+
+// An exchange is a recorded request and its response.
+type exchange struct {
+	req      *http.Request
+	reqBody  []byte
+	res      *http.Response
+	resBody  []byte
+	replayed bool
+}
+
+// alwaysRedacted are the header fields ReplayTransport always redacts.
+var alwaysRedacted = []string{"Authorization", "Proxy-Authorization"}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.Record {
+		return t.record(req, body)
+	}
+	return t.replay(req, body)
+}
+
+func (t *ReplayTransport) record(req *http.Request, body []byte) (*http.Response, error) {
+	outreq := req.Clone(req.Context())
+	outreq.Body = newBody(body)
+	outreq.GetBody = func() (io.ReadCloser, error) { return newBody(body), nil }
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	res, err := transport.RoundTrip(outreq)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = newBody(resBody)
+
+	recres := new(http.Response)
+	*recres = *res
+	recres.Header = res.Header.Clone()
+	recres.Body = newBody(resBody)
+	rec, err := t.redact(req, body, recres)
+	if err != nil {
+		return nil, err
+	}
+	recResBody, err := ioutil.ReadAll(recres.Body)
+	if err != nil {
+		return nil, err
+	}
+	// Round-trip the redacted exchange through the wire format, so
+	// it's kept exactly as it will be replayed.
+	var buf bytes.Buffer
+	if err := writeExchange(&buf, rec.req, rec.reqBody, recres, recResBody); err != nil {
+		return nil, err
+	}
+	ex, err := readExchange(bufio.NewReader(&buf))
+	if err != nil {
+		return nil, fmt.Errorf("httptest: recording %s %s: %v", req.Method, req.URL, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exchanges = append(t.exchanges, ex)
+	buf.Reset()
+	for _, ex := range t.exchanges {
+		if err := writeExchange(&buf, ex.req, ex.reqBody, ex.res, ex.resBody); err != nil {
+			return nil, err
+		}
+	}
+	if err := ioutil.WriteFile(t.File, buf.Bytes(), 0666); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *ReplayTransport) replay(req *http.Request, body []byte) (*http.Response, error) {
+	// Match against the request as it would have been recorded.
+	canon, err := t.redact(req, body, nil)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeRequest(&buf, canon.req, canon.reqBody); err != nil {
+		return nil, err
+	}
+	creq, err := http.ReadRequest(bufio.NewReader(&buf))
+	if err != nil {
+		return nil, err
+	}
+	cbody, err := ioutil.ReadAll(creq.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	for _, ex := range t.exchanges {
+		if ex.replayed || !t.match(creq, cbody, ex) {
+			continue
+		}
+		ex.replayed = true
+		res := new(http.Response)
+		*res = *ex.res
+		res.Header = ex.res.Header.Clone()
+		res.Body = newBody(ex.resBody)
+		res.Request = req
+		return res, nil
+	}
+	return nil, fmt.Errorf("httptest: no recorded exchange in %s matches %s %s", t.File, req.Method, req.URL)
+}
+
+// load reads the exchanges from t.File, once.
+// t.mu must be held.
+func (t *ReplayTransport) load() error {
+	if t.loaded {
+		return t.loadErr
+	}
+	t.loaded = true
+	data, err := ioutil.ReadFile(t.File)
+	if err != nil {
+		t.loadErr = err
+		return err
+	}
+	br := bufio.NewReader(bytes.NewReader(data))
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			break
+		}
+		ex, err := readExchange(br)
+		if err != nil {
+			t.loadErr = fmt.Errorf("httptest: reading exchange %d of %s: %v", len(t.exchanges)+1, t.File, err)
+			return t.loadErr
+		}
+		t.exchanges = append(t.exchanges, ex)
+	}
+	return nil
+}
+
+func (t *ReplayTransport) match(req *http.Request, body []byte, ex *exchange) bool {
+	if t.Match != nil {
+		req.Body = newBody(body)
+		ex.req.Body = newBody(ex.reqBody)
+		return t.Match(req, ex.req)
+	}
+	if req.Method != ex.req.Method || req.URL.String() != ex.req.URL.String() {
+		return false
+	}
+	for _, k := range t.MatchHeaders {
+		if !equalValues(req.Header.Values(k), ex.req.Header.Values(k)) {
+			return false
+		}
+	}
+	return t.IgnoreBody || bytes.Equal(body, ex.reqBody)
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// redact returns a copy of req, with the given body, redacted for
+// recording along with res, which it modifies. res may be nil.
+func (t *ReplayTransport) redact(req *http.Request, body []byte, res *http.Response) (*exchange, error) {
+	r := req.Clone(req.Context())
+	r.Body = newBody(body)
+	for _, k := range alwaysRedacted {
+		redactHeader(r.Header, k)
+	}
+	for _, k := range t.RedactHeaders {
+		redactHeader(r.Header, k)
+		if res != nil {
+			redactHeader(res.Header, k)
+		}
+	}
+	if t.Redact != nil {
+		t.Redact(r, res)
+	}
+	ex := &exchange{req: r}
+	if r.Body != nil {
+		var err error
+		ex.reqBody, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ex, nil
+}
+
+func redactHeader(h http.Header, key string) {
+	vv := h.Values(key)
+	if len(vv) == 0 {
+		return
+	}
+	h.Del(key)
+	for range vv {
+		h.Add(key, "REDACTED")
+	}
+}
+
+func newBody(b []byte) io.ReadCloser {
+	return ioutil.NopCloser(bytes.NewReader(b))
+}
+
+// writeRequest writes req in wire format, with body in place of
+// req.Body.
+func writeRequest(w io.Writer, req *http.Request, body []byte) error {
+	r := new(http.Request)
+	*r = *req
+	r.ContentLength = int64(len(body))
+	r.TransferEncoding = nil
+	r.Body = nil
+	if len(body) > 0 {
+		r.Body = newBody(body)
+	}
+	return r.WriteProxy(w)
+}
+
+// writeExchange writes req and res in wire format, with reqBody and
+// resBody in place of their bodies.
+func writeExchange(w io.Writer, req *http.Request, reqBody []byte, res *http.Response, resBody []byte) error {
+	if err := writeRequest(w, req, reqBody); err != nil {
+		return err
+	}
+	r := new(http.Response)
+	*r = *res
+	r.Request = req
+	if req.Method != "HEAD" {
+		r.ContentLength = int64(len(resBody))
+	}
+	r.TransferEncoding = nil
+	r.Trailer = nil
+	r.Body = nil
+	if len(resBody) > 0 {
+		r.Body = newBody(resBody)
+	}
+	return r.Write(w)
+}
+
+// readExchange reads a request and its response, as written by
+// writeExchange, from br.
+func readExchange(br *bufio.Reader) (*exchange, error) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	ex := &exchange{req: req}
+	if ex.reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+		return nil, err
+	}
+	if ex.res, err = http.ReadResponse(br, req); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if ex.resBody, err = ioutil.ReadAll(ex.res.Body); err != nil {
+		return nil, err
+	}
+	ex.res.Body = nil
+	return ex, nil
+}
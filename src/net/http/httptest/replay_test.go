@@ -0,0 +1,213 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// replayTestServer returns a server that replies to each request
+// with a counter, its method, path and body.
+func replayTestServer(t *testing.T) *Server {
+	n := 0
+	ts := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		fmt.Fprintf(w, "%d %s %s %s", n, r.Method, r.URL.RequestURI(), body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// goldenFile returns the name of a file in a new temporary directory.
+func goldenFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "golden")
+}
+
+// do sends a request with c and returns the response body.
+func do(t *testing.T, c *http.Client, method, url, body string, h http.Header) string {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, vv := range h {
+		req.Header[k] = vv
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// noNetwork is a RoundTripper that fails the test if it's used.
+type noNetwork struct{ t *testing.T }
+
+func (n noNetwork) RoundTrip(req *http.Request) (*http.Response, error) {
+	n.t.Errorf("replay sent %s %s to the network", req.Method, req.URL)
+	return nil, fmt.Errorf("no network")
+}
+
+func TestReplayTransport(t *testing.T) {
+	ts := replayTestServer(t)
+	file := goldenFile(t)
+	auth := http.Header{"Authorization": {"Bearer secret"}}
+
+	rec := &ReplayTransport{File: file, Record: true, RedactHeaders: []string{"Set-Cookie"}}
+	c := &http.Client{Transport: rec}
+	want := []string{
+		do(t, c, "GET", ts.URL+"/a?x=1", "", auth),
+		do(t, c, "POST", ts.URL+"/b", "hello", auth),
+		do(t, c, "GET", ts.URL+"/a?x=1", "", auth),
+	}
+	if want[0] != "1 GET /a?x=1 " || want[2] != "3 GET /a?x=1 " {
+		t.Fatalf("recorded responses = %q", want)
+	}
+
+	golden, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(golden, []byte("secret")) {
+		t.Errorf("golden file contains a secret:\n%s", golden)
+	}
+	if n := bytes.Count(golden, []byte("REDACTED")); n != 6 {
+		t.Errorf("golden file has %d redacted values; want 6:\n%s", n, golden)
+	}
+
+	// Replay the exchanges, in a different order, with a
+	// different Authorization header.
+	c = &http.Client{Transport: &ReplayTransport{File: file, Transport: noNetwork{t}}}
+	auth = http.Header{"Authorization": {"Bearer other"}}
+	if got := do(t, c, "POST", ts.URL+"/b", "hello", auth); got != want[1] {
+		t.Errorf("replayed POST = %q; want %q", got, want[1])
+	}
+	for _, w := range []string{want[0], want[2]} {
+		if got := do(t, c, "GET", ts.URL+"/a?x=1", "", auth); got != w {
+			t.Errorf("replayed GET = %q; want %q", got, w)
+		}
+	}
+	if _, err := c.Get(ts.URL + "/a?x=1"); err == nil || !strings.Contains(err.Error(), "no recorded exchange") {
+		t.Errorf("GET after all exchanges were replayed: %v; want no recorded exchange error", err)
+	}
+}
+
+func TestReplayTransportMatching(t *testing.T) {
+	ts := replayTestServer(t)
+	file := goldenFile(t)
+	c := &http.Client{Transport: &ReplayTransport{File: file, Record: true}}
+	do(t, c, "POST", ts.URL+"/", "body", http.Header{"X-Version": {"1"}})
+
+	tests := []struct {
+		name   string
+		rt     *ReplayTransport
+		method string
+		path   string
+		body   string
+		h      http.Header
+		match  bool
+	}{
+		{"same", &ReplayTransport{}, "POST", "/", "body", nil, true},
+		{"method", &ReplayTransport{}, "PUT", "/", "body", nil, false},
+		{"path", &ReplayTransport{}, "POST", "/x", "body", nil, false},
+		{"body", &ReplayTransport{}, "POST", "/", "other", nil, false},
+		{"IgnoreBody", &ReplayTransport{IgnoreBody: true}, "POST", "/", "other", nil, true},
+		{"unmatched header", &ReplayTransport{}, "POST", "/", "body", http.Header{"X-Version": {"2"}}, true},
+		{"MatchHeaders", &ReplayTransport{MatchHeaders: []string{"x-version"}}, "POST", "/", "body", http.Header{"X-Version": {"2"}}, false},
+		{"Match", &ReplayTransport{Match: func(req, rec *http.Request) bool {
+			b1, _ := ioutil.ReadAll(req.Body)
+			b2, _ := ioutil.ReadAll(rec.Body)
+			return strings.EqualFold(string(b1), string(b2))
+		}}, "DELETE", "/y", "BODY", nil, true},
+	}
+	for _, tt := range tests {
+		tt.rt.File = file
+		tt.rt.Transport = noNetwork{t}
+		req, _ := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+		for k, vv := range tt.h {
+			req.Header[k] = vv
+		}
+		res, err := tt.rt.RoundTrip(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		if match := err == nil; match != tt.match {
+			t.Errorf("%s: RoundTrip error = %v; want match %v", tt.name, err, tt.match)
+		}
+	}
+}
+
+func TestReplayTransportRedact(t *testing.T) {
+	ts := replayTestServer(t)
+	file := goldenFile(t)
+	redact := func(req *http.Request, res *http.Response) {
+		q := req.URL.Query()
+		q.Set("key", "KEY")
+		req.URL.RawQuery = q.Encode()
+		if res != nil {
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body = ioutil.NopCloser(bytes.NewReader(bytes.Replace(b, []byte("s3cr3t"), []byte("KEY"), -1)))
+		}
+	}
+	c := &http.Client{Transport: &ReplayTransport{File: file, Record: true, Redact: redact}}
+	if got, want := do(t, c, "GET", ts.URL+"/?key=s3cr3t", "", nil), "1 GET /?key=s3cr3t "; got != want {
+		t.Errorf("recorded response = %q; want %q", got, want)
+	}
+
+	c = &http.Client{Transport: &ReplayTransport{File: file, Redact: redact, Transport: noNetwork{t}}}
+	if got, want := do(t, c, "GET", ts.URL+"/?key=n3w", "", nil), "1 GET /?key=KEY "; got != want {
+		t.Errorf("replayed response = %q; want %q", got, want)
+	}
+	golden, _ := ioutil.ReadFile(file)
+	if bytes.Contains(golden, []byte("s3cr3t")) {
+		t.Errorf("golden file contains a secret:\n%s", golden)
+	}
+}
+
+func TestReplayTransportHead(t *testing.T) {
+	ts := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1234")
+	}))
+	defer ts.Close()
+	file := goldenFile(t)
+	for _, record := range []bool{true, false} {
+		c := &http.Client{Transport: &ReplayTransport{File: file, Record: record}}
+		res, err := c.Head(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.ContentLength != 1234 {
+			t.Errorf("record = %v: ContentLength = %d; want 1234", record, res.ContentLength)
+		}
+	}
+}
+
+func TestReplayTransportMissingFile(t *testing.T) {
+	rt := &ReplayTransport{File: goldenFile(t), Transport: noNetwork{t}}
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip with a missing file succeeded")
+	}
+}
@@ -22,7 +22,7 @@ import (
 )
 
 // A Server is an HTTP server listening on a system-chosen port on the
-// local loopback interface, for use in end-to-end HTTP tests.
+// local loopback interface, or in memory, for use in end-to-end HTTP tests.
 type Server struct {
 	URL      string // base URL of form http://ipaddr:port with no trailing slash
 	Listener net.Listener
@@ -55,6 +55,10 @@ type Server struct {
 	// client is configured for use with the server.
 	// Its transport is automatically closed when Close is called.
 	client *http.Client
+
+	// mem is the in-memory listener of a server from
+	// NewUnstartedMemoryServer, which client dials.
+	mem *memListener
 }
 
 func newLocalListener() net.Listener {
@@ -119,18 +123,56 @@ func NewUnstartedServer(handler http.Handler) *Server {
 	}
 }
 
+// NewMemoryServer starts and returns a new Server that, instead of
+// listening on a port, accepts connections in memory from the
+// client returned by its Client method. Other clients can't reach
+// it, so tests converted from NewServer must make their requests
+// with that client.
+// The caller should call Close when finished, to shut it down.
+func NewMemoryServer(handler http.Handler) *Server {
+	ts := NewUnstartedMemoryServer(handler)
+	ts.Start()
+	return ts
+}
+
+// NewMemoryTLSServer starts and returns a new in-memory Server
+// using TLS. See NewMemoryServer.
+// The caller should call Close when finished, to shut it down.
+func NewMemoryTLSServer(handler http.Handler) *Server {
+	ts := NewUnstartedMemoryServer(handler)
+	ts.StartTLS()
+	return ts
+}
+
+// NewUnstartedMemoryServer is like NewUnstartedServer but returns an
+// in-memory Server. See NewMemoryServer.
+//
+// After changing its configuration, the caller should call Start or
+// StartTLS. Setting EnableHTTP2 before StartTLS enables HTTP/2, as
+// with a Server listening on a port.
+//
+// The caller should call Close when finished, to shut it down.
+func NewUnstartedMemoryServer(handler http.Handler) *Server {
+	mem := newMemListener()
+	return &Server{
+		Listener: mem,
+		Config:   &http.Server{Handler: handler},
+		mem:      mem,
+	}
+}
+
 // Start starts a server from NewUnstartedServer.
 func (s *Server) Start() {
 	if s.URL != "" {
 		panic("Server already started")
 	}
 	if s.client == nil {
-		s.client = &http.Client{Transport: &http.Transport{}}
+		s.client = &http.Client{Transport: s.newTransport()}
 	}
 	s.URL = "http://" + s.Listener.Addr().String()
 	s.wrap()
 	s.goServe()
-	if serveFlag != "" {
+	if serveFlag != "" && s.mem == nil {
 		fmt.Fprintln(os.Stderr, "httptest: serving on", s.URL)
 		select {}
 	}
@@ -142,7 +184,7 @@ func (s *Server) StartTLS() {
 		panic("Server already started")
 	}
 	if s.client == nil {
-		s.client = &http.Client{Transport: &http.Transport{}}
+		s.client = &http.Client{Transport: s.newTransport()}
 	}
 	cert, err := tls.X509KeyPair(internal.LocalhostCert, internal.LocalhostKey)
 	if err != nil {
@@ -171,12 +213,12 @@ func (s *Server) StartTLS() {
 	}
 	certpool := x509.NewCertPool()
 	certpool.AddCert(s.certificate)
-	s.client.Transport = &http.Transport{
-		TLSClientConfig: &tls.Config{
-			RootCAs: certpool,
-		},
-		ForceAttemptHTTP2: s.EnableHTTP2,
+	tr := s.newTransport()
+	tr.TLSClientConfig = &tls.Config{
+		RootCAs: certpool,
 	}
+	tr.ForceAttemptHTTP2 = s.EnableHTTP2
+	s.client.Transport = tr
 	s.Listener = tls.NewListener(s.Listener, s.TLS)
 	s.URL = "https://" + s.Listener.Addr().String()
 	s.wrap()
@@ -191,6 +233,15 @@ func NewTLSServer(handler http.Handler) *Server {
 	return ts
 }
 
+// newTransport returns a new Transport for s.client.
+func (s *Server) newTransport() *http.Transport {
+	tr := &http.Transport{}
+	if s.mem != nil {
+		tr.DialContext = s.mem.dial
+	}
+	return tr
+}
+
 type closeIdleTransport interface {
 	CloseIdleConnections()
 }
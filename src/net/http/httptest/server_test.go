@@ -9,6 +9,8 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -238,3 +240,85 @@ func TestTLSServerWithHTTP2(t *testing.T) {
 		})
 	}
 }
+
+func TestMemoryServer(t *testing.T) {
+	modes := []struct {
+		name      string
+		start     func(*Server)
+		h2        bool
+		wantProto string
+	}{
+		{"http1", (*Server).Start, false, "HTTP/1.1"},
+		{"https1", (*Server).StartTLS, false, "HTTP/1.1"},
+		{"http2", (*Server).StartTLS, true, "HTTP/2.0"},
+	}
+	for _, tt := range modes {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := NewUnstartedMemoryServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, _, err := net.SplitHostPort(r.RemoteAddr); err != nil {
+					t.Errorf("RemoteAddr %q: %v", r.RemoteAddr, err)
+				}
+				w.Header().Set("X-Proto", r.Proto)
+				body, _ := ioutil.ReadAll(r.Body)
+				w.Write(body)
+			}))
+			ts.EnableHTTP2 = tt.h2
+			tt.start(ts)
+			defer ts.Close()
+
+			// Bodies larger than a pipe would buffer, sent and
+			// received concurrently by several requests.
+			body := strings.Repeat("x", 1<<20)
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := ts.Client().Post(ts.URL, "text/plain", strings.NewReader(body))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					got, err := ioutil.ReadAll(res.Body)
+					res.Body.Close()
+					if err != nil || string(got) != body {
+						t.Errorf("echoed %d bytes, %v; want %d bytes", len(got), err, len(body))
+					}
+					if g, w := res.Header.Get("X-Proto"), tt.wantProto; g != w {
+						t.Errorf("X-Proto = %q; want %q", g, w)
+					}
+				}()
+			}
+			wg.Wait()
+
+			ts.Close()
+			if res, err := ts.Client().Get(ts.URL); err == nil {
+				res.Body.Close()
+				t.Fatal("request succeeded after Close")
+			}
+		})
+	}
+}
+
+func TestMemoryServerCloseClientConnections(t *testing.T) {
+	var s *Server
+	s = NewMemoryServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.CloseClientConnections()
+	}))
+	defer s.Close()
+	res, err := s.Client().Get(s.URL)
+	if err == nil {
+		res.Body.Close()
+		t.Fatalf("Unexpected response: %#v", res)
+	}
+}
+
+func TestMemoryServersHaveDistinctURLs(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	s1, s2 := NewMemoryServer(h), NewMemoryTLSServer(h)
+	defer s1.Close()
+	defer s2.Close()
+	if strings.TrimPrefix(s1.URL, "http://") == strings.TrimPrefix(s2.URL, "https://") {
+		t.Errorf("in-memory servers share the address of %s and %s", s1.URL, s2.URL)
+	}
+}
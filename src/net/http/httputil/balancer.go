@@ -0,0 +1,473 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Load balancing across several upstream servers.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoHealthyUpstream is returned by Balancer.RoundTrip when no
+// upstream is available to serve a request.
+var ErrNoHealthyUpstream = errors.New("httputil: no healthy upstream")
+
+// An Upstream is one of the servers a Balancer sends requests to.
+type Upstream struct {
+	// URL is the scheme, host and base path of the upstream.
+	URL *url.URL
+
+	active   int64 // accessed atomically
+	requests int64 // accessed atomically
+	failures int64 // accessed atomically
+
+	mu        sync.Mutex
+	checkOK   bool      // result of the last active health check
+	fails     int       // consecutive failed requests
+	downUntil time.Time // passively marked down until then
+}
+
+// Active returns the number of requests to u currently in progress,
+// including those whose response body is still being read.
+func (u *Upstream) Active() int64 { return atomic.LoadInt64(&u.active) }
+
+// Requests returns the number of requests sent to u.
+func (u *Upstream) Requests() int64 { return atomic.LoadInt64(&u.requests) }
+
+// Failures returns the number of requests to u that failed without
+// a response.
+func (u *Upstream) Failures() int64 { return atomic.LoadInt64(&u.failures) }
+
+// Healthy reports whether u passed its last active health check and
+// is not marked down after failed requests.
+func (u *Upstream) Healthy() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.checkOK && !timeNow().Before(u.downUntil)
+}
+
+// timeNow is time.Now, replaced in tests.
+var timeNow = time.Now
+
+// A Picker chooses the upstream for a request from the healthy
+// upstreams that have not yet been tried for it. Pick is never called
+// with an empty slice, and must return one of its elements.
+//
+// Implementations must be safe for concurrent use by multiple
+// goroutines.
+type Picker interface {
+	Pick(req *http.Request, upstreams []*Upstream) *Upstream
+}
+
+// RoundRobin returns a Picker that chooses upstreams in turn.
+func RoundRobin() Picker { return new(roundRobin) }
+
+type roundRobin struct {
+	next uint64 // accessed atomically
+}
+
+func (p *roundRobin) Pick(req *http.Request, upstreams []*Upstream) *Upstream {
+	n := atomic.AddUint64(&p.next, 1) - 1
+	return upstreams[n%uint64(len(upstreams))]
+}
+
+// LeastConnections returns a Picker that chooses the upstream with the
+// fewest active requests. Ties are broken in turn.
+func LeastConnections() Picker { return new(leastConnections) }
+
+type leastConnections struct {
+	next uint64 // accessed atomically
+}
+
+func (p *leastConnections) Pick(req *http.Request, upstreams []*Upstream) *Upstream {
+	start := atomic.AddUint64(&p.next, 1) - 1
+	var best *Upstream
+	for i := range upstreams {
+		u := upstreams[(start+uint64(i))%uint64(len(upstreams))]
+		if best == nil || u.Active() < best.Active() {
+			best = u
+		}
+	}
+	return best
+}
+
+// HeaderHash returns a Picker that chooses an upstream by consistent
+// hashing of the value of the named request header, so that requests
+// with the same value go to the same upstream while it is healthy.
+// Adding or removing an upstream moves only the values assigned to it.
+// Requests without the header are distributed in turn.
+func HeaderHash(header string) Picker {
+	return &headerHash{header: http.CanonicalHeaderKey(header)}
+}
+
+type headerHash struct {
+	header string
+	rr     roundRobin
+}
+
+func (p *headerHash) Pick(req *http.Request, upstreams []*Upstream) *Upstream {
+	key := req.Header.Get(p.header)
+	if key == "" {
+		return p.rr.Pick(req, upstreams)
+	}
+	// Rendezvous hashing: choose the upstream with the highest
+	// hash of the key combined with its URL.
+	var best *Upstream
+	var bestScore uint64
+	for _, u := range upstreams {
+		h := fnv.New64a()
+		io.WriteString(h, key)
+		h.Write([]byte{0})
+		io.WriteString(h, u.URL.String())
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = u, score
+		}
+	}
+	return best
+}
+
+// A Balancer is an http.RoundTripper that sends each request to one of
+// a set of upstream servers. The request's URL path and query are
+// joined onto the upstream's URL, as with NewSingleHostReverseProxy.
+//
+// An upstream is taken out of rotation for FailTimeout after MaxFails
+// consecutive requests to it fail without a response, and while its
+// active health checks fail; see RunHealthChecks.
+//
+// Balancer implements the expvar.Var interface, so its statistics
+// can be published with expvar.Publish.
+//
+// A Balancer's fields must not be modified while it is in use.
+type Balancer struct {
+	// Transport is the transport used to send requests to upstreams.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Picker chooses the upstream for each request.
+	// If nil, RoundRobin is used.
+	Picker Picker
+
+	// Retries is the number of other upstreams to try when an
+	// idempotent request fails because an upstream could not be
+	// dialed. Requests with a body are retried only if they have a
+	// GetBody function.
+	Retries int
+
+	// MaxFails is the number of consecutive failed requests after
+	// which an upstream is marked down. If zero, 1 is used.
+	MaxFails int
+
+	// FailTimeout is how long an upstream stays marked down after
+	// failed requests. If zero, 10 seconds is used.
+	FailTimeout time.Duration
+
+	upstreams []*Upstream
+	defaultRR roundRobin
+}
+
+// NewBalancer returns a Balancer for the given upstream URLs,
+// all initially considered healthy.
+func NewBalancer(targets []*url.URL) *Balancer {
+	b := new(Balancer)
+	for _, t := range targets {
+		b.upstreams = append(b.upstreams, &Upstream{URL: t, checkOK: true})
+	}
+	return b
+}
+
+// NewBalancingReverseProxy returns a new ReverseProxy that sends each
+// request to one of the upstreams of b. Like NewSingleHostReverseProxy,
+// it does not rewrite the Host header.
+func NewBalancingReverseProxy(b *Balancer) *ReverseProxy {
+	director := func(req *http.Request) {
+		if _, ok := req.Header["User-Agent"]; !ok {
+			// explicitly disable User-Agent so it's not set to default value
+			req.Header.Set("User-Agent", "")
+		}
+	}
+	return &ReverseProxy{Director: director, Transport: b}
+}
+
+// Upstreams returns the upstreams of b.
+func (b *Balancer) Upstreams() []*Upstream {
+	return append([]*Upstream(nil), b.upstreams...)
+}
+
+func (b *Balancer) transport() http.RoundTripper {
+	if b.Transport != nil {
+		return b.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (b *Balancer) maxFails() int {
+	if b.MaxFails > 0 {
+		return b.MaxFails
+	}
+	return 1
+}
+
+func (b *Balancer) failTimeout() time.Duration {
+	if b.FailTimeout > 0 {
+		return b.FailTimeout
+	}
+	return 10 * time.Second
+}
+
+// pick chooses a healthy upstream not in tried, or returns nil.
+func (b *Balancer) pick(req *http.Request, tried []*Upstream) *Upstream {
+	var candidates []*Upstream
+outer:
+	for _, u := range b.upstreams {
+		for _, t := range tried {
+			if u == t {
+				continue outer
+			}
+		}
+		if u.Healthy() {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	if b.Picker != nil {
+		return b.Picker.Pick(req, candidates)
+	}
+	return b.defaultRR.Pick(req, candidates)
+}
+
+// RoundTrip sends req to an upstream chosen by b.Picker.
+func (b *Balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var tried []*Upstream
+	var lastErr error
+	for {
+		u := b.pick(req, tried)
+		if u == nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, ErrNoHealthyUpstream
+		}
+		tried = append(tried, u)
+
+		outreq := req.Clone(req.Context())
+		rewriteRequestURL(outreq, u.URL)
+		if len(tried) > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			outreq.Body = body
+		}
+
+		atomic.AddInt64(&u.requests, 1)
+		atomic.AddInt64(&u.active, 1)
+		res, err := b.transport().RoundTrip(outreq)
+		if err == nil {
+			u.succeeded()
+			res.Body = newUpstreamBody(res.Body, u)
+			return res, nil
+		}
+		atomic.AddInt64(&u.active, -1)
+		if req.Context().Err() != nil {
+			// The client went away; the upstream is not to blame.
+			return nil, err
+		}
+		atomic.AddInt64(&u.failures, 1)
+		u.failed(b.maxFails(), b.failTimeout())
+		lastErr = err
+		if len(tried) > b.Retries || !isDialError(err) || !retryable(req) {
+			return nil, err
+		}
+	}
+}
+
+// rewriteRequestURL directs req to target, as the Director of
+// NewSingleHostReverseProxy does.
+func rewriteRequestURL(req *http.Request, target *url.URL) {
+	u := *req.URL
+	u.Scheme = target.Scheme
+	u.Host = target.Host
+	u.Path = singleJoiningSlash(target.Path, req.URL.Path)
+	if target.RawQuery == "" || u.RawQuery == "" {
+		u.RawQuery = target.RawQuery + u.RawQuery
+	} else {
+		u.RawQuery = target.RawQuery + "&" + u.RawQuery
+	}
+	req.URL = &u
+}
+
+func (u *Upstream) succeeded() {
+	u.mu.Lock()
+	u.fails = 0
+	u.mu.Unlock()
+}
+
+func (u *Upstream) failed(maxFails int, timeout time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.fails++
+	if u.fails >= maxFails {
+		u.fails = 0
+		u.downUntil = timeNow().Add(timeout)
+	}
+}
+
+// isDialError reports whether err shows that no connection could be
+// made, so that the request was not sent.
+func isDialError(err error) bool {
+	var oe *net.OpError
+	return errors.As(err, &oe) && oe.Op == "dial"
+}
+
+// retryable reports whether req may be sent again.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// newUpstreamBody wraps body so that the upstream's active request
+// count is decremented when it is closed. The bodies of 101 Switching
+// Protocols responses remain writable.
+func newUpstreamBody(body io.ReadCloser, u *Upstream) io.ReadCloser {
+	ub := &upstreamBody{ReadCloser: body, u: u}
+	if w, ok := body.(io.Writer); ok {
+		return upstreamRWBody{ub, w}
+	}
+	return ub
+}
+
+type upstreamBody struct {
+	io.ReadCloser
+	u      *Upstream
+	closed int32 // accessed atomically
+}
+
+func (b *upstreamBody) Close() error {
+	if atomic.CompareAndSwapInt32(&b.closed, 0, 1) {
+		atomic.AddInt64(&b.u.active, -1)
+	}
+	return b.ReadCloser.Close()
+}
+
+type upstreamRWBody struct {
+	*upstreamBody
+	io.Writer
+}
+
+// String returns the statistics of b's upstreams as a JSON object
+// keyed by upstream URL, implementing expvar.Var.
+func (b *Balancer) String() string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, u := range b.upstreams {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, `%s: {"healthy": %t, "active": %d, "requests": %d, "failures": %d}`,
+			strconv.Quote(u.URL.String()), u.Healthy(), u.Active(), u.Requests(), u.Failures())
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// A HealthCheck configures the active health checks run by
+// Balancer.RunHealthChecks.
+type HealthCheck struct {
+	// Path is the path requested from each upstream, joined onto
+	// the upstream's URL.
+	Path string
+
+	// Interval is the time between checks. If zero, 10 seconds
+	// is used.
+	Interval time.Duration
+
+	// Timeout bounds each check. If zero, 5 seconds is used.
+	Timeout time.Duration
+
+	// Healthy reports whether a response shows the upstream to be
+	// healthy. If nil, any 2xx status does.
+	Healthy func(*http.Response) bool
+}
+
+// RunHealthChecks periodically sends a GET request for hc.Path to
+// each upstream, using b.Transport, and takes upstreams out of
+// rotation while their checks fail. The first checks are made
+// immediately. RunHealthChecks returns when ctx is done; it is
+// usually run in its own goroutine.
+func (b *Balancer) RunHealthChecks(ctx context.Context, hc HealthCheck) {
+	interval := hc.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		for _, u := range b.upstreams {
+			wg.Add(1)
+			go func(u *Upstream) {
+				defer wg.Done()
+				ok := b.checkHealth(ctx, u, &hc)
+				if ctx.Err() != nil {
+					return
+				}
+				u.mu.Lock()
+				u.checkOK = ok
+				u.mu.Unlock()
+			}(u)
+		}
+		wg.Wait()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth makes a single health check request to u.
+func (b *Balancer) checkHealth(ctx context.Context, u *Upstream, hc *HealthCheck) bool {
+	timeout := hc.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "/", nil)
+	if err != nil {
+		return false
+	}
+	req.URL.Path = hc.Path
+	rewriteRequestURL(req, u.URL)
+	res, err := b.transport().RoundTrip(req)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4<<10))
+	if hc.Healthy != nil {
+		return hc.Healthy(res)
+	}
+	return res.StatusCode >= 200 && res.StatusCode <= 299
+}
@@ -0,0 +1,285 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Load balancer tests.
+
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newUpstreams starts n servers that reply with their index and the
+// request's path, and returns their URLs.
+func newUpstreams(t *testing.T, n int) []*url.URL {
+	var urls []*url.URL
+	for i := 0; i < n; i++ {
+		i := i
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%d %s", i, r.URL.RequestURI())
+		}))
+		t.Cleanup(ts.Close)
+		u, _ := url.Parse(ts.URL)
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// deadURL returns the URL of a port nobody listens on.
+func deadURL(t *testing.T) *url.URL {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &url.URL{Scheme: "http", Host: ln.Addr().String()}
+	ln.Close()
+	return u
+}
+
+func get(c *http.Client, url string) (string, error) {
+	res, err := c.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %s", res.Status)
+	}
+	return string(body), err
+}
+
+func TestBalancingReverseProxy(t *testing.T) {
+	urls := newUpstreams(t, 3)
+	urls[2].Path = "/base"
+	b := NewBalancer(urls)
+	frontend := httptest.NewServer(NewBalancingReverseProxy(b))
+	defer frontend.Close()
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		body, err := get(frontend.Client(), frontend.URL+"/p?q=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, body)
+	}
+	want := []string{
+		"0 /p?q=1", "1 /p?q=1", "2 /base/p?q=1",
+		"0 /p?q=1", "1 /p?q=1", "2 /base/p?q=1",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("responses = %q; want %q", got, want)
+	}
+	for _, u := range b.Upstreams() {
+		if n := u.Requests(); n != 2 {
+			t.Errorf("%v: Requests = %d; want 2", u.URL, n)
+		}
+		if n := u.Active(); n != 0 {
+			t.Errorf("%v: Active = %d; want 0", u.URL, n)
+		}
+	}
+}
+
+func TestBalancerLeastConnections(t *testing.T) {
+	b := NewBalancer(newUpstreams(t, 3))
+	us := b.Upstreams()
+	atomic.StoreInt64(&us[0].active, 5)
+	atomic.StoreInt64(&us[1].active, 1)
+	atomic.StoreInt64(&us[2].active, 3)
+	p := LeastConnections()
+	req := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 3; i++ {
+		if u := p.Pick(req, us); u != us[1] {
+			t.Errorf("Pick = %v; want %v", u.URL, us[1].URL)
+		}
+	}
+
+	// Ties are broken in turn.
+	atomic.StoreInt64(&us[1].active, 3)
+	seen := make(map[*Upstream]bool)
+	for i := 0; i < 4; i++ {
+		seen[p.Pick(req, us)] = true
+	}
+	if len(seen) != 2 || seen[us[0]] {
+		t.Errorf("tied picks chose %d upstreams, including the busiest: %v", len(seen), seen[us[0]])
+	}
+}
+
+func TestBalancerHeaderHash(t *testing.T) {
+	b := NewBalancer(newUpstreams(t, 4))
+	us := b.Upstreams()
+	p := HeaderHash("x-user")
+	pick := func(user string, us []*Upstream) *Upstream {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User", user)
+		return p.Pick(req, us)
+	}
+
+	assigned := make(map[string]*Upstream)
+	used := make(map[*Upstream]bool)
+	for i := 0; i < 100; i++ {
+		user := fmt.Sprintf("user%d", i)
+		u := pick(user, us)
+		if again := pick(user, us); again != u {
+			t.Fatalf("%s: picked %v, then %v", user, u.URL, again.URL)
+		}
+		assigned[user] = u
+		used[u] = true
+	}
+	if len(used) != len(us) {
+		t.Errorf("100 keys used %d of %d upstreams", len(used), len(us))
+	}
+
+	// Without us[0], only its keys move.
+	for user, u := range assigned {
+		got := pick(user, us[1:])
+		if u != us[0] && got != u {
+			t.Errorf("%s moved from %v to %v", user, u.URL, got.URL)
+		}
+	}
+}
+
+func TestBalancerRetry(t *testing.T) {
+	dead := deadURL(t)
+	live := newUpstreams(t, 1)[0]
+	b := NewBalancer([]*url.URL{dead, live})
+	b.Retries = 1
+	c := &http.Client{Transport: b}
+
+	body, err := get(c, "http://balanced/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "0 /x" {
+		t.Errorf("body = %q; want %q", body, "0 /x")
+	}
+	us := b.Upstreams()
+	if us[0].Healthy() || us[0].Failures() != 1 {
+		t.Errorf("dead upstream: Healthy = %v, Failures = %d; want false, 1", us[0].Healthy(), us[0].Failures())
+	}
+	if !us[1].Healthy() {
+		t.Error("live upstream is not healthy")
+	}
+
+	// A POST is not retried.
+	b = NewBalancer([]*url.URL{dead, live})
+	b.Retries = 1
+	c = &http.Client{Transport: b}
+	res, err := c.Post("http://balanced/x", "text/plain", strings.NewReader("body"))
+	if err == nil {
+		res.Body.Close()
+		t.Fatal("POST to a dead upstream succeeded")
+	}
+}
+
+func TestBalancerPassiveHealth(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	b := NewBalancer([]*url.URL{deadURL(t)})
+	b.MaxFails = 2
+	b.FailTimeout = time.Minute
+	c := &http.Client{Transport: b}
+	u := b.Upstreams()[0]
+
+	if _, err := get(c, "http://balanced/"); err == nil {
+		t.Fatal("request to dead upstream succeeded")
+	}
+	if !u.Healthy() {
+		t.Fatal("upstream marked down after one failure; MaxFails is 2")
+	}
+	get(c, "http://balanced/")
+	if u.Healthy() {
+		t.Fatal("upstream not marked down after two failures")
+	}
+	if _, err := get(c, "http://balanced/"); !errors.Is(err, ErrNoHealthyUpstream) {
+		t.Errorf("request with no healthy upstream: %v; want ErrNoHealthyUpstream", err)
+	}
+	if n := u.Requests(); n != 2 {
+		t.Errorf("Requests = %d; want 2", n)
+	}
+
+	now = now.Add(time.Minute)
+	if !u.Healthy() {
+		t.Error("upstream still down after FailTimeout")
+	}
+}
+
+func TestBalancerHealthChecks(t *testing.T) {
+	var healthy int32 = 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/base/healthz" {
+			t.Errorf("health check for %q", r.URL.Path)
+		}
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL + "/base")
+	b := NewBalancer([]*url.URL{u})
+	up := b.Upstreams()[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.RunHealthChecks(ctx, HealthCheck{Path: "/healthz", Interval: 10 * time.Millisecond})
+		close(done)
+	}()
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for up.Healthy() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Healthy did not become %v", want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	atomic.StoreInt32(&healthy, 0)
+	waitFor(false)
+	atomic.StoreInt32(&healthy, 1)
+	waitFor(true)
+	cancel()
+	<-done
+}
+
+func TestBalancerString(t *testing.T) {
+	urls := newUpstreams(t, 2)
+	b := NewBalancer(urls)
+	c := &http.Client{Transport: b}
+	if _, err := get(c, "http://balanced/"); err != nil {
+		t.Fatal(err)
+	}
+	var stats map[string]struct {
+		Healthy  bool
+		Active   int64
+		Requests int64
+		Failures int64
+	}
+	if err := json.Unmarshal([]byte(b.String()), &stats); err != nil {
+		t.Fatalf("String() = %s: %v", b.String(), err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("String() = %s; want 2 upstreams", b.String())
+	}
+	s := stats[urls[0].String()]
+	if !s.Healthy || s.Requests != 1 || s.Active != 0 || s.Failures != 0 {
+		t.Errorf("stats for first upstream = %+v", s)
+	}
+}
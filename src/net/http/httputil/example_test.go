@@ -5,6 +5,8 @@
 package httputil_test
 
 import (
+	"context"
+	"expvar"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -121,3 +123,26 @@ func ExampleReverseProxy() {
 	// Output:
 	// this call was relayed by the reverse proxy
 }
+
+func ExampleNewBalancingReverseProxy() {
+	var targets []*url.URL
+	for _, s := range []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080"} {
+		u, err := url.Parse(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		targets = append(targets, u)
+	}
+
+	b := httputil.NewBalancer(targets)
+	b.Picker = httputil.HeaderHash("X-Session-Id")
+	b.Retries = 1
+
+	// Take upstreams out of rotation while /healthz fails.
+	go b.RunHealthChecks(context.Background(), httputil.HealthCheck{Path: "/healthz"})
+
+	// Serve per-upstream statistics at /debug/vars.
+	expvar.Publish("upstreams", b)
+
+	log.Fatal(http.ListenAndServe(":8000", httputil.NewBalancingReverseProxy(b)))
+}
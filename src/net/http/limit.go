@@ -0,0 +1,127 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Server handler concurrency limits.
+
+package http
+
+import "sync/atomic"
+
+// A HandlerState represents the progress of a request through the
+// server's handlers. It's used by the optional Server.HandlerState hook.
+type HandlerState int
+
+const (
+	// HandlerQueued represents a request waiting for a handler
+	// because Server.MaxConcurrentHandlers handlers are running.
+	// It transitions to HandlerStarted or HandlerRejected.
+	HandlerQueued HandlerState = iota
+
+	// HandlerStarted represents a request whose handler is about
+	// to be called. It transitions to HandlerFinished.
+	HandlerStarted
+
+	// HandlerFinished represents a request whose handler has
+	// returned. This is a terminal state.
+	HandlerFinished
+
+	// HandlerRejected represents a request that was not handled
+	// because too many requests were running or queued, or because
+	// it was canceled while queued. The Server.HandlerState hook for
+	// HandlerRejected fires before Server.OverloadHandler is called.
+	// This is a terminal state.
+	HandlerRejected
+)
+
+var handlerStateName = map[HandlerState]string{
+	HandlerQueued:   "queued",
+	HandlerStarted:  "started",
+	HandlerFinished: "finished",
+	HandlerRejected: "rejected",
+}
+
+func (s HandlerState) String() string {
+	return handlerStateName[s]
+}
+
+// Load returns the number of handlers srv is running and the number
+// of requests waiting for a handler because of MaxConcurrentHandlers.
+func (srv *Server) Load() (running, queued int) {
+	return int(atomic.LoadInt32(&srv.handlersRunning)), int(atomic.LoadInt32(&srv.handlersQueued))
+}
+
+func (srv *Server) setHandlerState(r *Request, state HandlerState) {
+	if hook := srv.HandlerState; hook != nil {
+		hook(r, state)
+	}
+}
+
+func (srv *Server) handlerSemaphore() chan struct{} {
+	srv.handlerSemOnce.Do(func() {
+		if srv.MaxConcurrentHandlers > 0 {
+			srv.handlerSem = make(chan struct{}, srv.MaxConcurrentHandlers)
+		}
+	})
+	return srv.handlerSem
+}
+
+// startHandler waits until a handler may run for r, and reports
+// whether r was admitted. If it returns true, finishHandler must be
+// called when the handler returns.
+func (srv *Server) startHandler(r *Request) bool {
+	if sem := srv.handlerSemaphore(); sem != nil {
+		select {
+		case sem <- struct{}{}:
+		default:
+			if !srv.waitForHandler(r, sem) {
+				return false
+			}
+		}
+	}
+	atomic.AddInt32(&srv.handlersRunning, 1)
+	srv.setHandlerState(r, HandlerStarted)
+	return true
+}
+
+// waitForHandler queues r until a slot in sem is free, unless the
+// queue is full or r is canceled first.
+func (srv *Server) waitForHandler(r *Request, sem chan struct{}) bool {
+	if int(atomic.AddInt32(&srv.handlersQueued, 1)) > srv.MaxQueuedRequests {
+		atomic.AddInt32(&srv.handlersQueued, -1)
+		srv.setHandlerState(r, HandlerRejected)
+		return false
+	}
+	srv.setHandlerState(r, HandlerQueued)
+	// Blocked senders on a channel are served in order, so queued
+	// requests start in the order they arrived.
+	select {
+	case sem <- struct{}{}:
+		atomic.AddInt32(&srv.handlersQueued, -1)
+		return true
+	case <-r.Context().Done():
+		atomic.AddInt32(&srv.handlersQueued, -1)
+		srv.setHandlerState(r, HandlerRejected)
+		return false
+	}
+}
+
+func (srv *Server) finishHandler(r *Request) {
+	atomic.AddInt32(&srv.handlersRunning, -1)
+	if srv.handlerSem != nil {
+		<-srv.handlerSem
+	}
+	srv.setHandlerState(r, HandlerFinished)
+}
+
+func (srv *Server) overloadHandler() Handler {
+	if srv.OverloadHandler != nil {
+		return srv.OverloadHandler
+	}
+	return HandlerFunc(serveOverloaded)
+}
+
+func serveOverloaded(w ResponseWriter, r *Request) {
+	w.Header().Set("Retry-After", "1")
+	Error(w, "503 server overloaded", StatusServiceUnavailable)
+}
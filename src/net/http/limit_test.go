@@ -0,0 +1,169 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	. "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServerMaxConcurrentHandlers_h1(t *testing.T) { testServerMaxConcurrentHandlers(t, h1Mode) }
+func TestServerMaxConcurrentHandlers_h2(t *testing.T) { testServerMaxConcurrentHandlers(t, h2Mode) }
+
+func testServerMaxConcurrentHandlers(t *testing.T, h2 bool) {
+	setParallel(t)
+	defer afterTest(t)
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	states := make(map[HandlerState]int)
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		started <- r.URL.Path
+		<-release
+		fmt.Fprint(w, "ok")
+	}), func(ts *httptest.Server) {
+		ts.Config.MaxConcurrentHandlers = 2
+		ts.Config.MaxQueuedRequests = 1
+		ts.Config.HandlerState = func(r *Request, s HandlerState) {
+			mu.Lock()
+			states[s]++
+			mu.Unlock()
+		}
+	})
+	defer cst.close()
+	srv := cst.ts.Config
+
+	type result struct {
+		code       int
+		retryAfter string
+		err        error
+	}
+	results := make(chan result, 4)
+	get := func(path string) {
+		res, err := cst.c.Get(cst.ts.URL + path)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		results <- result{code: res.StatusCode, retryAfter: res.Header.Get("Retry-After")}
+	}
+	waitLoad := func(running, queued int) {
+		t.Helper()
+		for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(time.Millisecond) {
+			r, q := srv.Load()
+			if r == running && q == queued {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Load() = %d, %d; want %d, %d", r, q, running, queued)
+			}
+		}
+	}
+
+	go get("/1")
+	go get("/2")
+	<-started
+	<-started
+	waitLoad(2, 0)
+
+	// The third request waits, and the fourth is rejected.
+	go get("/3")
+	waitLoad(2, 1)
+	get("/4")
+	if r := <-results; r.err != nil || r.code != StatusServiceUnavailable || r.retryAfter != "1" {
+		t.Fatalf("overloaded request = %+v; want 503 with Retry-After: 1", r)
+	}
+
+	close(release)
+	if p := <-started; p != "/3" {
+		t.Errorf("queued request %q started; want /3", p)
+	}
+	for i := 0; i < 3; i++ {
+		if r := <-results; r.err != nil || r.code != StatusOK {
+			t.Errorf("request result = %+v; want 200", r)
+		}
+	}
+	waitLoad(0, 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[HandlerState]int{
+		HandlerQueued:   1,
+		HandlerStarted:  3,
+		HandlerFinished: 3,
+		HandlerRejected: 1,
+	}
+	for s, n := range want {
+		if states[s] != n {
+			t.Errorf("HandlerState %v reported %d times; want %d", s, states[s], n)
+		}
+	}
+}
+
+func TestServerQueuedRequestCanceled(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	release := make(chan struct{})
+	rejected := make(chan struct{}, 1)
+	cst := newClientServerTest(t, h1Mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		<-release
+	}), func(ts *httptest.Server) {
+		ts.Config.MaxConcurrentHandlers = 1
+		ts.Config.MaxQueuedRequests = 1
+		ts.Config.OverloadHandler = HandlerFunc(func(w ResponseWriter, r *Request) {
+			rejected <- struct{}{}
+		})
+	})
+	defer cst.close()
+	defer close(release)
+	srv := cst.ts.Config
+
+	go cst.c.Get(cst.ts.URL)
+	for r, _ := srv.Load(); r != 1; r, _ = srv.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := NewRequestWithContext(ctx, "GET", cst.ts.URL, nil)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := cst.c.Do(req)
+		errc <- err
+	}()
+	for _, q := srv.Load(); q != 1; _, q = srv.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-errc
+	select {
+	case <-rejected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("OverloadHandler not called for canceled request")
+	}
+	if _, q := srv.Load(); q != 0 {
+		t.Errorf("queued = %d after cancellation; want 0", q)
+	}
+}
+
+func TestHandlerStateString(t *testing.T) {
+	for s, want := range map[HandlerState]string{
+		HandlerQueued:   "queued",
+		HandlerStarted:  "started",
+		HandlerFinished: "finished",
+		HandlerRejected: "rejected",
+	} {
+		if got := s.String(); got != want {
+			t.Errorf("HandlerState(%d).String() = %q; want %q", int(s), got, want)
+		}
+	}
+}
@@ -27,6 +27,9 @@ const http2NextProtoTLS = "h2"
 type http2Transport struct {
 	MaxHeaderListSize uint32
 	ConnPool          interface{}
+	AllowHTTP         bool
+	ReadIdleTimeout   time.Duration
+	PingTimeout       time.Duration
 }
 
 func (*http2Transport) RoundTrip(*Request) (*Response, error) { panic(noHTTP2) }
@@ -0,0 +1,132 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func getTestTree(tb testing.TB) *routingNode {
+	root := &routingNode{}
+	for _, p := range []string{
+		"/a",
+		"/a/b",
+		"/a/{x}",
+		"/g/h/i",
+		"/g/{x}/j",
+		"/a/b/{x...}",
+		"/a/b/{y}",
+		"/a/b/{$}",
+		"GET /a",
+		"POST /a",
+		"HEAD /a/c",
+		"GET /a/c",
+		"example.com/",
+		"example.com/a/{x}",
+		"GET example.com/a/{x}/{$}",
+		"/b/",
+		"PUT /b/",
+		"GET /c/{p...}",
+		"DELETE /c/{p...}",
+	} {
+		root.addPattern(mustParsePattern(tb, p), nil)
+	}
+	return root
+}
+
+func TestRoutingFirstSegment(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"/a/b/c", []string{"a", "b", "c"}},
+		{"/a/b/", []string{"a", "b", "/"}},
+		{"/", []string{"/"}},
+		{"/a/%62/c", []string{"a", "b", "c"}},
+		{"/a%2Fb%2fc", []string{"a/b/c"}},
+	} {
+		var got []string
+		rest := test.in
+		for len(rest) > 0 {
+			var seg string
+			seg, rest = firstSegment(rest)
+			got = append(got, seg)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestRoutingNodeMatch(t *testing.T) {
+	tree := getTestTree(t)
+	for _, test := range []struct {
+		host, method, path string
+		wantPat            string // "" for nil (no match)
+		wantMatches        []string
+	}{
+		{"", "GET", "/a", "GET /a", nil},
+		{"", "HEAD", "/a", "GET /a", nil},
+		{"", "POST", "/a", "POST /a", nil},
+		{"", "PUT", "/a", "/a", nil},
+		{"", "GET", "/a/b", "/a/b", nil},
+		{"", "GET", "/a/c", "GET /a/c", nil},
+		{"", "HEAD", "/a/c", "HEAD /a/c", nil},
+		{"", "GET", "/a/d", "/a/{x}", []string{"d"}},
+		{"", "GET", "/a/b/", "/a/b/{$}", nil},
+		{"", "GET", "/a/b/c", "/a/b/{y}", []string{"c"}},
+		{"", "GET", "/a/b/c/d", "/a/b/{x...}", []string{"c/d"}},
+		// Backtracking: /g/h/j fails to match /g/h/i and falls back to /g/{x}/j.
+		{"", "GET", "/g/h/j", "/g/{x}/j", []string{"h"}},
+		{"", "GET", "/g/h/i", "/g/h/i", nil},
+		{"", "GET", "/g/h", "", nil},
+		// Host-specific patterns take precedence.
+		{"example.com", "GET", "/a/b", "example.com/a/{x}", []string{"b"}},
+		{"example.com", "GET", "/a/b/", "GET example.com/a/{x}/{$}", []string{"b"}},
+		{"example.com", "GET", "/x", "example.com/", nil},
+		{"example.org", "GET", "/a/b", "/a/b", nil},
+		// Trailing slash patterns.
+		{"", "GET", "/b/", "/b/", nil},
+		{"", "PUT", "/b/c", "PUT /b/", nil},
+		{"", "GET", "/c/d/%65", "GET /c/{p...}", []string{"d/e"}},
+		{"", "POST", "/c/d", "", nil},
+		{"", "GET", "/d", "", nil},
+	} {
+		n, matches := tree.match(test.host, test.method, test.path)
+		got := ""
+		if n != nil {
+			got = n.pattern.String()
+		}
+		if got != test.wantPat {
+			t.Errorf("%s %s%s: got pattern %q, want %q", test.method, test.host, test.path, got, test.wantPat)
+			continue
+		}
+		if !reflect.DeepEqual(matches, test.wantMatches) {
+			t.Errorf("%s %s%s: got matches %q, want %q", test.method, test.host, test.path, matches, test.wantMatches)
+		}
+	}
+}
+
+func TestRoutingNodeMatchingMethods(t *testing.T) {
+	tree := getTestTree(t)
+	for _, test := range []struct {
+		host, path string
+		want       string
+	}{
+		{"", "/a", "GET,HEAD,POST"},
+		{"", "/a/c", "GET,HEAD"},
+		{"", "/b/", "PUT"},
+		{"", "/c/d", "DELETE,GET,HEAD"},
+		{"example.com", "/a/b/", "GET,HEAD"},
+		{"", "/d", ""},
+	} {
+		got := strings.Join(tree.matchingMethods(test.host, test.path), ",")
+		if got != test.want {
+			t.Errorf("%s%s: got %q, want %q", test.host, test.path, got, test.want)
+		}
+	}
+}
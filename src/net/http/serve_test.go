@@ -610,6 +610,185 @@ func TestShouldRedirectConcurrency(t *testing.T) {
 	mux.HandleFunc("/", func(w ResponseWriter, r *Request) {})
 }
 
+func TestServeMuxMethodsAndWildcards(t *testing.T) {
+	setParallel(t)
+	mux := NewServeMux()
+	h := func(name string) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			fmt.Fprintf(w, "%s id=%q rest=%q", name, r.PathValue("id"), r.PathValue("rest"))
+		}
+	}
+	mux.Handle("GET /users/{id}", h("get"))
+	mux.Handle("DELETE /users/{id}", h("delete"))
+	mux.Handle("GET /users/me", h("me"))
+	mux.Handle("GET /files/{rest...}", h("files"))
+	mux.Handle("GET /{$}", h("root"))
+
+	for _, test := range []struct {
+		method, path string
+		code         int
+		body         string
+		allow        string
+	}{
+		{"GET", "/users/42", 200, `get id="42" rest=""`, ""},
+		{"HEAD", "/users/42", 200, `get id="42" rest=""`, ""},
+		{"DELETE", "/users/42", 200, `delete id="42" rest=""`, ""},
+		{"GET", "/users/me", 200, `me id="" rest=""`, ""},
+		{"GET", "/users/a%2Fb", 200, `get id="a/b" rest=""`, ""},
+		{"GET", "/files/a/b/c", 200, `files id="" rest="a/b/c"`, ""},
+		{"GET", "/", 200, `root id="" rest=""`, ""},
+		{"PUT", "/users/42", 405, "Method Not Allowed\n", "DELETE, GET, HEAD"},
+		{"POST", "/files/x", 405, "Method Not Allowed\n", "GET, HEAD"},
+		{"GET", "/other", 404, "404 page not found\n", ""},
+	} {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s %s: code = %d, want %d", test.method, test.path, w.Code, test.code)
+		}
+		if got := w.Body.String(); got != test.body {
+			t.Errorf("%s %s: body = %q, want %q", test.method, test.path, got, test.body)
+		}
+		if got := w.Header().Get("Allow"); got != test.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", test.method, test.path, got, test.allow)
+		}
+	}
+}
+
+func TestServeMuxWildcardRedirect(t *testing.T) {
+	setParallel(t)
+	mux := NewServeMux()
+	mux.HandleFunc("/dir/{rest...}", func(w ResponseWriter, r *Request) {})
+	mux.HandleFunc("/a/{x}/", func(w ResponseWriter, r *Request) {})
+	for _, test := range []struct {
+		path, loc string
+	}{
+		{"/dir", "/dir/"},
+		{"/a/b", "/a/b/"},
+		{"/a/b/../c?q=1", "/a/c/?q=1"},
+		{"/dir/../x", "/x"},
+	} {
+		r := httptest.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != StatusMovedPermanently {
+			t.Errorf("%s: code = %d, want %d", test.path, w.Code, StatusMovedPermanently)
+		}
+		if got := w.Header().Get("Location"); got != test.loc {
+			t.Errorf("%s: Location = %q, want %q", test.path, got, test.loc)
+		}
+	}
+}
+
+func TestServeMux114(t *testing.T) {
+	defer ExportSetServeMux114(true)()
+
+	mux := NewServeMux()
+	h := func(name string) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			io.WriteString(w, name)
+		}
+	}
+	// None of these registrations panic in Go 1.14.
+	mux.Handle("/a b", h("space"))
+	mux.Handle("/files/{legacy}", h("literal"))
+	mux.Handle("GET /", h("get-host"))
+	mux.Handle("/index.html", h("index"))
+	mux.Handle("/a/b", h("ab"))
+	mux.HandleFunc("/", h("root"))
+
+	for _, test := range []struct {
+		path string
+		body string
+	}{
+		{"/a%20b", "space"},
+		{"/files/%7Blegacy%7D", "literal"},
+		{"/files/x", "root"},
+		{"/index.html", "index"},
+		{"/a%2Fb", "ab"},
+	} {
+		r := httptest.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if got := w.Body.String(); got != test.body {
+			t.Errorf("GET %s: body = %q, want %q", test.path, got, test.body)
+		}
+		if _, pattern := mux.Handler(r); pattern == "" {
+			t.Errorf("GET %s: Handler returned no pattern", test.path)
+		}
+	}
+
+	defer func() {
+		if e := recover(); e == nil || !strings.Contains(fmt.Sprint(e), "multiple registrations") {
+			t.Errorf("registering \"/a b\" twice: panic = %v, want multiple registrations", e)
+		}
+	}()
+	mux.Handle("/a b", h("space"))
+}
+
+func TestServeMuxRegisterConflict(t *testing.T) {
+	for _, test := range []struct {
+		p1, p2 string
+		want   string // substring of panic message; "" if no panic
+	}{
+		{"/a", "/a", "matches the same requests"},
+		{"/users/{id}", "/users/{name}", "matches the same requests"},
+		{"/a/{x}", "/{y}/b", "both match some paths"},
+		{"GET /", "/index.html", "matches more methods"},
+		{"/a/{x}", "/a/b", ""},
+		{"GET /a", "POST /a", ""},
+		{"/a", "example.com/a", ""},
+		{"/{bad", "", "bad wildcard segment"},
+		{"GET /a/../b", "", "unclean path"},
+		{"FOO=BAR /", "", "invalid method"},
+	} {
+		var got string
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					got = fmt.Sprint(e)
+				}
+			}()
+			mux := NewServeMux()
+			mux.HandleFunc(test.p1, func(ResponseWriter, *Request) {})
+			if test.p2 != "" {
+				mux.HandleFunc(test.p2, func(ResponseWriter, *Request) {})
+			}
+		}()
+		if test.want == "" {
+			if got != "" {
+				t.Errorf("%q, %q: unexpected panic: %s", test.p1, test.p2, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, test.want) {
+			t.Errorf("%q, %q: panic = %q, want it to contain %q", test.p1, test.p2, got, test.want)
+		}
+		if test.p2 != "" && !strings.Contains(got, "serve_test.go") {
+			t.Errorf("%q, %q: panic = %q, want registration location", test.p1, test.p2, got)
+		}
+	}
+}
+
+func TestRequestPathValue(t *testing.T) {
+	setParallel(t)
+	mux := NewServeMux()
+	var got []string
+	mux.HandleFunc("/a/{x}/b/{y...}", func(w ResponseWriter, r *Request) {
+		r.SetPathValue("y", "changed")
+		r.SetPathValue("other", "v")
+		r2 := r.Clone(r.Context())
+		r2.SetPathValue("x", "clone")
+		got = []string{r.PathValue("x"), r.PathValue("y"), r.PathValue("other"), r.PathValue("missing"), r2.PathValue("x")}
+	})
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a/%7Bx%7D/b/c/d", nil))
+	want := []string{"{x}", "changed", "v", "", "clone"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 func BenchmarkServeMux(b *testing.B)           { benchmarkServeMux(b, true) }
 func BenchmarkServeMux_SkipServe(b *testing.B) { benchmarkServeMux(b, false) }
 func benchmarkServeMux(b *testing.B, runHandler bool) {
@@ -3848,7 +4027,7 @@ func testServerReaderFromOrder(t *testing.T, h2 bool) {
 
 // Issue 6157, Issue 6685
 func TestCodesPreventingContentTypeAndBody(t *testing.T) {
-	for _, code := range []int{StatusNotModified, StatusNoContent, StatusContinue} {
+	for _, code := range []int{StatusNotModified, StatusNoContent} {
 		ht := newHandlerTest(HandlerFunc(func(w ResponseWriter, r *Request) {
 			if r.URL.Path == "/header" {
 				w.Header().Set("Content-Length", "123")
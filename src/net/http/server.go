@@ -11,6 +11,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -23,7 +24,6 @@ import (
 	"os"
 	"path"
 	"runtime"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -98,8 +98,8 @@ type ResponseWriter interface {
 	// Handlers can set HTTP trailers.
 	//
 	// Changing the header map after a call to WriteHeader (or
-	// Write) has no effect unless the modified headers are
-	// trailers.
+	// Write) has no effect unless the HTTP status code was of the
+	// 1xx class or the modified headers are trailers.
 	//
 	// There are two ways to set Trailers. The preferred way is to
 	// predeclare in the headers which trailers you will later
@@ -147,10 +147,15 @@ type ResponseWriter interface {
 	// send error codes.
 	//
 	// The provided code must be a valid HTTP 1xx-5xx status code.
-	// Only one header may be written. Go does not currently
-	// support sending user-defined 1xx informational headers,
-	// with the exception of 100-continue response header that the
-	// Server sends automatically when the Request.Body is read.
+	// Any number of 1xx headers may be written, followed by at most
+	// one 2xx-5xx header. 1xx headers are sent immediately, but 2xx-5xx
+	// headers may be buffered. Use the Flusher interface to send
+	// buffered data. The header map is cleared when 2xx-5xx headers are
+	// sent, but not with 1xx headers.
+	//
+	// The server will automatically send a 100 (Continue) header
+	// on the first read from the request body if the request has
+	// an "Expect: 100-continue" header.
 	WriteHeader(statusCode int)
 }
 
@@ -390,11 +395,11 @@ func (cw *chunkWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
-func (cw *chunkWriter) flush() {
+func (cw *chunkWriter) flush() error {
 	if !cw.wroteHeader {
 		cw.writeHeader(nil)
 	}
-	cw.res.conn.bufw.Flush()
+	return cw.res.conn.bufw.Flush()
 }
 
 func (cw *chunkWriter) close() {
@@ -449,6 +454,9 @@ type response struct {
 	contentLength int64 // explicitly-declared Content-Length; or -1
 	status        int   // status code passed to WriteHeader
 
+	timeHeader    bool      // record wroteHeaderAt, for Server.AccessLog
+	wroteHeaderAt time.Time // when WriteHeader was (logically) called
+
 	// close connection after this reply.  set on request and
 	// updated after response from handler if there's a
 	// "Connection: keep-alive" response header and a
@@ -472,6 +480,10 @@ type response struct {
 
 	handlerDone atomicBool // set true when the handler exits
 
+	// fullDuplex is set by EnableFullDuplex to allow the handler
+	// to keep reading the request body after writing the response.
+	fullDuplex bool
+
 	// Buffers for Date, Content-Length, and status code
 	dateBuf   [len(TimeFormat)]byte
 	clenBuf   [10]byte
@@ -972,6 +984,10 @@ func (c *conn) readRequest(ctx context.Context) (w *response, err error) {
 		defer func() {
 			c.rwc.SetWriteDeadline(time.Now().Add(d))
 		}()
+	} else {
+		// Clear any deadline set by a previous handler
+		// through a ResponseController.
+		c.rwc.SetWriteDeadline(time.Time{})
 	}
 
 	c.r.setReadLimit(c.server.initialReadLimitSize())
@@ -1141,8 +1157,19 @@ func (w *response) WriteHeader(code int) {
 		return
 	}
 	checkWriteHeaderCode(code)
+
+	// Handle informational headers. 101 Switching Protocols ends
+	// the HTTP/1.1 exchange, so it takes the non-informational path.
+	if code >= 100 && code <= 199 && code != StatusSwitchingProtocols {
+		w.writeInformational(code)
+		return
+	}
+
 	w.wroteHeader = true
 	w.status = code
+	if w.timeHeader {
+		w.wroteHeaderAt = time.Now()
+	}
 
 	if w.calledHeader && w.cw.header == nil {
 		w.cw.header = w.handlerHeader.Clone()
@@ -1159,6 +1186,30 @@ func (w *response) WriteHeader(code int) {
 	}
 }
 
+// excludedHeadersNoBody are the headers omitted from responses
+// that cannot have a body.
+var excludedHeadersNoBody = map[string]bool{"Content-Length": true, "Transfer-Encoding": true}
+
+// writeInformational writes a 1xx informational response with the
+// current contents of the header map, leaving the map unchanged as
+// RFC 8297 requires.
+func (w *response) writeInformational(code int) {
+	// HTTP/1.0 clients do not understand 1xx responses (RFC 7231, section 6.2).
+	if !w.req.ProtoAtLeast(1, 1) {
+		return
+	}
+	w.writeContinueMu.Lock()
+	defer w.writeContinueMu.Unlock()
+	if code == StatusContinue {
+		// The automatic 100 Continue is no longer needed.
+		w.canWriteContinue.setFalse()
+	}
+	writeStatusLine(w.conn.bufw, true, code, w.statusBuf[:])
+	w.handlerHeader.WriteSubset(w.conn.bufw, excludedHeadersNoBody)
+	w.conn.bufw.Write(crlf)
+	w.conn.bufw.Flush()
+}
+
 // extraHeader is the set of headers sometimes added by chunkWriter.writeHeader.
 // This type is used to avoid extra allocations from cloning and/or populating
 // the response Header map and all its 1-element slices.
@@ -1338,7 +1389,7 @@ func (cw *chunkWriter) writeHeader(p []byte) {
 	// TODO(bradfitz): where does RFC 2616 say that? See Issue 15527
 	// about HTTP/1.x Handlers concurrently reading and writing, like
 	// HTTP/2 handlers can do. Maybe this code should be relaxed?
-	if w.req.ContentLength != 0 && !w.closeAfterReply {
+	if w.req.ContentLength != 0 && !w.closeAfterReply && !w.fullDuplex {
 		var discard, tooBig bool
 
 		switch bdy := w.req.Body.(type) {
@@ -1666,11 +1717,34 @@ func (w *response) closedRequestBodyEarly() bool {
 }
 
 func (w *response) Flush() {
+	w.FlushError()
+}
+
+// FlushError is like Flush but returns any error encountered
+// while writing to the connection.
+func (w *response) FlushError() error {
 	if !w.wroteHeader {
 		w.WriteHeader(StatusOK)
 	}
-	w.w.Flush()
-	w.cw.flush()
+	err := w.w.Flush()
+	e2 := w.cw.flush()
+	if err == nil {
+		err = e2
+	}
+	return err
+}
+
+func (w *response) SetReadDeadline(deadline time.Time) error {
+	return w.conn.rwc.SetReadDeadline(deadline)
+}
+
+func (w *response) SetWriteDeadline(deadline time.Time) error {
+	return w.conn.rwc.SetWriteDeadline(deadline)
+}
+
+func (w *response) EnableFullDuplex() error {
+	w.fullDuplex = true
+	return nil
 }
 
 func (c *conn) finalFlush() {
@@ -1846,12 +1920,35 @@ func (c *conn) serve(ctx context.Context) {
 	c.bufr = newBufioReader(c.r)
 	c.bufw = newBufioWriterSize(checkConnErrorWriter{c}, 4<<10)
 
+	protos := c.server.protocols()
+	if c.tlsState == nil && protos.UnencryptedHTTP2() {
+		if c.maybeServeUnencryptedHTTP2(ctx) {
+			return
+		}
+	}
+	if !protos.HTTP1() {
+		return
+	}
+
+	// Bytes buffered while looking for an HTTP/2 preface
+	// belong to the first request.
+	sawBytes := c.bufr.Buffered() > 0
+
 	for {
 		w, err := c.readRequest(ctx)
-		if c.r.remain != c.server.initialReadLimitSize() {
+		if err == nil && c.tlsState == nil && protos.UnencryptedHTTP2() && c.server.serveUnencryptedHTTP2 != nil {
+			if settings, ok := h2cUpgrade(w.req); ok {
+				// The HTTP/2 server reports the connection
+				// as active when it starts serving the request.
+				c.serveUpgradedHTTP2(ctx, w.req, settings)
+				return
+			}
+		}
+		if c.r.remain != c.server.initialReadLimitSize() || sawBytes {
 			// If we read any bytes off the wire, we're active.
 			c.setState(c.rwc, StateActive)
 		}
+		sawBytes = false
 		if err != nil {
 			const errorHeaders = "\r\nContent-Type: text/plain; charset=utf-8\r\nConnection: close\r\n\r\n"
 
@@ -1954,6 +2051,70 @@ func (c *conn) serve(ctx context.Context) {
 	}
 }
 
+// http2Preface is the start of the HTTP/2 client connection preface.
+const http2Preface = "PRI * HTTP/2.0"
+
+// maybeServeUnencryptedHTTP2 serves c as HTTP/2 if the client sent
+// the HTTP/2 connection preface without first negotiating it
+// ("prior knowledge" h2c). It reports whether it did so.
+func (c *conn) maybeServeUnencryptedHTTP2(ctx context.Context) bool {
+	if c.server.serveUnencryptedHTTP2 == nil {
+		return false
+	}
+	if d := c.server.readHeaderTimeout(); d != 0 {
+		c.rwc.SetReadDeadline(time.Now().Add(d))
+	}
+	c.r.setInfiniteReadLimit()
+	hdr, err := c.bufr.Peek(len(http2Preface))
+	c.rwc.SetReadDeadline(time.Time{})
+	if err != nil || string(hdr) != http2Preface {
+		return false
+	}
+	c.server.serveUnencryptedHTTP2(ctx, c.rwc, c.bufr, nil, nil)
+	return true
+}
+
+// h2cUpgrade reports whether req asks to upgrade the connection to
+// unencrypted HTTP/2, per RFC 7540 section 3.2, and returns the
+// decoded contents of its HTTP2-Settings header.
+//
+// Requests with a body are served as HTTP/1.1, ignoring the
+// Upgrade header.
+func h2cUpgrade(req *Request) (settings []byte, ok bool) {
+	if !req.ProtoAtLeast(1, 1) || req.ContentLength != 0 {
+		return nil, false
+	}
+	if !httpguts.HeaderValuesContainsToken(req.Header["Upgrade"], "h2c") {
+		return nil, false
+	}
+	conn := req.Header["Connection"]
+	if !httpguts.HeaderValuesContainsToken(conn, "Upgrade") ||
+		!httpguts.HeaderValuesContainsToken(conn, "HTTP2-Settings") {
+		return nil, false
+	}
+	vals := req.Header["Http2-Settings"]
+	if len(vals) != 1 {
+		return nil, false
+	}
+	settings, err := base64.RawURLEncoding.DecodeString(vals[0])
+	if err != nil {
+		return nil, false
+	}
+	return settings, true
+}
+
+// serveUpgradedHTTP2 accepts an h2c upgrade and serves the rest of
+// the connection as HTTP/2, starting with req.
+func (c *conn) serveUpgradedHTTP2(ctx context.Context, req *Request, settings []byte) {
+	c.bufw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n")
+	if err := c.bufw.Flush(); err != nil {
+		return
+	}
+	c.rwc.SetReadDeadline(time.Time{})
+	c.rwc.SetWriteDeadline(time.Time{})
+	c.server.serveUnencryptedHTTP2(ctx, c.rwc, c.bufr, req, settings)
+}
+
 func (w *response) sendExpectationFailed() {
 	// TODO(bradfitz): let ServeHTTP handlers handle
 	// requests with non-standard expectation[s]? Seems
@@ -2188,46 +2349,134 @@ func RedirectHandler(url string, code int) Handler {
 // patterns and calls the handler for the pattern that
 // most closely matches the URL.
 //
-// Patterns name fixed, rooted paths, like "/favicon.ico",
-// or rooted subtrees, like "/images/" (note the trailing slash).
-// Longer patterns take precedence over shorter ones, so that
-// if there are handlers registered for both "/images/"
-// and "/images/thumbnails/", the latter handler will be
-// called for paths beginning "/images/thumbnails/" and the
-// former will receive requests for any other paths in the
-// "/images/" subtree.
-//
-// Note that since a pattern ending in a slash names a rooted subtree,
-// the pattern "/" matches all paths not matched by other registered
-// patterns, not just the URL with Path == "/".
-//
-// If a subtree has been registered and a request is received naming the
-// subtree root without its trailing slash, ServeMux redirects that
-// request to the subtree root (adding the trailing slash). This behavior can
-// be overridden with a separate registration for the path without
-// the trailing slash. For example, registering "/images/" causes ServeMux
+// Patterns
+//
+// Patterns can match the method, host and path of a request.
+// Some examples:
+//
+//   - "/index.html" matches the path "/index.html" for any host and method.
+//   - "GET /static/" matches a GET request whose path begins with "/static/".
+//   - "example.com/" matches any request to the host "example.com".
+//   - "example.com/{$}" matches requests with host "example.com" and path "/".
+//   - "/b/{bucket}/o/{objectname...}" matches paths whose first segment is "b"
+//     and whose third segment is "o". The name "bucket" denotes the second
+//     segment and "objectname" denotes the remainder of the path.
+//
+// In general, a pattern looks like
+//
+//	[METHOD ][HOST]/[PATH]
+//
+// All three parts are optional; "/" is a valid pattern.
+// If METHOD is present, it must be followed by at least one space or tab.
+//
+// Literal (that is, non-wildcard) parts of a pattern match
+// the corresponding parts of a request case-sensitively.
+//
+// A pattern with no method matches every method. A pattern
+// with the method GET matches both GET and HEAD requests.
+// Otherwise, the method must match exactly.
+//
+// A pattern with no host matches every host.
+// A pattern with a host matches URLs on that host only.
+//
+// A path can include wildcard segments of the form {NAME} or {NAME...}.
+// For example, "/b/{bucket}/o/{objectname...}".
+// The wildcard name must be a valid Go identifier.
+// Wildcards must be full path segments: they must be preceded by a slash and followed by
+// either a slash or the end of the string.
+// For example, "/b_{bucket}" is not a valid pattern.
+//
+// Normally a wildcard matches only a single path segment,
+// ending at the next literal slash (not %2F) in the request URL.
+// But if the "..." is present, then the wildcard matches the remainder of the URL path, including slashes.
+// (Therefore it is invalid for a "..." wildcard to appear anywhere but at the end of a pattern.)
+// The match for a wildcard can be obtained by calling Request.PathValue with the wildcard's name.
+// A trailing slash in a path acts as an anonymous "..." wildcard.
+//
+// The special wildcard {$} matches only the end of the URL.
+// For example, the pattern "/{$}" matches only the path "/",
+// whereas the pattern "/" matches every path.
+//
+// For matching, both pattern paths and incoming request paths are unescaped segment by segment.
+// So, for example, the path "/a%2Fb/100%25" is treated as having two segments, "a/b" and "100%".
+// The pattern "/a%2fb/" matches it, but the pattern "/a/b/" does not.
+//
+// Precedence
+//
+// If two or more patterns match a request, then the most specific pattern takes precedence.
+// A pattern P1 is more specific than P2 if P1 matches a strict subset of P2's requests;
+// that is, if P2 matches all the requests of P1 and more.
+// If neither is more specific, then the patterns conflict.
+// There is one exception to this rule:
+// if two patterns would otherwise conflict and one has a host while the other does not,
+// then the pattern with the host takes precedence.
+// If a pattern passed to ServeMux.Handle or ServeMux.HandleFunc conflicts with
+// another pattern that is already registered, those functions panic.
+//
+// As an example of the general rule, "/images/thumbnails/" is more specific than "/images/",
+// so both can be registered.
+// The former matches paths beginning with "/images/thumbnails/"
+// and the latter will match any other path in the "/images/" subtree.
+//
+// As another example, consider the patterns "GET /" and "/index.html":
+// both match a GET request for "/index.html", but the former pattern
+// matches all other GET and HEAD requests, while the latter matches any
+// request for "/index.html" that uses a different method.
+// The patterns conflict.
+//
+// Trailing-slash redirection
+//
+// Consider a ServeMux with a handler for a subtree, registered using a trailing slash or "..." wildcard.
+// If the ServeMux receives a request for the subtree root without a trailing slash,
+// it redirects the request by adding the trailing slash.
+// This behavior can be overridden with a separate registration for the path without
+// the trailing slash or "..." wildcard. For example, registering "/images/" causes ServeMux
 // to redirect a request for "/images" to "/images/", unless "/images" has
 // been registered separately.
 //
-// Patterns may optionally begin with a host name, restricting matches to
-// URLs on that host only. Host-specific patterns take precedence over
-// general patterns, so that a handler might register for the two patterns
-// "/codesearch" and "codesearch.google.com/" without also taking over
-// requests for "http://www.google.com/".
+// Request sanitizing
 //
 // ServeMux also takes care of sanitizing the URL request path and the Host
 // header, stripping the port number and redirecting any request containing . or
-// .. elements or repeated slashes to an equivalent, cleaner URL.
+// .. segments or repeated slashes to an equivalent, cleaner URL.
+//
+// Method not allowed
+//
+// If a request's path matches a registered pattern, but no pattern with
+// that path matches the request's method, ServeMux replies with
+// 405 Method Not Allowed and an Allow header listing the methods
+// that the path accepts.
+//
+// Compatibility
+//
+// The pattern syntax and matching behavior of ServeMux changed after
+// Go 1.14. To restore the Go 1.14 behavior, set the GODEBUG environment
+// variable to "httpmuxgo114=1". This setting is read once, at program
+// startup; changes during execution are ignored.
+//
+// The backwards-incompatible changes include:
+//   - In Go 1.14, wildcards are ordinary literal path segments.
+//     For example, the pattern "/{x}" matches only that path in Go 1.14,
+//     but now matches any one-segment path.
+//   - In Go 1.14, a leading method is part of the path or host. Now a
+//     pattern whose first space or tab is not preceded by a valid method
+//     makes Handle and HandleFunc panic. For example, "/a b" was a valid
+//     literal path and is now invalid.
+//   - In Go 1.14, no pattern was rejected unless it was empty or registered
+//     twice. Now syntactically invalid patterns, such as "/{" and "/a{x}",
+//     and patterns that conflict with a registered pattern, such as "GET /"
+//     and "/index.html", make Handle and HandleFunc panic.
+//   - In Go 1.14, the request path is unescaped as a whole before matching.
+//     Now each segment is unescaped separately, so a request for "/a%2Fb"
+//     no longer matches the pattern "/a/b".
+//
+// Request.PathValue and Request.SetPathValue have no wildcards to
+// refer to when the Go 1.14 behavior is in effect.
 type ServeMux struct {
-	mu    sync.RWMutex
-	m     map[string]muxEntry
-	es    []muxEntry // slice of entries sorted from longest to shortest.
-	hosts bool       // whether any patterns contain hostnames
-}
-
-type muxEntry struct {
-	h       Handler
-	pattern string
+	mu       sync.RWMutex
+	tree     routingNode
+	patterns []*pattern  // all registered patterns, for conflict detection
+	mux114   serveMux114 // used only when GODEBUG=httpmuxgo114=1
 }
 
 // NewServeMux allocates and returns a new ServeMux.
@@ -2273,66 +2522,6 @@ func stripHostPort(h string) string {
 	return host
 }
 
-// Find a handler on a handler map given a path string.
-// Most-specific (longest) pattern wins.
-func (mux *ServeMux) match(path string) (h Handler, pattern string) {
-	// Check for exact match first.
-	v, ok := mux.m[path]
-	if ok {
-		return v.h, v.pattern
-	}
-
-	// Check for longest valid match.  mux.es contains all patterns
-	// that end in / sorted from longest to shortest.
-	for _, e := range mux.es {
-		if strings.HasPrefix(path, e.pattern) {
-			return e.h, e.pattern
-		}
-	}
-	return nil, ""
-}
-
-// redirectToPathSlash determines if the given path needs appending "/" to it.
-// This occurs when a handler for path + "/" was already registered, but
-// not for path itself. If the path needs appending to, it creates a new
-// URL, setting the path to u.Path + "/" and returning true to indicate so.
-func (mux *ServeMux) redirectToPathSlash(host, path string, u *url.URL) (*url.URL, bool) {
-	mux.mu.RLock()
-	shouldRedirect := mux.shouldRedirectRLocked(host, path)
-	mux.mu.RUnlock()
-	if !shouldRedirect {
-		return u, false
-	}
-	path = path + "/"
-	u = &url.URL{Path: path, RawQuery: u.RawQuery}
-	return u, true
-}
-
-// shouldRedirectRLocked reports whether the given path and host should be redirected to
-// path+"/". This should happen if a handler is registered for path+"/" but
-// not path -- see comments at ServeMux.
-func (mux *ServeMux) shouldRedirectRLocked(host, path string) bool {
-	p := []string{path, host + path}
-
-	for _, c := range p {
-		if _, exist := mux.m[c]; exist {
-			return false
-		}
-	}
-
-	n := len(path)
-	if n == 0 {
-		return false
-	}
-	for _, c := range p {
-		if _, exist := mux.m[c+"/"]; exist {
-			return path[n-1] != '/'
-		}
-	}
-
-	return false
-}
-
 // Handler returns the handler to use for the given request,
 // consulting r.Method, r.Host, and r.URL.Path. It always returns
 // a non-nil handler. If the path is not in its canonical form, the
@@ -2348,58 +2537,135 @@ func (mux *ServeMux) shouldRedirectRLocked(host, path string) bool {
 //
 // If there is no registered handler that applies to the request,
 // Handler returns a ``page not found'' handler and an empty pattern.
+// If a registered pattern matches the request's path but not its
+// method, Handler returns a ``method not allowed'' handler and an
+// empty pattern.
 func (mux *ServeMux) Handler(r *Request) (h Handler, pattern string) {
+	if use114 {
+		return mux.mux114.findHandler(r)
+	}
+	h, pattern, _, _ = mux.findHandler(r)
+	return
+}
 
-	// CONNECT requests are not canonicalized.
+// findHandler finds a handler for a request.
+// If there is a matching handler, it returns it and the pattern that matched.
+// Otherwise it returns a Redirect or NotFound handler with the path that would match
+// after the redirect.
+func (mux *ServeMux) findHandler(r *Request) (h Handler, patStr string, _ *pattern, matches []string) {
+	var n *routingNode
+	var host, path string
 	if r.Method == "CONNECT" {
+		// CONNECT requests are not canonicalized.
+		path = r.URL.EscapedPath()
 		// If r.URL.Path is /tree and its handler is not registered,
 		// the /tree -> /tree/ redirect applies to CONNECT requests
 		// but the path canonicalization does not.
-		if u, ok := mux.redirectToPathSlash(r.URL.Host, r.URL.Path, r.URL); ok {
-			return RedirectHandler(u.String(), StatusMovedPermanently), u.Path
+		if _, _, u := mux.matchOrRedirect(r.URL.Host, r.Method, path, r.URL.Path, r.URL); u != nil {
+			return RedirectHandler(u.String(), StatusMovedPermanently), u.Path, nil, nil
+		}
+		// Redo the match, this time with r.Host instead of r.URL.Host.
+		// Pass a nil URL to skip the trailing-slash redirect logic.
+		host = r.Host
+		n, matches, _ = mux.matchOrRedirect(host, r.Method, path, "", nil)
+	} else {
+		// All other requests have any port stripped and path cleaned
+		// before matching.
+		host = stripHostPort(r.Host)
+		cleaned := cleanPath(r.URL.Path)
+		if cleaned == r.URL.Path {
+			path = r.URL.EscapedPath()
+		} else {
+			path = (&url.URL{Path: cleaned}).EscapedPath()
 		}
 
-		return mux.handler(r.Host, r.URL.Path)
-	}
-
-	// All other requests have any port stripped and path cleaned
-	// before passing to mux.handler.
-	host := stripHostPort(r.Host)
-	path := cleanPath(r.URL.Path)
+		// If the given path is /tree and its handler is not registered,
+		// redirect for /tree/.
+		var u *url.URL
+		n, matches, u = mux.matchOrRedirect(host, r.Method, path, cleaned, r.URL)
+		if u != nil {
+			return RedirectHandler(u.String(), StatusMovedPermanently), u.Path, nil, nil
+		}
 
-	// If the given path is /tree and its handler is not registered,
-	// redirect for /tree/.
-	if u, ok := mux.redirectToPathSlash(host, path, r.URL); ok {
-		return RedirectHandler(u.String(), StatusMovedPermanently), u.Path
+		if cleaned != r.URL.Path {
+			// Redirect to the cleaned path.
+			if n != nil {
+				patStr = n.pattern.String()
+			}
+			url := *r.URL
+			url.Path = cleaned
+			url.RawPath = ""
+			return RedirectHandler(url.String(), StatusMovedPermanently), patStr, nil, nil
+		}
 	}
-
-	if path != r.URL.Path {
-		_, pattern = mux.handler(host, path)
-		url := *r.URL
-		url.Path = path
-		return RedirectHandler(url.String(), StatusMovedPermanently), pattern
+	if n == nil {
+		// We didn't find a match with the request method. To distinguish between
+		// Not Found and Method Not Allowed, see if there is another pattern that
+		// matches except for the method.
+		mux.mu.RLock()
+		allowedMethods := mux.tree.matchingMethods(host, path)
+		mux.mu.RUnlock()
+		if len(allowedMethods) > 0 {
+			return HandlerFunc(func(w ResponseWriter, r *Request) {
+				w.Header().Set("Allow", strings.Join(allowedMethods, ", "))
+				Error(w, StatusText(StatusMethodNotAllowed), StatusMethodNotAllowed)
+			}), "", nil, nil
+		}
+		return NotFoundHandler(), "", nil, nil
 	}
-
-	return mux.handler(host, r.URL.Path)
+	return n.handler, n.pattern.String(), n.pattern, matches
 }
 
-// handler is the main implementation of Handler.
-// The path is known to be in canonical form, except for CONNECT methods.
-func (mux *ServeMux) handler(host, path string) (h Handler, pattern string) {
+// matchOrRedirect looks up a node in the tree that matches the host, method
+// and path, which must be escaped.
+//
+// If u is non-nil, it also checks whether the path, unescaped as upath,
+// needs a trailing slash. If so, it returns the URL to redirect to
+// in redirectTo.
+func (mux *ServeMux) matchOrRedirect(host, method, path, upath string, u *url.URL) (_ *routingNode, matches []string, redirectTo *url.URL) {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
 
-	// Host-specific pattern takes precedence over generic ones
-	if mux.hosts {
-		h, pattern = mux.match(host + path)
+	n, matches := mux.tree.match(host, method, path)
+	// If we have an exact match, or we were asked not to try trailing-slash redirection,
+	// or the URL already has a trailing slash, then we're done.
+	if !exactMatch(n, path) && u != nil && !strings.HasSuffix(path, "/") {
+		// If there is an exact match with a trailing slash, then redirect.
+		path += "/"
+		n2, _ := mux.tree.match(host, method, path)
+		if exactMatch(n2, path) {
+			return nil, nil, &url.URL{Path: upath + "/", RawQuery: u.RawQuery}
+		}
 	}
-	if h == nil {
-		h, pattern = mux.match(path)
+	return n, matches, nil
+}
+
+// exactMatch reports whether the node's pattern exactly matches the path.
+// The pattern matches exactly if it has no "..." wildcard, or if the
+// "..." wildcard matches the empty string.
+func exactMatch(n *routingNode, path string) bool {
+	if n == nil {
+		return false
 	}
-	if h == nil {
-		h, pattern = NotFoundHandler(), ""
+	// We can't directly implement the definition (empty match for multi
+	// wildcard) because we don't record a match for anonymous multis.
+
+	// If there is no multi, the match is exact.
+	if !n.pattern.lastSegment().multi {
+		return true
 	}
-	return
+
+	// If the path doesn't end in a trailing slash, then the multi match
+	// is non-empty.
+	if len(path) > 0 && path[len(path)-1] != '/' {
+		return false
+	}
+	// Only patterns ending in {$} or a multi wildcard can
+	// match a path with a trailing slash.
+	// For the match to be exact, the number of pattern
+	// segments should be the same as the number of slashes in the path.
+	// E.g. "/a/b/{$}" and "/a/b/{...}" exactly match "/a/b/", but "/a/" does not.
+	return len(n.pattern.segments) == strings.Count(path, "/")
 }
 
 // ServeHTTP dispatches the request to the handler whose
@@ -2412,73 +2678,98 @@ func (mux *ServeMux) ServeHTTP(w ResponseWriter, r *Request) {
 		w.WriteHeader(StatusBadRequest)
 		return
 	}
-	h, _ := mux.Handler(r)
+	var h Handler
+	if use114 {
+		h, _ = mux.mux114.findHandler(r)
+	} else {
+		h, _, r.pat, r.matches = mux.findHandler(r)
+	}
 	h.ServeHTTP(w, r)
 }
 
 // Handle registers the handler for the given pattern.
-// If a handler already exists for pattern, Handle panics.
+// If the given pattern conflicts with one that is already registered,
+// Handle panics.
 func (mux *ServeMux) Handle(pattern string, handler Handler) {
-	mux.mu.Lock()
-	defer mux.mu.Unlock()
-
-	if pattern == "" {
-		panic("http: invalid pattern")
-	}
-	if handler == nil {
-		panic("http: nil handler")
-	}
-	if _, exist := mux.m[pattern]; exist {
-		panic("http: multiple registrations for " + pattern)
-	}
-
-	if mux.m == nil {
-		mux.m = make(map[string]muxEntry)
-	}
-	e := muxEntry{h: handler, pattern: pattern}
-	mux.m[pattern] = e
-	if pattern[len(pattern)-1] == '/' {
-		mux.es = appendSorted(mux.es, e)
-	}
-
-	if pattern[0] != '/' {
-		mux.hosts = true
-	}
-}
-
-func appendSorted(es []muxEntry, e muxEntry) []muxEntry {
-	n := len(es)
-	i := sort.Search(n, func(i int) bool {
-		return len(es[i].pattern) < len(e.pattern)
-	})
-	if i == n {
-		return append(es, e)
+	if use114 {
+		mux.mux114.handle(pattern, handler)
+	} else {
+		mux.register(pattern, handler)
 	}
-	// we now know that i points at where we want to insert
-	es = append(es, muxEntry{}) // try to grow the slice in place, any entry works.
-	copy(es[i+1:], es[i:])      // Move shorter entries down
-	es[i] = e
-	return es
 }
 
 // HandleFunc registers the handler function for the given pattern.
+// If the given pattern conflicts with one that is already registered,
+// HandleFunc panics.
 func (mux *ServeMux) HandleFunc(pattern string, handler func(ResponseWriter, *Request)) {
+	if use114 {
+		mux.mux114.handleFunc(pattern, handler)
+		return
+	}
 	if handler == nil {
 		panic("http: nil handler")
 	}
-	mux.Handle(pattern, HandlerFunc(handler))
+	mux.register(pattern, HandlerFunc(handler))
 }
 
 // Handle registers the handler for the given pattern
 // in the DefaultServeMux.
 // The documentation for ServeMux explains how patterns are matched.
-func Handle(pattern string, handler Handler) { DefaultServeMux.Handle(pattern, handler) }
+func Handle(pattern string, handler Handler) {
+	if use114 {
+		DefaultServeMux.mux114.handle(pattern, handler)
+		return
+	}
+	DefaultServeMux.register(pattern, handler)
+}
 
 // HandleFunc registers the handler function for the given pattern
 // in the DefaultServeMux.
 // The documentation for ServeMux explains how patterns are matched.
 func HandleFunc(pattern string, handler func(ResponseWriter, *Request)) {
-	DefaultServeMux.HandleFunc(pattern, handler)
+	if use114 {
+		DefaultServeMux.mux114.handleFunc(pattern, handler)
+		return
+	}
+	if handler == nil {
+		panic("http: nil handler")
+	}
+	DefaultServeMux.register(pattern, HandlerFunc(handler))
+}
+
+// register is the implementation of the Handle and HandleFunc
+// functions and methods. It must be called directly by them so
+// that conflict messages can report the location of their caller.
+func (mux *ServeMux) register(patstr string, handler Handler) {
+	if patstr == "" {
+		panic("http: invalid pattern")
+	}
+	if handler == nil {
+		panic("http: nil handler")
+	}
+	pat, err := parsePattern(patstr)
+	if err != nil {
+		panic(fmt.Sprintf("http: parsing pattern %q: %v", patstr, err))
+	}
+
+	// Get the caller's location, for better conflict error messages.
+	// Skip register and the function that called it.
+	if _, file, line, ok := runtime.Caller(2); ok {
+		pat.loc = fmt.Sprintf("%s:%d", file, line)
+	} else {
+		pat.loc = "unknown location"
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	for _, pat2 := range mux.patterns {
+		if pat.conflictsWith(pat2) {
+			panic(fmt.Sprintf("http: pattern %q (registered at %s) conflicts with pattern %q (registered at %s):\n%s",
+				pat, pat.loc, pat2, pat2.loc, describeConflict(pat, pat2)))
+		}
+	}
+	mux.tree.addPattern(pat, handler)
+	mux.patterns = append(mux.patterns, pat)
 }
 
 // Serve accepts incoming HTTP connections on the listener l,
@@ -2581,11 +2872,57 @@ type Server struct {
 	// automatically.
 	TLSNextProto map[string]func(*Server, *tls.Conn, Handler)
 
+	// Protocols is the set of protocols accepted by the server.
+	//
+	// If Protocols includes UnencryptedHTTP2, the server will accept
+	// unencrypted HTTP/2 connections, both from clients that send
+	// the HTTP/2 connection preface directly ("prior knowledge")
+	// and from HTTP/1.1 requests carrying an "Upgrade: h2c" header.
+	//
+	// If Protocols is nil, the default is usually HTTP/1 and HTTP/2.
+	// If TLSNextProto is non-nil and does not contain an "h2" entry,
+	// the default is HTTP/1 only.
+	Protocols *Protocols
+
 	// ConnState specifies an optional callback function that is
 	// called when a client connection changes state. See the
 	// ConnState type and associated constants for details.
 	ConnState func(net.Conn, ConnState)
 
+	// MaxConcurrentHandlers limits the number of handlers the server
+	// runs at once, counting requests on HTTP/1 connections and
+	// HTTP/2 streams alike. A request arriving while the limit is
+	// reached waits for a running handler to return, in arrival
+	// order, if fewer than MaxQueuedRequests requests are already
+	// waiting. Otherwise, or if the request is canceled while it
+	// waits, it is rejected with OverloadHandler.
+	// If zero, there is no limit.
+	MaxConcurrentHandlers int
+
+	// MaxQueuedRequests is the maximum number of requests waiting
+	// for a handler while MaxConcurrentHandlers handlers are running.
+	// If zero, requests are rejected as soon as the limit is reached.
+	MaxQueuedRequests int
+
+	// OverloadHandler replies to requests rejected because of
+	// MaxConcurrentHandlers. If nil, the server replies with
+	// 503 Service Unavailable and a "Retry-After: 1" header.
+	OverloadHandler Handler
+
+	// HandlerState specifies an optional callback function that is
+	// called when a request changes state with respect to the
+	// server's handlers. It can be used to collect metrics.
+	// See the HandlerState type and associated constants for details.
+	HandlerState func(*Request, HandlerState)
+
+	// AccessLog optionally specifies a function that is called
+	// after each request's handler returns, with a record of the
+	// request and its response. It's called for HTTP/1 and HTTP/2
+	// requests alike, including requests rejected because of
+	// MaxConcurrentHandlers, and from the goroutine that ran the
+	// handler. See AccessRecord for details.
+	AccessLog func(*AccessRecord)
+
 	// ErrorLog specifies an optional logger for errors accepting
 	// connections, unexpected behavior from handlers, and
 	// underlying FileSystem errors.
@@ -2611,6 +2948,18 @@ type Server struct {
 	nextProtoOnce     sync.Once // guards setupHTTP2_* init
 	nextProtoErr      error     // result of http2.ConfigureServer if used
 
+	handlersRunning int32         // accessed atomically
+	handlersQueued  int32         // accessed atomically
+	handlerSemOnce  sync.Once     // guards handlerSem init
+	handlerSem      chan struct{} // non-nil if MaxConcurrentHandlers > 0
+
+	// serveUnencryptedHTTP2, if non-nil, serves c as an h2c
+	// connection. r holds any data already read from c. For an
+	// upgraded connection, upgrade is the initial request and
+	// settings is its decoded HTTP2-Settings header.
+	// It is set by http2ConfigureServer.
+	serveUnencryptedHTTP2 func(ctx context.Context, c net.Conn, r io.Reader, upgrade *Request, settings []byte)
+
 	mu         sync.Mutex
 	listeners  map[*net.Listener]struct{}
 	activeConn map[*conn]struct{}
@@ -2833,6 +3182,14 @@ func (sh serverHandler) ServeHTTP(rw ResponseWriter, req *Request) {
 	if req.RequestURI == "*" && req.Method == "OPTIONS" {
 		handler = globalOptionsHandler{}
 	}
+	if sh.srv.AccessLog != nil {
+		defer sh.srv.logAccess(rw, req)()
+	}
+	if !sh.srv.startHandler(req) {
+		sh.srv.overloadHandler().ServeHTTP(rw, req)
+		return
+	}
+	defer sh.srv.finishHandler(req)
 	handler.ServeHTTP(rw, req)
 }
 
@@ -2864,6 +3221,9 @@ var testHookServerServe func(*Server, net.Listener) // used if non-nil
 // shouldDoServeHTTP2 reports whether Server.Serve should configure
 // automatic HTTP/2. (which sets up the srv.TLSNextProto map)
 func (srv *Server) shouldConfigureHTTP2ForServe() bool {
+	if srv.Protocols != nil && srv.Protocols.UnencryptedHTTP2() {
+		return true
+	}
 	if srv.TLSConfig == nil {
 		// Compatibility with Go 1.6:
 		// If there's no TLSConfig, it's possible that the user just
@@ -2984,7 +3344,11 @@ func (srv *Server) ServeTLS(l net.Listener, certFile, keyFile string) error {
 	}
 
 	config := cloneTLSConfig(srv.TLSConfig)
-	if !strSliceContains(config.NextProtos, "http/1.1") {
+	protos := srv.protocols()
+	if !protos.HTTP2() {
+		config.NextProtos = strSliceRemove(config.NextProtos, http2NextProtoTLS)
+	}
+	if protos.HTTP1() && !strSliceContains(config.NextProtos, "http/1.1") {
 		config.NextProtos = append(config.NextProtos, "http/1.1")
 	}
 
@@ -3180,6 +3544,21 @@ func (srv *Server) setupHTTP2_Serve() error {
 	return srv.nextProtoErr
 }
 
+// protocols returns the set of protocols accepted by srv.
+func (srv *Server) protocols() Protocols {
+	if srv.Protocols != nil {
+		return *srv.Protocols
+	}
+	var p Protocols
+	p.SetHTTP1(true)
+	// A user-provided TLSNextProto map without an "h2" entry
+	// disables HTTP/2.
+	if srv.TLSNextProto == nil || srv.TLSNextProto[http2NextProtoTLS] != nil {
+		p.SetHTTP2(true)
+	}
+	return p
+}
+
 func (srv *Server) onceSetNextProtoDefaults_Serve() {
 	if srv.shouldConfigureHTTP2ForServe() {
 		srv.onceSetNextProtoDefaults()
@@ -3193,9 +3572,14 @@ func (srv *Server) onceSetNextProtoDefaults() {
 	if omitBundledHTTP2 || strings.Contains(os.Getenv("GODEBUG"), "http2server=0") {
 		return
 	}
+	p := srv.protocols()
+	if !p.HTTP2() && !p.UnencryptedHTTP2() {
+		return
+	}
 	// Enable HTTP/2 by default if the user hasn't otherwise
-	// configured their TLSNextProto map.
-	if srv.TLSNextProto == nil {
+	// configured their TLSNextProto map, or if they asked for
+	// it explicitly in Protocols.
+	if srv.TLSNextProto == nil || srv.Protocols != nil && srv.TLSNextProto[http2NextProtoTLS] == nil {
 		conf := &http2Server{
 			NewWriteScheduler: func() http2WriteScheduler { return http2NewPriorityWriteScheduler(nil) },
 		}
@@ -3489,6 +3873,17 @@ func strSliceContains(ss []string, s string) bool {
 	return false
 }
 
+// strSliceRemove returns a copy of ss without any occurrences of s.
+func strSliceRemove(ss []string, s string) []string {
+	var out []string
+	for _, v := range ss {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 // tlsRecordHeaderLooksLikeHTTP reports whether a TLS record header
 // looks like it might've been a misdirected plaintext HTTP request.
 func tlsRecordHeaderLooksLikeHTTP(hdr [5]byte) bool {
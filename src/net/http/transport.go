@@ -12,6 +12,7 @@ package http
 import (
 	"bufio"
 	"compress/gzip"
+	"compress/zstd"
 	"container/list"
 	"context"
 	"crypto/tls"
@@ -189,6 +190,13 @@ type Transport struct {
 	// uncompressed.
 	DisableCompression bool
 
+	// AcceptZstd, if true, adds zstd to the Accept-Encoding header
+	// the Transport sets when it requests compression, making it
+	// "Accept-Encoding: gzip, zstd". A zstd-encoded response to such
+	// a request is transparently decoded, as a gzipped one is.
+	// AcceptZstd has no effect if DisableCompression is true.
+	AcceptZstd bool
+
 	// MaxIdleConns controls the maximum number of idle (keep-alive)
 	// connections across all hosts. Zero means no limit.
 	MaxIdleConns int
@@ -271,6 +279,28 @@ type Transport struct {
 	// To use a custom dialer or TLS config and still attempt HTTP/2
 	// upgrades, set this to true.
 	ForceAttemptHTTP2 bool
+
+	// Protocols is the set of protocols supported by the transport.
+	//
+	// If Protocols includes UnencryptedHTTP2 and does not include HTTP1,
+	// the transport uses unencrypted HTTP/2 ("prior knowledge" h2c)
+	// for requests to http:// URLs that are not sent through a proxy.
+	// If Protocols does not include HTTP2, the transport does not
+	// negotiate HTTP/2 on TLS connections.
+	//
+	// Setting Protocols to a set including HTTP2 or UnencryptedHTTP2
+	// enables HTTP/2 as ForceAttemptHTTP2 does.
+	// If Protocols is nil, the default is HTTP/1 and HTTP/2, subject
+	// to the rules for TLSNextProto and ForceAttemptHTTP2 above.
+	Protocols *Protocols
+
+	// HTTP2 configures HTTP/2 connections.
+	// If nil, defaults are used.
+	HTTP2 *HTTP2Config
+
+	// h2cNext, if non-nil, returns a RoundTripper for an unencrypted
+	// HTTP/2 connection. It is set by http2configureTransport.
+	h2cNext func(authority string, c net.Conn) RoundTripper
 }
 
 func (t *Transport) writeBufferSize() int {
@@ -299,6 +329,7 @@ func (t *Transport) Clone() *Transport {
 		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
 		DisableKeepAlives:      t.DisableKeepAlives,
 		DisableCompression:     t.DisableCompression,
+		AcceptZstd:             t.AcceptZstd,
 		MaxIdleConns:           t.MaxIdleConns,
 		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
 		MaxConnsPerHost:        t.MaxConnsPerHost,
@@ -311,6 +342,14 @@ func (t *Transport) Clone() *Transport {
 		WriteBufferSize:        t.WriteBufferSize,
 		ReadBufferSize:         t.ReadBufferSize,
 	}
+	if t.Protocols != nil {
+		t2.Protocols = new(Protocols)
+		*t2.Protocols = *t.Protocols
+	}
+	if t.HTTP2 != nil {
+		t2.HTTP2 = new(HTTP2Config)
+		*t2.HTTP2 = *t.HTTP2
+	}
 	if t.TLSClientConfig != nil {
 		t2.TLSClientConfig = t.TLSClientConfig.Clone()
 	}
@@ -338,6 +377,17 @@ func (t *Transport) hasCustomTLSDialer() bool {
 	return t.DialTLS != nil || t.DialTLSContext != nil
 }
 
+// protocols returns the set of protocols supported by t.
+func (t *Transport) protocols() Protocols {
+	if t.Protocols != nil {
+		return *t.Protocols
+	}
+	var p Protocols
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	return p
+}
+
 // onceSetNextProtoDefaults initializes TLSNextProto.
 // It must be called via t.nextProtoOnce.Do.
 func (t *Transport) onceSetNextProtoDefaults() {
@@ -366,7 +416,11 @@ func (t *Transport) onceSetNextProtoDefaults() {
 		// Transport.
 		return
 	}
-	if !t.ForceAttemptHTTP2 && (t.TLSClientConfig != nil || t.Dial != nil || t.DialContext != nil || t.hasCustomTLSDialer()) {
+	protos := t.protocols()
+	if !protos.HTTP2() && !protos.UnencryptedHTTP2() {
+		return
+	}
+	if !t.ForceAttemptHTTP2 && t.Protocols == nil && (t.TLSClientConfig != nil || t.Dial != nil || t.DialContext != nil || t.hasCustomTLSDialer()) {
 		// Be conservative and don't automatically enable
 		// http2 if they've specified a custom TLS config or
 		// custom dialers. Let them opt-in themselves via
@@ -384,6 +438,17 @@ func (t *Transport) onceSetNextProtoDefaults() {
 		return
 	}
 	t.h2transport = t2
+	if protos.UnencryptedHTTP2() {
+		t2.AllowHTTP = true
+	}
+	if conf := t.HTTP2; conf != nil {
+		t2.ReadIdleTimeout = conf.SendPingTimeout
+		t2.PingTimeout = conf.PingTimeout
+	}
+	if !protos.HTTP2() {
+		delete(t.TLSNextProto, "h2")
+		t.TLSClientConfig.NextProtos = strSliceRemove(t.TLSClientConfig.NextProtos, "h2")
+	}
 
 	// Auto-configure the http2.Transport's MaxHeaderListSize from
 	// the http.Transport's MaxResponseHeaderBytes. They don't
@@ -1635,6 +1700,16 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		}
 	}
 
+	if cm.targetScheme == "http" && cm.proxyURL == nil {
+		if protos := t.protocols(); protos.UnencryptedHTTP2() && !protos.HTTP1() {
+			if t.h2cNext == nil {
+				pconn.conn.Close()
+				return nil, errors.New("net/http: unencrypted HTTP/2 not supported")
+			}
+			return &persistConn{t: t, cacheKey: pconn.cacheKey, alt: t.h2cNext(cm.targetAddr, pconn.conn)}, nil
+		}
+	}
+
 	if s := pconn.tlsState; s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol != "" {
 		if next, ok := t.TLSNextProto[s.NegotiatedProtocol]; ok {
 			return &persistConn{t: t, cacheKey: pconn.cacheKey, alt: next(cm.targetAddr, pconn.conn.(*tls.Conn))}, nil
@@ -2085,6 +2160,12 @@ func (pc *persistConn) readLoop() {
 			resp.Header.Del("Content-Length")
 			resp.ContentLength = -1
 			resp.Uncompressed = true
+		} else if rc.addedGzip && pc.t.AcceptZstd && strings.EqualFold(resp.Header.Get("Content-Encoding"), "zstd") {
+			resp.Body = &zstdReader{body: body}
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			resp.Uncompressed = true
 		}
 
 		select {
@@ -2358,7 +2439,8 @@ type requestAndChan struct {
 
 	// whether the Transport (as opposed to the user client code)
 	// added the Accept-Encoding gzip header. If the Transport
-	// set it, only then do we transparently decode the gzip.
+	// set it, only then do we transparently decode the gzip
+	// (or zstd, if AcceptZstd is set).
 	addedGzip bool
 
 	// Optional blocking chan for Expect: 100-continue (for send).
@@ -2439,7 +2521,8 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 		req.Header.Get("Range") == "" &&
 		req.Method != "HEAD" {
 		// Request gzip only, not deflate. Deflate is ambiguous and
-		// not as universally supported anyway.
+		// not as universally supported anyway. zstd is requested
+		// too if the user opted in with AcceptZstd.
 		// See: https://zlib.net/zlib_faq.html#faq39
 		//
 		// Note that we don't request this for HEAD requests,
@@ -2451,7 +2534,11 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 		// auto-decoding a portion of a gzipped document will just fail
 		// anyway. See https://golang.org/issue/8923
 		requestedGzip = true
-		req.extraHeaders().Set("Accept-Encoding", "gzip")
+		if pc.t.AcceptZstd {
+			req.extraHeaders().Set("Accept-Encoding", "gzip, zstd")
+		} else {
+			req.extraHeaders().Set("Accept-Encoding", "gzip")
+		}
 	}
 
 	var continueCh chan struct{}
@@ -2716,6 +2803,34 @@ func (gz *gzipReader) Close() error {
 	return gz.body.Close()
 }
 
+// zstdReader wraps a response body so it can lazily
+// create a zstd.Reader on the first call to Read.
+type zstdReader struct {
+	body *bodyEOFSignal // underlying HTTP/1 response body framing
+	zr   *zstd.Reader   // lazily-initialized zstd reader
+}
+
+func (zs *zstdReader) Read(p []byte) (n int, err error) {
+	if zs.zr == nil {
+		zs.zr = zstd.NewReader(zs.body)
+	}
+
+	zs.body.mu.Lock()
+	if zs.body.closed {
+		err = errReadOnClosedResBody
+	}
+	zs.body.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return zs.zr.Read(p)
+}
+
+func (zs *zstdReader) Close() error {
+	return zs.body.Close()
+}
+
 type tlsHandshakeTimeoutError struct{}
 
 func (tlsHandshakeTimeoutError) Timeout() bool   { return true }
@@ -13,6 +13,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"compress/zstd"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
@@ -1103,6 +1104,71 @@ func TestTransportGzip(t *testing.T) {
 	}
 }
 
+func TestTransportZstd(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	const testString = "The test string zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz"
+	ts := httptest.NewServer(HandlerFunc(func(rw ResponseWriter, req *Request) {
+		rw.Header().Set("Content-Encoding", "zstd")
+		zw := zstd.NewWriter(rw)
+		zw.Write([]byte(testString))
+		if req.FormValue("body") == "large" {
+			io.CopyN(zw, rand.Reader, 1<<20)
+		}
+		zw.Close()
+	}))
+	defer ts.Close()
+	c := ts.Client()
+
+	// Without AcceptZstd, zstd is neither requested nor decoded.
+	res, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if g := res.Header.Get("Content-Encoding"); g != "zstd" || res.Uncompressed {
+		t.Errorf("without AcceptZstd: Content-Encoding = %q, Uncompressed = %v; want zstd, false", g, res.Uncompressed)
+	}
+
+	c.Transport.(*Transport).AcceptZstd = true
+
+	// Read part of a large response and close it early.
+	res, err = c.Get(ts.URL + "/?body=large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(testString))
+	if _, err := io.ReadFull(res.Body, buf); err != nil {
+		t.Fatalf("partial read of large response: %v", err)
+	}
+	if g, e := string(buf), testString; g != e {
+		t.Errorf("partial read got %q, want %q", g, e)
+	}
+	res.Body.Close()
+	if n, err := res.Body.Read(buf); n != 0 || err == nil {
+		t.Errorf("expected error post-closed large Read; got = %d, %v", n, err)
+	}
+
+	res, err = c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := string(body), testString; g != e {
+		t.Errorf("body = %q; want %q", g, e)
+	}
+	if g := res.Header.Get("Content-Encoding"); g != "" {
+		t.Errorf("Content-Encoding = %q; want empty", g)
+	}
+	if !res.Uncompressed || res.ContentLength != -1 {
+		t.Errorf("Uncompressed = %v, ContentLength = %d; want true, -1", res.Uncompressed, res.ContentLength)
+	}
+}
+
 // If a request has Expect:100-continue header, the request blocks sending body until the first response.
 // Premature consumption of the request body should not be occurred.
 func TestTransportExpect100Continue(t *testing.T) {
@@ -5770,6 +5836,7 @@ func TestTransportClone(t *testing.T) {
 		TLSHandshakeTimeout:    time.Second,
 		DisableKeepAlives:      true,
 		DisableCompression:     true,
+		AcceptZstd:             true,
 		MaxIdleConns:           1,
 		MaxIdleConnsPerHost:    1,
 		MaxConnsPerHost:        1,
@@ -5784,6 +5851,8 @@ func TestTransportClone(t *testing.T) {
 		},
 		ReadBufferSize:  1,
 		WriteBufferSize: 1,
+		Protocols:       &Protocols{},
+		HTTP2:           &HTTP2Config{},
 	}
 	tr2 := tr.Clone()
 	rv := reflect.ValueOf(tr2).Elem()
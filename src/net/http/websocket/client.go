@@ -0,0 +1,268 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// ErrBadHandshake is returned by Dial when the server's response is
+// not a valid WebSocket handshake. Dial then also returns the
+// response, with up to 1 KB of its body.
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+
+// A Dialer opens WebSocket connections.
+// Its zero value is a valid configuration.
+type Dialer struct {
+	// DialContext specifies the dial function for creating TCP
+	// connections. If nil, a net.Dialer is used.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// TLSClientConfig specifies the TLS configuration for wss URLs.
+	// If nil, the default configuration is used.
+	TLSClientConfig *tls.Config
+
+	// Subprotocols lists the subprotocols requested by the client,
+	// in order of preference.
+	Subprotocols []string
+
+	// EnableCompression specifies whether the client offers the
+	// permessage-deflate extension.
+	EnableCompression bool
+
+	// ReadLimit is the initial read limit of the returned Conn.
+	// If zero, DefaultReadLimit is used.
+	ReadLimit int64
+}
+
+// Dial opens a WebSocket connection using a zero Dialer.
+// See Dialer.Dial for details.
+func Dial(ctx context.Context, urlStr string, requestHeader http.Header) (*Conn, *http.Response, error) {
+	var d Dialer
+	return d.Dial(ctx, urlStr, requestHeader)
+}
+
+// handshakeHeaders are the request headers set by Dial.
+var handshakeHeaders = []string{
+	"Upgrade",
+	"Connection",
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Protocol",
+	"Sec-Websocket-Extensions",
+}
+
+// Dial opens a WebSocket connection to the URL urlStr, whose scheme
+// is ws or wss. The headers in requestHeader, such as Origin or
+// Cookie, are sent with the handshake request; they must not include
+// the headers set by the handshake itself.
+//
+// The context bounds the time spent dialing and performing the
+// handshake. Once Dial returns, the context has no effect on the
+// connection.
+//
+// On success, Dial returns the Conn and the server's handshake
+// response. If the server rejects the handshake, Dial returns
+// ErrBadHandshake and the response.
+func (d *Dialer) Dial(ctx context.Context, urlStr string, requestHeader http.Header) (*Conn, *http.Response, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	var defaultPort string
+	switch u.Scheme {
+	case "ws":
+		u.Scheme, defaultPort = "http", "80"
+	case "wss":
+		u.Scheme, defaultPort = "https", "443"
+	default:
+		return nil, nil, errors.New("websocket: unsupported URL scheme " + u.Scheme)
+	}
+	if u.Fragment != "" {
+		return nil, nil, errors.New("websocket: URL must not have a fragment")
+	}
+
+	var keyBytes [16]byte
+	if _, err := io.ReadFull(rand.Reader, keyBytes[:]); err != nil {
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes[:])
+
+	req := &http.Request{
+		Method:     "GET",
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	for k, vv := range requestHeader {
+		k = http.CanonicalHeaderKey(k)
+		if k == "Host" {
+			if len(vv) > 0 {
+				req.Host = vv[0]
+			}
+			continue
+		}
+		for _, hk := range handshakeHeaders {
+			if k == hk {
+				return nil, nil, errors.New("websocket: handshake header not allowed in requestHeader: " + k)
+			}
+		}
+		req.Header[k] = vv
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-Websocket-Key", key)
+	req.Header.Set("Sec-Websocket-Version", "13")
+	if len(d.Subprotocols) > 0 {
+		req.Header.Set("Sec-Websocket-Protocol", strings.Join(d.Subprotocols, ", "))
+	}
+	if d.EnableCompression {
+		// The client never uses context takeover.
+		req.Header.Set("Sec-Websocket-Extensions", deflateExtension+"; client_no_context_takeover")
+	}
+	req = req.WithContext(ctx)
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	dial := d.DialContext
+	if dial == nil {
+		var nd net.Dialer
+		dial = nd.DialContext
+	}
+	netConn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Abort the handshake when ctx is done.
+	if deadline, ok := ctx.Deadline(); ok {
+		netConn.SetDeadline(deadline)
+	}
+	stop := make(chan struct{})
+	aborted := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			netConn.SetDeadline(time.Unix(1, 0))
+			close(aborted)
+		case <-stop:
+		}
+	}()
+	conn, resp, err := d.handshake(netConn, u, req, key)
+	close(stop)
+	if err == nil {
+		select {
+		case <-aborted:
+			err = ctx.Err()
+		default:
+			err = netConn.SetDeadline(time.Time{})
+		}
+	} else if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, resp, err
+	}
+	return conn, resp, nil
+}
+
+func (d *Dialer) handshake(netConn net.Conn, u *url.URL, req *http.Request, key string) (*Conn, *http.Response, error) {
+	if u.Scheme == "https" {
+		cfg := d.TLSClientConfig
+		if cfg == nil {
+			cfg = new(tls.Config)
+		} else {
+			cfg = cfg.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		// The WebSocket handshake requires HTTP/1.1.
+		cfg.NextProtos = []string{"http/1.1"}
+		tlsConn := tls.Client(netConn, cfg)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, nil, err
+		}
+		netConn = tlsConn
+	}
+
+	if err := req.Write(netConn); err != nil {
+		return nil, nil, err
+	}
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	compress, serverNoContext, err := d.checkResponse(resp, key)
+	if err != nil {
+		// Keep the start of the body for the caller's diagnostics.
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return nil, resp, err
+	}
+	resp.Body = http.NoBody
+	subprotocol := resp.Header.Get("Sec-Websocket-Protocol")
+	return newConn(netConn, br, false, subprotocol, compress, serverNoContext, d.ReadLimit), resp, nil
+}
+
+// checkResponse validates the server's handshake response and reports
+// whether permessage-deflate was negotiated, and whether the server
+// compresses without context takeover.
+func (d *Dialer) checkResponse(resp *http.Response, key string) (compress, serverNoContext bool, err error) {
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!httpguts.HeaderValuesContainsToken(resp.Header["Upgrade"], "websocket") ||
+		!httpguts.HeaderValuesContainsToken(resp.Header["Connection"], "upgrade") ||
+		resp.Header.Get("Sec-Websocket-Accept") != acceptKey(key) {
+		return false, false, ErrBadHandshake
+	}
+	if p := resp.Header.Get("Sec-Websocket-Protocol"); p != "" {
+		ok := false
+		for _, sp := range d.Subprotocols {
+			if p == sp {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false, false, ErrBadHandshake
+		}
+	}
+	exts := parseExtensions(resp.Header)
+	if len(exts) == 0 {
+		return false, false, nil
+	}
+	if !d.EnableCompression || len(exts) > 1 {
+		return false, false, ErrBadHandshake
+	}
+	serverNoContext, err = checkDeflateResponse(exts[0])
+	if err != nil {
+		return false, false, ErrBadHandshake
+	}
+	return true, serverNoContext, nil
+}
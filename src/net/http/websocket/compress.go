@@ -0,0 +1,282 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The permessage-deflate extension, RFC 7692.
+
+package websocket
+
+import (
+	"compress/flate"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const deflateExtension = "permessage-deflate"
+
+// deflateTail is the empty stored block that ends each compressed
+// message. Senders remove it and receivers restore it (RFC 7692,
+// section 7.2.1). The final empty block appended after it lets the
+// flate reader see the end of the stream.
+const (
+	deflateTail  = "\x00\x00\xff\xff"
+	deflateFinal = "\x01\x00\x00\xff\xff"
+)
+
+// maxWindow is the size of the LZ77 window used by compress/flate,
+// which corresponds to a max_window_bits value of 15.
+const maxWindow = 1 << 15
+
+var (
+	flateWriterPool sync.Pool // of *flate.Writer
+	flateReaderPool sync.Pool // of io.ReadCloser implementing flate.Resetter
+)
+
+// A compressor compresses a message into a frameWriter. Each message
+// is compressed separately, so the compressor never relies on
+// context takeover.
+type compressor struct {
+	fw *frameWriter
+	tw truncWriter
+	zw *flate.Writer
+}
+
+func newCompressor(fw *frameWriter) io.WriteCloser {
+	c := &compressor{fw: fw}
+	c.tw.w = fw
+	if zw, ok := flateWriterPool.Get().(*flate.Writer); ok {
+		zw.Reset(&c.tw)
+		c.zw = zw
+	} else {
+		c.zw, _ = flate.NewWriter(&c.tw, flate.BestSpeed)
+	}
+	return c
+}
+
+func (c *compressor) Write(p []byte) (int, error) {
+	if c.zw == nil {
+		return 0, errors.New("websocket: write to closed message writer")
+	}
+	return c.zw.Write(p)
+}
+
+func (c *compressor) Close() error {
+	if c.zw == nil {
+		return errors.New("websocket: message writer already closed")
+	}
+	err := c.zw.Flush()
+	flateWriterPool.Put(c.zw)
+	c.zw = nil
+	if err == nil && (c.tw.n != len(c.tw.p) || string(c.tw.p[:]) != deflateTail) {
+		err = errors.New("websocket: unexpected end of deflate stream")
+	}
+	if err1 := c.fw.writeFinal(nil); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// A truncWriter passes on all but the last four bytes written to it.
+type truncWriter struct {
+	w io.Writer
+	n int
+	p [len(deflateTail)]byte
+}
+
+func (w *truncWriter) Write(p []byte) (int, error) {
+	n := 0
+	if w.n < len(w.p) {
+		n = copy(w.p[w.n:], p)
+		p = p[n:]
+		w.n += n
+		if len(p) == 0 {
+			return n, nil
+		}
+	}
+	m := len(p)
+	if m > len(w.p) {
+		m = len(w.p)
+	}
+	if _, err := w.w.Write(w.p[:m]); err != nil {
+		return n, err
+	}
+	copy(w.p[:], w.p[m:])
+	copy(w.p[len(w.p)-m:], p[len(p)-m:])
+	_, err := w.w.Write(p[:len(p)-m])
+	return n + len(p), err
+}
+
+// newDecompressor returns a reader of the decompressed contents of
+// the compressed message r.
+func (c *Conn) newDecompressor(r io.Reader) io.Reader {
+	r = io.MultiReader(r, strings.NewReader(deflateTail+deflateFinal))
+	var dict []byte
+	if !c.readNoDict {
+		dict = c.readDict
+	}
+	var zr io.ReadCloser
+	if v, ok := flateReaderPool.Get().(io.ReadCloser); ok {
+		v.(flate.Resetter).Reset(r, dict)
+		zr = v
+	} else {
+		zr = flate.NewReaderDict(r, dict)
+	}
+	return &decompressor{c: c, zr: zr}
+}
+
+type decompressor struct {
+	c  *Conn
+	zr io.ReadCloser
+}
+
+func (d *decompressor) Read(p []byte) (int, error) {
+	if d.zr == nil {
+		return 0, io.EOF
+	}
+	n, err := d.zr.Read(p)
+	if !d.c.readNoDict {
+		d.c.readDict = appendWindow(d.c.readDict, p[:n])
+	}
+	if err == io.EOF {
+		flateReaderPool.Put(d.zr)
+		d.zr = nil
+	}
+	return n, err
+}
+
+// appendWindow appends p to w, keeping only the last maxWindow bytes.
+func appendWindow(w, p []byte) []byte {
+	if len(p) >= maxWindow {
+		return append(w[:0], p[len(p)-maxWindow:]...)
+	}
+	if over := len(w) + len(p) - maxWindow; over > 0 {
+		w = w[:copy(w, w[over:])]
+	}
+	return append(w, p...)
+}
+
+// An extension is an element of a Sec-WebSocket-Extensions header.
+type extension struct {
+	name   string
+	params map[string]string
+	bad    bool // a parameter is repeated
+}
+
+// parseExtensions parses the Sec-WebSocket-Extensions headers in h.
+func parseExtensions(h http.Header) []extension {
+	var exts []extension
+	for _, v := range h["Sec-Websocket-Extensions"] {
+		for _, elem := range splitQuoted(v, ',') {
+			parts := splitQuoted(elem, ';')
+			ext := extension{
+				name:   strings.ToLower(strings.TrimSpace(parts[0])),
+				params: make(map[string]string),
+			}
+			if ext.name == "" {
+				continue
+			}
+			for _, p := range parts[1:] {
+				k, v := p, ""
+				if i := strings.IndexByte(p, '='); i >= 0 {
+					k, v = p[:i], strings.TrimSpace(p[i+1:])
+					if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+						v = v[1 : len(v)-1]
+					}
+				}
+				k = strings.ToLower(strings.TrimSpace(k))
+				if _, dup := ext.params[k]; dup {
+					ext.bad = true
+				}
+				ext.params[k] = v
+			}
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// splitQuoted splits s at each sep outside a quoted string.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && quoted:
+			i++
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// validWindowBits reports whether v is a valid max_window_bits value.
+func validWindowBits(v string) bool {
+	n, err := strconv.Atoi(v)
+	return err == nil && n >= 8 && n <= 15 && v[0] != '0'
+}
+
+// acceptDeflateOffer reports whether a server can accept the client's
+// permessage-deflate offer ext, and whether the client will compress
+// without context takeover.
+func acceptDeflateOffer(ext extension) (ok, clientNoContext bool) {
+	if ext.name != deflateExtension || ext.bad {
+		return false, false
+	}
+	for k, v := range ext.params {
+		switch k {
+		case "server_no_context_takeover", "client_no_context_takeover":
+			if v != "" {
+				return false, false
+			}
+		case "server_max_window_bits":
+			// compress/flate always uses a 32 KB window.
+			if v != "15" {
+				return false, false
+			}
+		case "client_max_window_bits":
+			if v != "" && !validWindowBits(v) {
+				return false, false
+			}
+		default:
+			return false, false
+		}
+	}
+	_, clientNoContext = ext.params["client_no_context_takeover"]
+	return true, clientNoContext
+}
+
+// checkDeflateResponse validates the server's response to the client's
+// permessage-deflate offer, and reports whether the server will
+// compress without context takeover.
+func checkDeflateResponse(ext extension) (serverNoContext bool, err error) {
+	if ext.name != deflateExtension || ext.bad {
+		return false, errors.New("websocket: unexpected extension " + ext.name)
+	}
+	for k, v := range ext.params {
+		switch k {
+		case "server_no_context_takeover", "client_no_context_takeover":
+			if v != "" {
+				return false, errors.New("websocket: invalid " + k + " parameter")
+			}
+		case "server_max_window_bits":
+			if !validWindowBits(v) {
+				return false, errors.New("websocket: invalid server_max_window_bits parameter")
+			}
+		default:
+			// Including client_max_window_bits, which the client
+			// did not offer.
+			return false, errors.New("websocket: unexpected " + deflateExtension + " parameter " + k)
+		}
+	}
+	_, serverNoContext = ext.params["server_no_context_takeover"]
+	return serverNoContext, nil
+}
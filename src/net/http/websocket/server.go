@@ -0,0 +1,182 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// acceptGUID is the value appended to Sec-WebSocket-Key to compute
+// Sec-WebSocket-Accept (RFC 6455, section 1.3).
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// acceptKey returns the Sec-WebSocket-Accept value for key.
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key))
+	h.Write([]byte(acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// IsWebSocketUpgrade reports whether r asks to upgrade the connection
+// to the WebSocket protocol.
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return httpguts.HeaderValuesContainsToken(r.Header["Connection"], "upgrade") &&
+		httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "websocket")
+}
+
+// An Upgrader upgrades HTTP requests to WebSocket connections.
+// Its zero value is a valid configuration.
+type Upgrader struct {
+	// Subprotocols lists the subprotocols supported by the server,
+	// in order of preference. The first one also requested by the
+	// client is selected. If none is, the connection is accepted
+	// without a subprotocol.
+	Subprotocols []string
+
+	// CheckOrigin reports whether the request's Origin header is
+	// acceptable. If CheckOrigin is nil, a request with an Origin
+	// header is accepted only if the host in the header matches the
+	// request's Host.
+	CheckOrigin func(r *http.Request) bool
+
+	// EnableCompression specifies whether the server accepts the
+	// client's offer of the permessage-deflate extension.
+	EnableCompression bool
+
+	// ReadLimit is the initial read limit of the returned Conn.
+	// If zero, DefaultReadLimit is used.
+	ReadLimit int64
+}
+
+// Upgrade upgrades the HTTP server connection of the request r to the
+// WebSocket protocol. The response headers in responseHeader, if any,
+// are included in the handshake response, except for those set by
+// the handshake itself.
+//
+// If the request is not a valid WebSocket handshake, Upgrade replies
+// with an HTTP error and returns an error. Otherwise the connection is
+// hijacked and the handler must not use w after Upgrade returns.
+// Upgrade does not support HTTP/2 requests.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		return nil, reject(w, http.StatusMethodNotAllowed, "websocket: handshake method is not GET")
+	}
+	if !r.ProtoAtLeast(1, 1) || r.ProtoMajor != 1 || !IsWebSocketUpgrade(r) {
+		return nil, reject(w, http.StatusBadRequest, "websocket: not a WebSocket handshake")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		w.Header().Set("Sec-Websocket-Version", "13")
+		return nil, reject(w, http.StatusUpgradeRequired, "websocket: unsupported protocol version")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, reject(w, http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, reject(w, http.StatusForbidden, "websocket: origin not allowed")
+	}
+
+	h := make(http.Header)
+	for k, vv := range responseHeader {
+		h[k] = vv
+	}
+	for _, k := range []string{"Upgrade", "Connection", "Sec-Websocket-Accept", "Sec-Websocket-Protocol", "Sec-Websocket-Extensions"} {
+		delete(h, k)
+	}
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-Websocket-Accept", acceptKey(key))
+	subprotocol := u.selectSubprotocol(r)
+	if subprotocol != "" {
+		h.Set("Sec-Websocket-Protocol", subprotocol)
+	}
+	var compress, clientNoContext bool
+	if u.EnableCompression {
+		for _, ext := range parseExtensions(r.Header) {
+			if compress, clientNoContext = acceptDeflateOffer(ext); compress {
+				// The server never uses context takeover.
+				resp := deflateExtension + "; server_no_context_takeover"
+				if clientNoContext {
+					resp += "; client_no_context_takeover"
+				}
+				h.Set("Sec-Websocket-Extensions", resp)
+				break
+			}
+		}
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, reject(w, http.StatusInternalServerError, "websocket: connection does not support hijacking")
+	}
+	if brw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, errors.New("websocket: client sent data before handshake completed")
+	}
+	// Clear any deadlines set by the Server.
+	netConn.SetDeadline(time.Time{})
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	h.Write(&buf)
+	buf.WriteString("\r\n")
+	if _, err := netConn.Write(buf.Bytes()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return newConn(netConn, brw.Reader, true, subprotocol, compress, clientNoContext, u.ReadLimit), nil
+}
+
+// reject replies to a failed handshake and returns the error.
+func reject(w http.ResponseWriter, code int, msg string) error {
+	http.Error(w, msg, code)
+	return errors.New(msg)
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	var offered []string
+	for _, v := range r.Header["Sec-Websocket-Protocol"] {
+		for _, p := range strings.Split(v, ",") {
+			offered = append(offered, strings.TrimSpace(p))
+		}
+	}
+	for _, p := range u.Subprotocols {
+		for _, o := range offered {
+			if p == o {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// sameOrigin reports whether the request has no Origin header or
+// an Origin whose host matches the request's Host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header["Origin"]
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin[0])
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
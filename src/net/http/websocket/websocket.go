@@ -0,0 +1,912 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package websocket implements the WebSocket protocol defined in
+// RFC 6455.
+//
+// A server accepts WebSocket connections by calling Upgrader.Upgrade
+// from an http.Handler:
+//
+//	var upgrader websocket.Upgrader
+//
+//	func echo(w http.ResponseWriter, r *http.Request) {
+//		c, err := upgrader.Upgrade(w, r, nil)
+//		if err != nil {
+//			return // Upgrade has already replied to the client.
+//		}
+//		defer c.Close()
+//		for {
+//			typ, msg, err := c.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			if err := c.WriteMessage(typ, msg); err != nil {
+//				return
+//			}
+//		}
+//	}
+//
+// A client opens a connection with Dial or Dialer.Dial.
+//
+// Both sides support fragmented messages, ping and pong frames, the
+// closing handshake, and the permessage-deflate extension defined in
+// RFC 7692.
+//
+// A Conn supports one concurrent reader and one concurrent writer.
+// Close, CloseWithStatus and Ping may be called concurrently with
+// other methods.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// A MessageType is the type of a WebSocket data message.
+type MessageType int
+
+// The message types defined by RFC 6455, section 11.8. Their values
+// are the opcodes of the frames that carry them.
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case TextMessage:
+		return "text"
+	case BinaryMessage:
+		return "binary"
+	}
+	return "MessageType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// A StatusCode is a status code sent in a close frame,
+// as defined in RFC 6455, section 7.4.
+type StatusCode int
+
+// Status codes defined by RFC 6455, section 7.4.1.
+const (
+	StatusNormalClosure      StatusCode = 1000
+	StatusGoingAway          StatusCode = 1001
+	StatusProtocolError      StatusCode = 1002
+	StatusUnsupportedData    StatusCode = 1003
+	StatusNoStatusReceived   StatusCode = 1005 // never sent; reported when a close frame has no status
+	StatusAbnormalClosure    StatusCode = 1006 // never sent; reported when the connection closed without a close frame
+	StatusInvalidPayloadData StatusCode = 1007
+	StatusPolicyViolation    StatusCode = 1008
+	StatusMessageTooBig      StatusCode = 1009
+	StatusMandatoryExtension StatusCode = 1010
+	StatusInternalError      StatusCode = 1011
+)
+
+// validSendCode reports whether code may be sent in a close frame.
+func validSendCode(code StatusCode) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// A CloseError is returned by the read methods of a Conn after the
+// peer closed the connection. If the peer closed it without sending
+// a close frame, Code is StatusAbnormalClosure.
+type CloseError struct {
+	Code   StatusCode
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Reason)
+}
+
+var (
+	// ErrReadLimit is returned when reading a message longer than
+	// the Conn's read limit. The connection is closed with
+	// StatusMessageTooBig.
+	ErrReadLimit = errors.New("websocket: message exceeds read limit")
+
+	// ErrCloseSent is returned when writing to a Conn after a close
+	// frame was sent.
+	ErrCloseSent = errors.New("websocket: close sent")
+)
+
+// DefaultReadLimit is the largest message, in bytes, that a Conn
+// reads when no read limit is configured.
+const DefaultReadLimit = 32 << 20
+
+// Frame opcodes, RFC 6455 section 5.2.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+const (
+	finBit  = 1 << 7
+	rsv1Bit = 1 << 6
+	rsv2Bit = 1 << 5
+	rsv3Bit = 1 << 4
+	maskBit = 1 << 7
+
+	maxControlPayload = 125
+
+	// writeFrameSize is the payload size of the frames written by
+	// a message writer.
+	writeFrameSize = 4096
+
+	// closeTimeout bounds how long the closing handshake waits for
+	// the peer's close frame.
+	closeTimeout = 5 * time.Second
+)
+
+// A protocolError is a violation of RFC 6455 by the peer.
+type protocolError struct {
+	code StatusCode
+	msg  string
+}
+
+func (e *protocolError) Error() string { return "websocket: " + e.msg }
+
+func errProtocol(msg string) error {
+	return &protocolError{StatusProtocolError, msg}
+}
+
+var errInvalidUTF8 = &protocolError{StatusInvalidPayloadData, "invalid UTF-8 in text message"}
+
+// Conn is a WebSocket connection, returned by Upgrader.Upgrade or
+// Dialer.Dial.
+type Conn struct {
+	conn        net.Conn
+	isServer    bool
+	subprotocol string
+	compress    bool // permessage-deflate was negotiated
+
+	// readSem is held while reading frames from br.
+	readSem      chan struct{}
+	br           *bufio.Reader
+	readLimit    int64
+	readErr      error
+	readFrame    frameHeader // current data frame
+	readRemain   int64       // unread payload bytes of readFrame
+	readFramePos int         // masking key offset in readFrame
+	readMsg      *messageReader
+	readDict     []byte // recent decompressed data, for context takeover
+	readNoDict   bool   // peer compresses without context takeover
+	closeRecvd   chan struct{}
+	closeRecvErr *CloseError
+
+	writeMu    sync.Mutex // guards frame writes and the fields below
+	bw         *bufio.Writer
+	writeErr   error
+	closeSent  bool
+	writingMsg bool // a messageWriter is open
+
+	pingMu  sync.Mutex
+	pings   map[string]chan struct{}
+	pingSeq uint64
+
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool, subprotocol string, compress, readNoDict bool, readLimit int64) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	if readLimit <= 0 {
+		readLimit = DefaultReadLimit
+	}
+	return &Conn{
+		conn:        conn,
+		isServer:    isServer,
+		subprotocol: subprotocol,
+		compress:    compress,
+		readNoDict:  readNoDict,
+		readSem:     make(chan struct{}, 1),
+		br:          br,
+		readLimit:   readLimit,
+		closeRecvd:  make(chan struct{}),
+		bw:          bufio.NewWriterSize(conn, writeFrameSize+14),
+		pings:       make(map[string]chan struct{}),
+	}
+}
+
+// Subprotocol returns the subprotocol negotiated during the handshake,
+// or "" if none was.
+func (c *Conn) Subprotocol() string { return c.subprotocol }
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr { return c.conn.LocalAddr() }
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// SetReadDeadline sets the deadline for future and pending reads on
+// the underlying connection. After a read has timed out, the Conn
+// cannot be read again. A zero value means reads will not time out.
+func (c *Conn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+// SetWriteDeadline sets the deadline for future and pending writes on
+// the underlying connection. After a write has timed out, the Conn
+// cannot be written again. A zero value means writes will not time out.
+func (c *Conn) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
+
+// SetReadLimit sets the largest message, in bytes, that the Conn
+// reads. If a message exceeds the limit, the read returns
+// ErrReadLimit and the connection is closed. For compressed messages
+// the limit applies to the decompressed size.
+func (c *Conn) SetReadLimit(n int64) {
+	if n <= 0 {
+		n = DefaultReadLimit
+	}
+	c.readLimit = n
+}
+
+// A frameHeader is the decoded header of a frame.
+type frameHeader struct {
+	fin    bool
+	rsv1   bool
+	op     byte
+	masked bool
+	mask   [4]byte
+	length int64
+}
+
+func isControl(op byte) bool { return op&0x8 != 0 }
+
+// readFrameHeader reads and validates the next frame header.
+// c.readSem must be held.
+func (c *Conn) readFrameHeader() (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return h, err
+	}
+	h.fin = b[0]&finBit != 0
+	h.rsv1 = b[0]&rsv1Bit != 0
+	h.op = b[0] & 0xf
+	h.masked = b[1]&maskBit != 0
+	h.length = int64(b[1] & 0x7f)
+
+	if b[0]&(rsv2Bit|rsv3Bit) != 0 {
+		return h, errProtocol("reserved bits set")
+	}
+	switch h.op {
+	case opContinuation, opText, opBinary, opClose, opPing, opPong:
+	default:
+		return h, errProtocol("unknown opcode " + strconv.Itoa(int(h.op)))
+	}
+	if h.rsv1 && (!c.compress || isControl(h.op) || h.op == opContinuation) {
+		return h, errProtocol("unexpected RSV1 bit")
+	}
+	if h.masked != c.isServer {
+		if c.isServer {
+			return h, errProtocol("unmasked client frame")
+		}
+		return h, errProtocol("masked server frame")
+	}
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return h, err
+		}
+		n := binary.BigEndian.Uint64(b[:8])
+		if n > 1<<63-1 {
+			return h, errProtocol("frame too long")
+		}
+		h.length = int64(n)
+	}
+	if isControl(h.op) && (!h.fin || h.length > maxControlPayload) {
+		return h, errProtocol("invalid control frame")
+	}
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// readPayload reads the payload of h, which must be short.
+func (c *Conn) readPayload(h frameHeader) ([]byte, error) {
+	p := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, p); err != nil {
+		return nil, err
+	}
+	if h.masked {
+		maskBytes(h.mask, 0, p)
+	}
+	return p, nil
+}
+
+// maskBytes applies the masking key to p, which starts at offset pos
+// of the payload, and returns the offset following p.
+func maskBytes(key [4]byte, pos int, p []byte) int {
+	for i := range p {
+		p[i] ^= key[pos&3]
+		pos++
+	}
+	return pos & 3
+}
+
+// nextDataFrame reads frames until it finds a data frame, handling
+// any control frames that precede it. c.readSem must be held.
+func (c *Conn) nextDataFrame() (frameHeader, error) {
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return h, err
+		}
+		if !isControl(h.op) {
+			return h, nil
+		}
+		if err := c.handleControl(h); err != nil {
+			return h, err
+		}
+	}
+}
+
+// handleControl processes the control frame with header h.
+func (c *Conn) handleControl(h frameHeader) error {
+	p, err := c.readPayload(h)
+	if err != nil {
+		return err
+	}
+	switch h.op {
+	case opPing:
+		err := c.writeControl(opPong, p)
+		if err == ErrCloseSent {
+			err = nil
+		}
+		return err
+	case opPong:
+		c.pingMu.Lock()
+		if ch, ok := c.pings[string(p)]; ok {
+			close(ch)
+			delete(c.pings, string(p))
+		}
+		c.pingMu.Unlock()
+		return nil
+	}
+
+	// A close frame.
+	ce := &CloseError{Code: StatusNoStatusReceived}
+	switch {
+	case len(p) == 1:
+		return errProtocol("invalid close frame")
+	case len(p) >= 2:
+		ce.Code = StatusCode(binary.BigEndian.Uint16(p))
+		ce.Reason = string(p[2:])
+		if !validSendCode(ce.Code) {
+			return errProtocol("invalid close status " + strconv.Itoa(int(ce.Code)))
+		}
+		if !utf8.Valid(p[2:]) {
+			return errInvalidUTF8
+		}
+	}
+	// Echo the status back, completing the closing handshake.
+	echo := ce.Code
+	if echo == StatusNoStatusReceived {
+		echo = StatusNormalClosure
+	}
+	c.writeClose(echo, "")
+	c.closeRecvErr = ce
+	close(c.closeRecvd)
+	c.closeConn()
+	return ce
+}
+
+// fail records a read error. Protocol violations are reported to the
+// peer in a close frame before the connection is closed.
+func (c *Conn) fail(err error) error {
+	if c.readErr != nil {
+		return c.readErr
+	}
+	switch e := err.(type) {
+	case *CloseError:
+	case *protocolError:
+		c.writeClose(e.code, "")
+		c.closeConn()
+	default:
+		if err == ErrReadLimit {
+			c.writeClose(StatusMessageTooBig, "")
+		} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = &CloseError{Code: StatusAbnormalClosure}
+		}
+		c.closeConn()
+	}
+	c.readErr = err
+	return err
+}
+
+// NextReader returns the type of the next data message received from
+// the peer and a reader for its contents. Any unread part of the
+// previous message is discarded.
+//
+// Ping frames received while reading are answered automatically.
+// After the peer closes the connection, NextReader returns a
+// *CloseError.
+func (c *Conn) NextReader() (MessageType, io.Reader, error) {
+	c.readSem <- struct{}{}
+	defer func() { <-c.readSem }()
+
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	if mr := c.readMsg; mr != nil {
+		// Discard the rest of the previous message. Compressed
+		// messages are decompressed to keep the window current.
+		mr.done = true
+		c.readMsg = nil
+		if _, err := io.Copy(ioutil.Discard, mr.r); err != nil {
+			return 0, nil, c.fail(err)
+		}
+	}
+
+	h, err := c.nextDataFrame()
+	if err != nil {
+		return 0, nil, c.fail(err)
+	}
+	if h.op == opContinuation {
+		return 0, nil, c.fail(errProtocol("unexpected continuation frame"))
+	}
+	c.readFrame = h
+	c.readRemain = h.length
+	c.readFramePos = 0
+
+	mr := &messageReader{c: c, typ: MessageType(h.op)}
+	var r io.Reader = &frameReader{mr: mr}
+	if h.rsv1 {
+		r = c.newDecompressor(r)
+	}
+	mr.r = r
+	c.readMsg = mr
+	return mr.typ, mr, nil
+}
+
+// nextContinuation advances to the next frame of the current message.
+// c.readSem must be held.
+func (c *Conn) nextContinuation() error {
+	h, err := c.nextDataFrame()
+	if err != nil {
+		return err
+	}
+	if h.op != opContinuation {
+		return errProtocol("expected continuation frame")
+	}
+	c.readFrame.fin = h.fin
+	c.readFrame.masked = h.masked
+	c.readFrame.mask = h.mask
+	c.readRemain = h.length
+	c.readFramePos = 0
+	return nil
+}
+
+// ReadMessage reads the next data message from the peer.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	typ, r, err := c.NextReader()
+	if err != nil {
+		return 0, nil, err
+	}
+	p, err := ioutil.ReadAll(r)
+	return typ, p, err
+}
+
+// A messageReader is the reader returned by NextReader.
+type messageReader struct {
+	c    *Conn
+	typ  MessageType
+	r    io.Reader // frameReader, possibly decompressed
+	n    int64     // bytes returned so far
+	done bool      // superseded by a later NextReader
+	err  error
+
+	utf8Buf [utf8.UTFMax]byte
+	utf8Len int // incomplete rune at the end of the last Read
+}
+
+func (mr *messageReader) Read(p []byte) (int, error) {
+	c := mr.c
+	c.readSem <- struct{}{}
+	defer func() { <-c.readSem }()
+
+	if mr.err != nil {
+		return 0, mr.err
+	}
+	if mr.done {
+		return 0, io.EOF
+	}
+	n, err := mr.r.Read(p)
+	mr.n += int64(n)
+	if mr.n > c.readLimit {
+		err = ErrReadLimit
+	}
+	if mr.typ == TextMessage && (n > 0 || err == io.EOF) && !mr.validUTF8(p[:n], err == io.EOF) {
+		err = errInvalidUTF8
+	}
+	if err == io.EOF {
+		c.readMsg = nil
+		mr.done = true
+	} else if err != nil {
+		err = c.fail(err)
+	}
+	mr.err = err
+	return n, err
+}
+
+// validUTF8 reports whether p continues a valid UTF-8 text. A rune
+// may span Reads; at the end of the message none may be incomplete.
+func (mr *messageReader) validUTF8(p []byte, final bool) bool {
+	if mr.utf8Len > 0 {
+		for len(p) > 0 && !utf8.FullRune(mr.utf8Buf[:mr.utf8Len]) {
+			mr.utf8Buf[mr.utf8Len] = p[0]
+			mr.utf8Len++
+			p = p[1:]
+		}
+		if !utf8.FullRune(mr.utf8Buf[:mr.utf8Len]) {
+			return !final
+		}
+		if r, size := utf8.DecodeRune(mr.utf8Buf[:mr.utf8Len]); r == utf8.RuneError && size == 1 {
+			return false
+		}
+		mr.utf8Len = 0
+	}
+	for i := 0; i < len(p); {
+		if p[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(p[i:])
+		if r == utf8.RuneError && size == 1 {
+			if final || utf8.FullRune(p[i:]) {
+				return false
+			}
+			mr.utf8Len = copy(mr.utf8Buf[:], p[i:])
+			return true
+		}
+		i += size
+	}
+	return true
+}
+
+// A frameReader reads the payloads of the frames of one message.
+// Its caller holds c.readSem.
+type frameReader struct {
+	mr *messageReader
+}
+
+func (fr *frameReader) Read(p []byte) (int, error) {
+	c := fr.mr.c
+	for c.readRemain == 0 {
+		if c.readFrame.fin {
+			return 0, io.EOF
+		}
+		if err := c.nextContinuation(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > c.readRemain {
+		p = p[:c.readRemain]
+	}
+	n, err := c.br.Read(p)
+	c.readRemain -= int64(n)
+	if c.readFrame.masked {
+		c.readFramePos = maskBytes(c.readFrame.mask, c.readFramePos, p[:n])
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// writeFrame writes a single frame. c.writeMu must be held.
+func (c *Conn) writeFrame(fin, rsv1 bool, op byte, payload []byte) error {
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	var hdr [14]byte
+	hdr[0] = op
+	if fin {
+		hdr[0] |= finBit
+	}
+	if rsv1 {
+		hdr[0] |= rsv1Bit
+	}
+	n := 2
+	switch l := len(payload); {
+	case l <= 125:
+		hdr[1] = byte(l)
+	case l <= 0xffff:
+		hdr[1] = 126
+		binary.BigEndian.PutUint16(hdr[2:], uint16(l))
+		n += 2
+	default:
+		hdr[1] = 127
+		binary.BigEndian.PutUint64(hdr[2:], uint64(l))
+		n += 8
+	}
+	if !c.isServer {
+		// Clients mask every frame (RFC 6455, section 5.3).
+		hdr[1] |= maskBit
+		var key [4]byte
+		if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+			c.writeErr = err
+			return err
+		}
+		copy(hdr[n:], key[:])
+		n += 4
+		masked := make([]byte, len(payload))
+		copy(masked, payload)
+		maskBytes(key, 0, masked)
+		payload = masked
+	}
+	c.bw.Write(hdr[:n])
+	c.bw.Write(payload)
+	if err := c.bw.Flush(); err != nil {
+		c.writeErr = err
+		return err
+	}
+	return nil
+}
+
+// writeControl writes a control frame.
+func (c *Conn) writeControl(op byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	return c.writeFrame(true, false, op, payload)
+}
+
+// writeClose sends a close frame, if none was sent yet.
+func (c *Conn) writeClose(code StatusCode, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	c.closeSent = true
+	var p []byte
+	if code != StatusNoStatusReceived {
+		p = make([]byte, 2+len(reason))
+		binary.BigEndian.PutUint16(p, uint16(code))
+		copy(p[2:], reason)
+	}
+	return c.writeFrame(true, false, opClose, p)
+}
+
+// NextWriter returns a writer for a new data message of type typ.
+// The message is sent in one or more frames as data is written, and
+// is complete when the writer is closed. Only one message writer may
+// be open at a time.
+func (c *Conn) NextWriter(typ MessageType) (io.WriteCloser, error) {
+	if typ != TextMessage && typ != BinaryMessage {
+		return nil, errors.New("websocket: invalid message type " + typ.String())
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return nil, ErrCloseSent
+	}
+	if c.writeErr != nil {
+		return nil, c.writeErr
+	}
+	if c.writingMsg {
+		return nil, errors.New("websocket: previous message writer not closed")
+	}
+	c.writingMsg = true
+	fw := &frameWriter{c: c, op: byte(typ), rsv1: c.compress}
+	if c.compress {
+		return newCompressor(fw), nil
+	}
+	return fw, nil
+}
+
+// WriteMessage writes a data message of type typ with the contents p.
+func (c *Conn) WriteMessage(typ MessageType, p []byte) error {
+	w, err := c.NextWriter(typ)
+	if err != nil {
+		return err
+	}
+	if fw, ok := w.(*frameWriter); ok {
+		// Send small uncompressed messages in a single frame.
+		return fw.writeFinal(p)
+	}
+	if _, err := w.Write(p); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// A frameWriter sends the data written to it as the frames of a
+// message.
+type frameWriter struct {
+	c      *Conn
+	op     byte // opcode of the next frame
+	rsv1   bool // set RSV1 on the next frame
+	buf    []byte
+	closed bool
+}
+
+// flushFrame sends buf as a frame of the message.
+func (fw *frameWriter) flushFrame(fin bool) error {
+	c := fw.c
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	err := c.writeFrame(fin, fw.rsv1, fw.op, fw.buf)
+	fw.buf = fw.buf[:0]
+	fw.op = opContinuation
+	fw.rsv1 = false
+	return err
+}
+
+func (fw *frameWriter) Write(p []byte) (int, error) {
+	if fw.closed {
+		return 0, errors.New("websocket: write to closed message writer")
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(fw.buf) == writeFrameSize {
+			if err := fw.flushFrame(false); err != nil {
+				return n, err
+			}
+		}
+		if fw.buf == nil {
+			fw.buf = make([]byte, 0, writeFrameSize)
+		}
+		m := copy(fw.buf[len(fw.buf):writeFrameSize], p)
+		fw.buf = fw.buf[:len(fw.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// writeFinal sends p and any buffered data as the end of the
+// message, and closes fw.
+func (fw *frameWriter) writeFinal(p []byte) error {
+	if fw.closed {
+		return errors.New("websocket: message writer already closed")
+	}
+	fw.closed = true
+	if len(fw.buf) == 0 {
+		fw.buf = p
+	} else {
+		fw.buf = append(fw.buf, p...)
+	}
+	err := fw.flushFrame(true)
+	fw.c.writeMu.Lock()
+	fw.c.writingMsg = false
+	fw.c.writeMu.Unlock()
+	return err
+}
+
+func (fw *frameWriter) Close() error { return fw.writeFinal(nil) }
+
+// Ping sends a ping frame and waits for the peer's pong, or for ctx
+// to be done. The pong is processed by the Conn's read methods, so
+// another goroutine must be reading from the Conn.
+func (c *Conn) Ping(ctx context.Context) error {
+	c.pingMu.Lock()
+	c.pingSeq++
+	payload := strconv.FormatUint(c.pingSeq, 10)
+	ch := make(chan struct{})
+	c.pings[payload] = ch
+	c.pingMu.Unlock()
+
+	defer func() {
+		c.pingMu.Lock()
+		delete(c.pings, payload)
+		c.pingMu.Unlock()
+	}()
+	if err := c.writeControl(opPing, []byte(payload)); err != nil {
+		return err
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-c.closeRecvd:
+		return c.closeRecvErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close performs the closing handshake with StatusNormalClosure and
+// closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.CloseWithStatus(StatusNormalClosure, "")
+}
+
+// CloseWithStatus sends a close frame with the given status code and
+// reason, waits briefly for the peer's close frame, and closes the
+// underlying connection. The reason must be at most 123 bytes long.
+//
+// Data messages received while waiting for the peer's close frame are
+// discarded, unless another goroutine is reading from the Conn.
+func (c *Conn) CloseWithStatus(code StatusCode, reason string) error {
+	if !validSendCode(code) {
+		return errors.New("websocket: invalid close status " + strconv.Itoa(int(code)))
+	}
+	if len(reason) > maxControlPayload-2 {
+		return errors.New("websocket: close reason too long")
+	}
+	err := c.writeClose(code, reason)
+	if err == ErrCloseSent {
+		// The closing handshake is already under way.
+		err = nil
+	}
+	if err != nil {
+		c.closeConn()
+		return err
+	}
+
+	timer := time.NewTimer(closeTimeout)
+	defer timer.Stop()
+	select {
+	case c.readSem <- struct{}{}:
+		// Nobody is reading; wait for the peer's close frame here.
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-timer.C:
+				c.closeConn()
+			case <-done:
+			}
+		}()
+		for c.readErr == nil {
+			h, err := c.nextDataFrame()
+			if err == nil {
+				_, err = io.CopyN(ioutil.Discard, c.br, h.length)
+			}
+			if err != nil {
+				c.fail(err)
+			}
+		}
+		close(done)
+		<-c.readSem
+	case <-c.closeRecvd:
+	case <-timer.C:
+	}
+	return c.closeConn()
+}
+
+// closeConn closes the underlying connection.
+func (c *Conn) closeConn() error {
+	var err error
+	c.closeOnce.Do(func() { err = c.conn.Close() })
+	return err
+}
@@ -0,0 +1,540 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEchoServer starts a server that echoes every message it receives.
+func newEchoServer(t *testing.T, u *Upgrader) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			typ, r, err := c.NextReader()
+			if err != nil {
+				return
+			}
+			w, err := c.NextWriter(typ)
+			if err != nil {
+				return
+			}
+			if _, err := io.Copy(w, r); err != nil {
+				return
+			}
+			if err := w.Close(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func wsURL(ts *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func TestEcho(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		server, client bool // EnableCompression
+	}{
+		{"plain", false, false},
+		{"compressed", true, true},
+		{"server only", true, false},
+		{"client only", false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newEchoServer(t, &Upgrader{EnableCompression: tt.server})
+			d := &Dialer{EnableCompression: tt.client}
+			c, resp, err := d.Dial(context.Background(), wsURL(ts), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			wantCompress := tt.server && tt.client
+			if c.compress != wantCompress {
+				t.Errorf("compress = %v; want %v (extensions %q)", c.compress, wantCompress, resp.Header["Sec-Websocket-Extensions"])
+			}
+
+			big := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+			msgs := []struct {
+				typ  MessageType
+				data []byte
+			}{
+				{TextMessage, []byte("hello")},
+				{TextMessage, []byte("")},
+				{BinaryMessage, []byte{0, 1, 2, 0xff}},
+				{BinaryMessage, big},
+				{TextMessage, []byte("héllo, 世界")},
+			}
+			for _, m := range msgs {
+				if err := c.WriteMessage(m.typ, m.data); err != nil {
+					t.Fatal(err)
+				}
+				typ, data, err := c.ReadMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if typ != m.typ || !bytes.Equal(data, m.data) {
+					t.Errorf("echo of %v message of %d bytes = %v message of %d bytes", m.typ, len(m.data), typ, len(data))
+				}
+			}
+
+			// A message written in pieces is sent in several frames.
+			w, err := c.NextWriter(BinaryMessage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < len(big); i += 1000 {
+				w.Write(big[i : i+1000])
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, big) {
+				t.Errorf("fragmented echo has %d bytes; want %d", len(data), len(big))
+			}
+		})
+	}
+}
+
+func TestSubprotocol(t *testing.T) {
+	ts := newEchoServer(t, &Upgrader{Subprotocols: []string{"v2", "v1"}})
+	for _, tt := range []struct {
+		client []string
+		want   string
+	}{
+		{nil, ""},
+		{[]string{"v1"}, "v1"},
+		{[]string{"v1", "v2"}, "v2"},
+		{[]string{"v3"}, ""},
+	} {
+		d := &Dialer{Subprotocols: tt.client}
+		c, _, err := d.Dial(context.Background(), wsURL(ts), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Subprotocol(); got != tt.want {
+			t.Errorf("client %q: Subprotocol = %q; want %q", tt.client, got, tt.want)
+		}
+		c.Close()
+	}
+}
+
+func TestBadHandshake(t *testing.T) {
+	var u Upgrader
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/notfound" {
+			http.NotFound(w, r)
+			return
+		}
+		if c, err := u.Upgrade(w, r, nil); err == nil {
+			c.Close()
+		}
+	}))
+	defer ts.Close()
+
+	_, resp, err := Dial(context.Background(), wsURL(ts)+"/notfound", nil)
+	if err != ErrBadHandshake {
+		t.Fatalf("Dial error = %v; want ErrBadHandshake", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d; want 404", resp.StatusCode)
+	}
+	if body, _ := ioutil.ReadAll(resp.Body); !strings.Contains(string(body), "not found") {
+		t.Errorf("body = %q; want the server's", body)
+	}
+
+	// A plain request to an upgrading handler.
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET status = %d; want 400", res.StatusCode)
+	}
+
+	// A cross-origin request.
+	h := http.Header{"Origin": {"http://evil.example"}}
+	_, resp, err = Dial(context.Background(), wsURL(ts), h)
+	if err != ErrBadHandshake || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("cross-origin Dial = %v, %v; want ErrBadHandshake, 403", resp, err)
+	}
+	h = http.Header{"Origin": {ts.URL}}
+	c, _, err := Dial(context.Background(), wsURL(ts), h)
+	if err != nil {
+		t.Fatalf("same-origin Dial: %v", err)
+	}
+	c.Close()
+
+	if _, _, err := Dial(context.Background(), wsURL(ts), http.Header{"Sec-Websocket-Key": {"x"}}); err == nil {
+		t.Error("Dial with a handshake header in requestHeader succeeded")
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	// A server that never answers the handshake.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := Dial(ctx, wsURL(ts), nil); err != context.DeadlineExceeded {
+		t.Errorf("Dial error = %v; want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	ts := newEchoServer(t, &Upgrader{})
+	c, _, err := Dial(context.Background(), wsURL(ts), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := c.Ping(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("Ping %d: %v", i, err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var ce *CloseError
+	if err := <-readErr; !errors.As(err, &ce) || ce.Code != StatusNormalClosure {
+		t.Errorf("read error after Close = %v; want close 1000", err)
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	serverErr := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u Upgrader
+		c, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		_, _, err = c.ReadMessage()
+		if _, _, err2 := c.ReadMessage(); err2 != err {
+			t.Errorf("second read error = %v; want %v", err2, err)
+		}
+		serverErr <- err
+	}))
+	defer ts.Close()
+
+	c, _, err := Dial(context.Background(), wsURL(ts), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := c.CloseWithStatus(StatusGoingAway, "bye"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d >= closeTimeout {
+		t.Errorf("CloseWithStatus took %v; the server did not answer", d)
+	}
+	want := &CloseError{Code: StatusGoingAway, Reason: "bye"}
+	if err := <-serverErr; !reflect.DeepEqual(err, want) {
+		t.Errorf("server read error = %v; want %v", err, want)
+	}
+	if err := c.WriteMessage(TextMessage, []byte("late")); err != ErrCloseSent {
+		t.Errorf("write after close = %v; want ErrCloseSent", err)
+	}
+	if err := c.CloseWithStatus(1004, ""); err == nil {
+		t.Error("CloseWithStatus with reserved code succeeded")
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	serverErr := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := Upgrader{ReadLimit: 10, EnableCompression: true}
+		c, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		_, _, err = c.ReadMessage()
+		serverErr <- err
+	}))
+	defer ts.Close()
+
+	for _, compress := range []bool{false, true} {
+		d := &Dialer{EnableCompression: compress}
+		c, _, err := d.Dial(context.Background(), wsURL(ts), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.WriteMessage(BinaryMessage, make([]byte, 100)); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-serverErr; err != ErrReadLimit {
+			t.Errorf("compress=%v: server read error = %v; want ErrReadLimit", compress, err)
+		}
+		var ce *CloseError
+		if _, _, err := c.ReadMessage(); !errors.As(err, &ce) || ce.Code != StatusMessageTooBig {
+			t.Errorf("compress=%v: client read error = %v; want close 1009", compress, err)
+		}
+		c.Close()
+	}
+}
+
+// rawFrame encodes a frame as sent by a client.
+func rawFrame(fin, rsv1 bool, op byte, payload []byte, masked bool) []byte {
+	var b bytes.Buffer
+	b0 := op
+	if fin {
+		b0 |= finBit
+	}
+	if rsv1 {
+		b0 |= rsv1Bit
+	}
+	b.WriteByte(b0)
+	var b1 byte
+	if masked {
+		b1 = maskBit
+	}
+	switch {
+	case len(payload) <= 125:
+		b.WriteByte(b1 | byte(len(payload)))
+	case len(payload) <= 0xffff:
+		b.WriteByte(b1 | 126)
+		binary.Write(&b, binary.BigEndian, uint16(len(payload)))
+	default:
+		b.WriteByte(b1 | 127)
+		binary.Write(&b, binary.BigEndian, uint64(len(payload)))
+	}
+	p := append([]byte(nil), payload...)
+	if masked {
+		key := [4]byte{1, 2, 3, 4}
+		b.Write(key[:])
+		maskBytes(key, 0, p)
+	}
+	b.Write(p)
+	return b.Bytes()
+}
+
+// newPipeServer returns a server Conn reading the frames in input,
+// and a reader of the frames it writes.
+func newPipeServer(input []byte, compress, readNoDict bool) (*Conn, net.Conn) {
+	server, client := net.Pipe()
+	go func() {
+		client.Write(input)
+	}()
+	return newConn(server, nil, true, "", compress, readNoDict, 0), client
+}
+
+// readCloseCode reads frames written by a server until a close frame
+// and returns its status code.
+func readCloseCode(t *testing.T, r io.Reader) StatusCode {
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			t.Fatalf("reading close frame: %v", err)
+		}
+		p := make([]byte, hdr[1]&0x7f)
+		io.ReadFull(r, p)
+		if hdr[0]&0xf == opClose {
+			if len(p) < 2 {
+				return StatusNoStatusReceived
+			}
+			return StatusCode(binary.BigEndian.Uint16(p))
+		}
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		code  StatusCode
+	}{
+		{"unmasked", rawFrame(true, false, opText, []byte("hi"), false), StatusProtocolError},
+		{"reserved opcode", rawFrame(true, false, 0x3, nil, true), StatusProtocolError},
+		{"uncompressed RSV1", rawFrame(true, true, opText, []byte("hi"), true), StatusProtocolError},
+		{"long ping", rawFrame(true, false, opPing, make([]byte, 126), true), StatusProtocolError},
+		{"fragmented ping", rawFrame(false, false, opPing, nil, true), StatusProtocolError},
+		{"bare continuation", rawFrame(true, false, opContinuation, []byte("hi"), true), StatusProtocolError},
+		{"invalid UTF-8", rawFrame(true, false, opText, []byte("a\xffb"), true), StatusInvalidPayloadData},
+		{"truncated UTF-8", rawFrame(true, false, opText, []byte("a\xe4\xb8"), true), StatusInvalidPayloadData},
+		{"interleaved message", append(
+			rawFrame(false, false, opText, []byte("a"), true),
+			rawFrame(true, false, opText, []byte("b"), true)...), StatusProtocolError},
+		{"bad close code", rawFrame(true, false, opClose, []byte{0x03, 0xec}, true), StatusProtocolError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, peer := newPipeServer(tt.input, false, false)
+			defer peer.Close()
+			errc := make(chan error, 1)
+			go func() {
+				_, _, err := c.ReadMessage()
+				errc <- err
+			}()
+			if code := readCloseCode(t, peer); code != tt.code {
+				t.Errorf("close code = %d; want %d", code, tt.code)
+			}
+			if err := <-errc; err == nil {
+				t.Error("ReadMessage succeeded")
+			}
+		})
+	}
+}
+
+func TestReadFragments(t *testing.T) {
+	// A text message split inside a rune, with a ping between
+	// its frames.
+	var input []byte
+	input = append(input, rawFrame(false, false, opText, []byte("a\xe4"), true)...)
+	input = append(input, rawFrame(true, false, opPing, []byte("p"), true)...)
+	input = append(input, rawFrame(false, false, opContinuation, []byte("\xb8"), true)...)
+	input = append(input, rawFrame(true, false, opContinuation, []byte("\x96b"), true)...)
+	c, peer := newPipeServer(input, false, false)
+	defer peer.Close()
+
+	pong := make(chan []byte, 1)
+	go func() {
+		var hdr [2]byte
+		io.ReadFull(peer, hdr[:])
+		p := make([]byte, hdr[1]&0x7f)
+		io.ReadFull(peer, p)
+		if hdr[0]&0xf != opPong {
+			p = nil
+		}
+		pong <- p
+	}()
+	typ, data, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != TextMessage || string(data) != "a世b" {
+		t.Errorf("ReadMessage = %v, %q; want text, %q", typ, data, "a世b")
+	}
+	if p := <-pong; string(p) != "p" {
+		t.Errorf("pong payload = %q; want %q", p, "p")
+	}
+}
+
+// TestContextTakeover checks that the reader decompresses messages
+// from a peer that reuses the LZ77 window across messages.
+func TestContextTakeover(t *testing.T) {
+	var buf bytes.Buffer
+	zw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	msgs := []string{
+		strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20),
+		strings.Repeat("The quick brown fox jumps over the lazy dog. ", 30),
+		"short",
+	}
+	var input []byte
+	for _, m := range msgs {
+		buf.Reset()
+		zw.Write([]byte(m))
+		zw.Flush()
+		p := bytes.TrimSuffix(buf.Bytes(), []byte(deflateTail))
+		input = append(input, rawFrame(true, true, opText, p, true)...)
+	}
+	if len(input) > 200 {
+		t.Fatalf("input of %d bytes does not use context takeover", len(input))
+	}
+	c, peer := newPipeServer(input, true, false)
+	defer peer.Close()
+	for i, m := range msgs {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if string(data) != m {
+			t.Errorf("message %d = %q; want %q", i, data, m)
+		}
+	}
+}
+
+func TestTruncWriter(t *testing.T) {
+	const data = "0123456789"
+	for chunk := 1; chunk <= len(data); chunk++ {
+		var out bytes.Buffer
+		w := &truncWriter{w: &out}
+		for i := 0; i < len(data); i += chunk {
+			end := i + chunk
+			if end > len(data) {
+				end = len(data)
+			}
+			w.Write([]byte(data[i:end]))
+		}
+		if got, want := out.String()+string(w.p[:w.n]), data; got != want || w.n != 4 {
+			t.Errorf("chunk %d: wrote %q, held %q", chunk, out.String(), w.p[:w.n])
+		}
+	}
+}
+
+func TestParseExtensions(t *testing.T) {
+	h := http.Header{"Sec-Websocket-Extensions": {
+		`permessage-deflate; client_max_window_bits, x-foo; a="1,2"`,
+		`Permessage-Deflate;server_no_context_takeover;server_no_context_takeover`,
+	}}
+	want := []extension{
+		{name: "permessage-deflate", params: map[string]string{"client_max_window_bits": ""}},
+		{name: "x-foo", params: map[string]string{"a": "1,2"}},
+		{name: "permessage-deflate", params: map[string]string{"server_no_context_takeover": ""}, bad: true},
+	}
+	if got := parseExtensions(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseExtensions = %+v; want %+v", got, want)
+	}
+
+	for _, tt := range []struct {
+		offer           string
+		ok, noClientCtx bool
+	}{
+		{"permessage-deflate", true, false},
+		{"permessage-deflate; client_no_context_takeover", true, true},
+		{"permessage-deflate; client_max_window_bits=10", true, false},
+		{"permessage-deflate; server_max_window_bits=10", false, false},
+		{"permessage-deflate; server_max_window_bits=15", true, false},
+		{"permessage-deflate; unknown", false, false},
+		{"x-foo", false, false},
+	} {
+		ext := parseExtensions(http.Header{"Sec-Websocket-Extensions": {tt.offer}})[0]
+		ok, noClientCtx := acceptDeflateOffer(ext)
+		if ok != tt.ok || noClientCtx != tt.noClientCtx {
+			t.Errorf("acceptDeflateOffer(%q) = %v, %v; want %v, %v", tt.offer, ok, noClientCtx, tt.ok, tt.noClientCtx)
+		}
+	}
+}
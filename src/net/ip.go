@@ -12,7 +12,10 @@
 
 package net
 
-import "internal/bytealg"
+import (
+	"internal/bytealg"
+	"net/netip"
+)
 
 // IP address lengths (bytes).
 const (
@@ -534,6 +537,37 @@ func (n *IPNet) String() string {
 	return nn.String() + "/" + uitoa(uint(l))
 }
 
+// Prefix returns n as a netip.Prefix. IPv4 networks are returned as
+// IPv4 prefixes, even if n.IP is in 16-byte form. The boolean is
+// false if n is nil or its mask is not in canonical form (ones
+// followed by zeros).
+func (n *IPNet) Prefix() (netip.Prefix, bool) {
+	if n == nil {
+		return netip.Prefix{}, false
+	}
+	nn, m := networkNumberAndMask(n)
+	if nn == nil || m == nil {
+		return netip.Prefix{}, false
+	}
+	l := simpleMaskLength(m)
+	if l == -1 {
+		return netip.Prefix{}, false
+	}
+	addr, _ := netip.AddrFromSlice(nn)
+	return netip.PrefixFrom(addr, l), true
+}
+
+// IPNetFromPrefix returns p as an IPNet, or nil if p is not valid.
+// The address is not masked; use p.Masked() to obtain the network
+// number first if p may have host bits set.
+func IPNetFromPrefix(p netip.Prefix) *IPNet {
+	if !p.IsValid() {
+		return nil
+	}
+	addr := p.Addr()
+	return &IPNet{IP: addr.AsSlice(), Mask: CIDRMask(p.Bits(), addr.BitLen())}
+}
+
 // Parse IPv4 address (d.d.d.d).
 func parseIPv4(s string) IP {
 	var p [IPv4len]byte
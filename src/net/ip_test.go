@@ -9,6 +9,7 @@ package net
 import (
 	"bytes"
 	"math/rand"
+	"net/netip"
 	"reflect"
 	"runtime"
 	"testing"
@@ -413,6 +414,40 @@ func TestIPNetString(t *testing.T) {
 	}
 }
 
+var ipNetPrefixTests = []struct {
+	in  *IPNet
+	out string // empty if not a canonical mask
+}{
+	{&IPNet{IP: IPv4(192, 168, 1, 0), Mask: CIDRMask(26, 32)}, "192.168.1.0/26"},
+	{&IPNet{IP: IP{10, 0, 0, 0}, Mask: CIDRMask(104, 128)}, "10.0.0.0/8"},
+	{&IPNet{IP: ParseIP("2001:db8::"), Mask: CIDRMask(55, 128)}, "2001:db8::/55"},
+	{&IPNet{IP: IPv4(192, 168, 1, 0), Mask: IPv4Mask(255, 0, 255, 0)}, ""},
+	{&IPNet{IP: IP{1, 2, 3}, Mask: CIDRMask(8, 32)}, ""},
+	{nil, ""},
+}
+
+func TestIPNetPrefix(t *testing.T) {
+	for _, tt := range ipNetPrefixTests {
+		p, ok := tt.in.Prefix()
+		if ok != (tt.out != "") {
+			t.Errorf("IPNet.Prefix(%v) = %v, %v", tt.in, p, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if s := p.String(); s != tt.out {
+			t.Errorf("IPNet.Prefix(%v) = %v, want %v", tt.in, s, tt.out)
+		}
+		if n := IPNetFromPrefix(p); n.String() != tt.out {
+			t.Errorf("IPNetFromPrefix(%v) = %v, want %v", p, n, tt.out)
+		}
+	}
+	if n := IPNetFromPrefix(netip.Prefix{}); n != nil {
+		t.Errorf("IPNetFromPrefix of zero Prefix = %v, want nil", n)
+	}
+}
+
 var cidrMaskTests = []struct {
 	ones int
 	bits int
@@ -9,6 +9,7 @@ package net
 import (
 	"context"
 	"internal/poll"
+	"net/netip"
 	"runtime"
 	"syscall"
 )
@@ -180,3 +181,32 @@ func ipToSockaddr(family int, ip IP, port int, zone string) (syscall.Sockaddr, e
 	}
 	return nil, &AddrError{Err: "invalid address family", Addr: ip.String()}
 }
+
+// addrPortToSockaddrInet4 is like ipToSockaddr for AF_INET but takes
+// a netip.AddrPort and returns the sockaddr by value, so that sending
+// to it does not allocate.
+func addrPortToSockaddrInet4(ap netip.AddrPort) (syscall.SockaddrInet4, error) {
+	// Unlike ipToSockaddr, there is no special handling of the zero
+	// address here: netip has no family-agnostic unspecified address.
+	addr := ap.Addr().Unmap()
+	if !addr.Is4() {
+		return syscall.SockaddrInet4{}, &AddrError{Err: "non-IPv4 address", Addr: addr.String()}
+	}
+	return syscall.SockaddrInet4{Addr: addr.As4(), Port: int(ap.Port())}, nil
+}
+
+// addrPortToSockaddrInet6 is like ipToSockaddr for AF_INET6 but takes
+// a netip.AddrPort and returns the sockaddr by value, so that sending
+// to it does not allocate. IPv4 addresses are sent as IPv4-mapped IPv6
+// addresses.
+func addrPortToSockaddrInet6(ap netip.AddrPort) (syscall.SockaddrInet6, error) {
+	addr := ap.Addr()
+	if !addr.IsValid() {
+		return syscall.SockaddrInet6{}, &AddrError{Err: "non-IPv6 address", Addr: addr.String()}
+	}
+	return syscall.SockaddrInet6{
+		Addr:   addr.As16(),
+		Port:   int(ap.Port()),
+		ZoneId: uint32(zoneCache.index(addr.Zone())),
+	}, nil
+}
@@ -145,6 +145,27 @@ type Resolver struct {
 	// If nil, the default dialer is used.
 	Dial func(ctx context.Context, network, address string) (Conn, error)
 
+	// Upstreams optionally lists DNS servers that Go's built-in DNS
+	// resolver queries instead of the name servers configured in
+	// /etc/resolv.conf. Package net/securedns provides upstreams
+	// that use DNS over TLS and DNS over HTTPS.
+	//
+	// Upstreams are tried in order for each query. A timeout,
+	// transport error or server failure moves on to the next one,
+	// while an answer, including "no such host", ends the query.
+	// The other resolv.conf settings, such as the search list,
+	// timeout and attempts, still apply.
+	//
+	// Setting Upstreams implies PreferGo. Upstreams are only used
+	// on platforms where Go's built-in resolver is available.
+	Upstreams []DNSUpstream
+
+	// Cache optionally specifies a cache for the responses that
+	// Go's built-in DNS resolver receives. If nil, every lookup
+	// queries the DNS servers. The cache is not used by the
+	// system resolver.
+	Cache *DNSCache
+
 	// lookupGroup merges LookupIPAddr calls together for lookups for the same
 	// host. The lookupGroup key is the LookupIPAddr.host argument.
 	// The return values are ([]IPAddr, error).
@@ -154,9 +175,16 @@ type Resolver struct {
 	// TODO(bradfitz): Timeout time.Duration?
 }
 
-func (r *Resolver) preferGo() bool     { return r != nil && r.PreferGo }
+func (r *Resolver) preferGo() bool     { return r != nil && (r.PreferGo || len(r.Upstreams) > 0) }
 func (r *Resolver) strictErrors() bool { return r != nil && r.StrictErrors }
 
+func (r *Resolver) cache() *DNSCache {
+	if r == nil {
+		return nil
+	}
+	return r.Cache
+}
+
 func (r *Resolver) getLookupGroup() *singleflight.Group {
 	if r == nil {
 		return &DefaultResolver.lookupGroup
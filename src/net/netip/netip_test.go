@@ -0,0 +1,592 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netip_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	. "net/netip"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var corpus = []string{
+	// Basic zero IPv4 address.
+	"0.0.0.0",
+	// Basic non-zero IPv4 address.
+	"192.168.140.255",
+	// IPv4 address in windows-style "print all the digits" form.
+	"010.000.015.001",
+	// IPv4 address with a silly amount of leading zeros.
+	"000001.00000002.00000003.000000004",
+	// 4-in-6 with octet with leading zero
+	"::ffff:1.2.03.4",
+	// Basic zero IPv6 address.
+	"::",
+	// Localhost IPv6.
+	"::1",
+	// Fully expanded IPv6 address.
+	"fd7a:115c:a1e0:ab12:4843:cd96:626b:430b",
+	// IPv6 with elided fields in the middle.
+	"fd7a:115c::626b:430b",
+	// IPv6 with elided fields at the end.
+	"fd7a:115c:a1e0:ab12:4843:cd96::",
+	// IPv6 with single elided field at the end.
+	"fd7a:115c:a1e0:ab12:4843:cd96:626b::",
+	"fd7a:115c:a1e0:ab12:4843:cd96:626b:0",
+	// IPv6 with single elided field in the middle.
+	"fd7a:115c:a1e0::4843:cd96:626b:430b",
+	"fd7a:115c:a1e0:0:4843:cd96:626b:430b",
+	// IPv6 with the trailing 32 bits written as IPv4 dotted decimal. (4in6)
+	"::ffff:192.168.140.255",
+	"::ffff:192.168.140.255",
+	// IPv6 with a zone specifier.
+	"fd7a:115c:a1e0:ab12:4843:cd96:626b:430b%eth0",
+	// IPv6 with dotted decimal and zone specifier.
+	"1:2::ffff:192.168.140.255%eth1",
+	"1:2::ffff:c0a8:8cff%eth1",
+	// IPv6 with capital letters.
+	"FD9E:1A04:F01D::1",
+	"fd9e:1a04:f01d::1",
+	// Empty string.
+	"",
+	// Garbage non-IP.
+	"bad",
+	// Single number. Some parsers accept this as an IPv4 address in
+	// big-endian uint32 form, but we don't.
+	"1234",
+	// IPv4 with a zone specifier.
+	"1.2.3.4%eth0",
+	// IPv4 field must have at least one digit.
+	".1.2.3",
+	"1.2.3.",
+	"1..2.3",
+	// IPv4 address too long.
+	"1.2.3.4.5",
+	// IPv4 in dotted octal form.
+	"0300.0250.0214.0377",
+	// IPv4 in dotted hex form.
+	"0xc0.0xa8.0x8c.0xff",
+	// IPv4 in class B form.
+	"192.168.12345",
+	// IPv4 in class B form, with a small enough number to be
+	// parseable as a regular dotted decimal field.
+	"127.0.1",
+	// IPv4 in class A form.
+	"192.1234567",
+	// IPv4 in class A form, with a small enough number to be
+	// parseable as a regular dotted decimal field.
+	"127.1",
+	// IPv4 field has value >255.
+	"192.168.300.1",
+	// IPv4 with too many fields.
+	"192.168.0.1.5.6",
+	// IPv6 with not enough fields.
+	"1:2:3:4:5:6:7",
+	// IPv6 with too many fields.
+	"1:2:3:4:5:6:7:8:9",
+	// IPv6 with 8 fields and a :: expander.
+	"1:2:3:4::5:6:7:8",
+	// IPv6 with a field bigger than 2b.
+	"fe801::1",
+	// IPv6 with non-hex values in field.
+	"fe80:tail:scal:e::",
+	// IPv6 with a zone delimiter but no zone.
+	"fe80::1%",
+	// IPv6 with a zone specifier of zero.
+	"::ffff:0:0%0",
+	// IPv6 (without ellipsis) with too many fields for trailing embedded IPv4.
+	"ffff:ffff:ffff:ffff:ffff:ffff:ffff:192.168.140.255",
+	// IPv6 (with ellipsis) with too many fields for trailing embedded IPv4.
+	"ffff::ffff:ffff:ffff:ffff:ffff:ffff:192.168.140.255",
+	// IPv6 with invalid embedded IPv4.
+	"::ffff:192.168.140.bad",
+	// IPv6 with multiple ellipsis ::.
+	"fe80::1::1",
+	// IPv6 with invalid non hex/colon character.
+	"fe80:1?:1",
+	// IPv6 with truncated bytes after single colon.
+	"fe80:",
+}
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Addr
+		str  string // expected String; in if empty
+	}{
+		{"0.0.0.0", AddrFrom4([4]byte{}), ""},
+		{"192.168.140.255", AddrFrom4([4]byte{192, 168, 140, 255}), ""},
+		{"::", IPv6Unspecified(), ""},
+		{"::1", AddrFrom16([16]byte{15: 1}), ""},
+		{"fd7a:115c:a1e0:ab12:4843:cd96:626b:430b", AddrFrom16([16]byte{0xfd, 0x7a, 0x11, 0x5c, 0xa1, 0xe0, 0xab, 0x12, 0x48, 0x43, 0xcd, 0x96, 0x62, 0x6b, 0x43, 0x0b}), ""},
+		{"fd7a:115c::626b:430b", AddrFrom16([16]byte{0xfd, 0x7a, 0x11, 0x5c, 12: 0x62, 0x6b, 0x43, 0x0b}), ""},
+		{"fd7a:115c:a1e0:ab12:4843:cd96:626b:0", AddrFrom16([16]byte{0xfd, 0x7a, 0x11, 0x5c, 0xa1, 0xe0, 0xab, 0x12, 0x48, 0x43, 0xcd, 0x96, 0x62, 0x6b}), "fd7a:115c:a1e0:ab12:4843:cd96:626b:0"},
+		{"fd7a:115c:a1e0:0:4843:cd96:626b:430b", AddrFrom16([16]byte{0xfd, 0x7a, 0x11, 0x5c, 0xa1, 0xe0, 8: 0x48, 0x43, 0xcd, 0x96, 0x62, 0x6b, 0x43, 0x0b}), ""},
+		{"::ffff:192.168.140.255", AddrFrom16([16]byte{10: 0xff, 0xff, 192, 168, 140, 255}), ""},
+		{"fd7a:115c:a1e0:ab12:4843:cd96:626b:430b%eth0", AddrFrom16([16]byte{0xfd, 0x7a, 0x11, 0x5c, 0xa1, 0xe0, 0xab, 0x12, 0x48, 0x43, 0xcd, 0x96, 0x62, 0x6b, 0x43, 0x0b}).WithZone("eth0"), ""},
+		{"1:2::ffff:192.168.140.255%eth1", AddrFrom16([16]byte{0, 1, 0, 2, 10: 0xff, 0xff, 192, 168, 140, 255}).WithZone("eth1"), "1:2::ffff:c0a8:8cff%eth1"},
+		{"FD9E:1A04:F01D::1", AddrFrom16([16]byte{0xfd, 0x9e, 0x1a, 0x04, 0xf0, 0x1d, 15: 1}), "fd9e:1a04:f01d::1"},
+		{"::ffff:0:0%0", AddrFrom16([16]byte{10: 0xff, 0xff}).WithZone("0"), "::ffff:0.0.0.0%0"},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddr(tt.in)
+		if err != nil {
+			t.Errorf("ParseAddr(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAddr(%q) = %#v; want %#v", tt.in, got, tt.want)
+		}
+		str := tt.str
+		if str == "" {
+			str = tt.in
+		}
+		if s := got.String(); s != str {
+			t.Errorf("ParseAddr(%q).String() = %q; want %q", tt.in, s, str)
+		}
+		if b := got.AppendTo([]byte("x")); string(b) != "x"+str {
+			t.Errorf("ParseAddr(%q).AppendTo = %q; want %q", tt.in, b, "x"+str)
+		}
+		again, err := ParseAddr(str)
+		if err != nil || again != got {
+			t.Errorf("ParseAddr(String of %q) = %v, %v; want %v", tt.in, again, err, got)
+		}
+	}
+
+	valid := make(map[string]bool)
+	for _, tt := range tests {
+		valid[tt.in] = true
+	}
+	valid["fd7a:115c:a1e0:ab12:4843:cd96::"] = true
+	valid["fd7a:115c:a1e0:ab12:4843:cd96:626b::"] = true
+	valid["fd7a:115c:a1e0::4843:cd96:626b:430b"] = true
+	valid["1:2::ffff:c0a8:8cff%eth1"] = true
+	valid["fd9e:1a04:f01d::1"] = true
+	for _, s := range corpus {
+		_, err := ParseAddr(s)
+		if valid[s] && err != nil {
+			t.Errorf("ParseAddr(%q): %v", s, err)
+		}
+		if !valid[s] && err == nil {
+			t.Errorf("ParseAddr(%q) succeeded; want error", s)
+		}
+	}
+}
+
+func TestAddrMarshalText(t *testing.T) {
+	for _, s := range []string{"", "1.2.3.4", "::", "fe80::1%eth0", "::ffff:1.2.3.4", "::ffff:1.2.3.4%eth1"} {
+		var ip Addr
+		if err := ip.UnmarshalText([]byte(s)); err != nil {
+			t.Fatalf("UnmarshalText(%q): %v", s, err)
+		}
+		b, err := ip.MarshalText()
+		if err != nil || string(b) != s {
+			t.Errorf("MarshalText of %q = %q, %v", s, b, err)
+		}
+		bin, _ := ip.MarshalBinary()
+		var ip2 Addr
+		if err := ip2.UnmarshalBinary(bin); err != nil || ip2 != ip {
+			t.Errorf("binary round trip of %q = %v, %v", s, ip2, err)
+		}
+	}
+
+	type T struct {
+		IP   Addr
+		IPP  AddrPort
+		Pfx  Prefix
+		Zero Addr
+	}
+	in := T{MustParseAddr("fe80::1%eth0"), MustParseAddrPort("[::1]:80"), MustParsePrefix("10.0.0.0/8"), Addr{}}
+	j, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"IP":"fe80::1%eth0","IPP":"[::1]:80","Pfx":"10.0.0.0/8","Zero":""}`; string(j) != want {
+		t.Errorf("json = %s; want %s", j, want)
+	}
+	var out T
+	if err := json.Unmarshal(j, &out); err != nil || out != in {
+		t.Errorf("json round trip = %+v, %v; want %+v", out, err, in)
+	}
+}
+
+func TestAddrProperties(t *testing.T) {
+	tests := []string{
+		"0.0.0.0", "127.0.0.1", "10.1.2.3", "172.16.0.1", "172.32.0.1",
+		"192.168.0.1", "169.254.0.1", "224.0.0.1", "239.1.2.3", "255.255.255.255",
+		"8.8.8.8", "::", "::1", "fe80::1", "ff01::1", "ff02::1", "ff05::1",
+		"fc00::1", "fd00::1", "2001:db8::1", "::ffff:10.0.0.1",
+	}
+	for _, s := range tests {
+		ip := MustParseAddr(s)
+		nip := net.ParseIP(s)
+		if ip.Is4In6() {
+			// package net doesn't distinguish 4-in-6 addresses.
+			continue
+		}
+		checks := []struct {
+			name string
+			got  bool
+			want bool
+		}{
+			{"IsGlobalUnicast", ip.IsGlobalUnicast(), nip.IsGlobalUnicast()},
+			{"IsInterfaceLocalMulticast", ip.IsInterfaceLocalMulticast(), nip.IsInterfaceLocalMulticast()},
+			{"IsLinkLocalMulticast", ip.IsLinkLocalMulticast(), nip.IsLinkLocalMulticast()},
+			{"IsLinkLocalUnicast", ip.IsLinkLocalUnicast(), nip.IsLinkLocalUnicast()},
+			{"IsLoopback", ip.IsLoopback(), nip.IsLoopback()},
+			{"IsMulticast", ip.IsMulticast(), nip.IsMulticast()},
+			{"IsUnspecified", ip.IsUnspecified(), nip.IsUnspecified()},
+		}
+		for _, c := range checks {
+			if c.got != c.want {
+				t.Errorf("%s.%s = %v; net.IP says %v", s, c.name, c.got, c.want)
+			}
+		}
+	}
+
+	private := map[string]bool{"10.1.2.3": true, "172.16.0.1": true, "192.168.0.1": true, "fc00::1": true, "fd00::1": true}
+	for _, s := range tests {
+		if got := MustParseAddr(s).IsPrivate(); got != private[s] {
+			t.Errorf("%s.IsPrivate = %v; want %v", s, got, private[s])
+		}
+	}
+
+	var zero Addr
+	if zero.IsValid() || zero.IsGlobalUnicast() || zero.IsLoopback() || zero.IsUnspecified() || zero.BitLen() != 0 {
+		t.Error("zero Addr has properties of a valid address")
+	}
+	if s := zero.String(); s != "invalid IP" {
+		t.Errorf("zero Addr String = %q", s)
+	}
+}
+
+func TestAddrConversions(t *testing.T) {
+	v4 := MustParseAddr("1.2.3.4")
+	mapped := MustParseAddr("::ffff:1.2.3.4")
+	if v4 == mapped || !v4.Is4() || v4.Is6() || !mapped.Is4In6() || !mapped.Is6() {
+		t.Fatal("IPv4 and IPv4-mapped IPv6 addresses aren't distinct")
+	}
+	if mapped.Unmap() != v4 || v4.Unmap() != v4 {
+		t.Error("Unmap failed")
+	}
+	if v4.As4() != [4]byte{1, 2, 3, 4} || mapped.As4() != [4]byte{1, 2, 3, 4} {
+		t.Error("As4 failed")
+	}
+	if v4.As16() != mapped.As16() {
+		t.Error("As16 of IPv4 is not the IPv4-mapped address")
+	}
+	if v4.BitLen() != 32 || mapped.BitLen() != 128 {
+		t.Error("wrong BitLen")
+	}
+	if !bytes.Equal(v4.AsSlice(), []byte{1, 2, 3, 4}) || len(mapped.AsSlice()) != 16 {
+		t.Error("wrong AsSlice")
+	}
+
+	// net.IP conversions.
+	for _, s := range []string{"1.2.3.4", "2001:db8::1"} {
+		nip := net.ParseIP(s)
+		ip, ok := AddrFromSlice(nip)
+		if !ok || ip.Unmap() != MustParseAddr(s) {
+			t.Errorf("AddrFromSlice(net.ParseIP(%q)) = %v, %v", s, ip, ok)
+		}
+		if back := net.IP(ip.AsSlice()); !back.Equal(nip) {
+			t.Errorf("net.IP(AsSlice) of %s = %v", s, back)
+		}
+	}
+	if _, ok := AddrFromSlice([]byte{1, 2, 3}); ok {
+		t.Error("AddrFromSlice accepted a 3-byte slice")
+	}
+
+	z := MustParseAddr("fe80::1").WithZone("eth0")
+	if z.Zone() != "eth0" || z.WithZone("") != MustParseAddr("fe80::1") {
+		t.Error("WithZone failed")
+	}
+	if v4.WithZone("eth0") != v4 {
+		t.Error("WithZone changed an IPv4 address")
+	}
+	m := map[Addr]bool{MustParseAddr("fe80::1%eth0"): true}
+	if !m[z] {
+		t.Error("Addrs with equal zones are not equal map keys")
+	}
+}
+
+func TestAddrCompare(t *testing.T) {
+	values := []string{
+		"1.2.3.4", "1.2.3.5", "::", "::1", "::ffff:1.2.3.4", "fe80::1", "fe80::1%eth0", "fe80::1%eth1", "fe80::2",
+	}
+	var addrs []Addr
+	for i := len(values) - 1; i >= 0; i-- {
+		addrs = append(addrs, MustParseAddr(values[i]))
+	}
+	addrs = append(addrs, Addr{})
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+	got := []string{}
+	for _, a := range addrs[1:] {
+		got = append(got, a.String())
+	}
+	if addrs[0] != (Addr{}) || !reflect.DeepEqual(got, values) {
+		t.Errorf("sorted = %v, %v; want zero Addr, %q", addrs[0], got, values)
+	}
+	for i, a := range addrs {
+		if c := a.Compare(a); c != 0 {
+			t.Errorf("%v.Compare(itself) = %d", a, c)
+		}
+		if i > 0 && a.Compare(addrs[i-1]) != 1 {
+			t.Errorf("%v.Compare(%v) != 1", a, addrs[i-1])
+		}
+	}
+}
+
+func TestAddrNextPrev(t *testing.T) {
+	tests := []struct {
+		ip, next string
+	}{
+		{"1.2.3.4", "1.2.3.5"},
+		{"1.2.3.255", "1.2.4.0"},
+		{"::", "::1"},
+		{"::ffff", "::1:0"},
+		{"fe80::ffff:ffff:ffff:ffff", "fe80:0:0:1::"},
+	}
+	for _, tt := range tests {
+		ip, next := MustParseAddr(tt.ip), MustParseAddr(tt.next)
+		if got := ip.Next(); got != next {
+			t.Errorf("%v.Next = %v; want %v", ip, got, next)
+		}
+		if got := next.Prev(); got != ip {
+			t.Errorf("%v.Prev = %v; want %v", next, got, ip)
+		}
+	}
+	for _, s := range []string{"255.255.255.255", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		if next := MustParseAddr(s).Next(); next.IsValid() {
+			t.Errorf("%s.Next = %v; want zero Addr", s, next)
+		}
+	}
+	for _, s := range []string{"0.0.0.0", "::"} {
+		if prev := MustParseAddr(s).Prev(); prev.IsValid() {
+			t.Errorf("%s.Prev = %v; want zero Addr", s, prev)
+		}
+	}
+}
+
+func TestStringExpanded(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3.4":        "1.2.3.4",
+		"::":             "0000:0000:0000:0000:0000:0000:0000:0000",
+		"2001:db8::1":    "2001:0db8:0000:0000:0000:0000:0000:0001",
+		"fe80::1%eth0":   "fe80:0000:0000:0000:0000:0000:0000:0001%eth0",
+		"::ffff:1.2.3.4": "0000:0000:0000:0000:0000:ffff:0102:0304",
+	}
+	for in, want := range tests {
+		if got := MustParseAddr(in).StringExpanded(); got != want {
+			t.Errorf("%s.StringExpanded = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestAddrPort(t *testing.T) {
+	tests := []struct {
+		in   string
+		want AddrPort
+		str  string
+	}{
+		{"1.2.3.4:80", AddrPortFrom(MustParseAddr("1.2.3.4"), 80), ""},
+		{"[::1]:65535", AddrPortFrom(MustParseAddr("::1"), 65535), ""},
+		{"[fe80::1%eth0]:0", AddrPortFrom(MustParseAddr("fe80::1%eth0"), 0), ""},
+		{"[::ffff:1.2.3.4]:1", AddrPortFrom(MustParseAddr("::ffff:1.2.3.4"), 1), ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddrPort(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAddrPort(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+			continue
+		}
+		if s := got.String(); s != tt.in {
+			t.Errorf("String = %q; want %q", s, tt.in)
+		}
+		if b := got.AppendTo(nil); string(b) != tt.in {
+			t.Errorf("AppendTo = %q; want %q", b, tt.in)
+		}
+		bin, _ := got.MarshalBinary()
+		var back AddrPort
+		if err := back.UnmarshalBinary(bin); err != nil || back != got {
+			t.Errorf("binary round trip of %v = %v, %v", got, back, err)
+		}
+	}
+	for _, s := range []string{"", "1.2.3.4", "1.2.3.4:", ":80", "1.2.3.4:65536", "::1:80", "[1.2.3.4]:80", "[::1:80", "host:80"} {
+		if _, err := ParseAddrPort(s); err == nil {
+			t.Errorf("ParseAddrPort(%q) succeeded; want error", s)
+		}
+	}
+	if s := (AddrPort{}).String(); s != "invalid AddrPort" {
+		t.Errorf("zero AddrPort String = %q", s)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		in       string
+		masked   string
+		contains []string
+		notIn    []string
+	}{
+		{"192.168.0.0/24", "192.168.0.0/24", []string{"192.168.0.0", "192.168.0.255"}, []string{"192.168.1.0", "::ffff:192.168.0.1"}},
+		{"192.168.1.77/16", "192.168.0.0/16", []string{"192.168.255.1"}, []string{"192.169.0.1"}},
+		{"10.0.0.1/32", "10.0.0.1/32", []string{"10.0.0.1"}, []string{"10.0.0.2"}},
+		{"0.0.0.0/0", "0.0.0.0/0", []string{"1.2.3.4", "255.255.255.255"}, []string{"::1"}},
+		{"2001:db8::/32", "2001:db8::/32", []string{"2001:db8:ffff::1"}, []string{"2001:db9::1", "2001:db8::1%eth0"}},
+		{"2001:db8:1:2:3::/65", "2001:db8:1:2::/65", []string{"2001:db8:1:2::"}, []string{"2001:db8:1:2:8000::"}},
+		{"::ffff:10.0.0.0/104", "::ffff:10.0.0.0/104", []string{"::ffff:10.1.2.3"}, []string{"10.1.2.3"}},
+	}
+	for _, tt := range tests {
+		p, err := ParsePrefix(tt.in)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q): %v", tt.in, err)
+			continue
+		}
+		if s := p.String(); s != tt.in {
+			t.Errorf("String = %q; want %q", s, tt.in)
+		}
+		if m := p.Masked().String(); m != tt.masked {
+			t.Errorf("%v.Masked = %v; want %v", p, m, tt.masked)
+		}
+		for _, s := range tt.contains {
+			if !p.Contains(MustParseAddr(s)) {
+				t.Errorf("%v doesn't contain %s", p, s)
+			}
+		}
+		for _, s := range tt.notIn {
+			if p.Contains(MustParseAddr(s)) {
+				t.Errorf("%v contains %s", p, s)
+			}
+		}
+		var back Prefix
+		if b, _ := p.MarshalText(); back.UnmarshalText(b) != nil || back != p {
+			t.Errorf("text round trip of %v = %v", p, back)
+		}
+		bin, _ := p.MarshalBinary()
+		if err := back.UnmarshalBinary(bin); err != nil || back != p {
+			t.Errorf("binary round trip of %v = %v, %v", p, back, err)
+		}
+	}
+	for _, s := range []string{"", "1.2.3.4", "1.2.3.4/33", "1.2.3.4/-1", "1.2.3.4/a", "::1/129", "fe80::1%eth0/64", "/24"} {
+		if _, err := ParsePrefix(s); err == nil {
+			t.Errorf("ParsePrefix(%q) succeeded; want error", s)
+		}
+	}
+	if p := PrefixFrom(MustParseAddr("1.2.3.4"), 33); p.IsValid() || p.Bits() != -1 {
+		t.Errorf("PrefixFrom with too many bits = %v, valid %v", p, p.IsValid())
+	}
+	if !MustParsePrefix("1.2.3.4/32").IsSingleIP() || MustParsePrefix("1.2.3.4/31").IsSingleIP() {
+		t.Error("wrong IsSingleIP")
+	}
+	if p, err := MustParseAddr("2001:db8::1").Prefix(48); err != nil || p != MustParsePrefix("2001:db8::/48") {
+		t.Errorf("Addr.Prefix = %v, %v", p, err)
+	}
+	if _, err := MustParseAddr("1.2.3.4").Prefix(33); err == nil {
+		t.Error("Addr.Prefix(33) of IPv4 succeeded")
+	}
+}
+
+func TestPrefixOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.0.0/16", "1.2.0.0/16", true},
+		{"1.2.0.0/16", "1.2.3.0/24", true},
+		{"1.2.3.0/24", "1.2.0.0/16", true},
+		{"1.2.0.0/16", "1.3.0.0/16", false},
+		{"0.0.0.0/0", "8.8.8.8/32", true},
+		{"1.2.3.4/32", "1.2.3.4/32", true},
+		{"1.2.3.4/32", "1.2.3.5/32", false},
+		{"1.2.0.0/16", "::/0", false},
+		{"2001:db8::/32", "2001:db8:1::/48", true},
+		{"2001:db8::/32", "2001:db9::/32", false},
+	}
+	for _, tt := range tests {
+		a, b := MustParsePrefix(tt.a), MustParsePrefix(tt.b)
+		if got := a.Overlaps(b); got != tt.want {
+			t.Errorf("%v.Overlaps(%v) = %v; want %v", a, b, got, tt.want)
+		}
+	}
+}
+
+var (
+	sinkAddr     Addr
+	sinkAddrPort AddrPort
+	sinkPrefix   Prefix
+	sinkBytes    []byte
+	sinkBool     bool
+)
+
+func TestNoAllocs(t *testing.T) {
+	buf := make([]byte, 0, 64)
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"ParseAddr/4", func() { sinkAddr, _ = ParseAddr("192.168.1.1") }},
+		{"ParseAddr/6", func() { sinkAddr, _ = ParseAddr("2001:db8::1") }},
+		{"ParseAddr/6zone", func() { sinkAddr, _ = ParseAddr("fe80::1%eth0") }},
+		{"ParseAddrPort", func() { sinkAddrPort, _ = ParseAddrPort("[2001:db8::1]:443") }},
+		{"ParsePrefix", func() { sinkPrefix, _ = ParsePrefix("10.0.0.0/8") }},
+		{"AddrFromSlice", func() { sinkAddr, _ = AddrFromSlice(net.IPv4(1, 2, 3, 4)) }},
+		{"Addr.AppendTo/4", func() { sinkBytes = MustParseAddr("1.2.3.4").AppendTo(buf[:0]) }},
+		{"Addr.AppendTo/6", func() { sinkBytes = MustParseAddr("fe80::1%eth0").AppendTo(buf[:0]) }},
+		{"AddrPort.AppendTo", func() { sinkBytes = MustParseAddrPort("[::1]:80").AppendTo(buf[:0]) }},
+		{"Prefix.AppendTo", func() { sinkBytes = MustParsePrefix("2001:db8::/32").AppendTo(buf[:0]) }},
+		{"Prefix.Contains", func() { sinkBool = MustParsePrefix("10.0.0.0/8").Contains(MustParseAddr("10.1.2.3")) }},
+		{"Prefix.Masked", func() { sinkPrefix = MustParsePrefix("10.1.2.3/8").Masked() }},
+	}
+	for _, tt := range tests {
+		if n := testing.AllocsPerRun(100, tt.fn); n != 0 {
+			t.Errorf("%s allocates %v times; want 0", tt.name, n)
+		}
+	}
+}
+
+func TestAddrStringAllocs(t *testing.T) {
+	for _, s := range []string{"1.2.3.4", "2001:db8::1", "fe80::1%eth0"} {
+		ip := MustParseAddr(s)
+		if n := testing.AllocsPerRun(100, func() { _ = ip.String() }); n != 1 {
+			t.Errorf("%s.String allocates %v times; want 1", s, n)
+		}
+	}
+}
+
+func TestParseAddrErrorMessage(t *testing.T) {
+	_, err := ParseAddr("fe80::1::1")
+	if err == nil || !strings.Contains(err.Error(), `ParseAddr("fe80::1::1"): multiple :: in address`) {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func BenchmarkParseAddr(b *testing.B) {
+	for _, s := range []string{"192.168.1.1", "2001:db8::1", "fe80::1%eth0"} {
+		b.Run(s, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sinkAddr, _ = ParseAddr(s)
+			}
+		})
+	}
+}
+
+func BenchmarkAddrAppendTo(b *testing.B) {
+	buf := make([]byte, 0, 64)
+	for _, s := range []string{"192.168.1.1", "2001:db8::1"} {
+		ip := MustParseAddr(s)
+		b.Run(s, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sinkBytes = ip.AppendTo(buf[:0])
+			}
+		})
+	}
+}
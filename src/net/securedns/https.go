@@ -0,0 +1,136 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securedns
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mediaType is the media type of DNS messages sent over HTTPS.
+const mediaType = "application/dns-message"
+
+// HTTPSUpstream is a net.DNSUpstream that sends queries over HTTPS,
+// as specified by RFC 8484.
+//
+// An HTTPSUpstream is safe for concurrent use by multiple goroutines.
+// It must not be copied after first use.
+type HTTPSUpstream struct {
+	// URL is the server's URI template, which must not contain
+	// variables, such as "https://dns.example/dns-query".
+	URL string
+
+	// UseGET specifies whether queries are sent in GET requests,
+	// which HTTP caches can answer. By default, queries are sent in
+	// POST requests, which are smaller.
+	UseGET bool
+
+	// Client optionally specifies the HTTP client to use. If nil, a
+	// client with its own Transport is used, so that connections to
+	// the server are reused without being shared with other users of
+	// http.DefaultTransport. The client's dialer resolves the host in
+	// URL, which must therefore not require this upstream.
+	Client *http.Client
+
+	clientOnce    sync.Once
+	defaultClient *http.Client
+}
+
+// String returns u.URL.
+func (u *HTTPSUpstream) String() string {
+	return u.URL
+}
+
+func (u *HTTPSUpstream) client() *http.Client {
+	if u.Client != nil {
+		return u.Client
+	}
+	u.clientOnce.Do(func() {
+		u.defaultClient = &http.Client{
+			Transport: &http.Transport{
+				ForceAttemptHTTP2:   true,
+				MaxIdleConnsPerHost: defaultMaxIdleConns,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+		}
+	})
+	return u.defaultClient
+}
+
+// Exchange sends the query req to the server and returns its response.
+//
+// As recommended by RFC 8484, the query is sent with a message ID of 0,
+// so that responses may be cached. The ID of req is restored in the
+// returned response.
+func (u *HTTPSUpstream) Exchange(ctx context.Context, req []byte) ([]byte, error) {
+	if len(req) < headerLen || len(req) > maxMessageLen {
+		return nil, errInvalidQuery
+	}
+	msg := make([]byte, len(req))
+	copy(msg, req)
+	msg[0], msg[1] = 0, 0
+
+	var hreq *http.Request
+	var err error
+	if u.UseGET {
+		sep := "?"
+		if strings.Contains(u.URL, "?") {
+			sep = "&"
+		}
+		url := u.URL + sep + "dns=" + base64.RawURLEncoding.EncodeToString(msg)
+		hreq, err = http.NewRequestWithContext(ctx, "GET", url, nil)
+	} else {
+		hreq, err = http.NewRequestWithContext(ctx, "POST", u.URL, bytes.NewReader(msg))
+		if err == nil {
+			hreq.Header.Set("Content-Type", mediaType)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Accept", mediaType)
+
+	res, err := u.client().Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("securedns: unexpected HTTP status " + res.Status)
+	}
+	if ct := res.Header.Get("Content-Type"); !isDNSMessage(ct) {
+		return nil, errors.New("securedns: unexpected Content-Type " + ct)
+	}
+	resp, err := ioutil.ReadAll(io.LimitReader(res.Body, maxMessageLen+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < headerLen || len(resp) > maxMessageLen {
+		return nil, errInvalidResponse
+	}
+	if resp[0] != 0 || resp[1] != 0 {
+		return nil, errIDMismatch
+	}
+	resp[0], resp[1] = req[0], req[1]
+	return resp, nil
+}
+
+// isDNSMessage reports whether the Content-Type ct is mediaType,
+// ignoring any parameters.
+func isDNSMessage(ct string) bool {
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	return strings.EqualFold(strings.TrimSpace(ct), mediaType)
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package securedns implements encrypted transports for Go's built-in
+// DNS resolver: DNS over TLS (RFC 7858) and DNS over HTTPS (RFC 8484).
+//
+// The upstreams in this package implement net.DNSUpstream. To use
+// them, list them in a net.Resolver's Upstreams field:
+//
+//	r := &net.Resolver{
+//		Upstreams: []net.DNSUpstream{
+//			&securedns.TLSUpstream{
+//				Addr:   "192.0.2.53:853",
+//				Config: &tls.Config{ServerName: "dns.example"},
+//			},
+//			&securedns.HTTPSUpstream{URL: "https://dns.example/dns-query"},
+//		},
+//	}
+//
+// The resolver tries the upstreams in order, moving on to the next one
+// when an upstream fails. There is no fallback to unencrypted DNS.
+//
+// The upstreams themselves must be reachable without resolving a name
+// through the resolver that uses them. Use IP addresses where possible,
+// or give HTTPSUpstream a Client that does not depend on that resolver.
+package securedns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+const (
+	// headerLen is the length of a DNS message header.
+	headerLen = 12
+
+	// maxMessageLen is the longest DNS message that can be
+	// sent over TCP or TLS, which use a 2-byte length prefix.
+	maxMessageLen = 1<<16 - 1
+)
+
+var (
+	errInvalidQuery    = errors.New("securedns: invalid DNS query message")
+	errInvalidResponse = errors.New("securedns: invalid DNS response message")
+	errIDMismatch      = errors.New("securedns: response ID does not match query")
+)
+
+// aLongTimeAgo is a non-zero time, far in the past, used for
+// immediate cancelation of I/O.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// withContext calls f, which performs I/O on c, applying ctx's
+// deadline to c and interrupting f if ctx is done before f returns.
+func withContext(ctx context.Context, c net.Conn, f func() error) error {
+	d, _ := ctx.Deadline()
+	c.SetDeadline(d)
+	if ctx.Done() == nil {
+		return f()
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			c.SetDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+	err := f()
+	close(stop)
+	<-stopped
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
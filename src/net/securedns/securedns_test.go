@@ -0,0 +1,351 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securedns_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	. "net/securedns"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// answer returns the response of the stand-in servers to the query
+// req: 192.0.2.1 for A queries of www.example, no such host for
+// missing.example, and no records otherwise.
+func answer(t *testing.T, req []byte) []byte {
+	var q dnsmessage.Message
+	if err := q.Unpack(req); err != nil {
+		t.Errorf("invalid query: %v", err)
+		return nil
+	}
+	r := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 q.ID,
+			Response:           true,
+			RecursionAvailable: true,
+		},
+		Questions: q.Questions,
+	}
+	switch name := q.Questions[0].Name.String(); {
+	case name == "missing.example.":
+		r.RCode = dnsmessage.RCodeNameError
+	case name == "www.example." && q.Questions[0].Type == dnsmessage.TypeA:
+		r.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{
+				Name:  q.Questions[0].Name,
+				Type:  dnsmessage.TypeA,
+				Class: dnsmessage.ClassINET,
+				TTL:   60,
+			},
+			Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+		}}
+	}
+	b, err := r.Pack()
+	if err != nil {
+		t.Errorf("packing response: %v", err)
+	}
+	return b
+}
+
+func query(t *testing.T, id uint16, name string) []byte {
+	q := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	b, err := q.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// tlsServer is a stand-in DNS-over-TLS server.
+type tlsServer struct {
+	ln       net.Listener
+	roots    *x509.CertPool
+	accepted int32 // atomic
+	// closeAfter, if non-zero, is the number of queries answered
+	// on a connection before the server closes it.
+	closeAfter int
+
+	wg sync.WaitGroup
+}
+
+func newTLSServer(t *testing.T) *tlsServer {
+	// Borrow the test certificate of an httptest server.
+	hs := httptest.NewTLSServer(http.NotFoundHandler())
+	hs.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(hs.Certificate())
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", hs.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &tlsServer{ln: ln, roots: roots}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.accepted, 1)
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(t, c)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *tlsServer) serve(t *testing.T, c net.Conn) {
+	defer c.Close()
+	for n := 1; ; n++ {
+		var l [2]byte
+		if _, err := io.ReadFull(c, l[:]); err != nil {
+			return
+		}
+		req := make([]byte, int(l[0])<<8|int(l[1]))
+		if _, err := io.ReadFull(c, req); err != nil {
+			return
+		}
+		resp := answer(t, req)
+		resp = append([]byte{byte(len(resp) >> 8), byte(len(resp))}, resp...)
+		if _, err := c.Write(resp); err != nil {
+			return
+		}
+		if n == s.closeAfter {
+			return
+		}
+	}
+}
+
+func (s *tlsServer) upstream() *TLSUpstream {
+	return &TLSUpstream{
+		Addr:   s.ln.Addr().String(),
+		Config: &tls.Config{RootCAs: s.roots},
+	}
+}
+
+func exchange(t *testing.T, u net.DNSUpstream, id uint16, name string) dnsmessage.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := u.Exchange(ctx, query(t, id, name))
+	if err != nil {
+		t.Fatalf("Exchange(%s): %v", name, err)
+	}
+	var m dnsmessage.Message
+	if err := m.Unpack(resp); err != nil {
+		t.Fatalf("Exchange(%s) returned invalid response: %v", name, err)
+	}
+	if m.ID != id {
+		t.Errorf("Exchange(%s) response ID = %d; want %d", name, m.ID, id)
+	}
+	return m
+}
+
+func TestTLSUpstreamReusesConnections(t *testing.T) {
+	s := newTLSServer(t)
+	u := s.upstream()
+	defer u.CloseIdleConnections()
+
+	for i := 0; i < 3; i++ {
+		m := exchange(t, u, uint16(100+i), "www.example.")
+		if len(m.Answers) != 1 {
+			t.Fatalf("got %d answers; want 1", len(m.Answers))
+		}
+	}
+	if n := atomic.LoadInt32(&s.accepted); n != 1 {
+		t.Errorf("server accepted %d connections; want 1", n)
+	}
+}
+
+func TestTLSUpstreamRetriesClosedConnection(t *testing.T) {
+	s := newTLSServer(t)
+	s.closeAfter = 1
+	u := s.upstream()
+	defer u.CloseIdleConnections()
+
+	exchange(t, u, 1, "www.example.")
+	// Give the server time to close the idle connection, so that the
+	// next exchange finds it stale and has to dial again.
+	time.Sleep(50 * time.Millisecond)
+	exchange(t, u, 2, "www.example.")
+	if n := atomic.LoadInt32(&s.accepted); n != 2 {
+		t.Errorf("server accepted %d connections; want 2", n)
+	}
+}
+
+func TestTLSUpstreamNoReuse(t *testing.T) {
+	s := newTLSServer(t)
+	u := s.upstream()
+	u.MaxIdleConns = -1
+
+	exchange(t, u, 1, "www.example.")
+	exchange(t, u, 2, "www.example.")
+	if n := atomic.LoadInt32(&s.accepted); n != 2 {
+		t.Errorf("server accepted %d connections; want 2", n)
+	}
+}
+
+func TestTLSUpstreamVerifiesCertificate(t *testing.T) {
+	s := newTLSServer(t)
+	u := s.upstream()
+	u.Config.RootCAs = x509.NewCertPool()
+	if _, err := u.Exchange(context.Background(), query(t, 1, "www.example.")); err == nil {
+		t.Fatal("Exchange with an untrusted certificate succeeded")
+	}
+}
+
+func TestTLSUpstreamString(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.53":      "tls://192.0.2.53:853",
+		"192.0.2.53:8853": "tls://192.0.2.53:8853",
+		"2001:db8::53":    "tls://[2001:db8::53]:853",
+		"[2001:db8::53]":  "tls://[2001:db8::53]:853",
+	}
+	for addr, want := range tests {
+		if got := (&TLSUpstream{Addr: addr}).String(); got != want {
+			t.Errorf("TLSUpstream{Addr: %q}.String() = %q; want %q", addr, got, want)
+		}
+	}
+}
+
+func newHTTPSServer(t *testing.T, useGET bool) *httptest.Server {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/dns-message" {
+			t.Errorf("Accept = %q", r.Header.Get("Accept"))
+		}
+		var req []byte
+		var err error
+		if useGET {
+			if r.Method != "GET" {
+				t.Errorf("method = %s; want GET", r.Method)
+			}
+			req, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		} else {
+			if r.Method != "POST" || r.Header.Get("Content-Type") != "application/dns-message" {
+				t.Errorf("method = %s, Content-Type = %q; want POST of application/dns-message", r.Method, r.Header.Get("Content-Type"))
+			}
+			req, err = ioutil.ReadAll(r.Body)
+		}
+		if err != nil || len(req) < 2 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		if req[0] != 0 || req[1] != 0 {
+			t.Errorf("query ID = %d; want 0", int(req[0])<<8|int(req[1]))
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(answer(t, req))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestHTTPSUpstream(t *testing.T) {
+	for _, useGET := range []bool{false, true} {
+		ts := newHTTPSServer(t, useGET)
+		u := &HTTPSUpstream{URL: ts.URL + "/dns-query", UseGET: useGET, Client: ts.Client()}
+		m := exchange(t, u, 4242, "www.example.")
+		if len(m.Answers) != 1 {
+			t.Errorf("UseGET=%v: got %d answers; want 1", useGET, len(m.Answers))
+		}
+		m = exchange(t, u, 4243, "missing.example.")
+		if m.RCode != dnsmessage.RCodeNameError {
+			t.Errorf("UseGET=%v: RCode = %v; want %v", useGET, m.RCode, dnsmessage.RCodeNameError)
+		}
+	}
+}
+
+func TestHTTPSUpstreamErrors(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<html></html>")
+		}
+	}))
+	defer ts.Close()
+
+	for _, path := range []string{"/fail", "/html"} {
+		u := &HTTPSUpstream{URL: ts.URL + path, Client: ts.Client()}
+		if _, err := u.Exchange(context.Background(), query(t, 1, "www.example.")); err == nil {
+			t.Errorf("Exchange with %s succeeded", path)
+		}
+	}
+}
+
+func TestResolverWithUpstreams(t *testing.T) {
+	s := newTLSServer(t)
+	dot := s.upstream()
+	defer dot.CloseIdleConnections()
+	ts := newHTTPSServer(t, false)
+	doh := &HTTPSUpstream{URL: ts.URL + "/dns-query", Client: ts.Client()}
+
+	// An address nothing listens on, to test falling back.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := &TLSUpstream{Addr: ln.Addr().String()}
+	ln.Close()
+
+	tests := []struct {
+		name      string
+		upstreams []net.DNSUpstream
+	}{
+		{"tls", []net.DNSUpstream{dot}},
+		{"https", []net.DNSUpstream{doh}},
+		{"fallback", []net.DNSUpstream{down, doh}},
+	}
+	for _, tt := range tests {
+		r := &net.Resolver{Upstreams: tt.upstreams}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		addrs, err := r.LookupHost(ctx, "www.example.")
+		if err != nil || !reflect.DeepEqual(addrs, []string{"192.0.2.1"}) {
+			t.Errorf("%s: LookupHost = %v, %v; want [192.0.2.1]", tt.name, addrs, err)
+		}
+		_, err = r.LookupHost(ctx, "missing.example.")
+		if de, ok := err.(*net.DNSError); !ok || !de.IsNotFound {
+			t.Errorf("%s: LookupHost of missing name = %v; want not found", tt.name, err)
+		}
+		cancel()
+	}
+
+	r := &net.Resolver{Upstreams: []net.DNSUpstream{down}}
+	_, err = r.LookupHost(context.Background(), "www.example.")
+	if de, ok := err.(*net.DNSError); !ok || de.Server != down.String() || !de.Temporary() {
+		t.Errorf("LookupHost with unreachable upstream = %#v; want temporary error from %s", err, down)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securedns
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTLSPort      = "853"
+	defaultMaxIdleConns = 2
+	defaultIdleTimeout  = 30 * time.Second
+)
+
+// TLSUpstream is a net.DNSUpstream that sends queries over TLS, as
+// specified by RFC 7858. Connections are kept open and reused for
+// later queries.
+//
+// A TLSUpstream is safe for concurrent use by multiple goroutines.
+// It must not be copied after first use.
+type TLSUpstream struct {
+	// Addr is the address of the server in "host:port" form. If the
+	// port is omitted, the standard port 853 is used. The host should
+	// be an IP address, so that dialing it requires no DNS lookup.
+	Addr string
+
+	// Config optionally specifies the TLS configuration to use.
+	// If Config.ServerName is empty, the host in Addr is used to
+	// verify the server's certificate.
+	Config *tls.Config
+
+	// Dialer optionally specifies the dialer used to connect to
+	// the server. If nil, the zero Dialer is used.
+	Dialer *net.Dialer
+
+	// MaxIdleConns is the maximum number of idle connections kept
+	// for reuse. If zero, 2 connections are kept. If negative,
+	// connections are not reused.
+	MaxIdleConns int
+
+	// IdleTimeout is how long an idle connection may be kept
+	// before it is closed. If zero, 30 seconds is used.
+	IdleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle []idleConn // most recently used last
+}
+
+type idleConn struct {
+	c     *tls.Conn
+	since time.Time
+}
+
+// String returns the address of u as a URL with the scheme "tls".
+func (u *TLSUpstream) String() string {
+	return "tls://" + u.addr()
+}
+
+func (u *TLSUpstream) addr() string {
+	if _, _, err := net.SplitHostPort(u.Addr); err != nil {
+		host := strings.TrimSuffix(strings.TrimPrefix(u.Addr, "["), "]")
+		return net.JoinHostPort(host, defaultTLSPort)
+	}
+	return u.Addr
+}
+
+func (u *TLSUpstream) maxIdleConns() int {
+	if u.MaxIdleConns == 0 {
+		return defaultMaxIdleConns
+	}
+	return u.MaxIdleConns
+}
+
+func (u *TLSUpstream) idleTimeout() time.Duration {
+	if u.IdleTimeout == 0 {
+		return defaultIdleTimeout
+	}
+	return u.IdleTimeout
+}
+
+// Exchange sends the query req to the server and returns its response.
+// It uses an idle connection if one is available. If the server has
+// closed it in the meantime, the query is retried on a new connection.
+func (u *TLSUpstream) Exchange(ctx context.Context, req []byte) ([]byte, error) {
+	if len(req) < headerLen || len(req) > maxMessageLen {
+		return nil, errInvalidQuery
+	}
+	msg := make([]byte, 2+len(req))
+	msg[0] = byte(len(req) >> 8)
+	msg[1] = byte(len(req))
+	copy(msg[2:], req)
+
+	for {
+		c, reused := u.getIdleConn()
+		if c == nil {
+			var err error
+			if c, err = u.dial(ctx); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := roundTrip(ctx, c, msg)
+		if err == nil {
+			u.putIdleConn(c)
+			return resp, nil
+		}
+		c.Close()
+		if !reused || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+// CloseIdleConnections closes any connections that are kept for
+// reuse. It does not interrupt connections currently in use.
+func (u *TLSUpstream) CloseIdleConnections() {
+	u.mu.Lock()
+	idle := u.idle
+	u.idle = nil
+	u.mu.Unlock()
+	for _, ic := range idle {
+		ic.c.Close()
+	}
+}
+
+// getIdleConn returns the most recently used idle connection,
+// or nil if there is none. Expired connections are closed.
+func (u *TLSUpstream) getIdleConn() (c *tls.Conn, reused bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	timeout := u.idleTimeout()
+	for len(u.idle) > 0 {
+		ic := u.idle[len(u.idle)-1]
+		u.idle[len(u.idle)-1] = idleConn{}
+		u.idle = u.idle[:len(u.idle)-1]
+		if time.Since(ic.since) < timeout {
+			return ic.c, true
+		}
+		ic.c.Close()
+	}
+	return nil, false
+}
+
+func (u *TLSUpstream) putIdleConn(c *tls.Conn) {
+	u.mu.Lock()
+	if len(u.idle) < u.maxIdleConns() {
+		u.idle = append(u.idle, idleConn{c, time.Now()})
+		c = nil
+	}
+	u.mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+// dial connects to the server and completes the TLS handshake.
+func (u *TLSUpstream) dial(ctx context.Context) (*tls.Conn, error) {
+	addr := u.addr()
+	d := u.Dialer
+	if d == nil {
+		d = new(net.Dialer)
+	}
+	raw, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var config *tls.Config
+	if u.Config != nil {
+		config = u.Config.Clone()
+	} else {
+		config = new(tls.Config)
+	}
+	if config.ServerName == "" {
+		host, _, _ := net.SplitHostPort(addr)
+		config.ServerName = host
+	}
+	c := tls.Client(raw, config)
+	if err := withContext(ctx, c, c.Handshake); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// roundTrip writes the length-prefixed query msg to c and reads the
+// response, as in RFC 1035 section 4.2.2.
+func roundTrip(ctx context.Context, c *tls.Conn, msg []byte) (resp []byte, err error) {
+	err = withContext(ctx, c, func() error {
+		if _, err := c.Write(msg); err != nil {
+			return err
+		}
+		var l [2]byte
+		if _, err := io.ReadFull(c, l[:]); err != nil {
+			return err
+		}
+		resp = make([]byte, int(l[0])<<8|int(l[1]))
+		_, err := io.ReadFull(c, resp)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < headerLen {
+		return nil, errInvalidResponse
+	}
+	if resp[0] != msg[2] || resp[1] != msg[3] {
+		return nil, errIDMismatch
+	}
+	return resp, nil
+}
@@ -8,6 +8,7 @@ package net
 
 import (
 	"internal/testenv"
+	"net/netip"
 	"reflect"
 	"runtime"
 	"testing"
@@ -444,3 +445,138 @@ func TestUDPReadSizeError(t *testing.T) {
 		}
 	}
 }
+
+func TestUDPAddrPortReadWrite(t *testing.T) {
+	networks := []struct {
+		network string
+		ip      IP
+	}{
+		{"udp4", IPv4(127, 0, 0, 1)},
+	}
+	if supportsIPv6() {
+		networks = append(networks, struct {
+			network string
+			ip      IP
+		}{"udp6", IPv6loopback})
+	}
+	for _, tt := range networks {
+		c1, err := ListenUDP(tt.network, &UDPAddr{IP: tt.ip})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c1.Close()
+		c2, err := ListenUDP(tt.network, &UDPAddr{IP: tt.ip})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c2.Close()
+		c1.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		addr1 := c1.LocalAddr().(*UDPAddr).AddrPort()
+		addr2 := c2.LocalAddr().(*UDPAddr).AddrPort()
+		if !addr1.IsValid() || addr1.Port() == 0 {
+			t.Fatalf("%s: AddrPort of %v = %v", tt.network, c1.LocalAddr(), addr1)
+		}
+
+		wb := []byte("ADDRPORT TEST")
+		if n, err := c2.WriteToUDPAddrPort(wb, addr1); err != nil || n != len(wb) {
+			t.Fatalf("%s: WriteToUDPAddrPort = %d, %v", tt.network, n, err)
+		}
+		rb := make([]byte, 128)
+		n, from, err := c1.ReadFromUDPAddrPort(rb)
+		if err != nil {
+			t.Fatalf("%s: ReadFromUDPAddrPort: %v", tt.network, err)
+		}
+		if string(rb[:n]) != string(wb) || from != addr2 {
+			t.Errorf("%s: ReadFromUDPAddrPort = %q from %v; want %q from %v", tt.network, rb[:n], from, wb, addr2)
+		}
+
+		if runtime.GOOS == "plan9" {
+			continue
+		}
+		if n, _, err := c2.WriteMsgUDPAddrPort(wb, nil, addr1); err != nil || n != len(wb) {
+			t.Fatalf("%s: WriteMsgUDPAddrPort = %d, %v", tt.network, n, err)
+		}
+		n, _, _, from, err = c1.ReadMsgUDPAddrPort(rb, nil)
+		if err != nil {
+			t.Fatalf("%s: ReadMsgUDPAddrPort: %v", tt.network, err)
+		}
+		if string(rb[:n]) != string(wb) || from != addr2 {
+			t.Errorf("%s: ReadMsgUDPAddrPort = %q from %v; want %q from %v", tt.network, rb[:n], from, wb, addr2)
+		}
+	}
+}
+
+func TestUDPAddrPortErrors(t *testing.T) {
+	c, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.WriteToUDPAddrPort([]byte("x"), netip.AddrPort{}); err == nil {
+		t.Error("WriteToUDPAddrPort with zero AddrPort succeeded")
+	}
+	ap := netip.AddrPortFrom(netip.MustParseAddr("::2"), 9)
+	_, err = c.WriteToUDPAddrPort([]byte("x"), ap)
+	if perr := parseWriteError(err); perr != nil {
+		t.Error(perr)
+	}
+	if err == nil {
+		t.Error("WriteToUDPAddrPort to IPv6 address on udp4 socket succeeded")
+	} else if oe, ok := err.(*OpError); !ok || oe.Addr.String() != "[::2]:9" {
+		t.Errorf("WriteToUDPAddrPort error = %v; want OpError with address [::2]:9", err)
+	}
+}
+
+func TestUDPAddrPortReadWriteAllocs(t *testing.T) {
+	switch runtime.GOOS {
+	case "plan9", "windows":
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+
+	conn, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	addr := conn.LocalAddr().(*UDPAddr).AddrPort()
+	buf := make([]byte, 8)
+	allocs := testing.AllocsPerRun(1000, func() {
+		if _, err := conn.WriteToUDPAddrPort(buf, addr); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := conn.ReadFromUDPAddrPort(buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("got %v; want 0", allocs)
+	}
+}
+
+func TestUDPAddrAddrPort(t *testing.T) {
+	tests := []struct {
+		addr *UDPAddr
+		ap   netip.AddrPort
+	}{
+		{nil, netip.AddrPort{}},
+		{&UDPAddr{IP: IPv4(1, 2, 3, 4), Port: 53}, netip.MustParseAddrPort("[::ffff:1.2.3.4]:53")},
+		{&UDPAddr{IP: IP{1, 2, 3, 4}, Port: 53}, netip.MustParseAddrPort("1.2.3.4:53")},
+		{&UDPAddr{IP: ParseIP("fe80::1"), Port: 80, Zone: "eth0"}, netip.MustParseAddrPort("[fe80::1%eth0]:80")},
+	}
+	for _, tt := range tests {
+		if got := tt.addr.AddrPort(); got != tt.ap {
+			t.Errorf("%v.AddrPort() = %v; want %v", tt.addr, got, tt.ap)
+		}
+		if tt.addr == nil {
+			continue
+		}
+		if got := UDPAddrFromAddrPort(tt.ap); !got.IP.Equal(tt.addr.IP) || got.Port != tt.addr.Port || got.Zone != tt.addr.Zone {
+			t.Errorf("UDPAddrFromAddrPort(%v) = %v; want %v", tt.ap, got, tt.addr)
+		}
+	}
+	if a := UDPAddrFromAddrPort(netip.AddrPort{}); a.IP != nil || a.Port != 0 {
+		t.Errorf("UDPAddrFromAddrPort of zero AddrPort = %#v", a)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"internal/testenv"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -522,6 +523,69 @@ func TestWalk(t *testing.T) {
 	}
 }
 
+func TestWalkDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "TestWalkDir")
+	if err != nil {
+		t.Fatal("creating temp dir:", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+
+	makeTree(t)
+	errors := make([]error, 0, 10)
+	markFn := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errors = append(errors, err)
+			return err
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() != info.IsDir() || d.Type() != info.Mode().Type() {
+			t.Errorf("%s: DirEntry type %v, want %v", path, d.Type(), info.Mode().Type())
+		}
+		return mark(info, nil, &errors, false)
+	}
+	// Expect no errors.
+	err = filepath.WalkDir(tree.name, markFn)
+	if err != nil {
+		t.Fatalf("no error expected, found: %s", err)
+	}
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors: %s", errors)
+	}
+	checkMarks(t, true)
+
+	// Skipping a directory skips its contents but not its siblings.
+	var visited []string
+	err = filepath.WalkDir(tree.name, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, filepath.ToSlash(path))
+		if d.IsDir() && d.Name() == "d" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir with SkipDir: %v", err)
+	}
+	want := []string{"testdata", "testdata/a", "testdata/b", "testdata/c", "testdata/d"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("WalkDir with SkipDir visited %q, want %q", visited, want)
+	}
+}
+
 func touch(t *testing.T, name string) {
 	f, err := os.Create(name)
 	if err != nil {
@@ -300,6 +300,63 @@ func Sendto(fd int, p []byte, flags int, to Sockaddr) (err error) {
 	return sendto(fd, p, flags, ptr, n)
 }
 
+// RecvfromInet4 is like Recvfrom but stores the source address of an
+// IPv4 packet in from instead of allocating a new Sockaddr.
+// If the source address is not an IPv4 address, from is left unchanged.
+func RecvfromInet4(fd int, p []byte, flags int, from *SockaddrInet4) (n int, err error) {
+	var rsa RawSockaddrAny
+	var len _Socklen = SizeofSockaddrAny
+	if n, err = recvfrom(fd, p, flags, &rsa, &len); err != nil {
+		return
+	}
+	if rsa.Addr.Family == AF_INET {
+		pp := (*RawSockaddrInet4)(unsafe.Pointer(&rsa))
+		port := (*[2]byte)(unsafe.Pointer(&pp.Port))
+		from.Port = int(port[0])<<8 + int(port[1])
+		from.Addr = pp.Addr
+	}
+	return
+}
+
+// RecvfromInet6 is like Recvfrom but stores the source address of an
+// IPv6 packet in from instead of allocating a new Sockaddr.
+// If the source address is not an IPv6 address, from is left unchanged.
+func RecvfromInet6(fd int, p []byte, flags int, from *SockaddrInet6) (n int, err error) {
+	var rsa RawSockaddrAny
+	var len _Socklen = SizeofSockaddrAny
+	if n, err = recvfrom(fd, p, flags, &rsa, &len); err != nil {
+		return
+	}
+	if rsa.Addr.Family == AF_INET6 {
+		pp := (*RawSockaddrInet6)(unsafe.Pointer(&rsa))
+		port := (*[2]byte)(unsafe.Pointer(&pp.Port))
+		from.Port = int(port[0])<<8 + int(port[1])
+		from.ZoneId = pp.Scope_id
+		from.Addr = pp.Addr
+	}
+	return
+}
+
+// SendtoInet4 is like Sendto but takes an IPv4 address directly,
+// which avoids converting it to a Sockaddr interface.
+func SendtoInet4(fd int, p []byte, flags int, to *SockaddrInet4) (err error) {
+	ptr, n, err := to.sockaddr()
+	if err != nil {
+		return err
+	}
+	return sendto(fd, p, flags, ptr, n)
+}
+
+// SendtoInet6 is like Sendto but takes an IPv6 address directly,
+// which avoids converting it to a Sockaddr interface.
+func SendtoInet6(fd int, p []byte, flags int, to *SockaddrInet6) (err error) {
+	ptr, n, err := to.sockaddr()
+	if err != nil {
+		return err
+	}
+	return sendto(fd, p, flags, ptr, n)
+}
+
 func SetsockoptByte(fd, level, opt int, value byte) (err error) {
 	return setsockopt(fd, level, opt, unsafe.Pointer(&value), 1)
 }
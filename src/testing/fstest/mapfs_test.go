@@ -0,0 +1,81 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fstest
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestMapFS(t *testing.T) {
+	m := MapFS{
+		"hello":             {Data: []byte("hello, world\n")},
+		"fortune/k/ken.txt": {Data: []byte("If a program is too slow, it must have a loop.\n")},
+	}
+	if err := TestFS(m, "hello", "fortune", "fortune/k", "fortune/k/ken.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapFSChmodDot(t *testing.T) {
+	m := MapFS{
+		"a/b.txt": &MapFile{Mode: 0666},
+		".":       &MapFile{Mode: 0777 | fs.ModeDir},
+	}
+	buf := new(strings.Builder)
+	fs.WalkDir(m, ".", func(path string, d fs.DirEntry, err error) error {
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s: %v\n", path, fi.Mode())
+		return nil
+	})
+	want := `
+.: drwxrwxrwx
+a: d---------
+a/b.txt: -rw-rw-rw-
+`[1:]
+	got := buf.String()
+	if want != got {
+		t.Errorf("MapFS modes want:\n%s\ngot:\n%s\n", want, got)
+	}
+}
+
+func TestTestFSReportsErrors(t *testing.T) {
+	m := MapFS{
+		"hello": {Data: []byte("hello, world\n")},
+	}
+	if err := TestFS(m, "missing"); err == nil {
+		t.Fatal("TestFS succeeded with a missing expected file")
+	} else if !strings.Contains(err.Error(), "expected but not found: missing") {
+		t.Fatalf("TestFS error = %v, want mention of missing file", err)
+	}
+	if err := TestFS(m); err == nil {
+		t.Fatal("TestFS succeeded on a non-empty file system with no expected files")
+	}
+}
+
+// brokenFS wraps a MapFS but returns truncated data from ReadFile,
+// which TestFS must detect.
+type brokenFS struct{ MapFS }
+
+func (b brokenFS) ReadFile(name string) ([]byte, error) {
+	data, err := b.MapFS.ReadFile(name)
+	if len(data) > 0 {
+		data = data[:len(data)-1]
+	}
+	return data, err
+}
+
+func TestTestFSDetectsBadReadFile(t *testing.T) {
+	m := brokenFS{MapFS{"hello": {Data: []byte("hello, world\n")}}}
+	err := TestFS(m, "hello")
+	if err == nil || !strings.Contains(err.Error(), "fsys.ReadFile: different data returned") {
+		t.Fatalf("TestFS = %v, want ReadFile mismatch", err)
+	}
+}
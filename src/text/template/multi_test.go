@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"fmt"
 	"testing"
+	"testing/fstest"
 	"text/template/parse"
 )
 
@@ -153,6 +154,38 @@ func TestParseGlob(t *testing.T) {
 	testExecute(multiExecTests, template, t)
 }
 
+var multiFS = fstest.MapFS{
+	"file1.tmpl":     {Data: []byte("{{define \"x\"}}TEXT{{end}}\n{{define \"dotV\"}}{{.V}}{{end}}\n")},
+	"file2.tmpl":     {Data: []byte("{{define \"dot\"}}{{.}}{{end}}\n{{define \"nested\"}}{{template \"dot\" .}}{{end}}\n")},
+	"dir/tmpl1.tmpl": {Data: []byte("template1\n{{define \"x\"}}x{{end}}\n{{template \"y\"}}\n")},
+	"dir/tmpl2.tmpl": {Data: []byte("template2\n{{define \"y\"}}y{{end}}\n{{template \"x\"}}\n")},
+}
+
+func TestParseFS(t *testing.T) {
+	_, err := ParseFS(multiFS, "DOES NOT EXIST")
+	if err == nil {
+		t.Error("expected error for non-existent file; got none")
+	}
+	_, err = New("error").ParseFS(multiFS, "[x")
+	if err == nil {
+		t.Error("expected error for bad pattern; got none")
+	}
+
+	template := New("root")
+	_, err = template.ParseFS(multiFS, "file1.tmpl", "file2.tmpl")
+	if err != nil {
+		t.Fatalf("error parsing files: %v", err)
+	}
+	testExecute(multiExecTests, template, t)
+
+	template = New("root")
+	_, err = template.ParseFS(multiFS, "file*.tmpl")
+	if err != nil {
+		t.Fatalf("error parsing files: %v", err)
+	}
+	testExecute(multiExecTests, template, t)
+}
+
 // In these tests, actual content (not just template definitions) comes from the parsed files.
 
 var templateFileExecTests = []execTest{
@@ -175,6 +208,14 @@ func TestParseGlobWithData(t *testing.T) {
 	testExecute(templateFileExecTests, template, t)
 }
 
+func TestParseFSWithData(t *testing.T) {
+	template, err := New("root").ParseFS(multiFS, "dir/tmpl*.tmpl")
+	if err != nil {
+		t.Fatalf("error parsing files: %v", err)
+	}
+	testExecute(templateFileExecTests, template, t)
+}
+
 const (
 	cloneText1 = `{{define "a"}}{{template "b"}}{{template "c"}}{{end}}`
 	cloneText2 = `{{define "b"}}b{{end}}`